@@ -0,0 +1,50 @@
+/*
+Package cache stores opaque values by key, for the caches of embeddings and completions.
+
+`MemoryCache` keeps the values in memory and evicts the least recently used ones past its limits.
+`FileCache` stores every value in a file of a directory, so the cache survives restarts of a
+single process. `SQLCache` stores them in a table through any `database/sql` driver, and
+`RedisCache` in Redis or a server that speaks its protocol, so they can be shared between processes.
+*/
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+type Cache interface {
+	// Returns the value of the key, and whether it was found and has not expired
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+
+	// Stores the value, replacing the value of the key. A ttl of 0 keeps the value until it is evicted or deleted
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+
+	// Deletes the keys. Keys that are not stored are ignored
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// Hashes the parts into a key, so keys have a fixed length whatever they are built from
+func Key(parts ...string) string {
+	h := sha256.New()
+	for _, item := range parts {
+		// prefix the length so the parts cannot run into each other
+		h.Write([]byte{byte(len(item) >> 24), byte(len(item) >> 16), byte(len(item) >> 8), byte(len(item))})
+		h.Write([]byte(item))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Returns when an entry with the ttl expires, or the zero time when it does not
+func expiresAt(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
+}
+
+func isExpired(expires time.Time) bool {
+	return !expires.IsZero() && !time.Now().Before(expires)
+}
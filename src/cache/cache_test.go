@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Runs the behaviour that every cache shares
+func testCache(t *testing.T, c Cache) {
+	ctx := context.TODO()
+
+	_, ok, err := c.Get(ctx, "missing")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	require.NoError(t, c.Set(ctx, "a", []byte("first"), 0))
+	require.NoError(t, c.Set(ctx, "a", []byte("second"), 0))
+	value, ok, err := c.Get(ctx, "a")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "second", string(value))
+
+	require.NoError(t, c.Set(ctx, "expired", []byte("value"), time.Nanosecond))
+	time.Sleep(time.Millisecond)
+	_, ok, err = c.Get(ctx, "expired")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	require.NoError(t, c.Delete(ctx, "a", "missing"))
+	_, ok, err = c.Get(ctx, "a")
+	require.NoError(t, err)
+	require.False(t, ok)
+}
+
+func TestKey(t *testing.T) {
+	require.Len(t, Key("a", "b"), 64)
+	require.Equal(t, Key("a", "b"), Key("a", "b"))
+	require.NotEqual(t, Key("ab", "c"), Key("a", "bc"))
+}
+
+func TestMemoryCache(t *testing.T) {
+	testCache(t, NewMemoryCache(nil))
+
+	// the least recently used entries are evicted first
+	ctx := context.TODO()
+	c := NewMemoryCache(&MemoryOpts{MaxEntries: 2})
+	require.NoError(t, c.Set(ctx, "a", []byte("a"), 0))
+	require.NoError(t, c.Set(ctx, "b", []byte("b"), 0))
+	_, ok, _ := c.Get(ctx, "a")
+	require.True(t, ok)
+	require.NoError(t, c.Set(ctx, "c", []byte("c"), 0))
+	require.Equal(t, 2, c.Len())
+	_, ok, _ = c.Get(ctx, "b")
+	require.False(t, ok)
+
+	// values are limited by their total size, and values over the limit are not stored
+	c = NewMemoryCache(&MemoryOpts{MaxBytes: 10})
+	require.NoError(t, c.Set(ctx, "a", []byte("12345"), 0))
+	require.NoError(t, c.Set(ctx, "b", []byte("123456"), 0))
+	_, ok, _ = c.Get(ctx, "a")
+	require.False(t, ok)
+	require.NoError(t, c.Set(ctx, "c", []byte("12345678901"), 0))
+	require.Equal(t, 1, c.Len())
+
+	// the values are copied in and out
+	value := []byte("value")
+	require.NoError(t, c.Set(ctx, "d", value, 0))
+	value[0] = 'X'
+	read, _, _ := c.Get(ctx, "d")
+	read[1] = 'X'
+	read, _, _ = c.Get(ctx, "d")
+	require.Equal(t, "value", string(read))
+}
+
+func TestFileCache(t *testing.T) {
+	dir := t.TempDir()
+	c, err := NewFileCache(dir)
+	require.NoError(t, err)
+	testCache(t, c)
+
+	// the values survive a new cache over the same directory
+	ctx := context.TODO()
+	require.NoError(t, c.Set(ctx, "kept", []byte("value"), 0))
+	require.NoError(t, c.Set(ctx, "stale", []byte("value"), time.Nanosecond))
+	time.Sleep(time.Millisecond)
+	c, err = NewFileCache(dir)
+	require.NoError(t, err)
+	value, ok, err := c.Get(ctx, "kept")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "value", string(value))
+
+	deleted, err := c.Prune(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, deleted)
+
+	// the files are named by the hash of the key
+	files, err := filepath.Glob(filepath.Join(dir, "*", "*"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	_, err = os.Stat(c.path("kept"))
+	require.NoError(t, err)
+}
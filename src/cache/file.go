@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// The expiry of an entry is stored before its value as unix nanoseconds, or 0 when it does not expire
+const file_header_size = 8
+
+/*
+Stores every value in a file of a directory, named by the hash of its key. Files are replaced
+atomically, so concurrent processes never read partial values. Expired files are deleted when
+they are read, or by `Prune`.
+*/
+type FileCache struct {
+	dir string
+}
+
+// Creates the cache in the directory, creating the directory if it does not exist
+func NewFileCache(dir string) (*FileCache, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("the directory cannot be empty")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("there was an issue creating the cache directory: %v", err)
+	}
+	return &FileCache{dir: dir}, nil
+}
+
+// Spreads the files over subdirectories by the first byte of the hash, so no directory grows too large
+func (c *FileCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	name := hex.EncodeToString(sum[:])
+	return filepath.Join(c.dir, name[:2], name)
+}
+
+func (c *FileCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	path := c.path(key)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("there was an issue reading the cache file: %v", err)
+	}
+	value, expires, ok := decodeFileEntry(data)
+	if !ok || isExpired(expires) {
+		os.Remove(path)
+		return nil, false, nil
+	}
+	return value, true, nil
+}
+
+func (c *FileCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("there was an issue creating the cache directory: %v", err)
+	}
+
+	data := make([]byte, file_header_size+len(value))
+	if expires := expiresAt(ttl); !expires.IsZero() {
+		binary.BigEndian.PutUint64(data, uint64(expires.UnixNano()))
+	}
+	copy(data[file_header_size:], value)
+
+	// write to a temporary file and rename it over the entry, which is atomic
+	file, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("there was an issue creating the cache file: %v", err)
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return fmt.Errorf("there was an issue writing the cache file: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("there was an issue writing the cache file: %v", err)
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		return fmt.Errorf("there was an issue writing the cache file: %v", err)
+	}
+	return nil
+}
+
+func (c *FileCache) Delete(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		if err := os.Remove(c.path(key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("there was an issue deleting the cache file: %v", err)
+		}
+	}
+	return nil
+}
+
+// Deletes the expired files, returning how many were deleted
+func (c *FileCache) Prune(ctx context.Context) (int, error) {
+	deleted := 0
+	err := filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".tmp-") {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil // removed by another process
+		}
+		if _, expires, ok := decodeFileEntry(data); !ok || isExpired(expires) {
+			if os.Remove(path) == nil {
+				deleted++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return deleted, fmt.Errorf("there was an issue pruning the cache: %v", err)
+	}
+	return deleted, nil
+}
+
+func decodeFileEntry(data []byte) ([]byte, time.Time, bool) {
+	if len(data) < file_header_size {
+		return nil, time.Time{}, false
+	}
+	var expires time.Time
+	if nanos := binary.BigEndian.Uint64(data); nanos != 0 {
+		expires = time.Unix(0, int64(nanos))
+	}
+	return data[file_header_size:], expires, true
+}
@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// Keeps the values in memory, evicting the least recently used ones past the limits. Safe for concurrent use
+type MemoryCache struct {
+	opts *MemoryOpts
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // most recently used first
+	size    int
+}
+
+type memoryEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// Optional limits of the cache. Both are unlimited when 0
+type MemoryOpts struct {
+	// The most entries kept
+	MaxEntries int
+
+	// The most bytes of values kept
+	MaxBytes int
+}
+
+func NewMemoryCache(opts *MemoryOpts) *MemoryCache {
+	resp := MemoryOpts{}
+	if opts != nil {
+		resp = *opts
+	}
+	return &MemoryCache{opts: &resp, entries: make(map[string]*list.Element), order: list.New()}
+}
+
+func (c *MemoryCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	entry := element.Value.(*memoryEntry)
+	if isExpired(entry.expires) {
+		c.remove(element)
+		return nil, false, nil
+	}
+	c.order.MoveToFront(element)
+
+	// copy the value so changes by the caller do not leak into the cache
+	return append([]byte(nil), entry.value...), true, nil
+}
+
+func (c *MemoryCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	// values larger than the whole cache would evict everything and then themselves
+	if c.opts.MaxBytes > 0 && len(value) > c.opts.MaxBytes {
+		return c.Delete(ctx, key)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+	entry := &memoryEntry{key: key, value: append([]byte(nil), value...), expires: expiresAt(ttl)}
+	c.entries[key] = c.order.PushFront(entry)
+	c.size += len(entry.value)
+
+	for (c.opts.MaxEntries > 0 && c.order.Len() > c.opts.MaxEntries) || (c.opts.MaxBytes > 0 && c.size > c.opts.MaxBytes) {
+		c.remove(c.order.Back())
+	}
+	return nil
+}
+
+func (c *MemoryCache) Delete(ctx context.Context, keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		if element, ok := c.entries[key]; ok {
+			c.remove(element)
+		}
+	}
+	return nil
+}
+
+// Returns the number of entries, including expired entries that were not read since they expired
+func (c *MemoryCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// Removes an entry. The lock must be held
+func (c *MemoryCache) remove(element *list.Element) {
+	entry := element.Value.(*memoryEntry)
+	c.order.Remove(element)
+	delete(c.entries, entry.key)
+	c.size -= len(entry.value)
+}
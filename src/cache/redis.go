@@ -0,0 +1,380 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+const (
+	redis_default_addr      = "localhost:6379"
+	redis_default_idle      = 4
+	redis_default_dial_time = 5 * time.Second
+	redis_default_io_time   = 5 * time.Second
+)
+
+/*
+Stores the values in Redis or a server that speaks its protocol, such as Valkey, KeyDB, Dragonfly
+or Upstash, so the cache can be shared between processes. Expiry is left to the server.
+`NewRedisCache` speaks the RESP protocol directly over a small pool of connections, so no client
+library is needed, and `NewRedisCacheFromClient` uses any client through a `RedisClient` adapter.
+*/
+type RedisCache struct {
+	client RedisClient
+}
+
+/*
+The commands the cache sends to Redis. Adapt a client library to use its pooling, cluster or
+sentinel support, such as go-redis:
+
+	type goRedisClient struct{ client *redis.Client }
+
+	func (c goRedisClient) Get(ctx context.Context, key string) ([]byte, bool, error) {
+		value, err := c.client.Get(ctx, key).Bytes()
+		if errors.Is(err, redis.Nil) {
+			return nil, false, nil
+		}
+		return value, err == nil, err
+	}
+
+	func (c goRedisClient) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+		return c.client.Set(ctx, key, value, ttl).Err()
+	}
+
+	func (c goRedisClient) Del(ctx context.Context, keys ...string) error {
+		return c.client.Del(ctx, keys...).Err()
+	}
+*/
+type RedisClient interface {
+	// Returns the value of the key, and whether it was found
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+
+	// Stores the value. A ttl of 0 keeps the value until it is deleted
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+
+	// Deletes the keys. Keys that are not stored are ignored
+	Del(ctx context.Context, keys ...string) error
+}
+
+type RedisOpts struct {
+	// Defaults to `localhost:6379`
+	Addr string
+
+	// Optionally authenticate with `AUTH`. The username is only sent when set, for servers with ACLs
+	Username string
+	Password string
+
+	// The database selected on every connection. Defaults to 0
+	DB int
+
+	// Optionally connect with TLS, as managed servers usually require
+	TLSConfig *tls.Config
+
+	// Defaults to 5 seconds
+	DialTimeout time.Duration
+
+	// The most time a command waits to be sent and answered, when the context has no earlier
+	// deadline. Defaults to 5 seconds
+	IOTimeout time.Duration
+
+	// The most connections kept open between requests. Defaults to 4
+	MaxIdleConns int
+}
+
+// Creates a cache that connects to the server with the built in client
+func NewRedisCache(opts *RedisOpts) *RedisCache {
+	resp := RedisOpts{}
+	if opts != nil {
+		resp = *opts
+	}
+	if resp.Addr == "" {
+		resp.Addr = redis_default_addr
+	}
+	if resp.DialTimeout == 0 {
+		resp.DialTimeout = redis_default_dial_time
+	}
+	if resp.IOTimeout == 0 {
+		resp.IOTimeout = redis_default_io_time
+	}
+	if resp.MaxIdleConns == 0 {
+		resp.MaxIdleConns = redis_default_idle
+	}
+	return &RedisCache{client: &redisPool{opts: &resp, idle: make(chan *redisConn, resp.MaxIdleConns)}}
+}
+
+// Creates a cache that sends its commands through the client, which stays owned by the caller
+func NewRedisCacheFromClient(client RedisClient) (*RedisCache, error) {
+	if client == nil {
+		return nil, fmt.Errorf("the client cannot be nil")
+	}
+	return &RedisCache{client: client}, nil
+}
+
+func (c *RedisCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, ok, err := c.client.Get(ctx, key)
+	if err != nil {
+		return nil, false, fmt.Errorf("there was an issue reading the key: %v", err)
+	}
+	return value, ok, nil
+}
+
+func (c *RedisCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := c.client.Set(ctx, key, value, max(ttl, 0)); err != nil {
+		return fmt.Errorf("there was an issue storing the key: %v", err)
+	}
+	return nil
+}
+
+func (c *RedisCache) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	if err := c.client.Del(ctx, keys...); err != nil {
+		return fmt.Errorf("there was an issue deleting the keys: %v", err)
+	}
+	return nil
+}
+
+// Closes the idle connections of the built in client. Connections in use are closed when they are
+// returned, and clients passed to `NewRedisCacheFromClient` are left to the caller
+func (c *RedisCache) Close() error {
+	if pool, ok := c.client.(*redisPool); ok {
+		pool.Close()
+	}
+	return nil
+}
+
+// An error reply from the server, such as `WRONGTYPE` or `NOAUTH`
+type RedisError struct {
+	Message string
+}
+
+func (e *RedisError) Error() string {
+	return e.Message
+}
+
+// The built in client, which speaks RESP over a pool of connections
+type redisPool struct {
+	opts *RedisOpts
+	idle chan *redisConn
+}
+
+func (c *redisPool) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	reply, err := c.do(ctx, "GET", []byte(key))
+	if err != nil {
+		return nil, false, err
+	}
+	if reply == nil {
+		return nil, false, nil
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, false, fmt.Errorf("unexpected reply to GET: %v", reply)
+	}
+	return value, true, nil
+}
+
+func (c *redisPool) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	args := [][]byte{[]byte(key), value}
+	if ttl > 0 {
+		args = append(args, []byte("PX"), []byte(strconv.FormatInt(max(ttl.Milliseconds(), 1), 10)))
+	}
+	_, err := c.do(ctx, "SET", args...)
+	return err
+}
+
+func (c *redisPool) Del(ctx context.Context, keys ...string) error {
+	args := make([][]byte, len(keys))
+	for idx, item := range keys {
+		args[idx] = []byte(item)
+	}
+	_, err := c.do(ctx, "DEL", args...)
+	return err
+}
+
+// Closes the idle connections
+func (c *redisPool) Close() {
+	for {
+		select {
+		case conn := <-c.idle:
+			conn.Close()
+		default:
+			return
+		}
+	}
+}
+
+// Sends the command on a pooled connection and returns its reply
+func (c *redisPool) do(ctx context.Context, command string, args ...[]byte) (any, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	conn, err := c.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := conn.do(ctx, c.opts.IOTimeout, command, args...)
+
+	// error replies leave the connection usable, other errors leave it in an unknown state
+	var redisErr *RedisError
+	if err != nil && !errors.As(err, &redisErr) {
+		conn.Close()
+		return nil, err
+	}
+	select {
+	case c.idle <- conn:
+	default:
+		conn.Close()
+	}
+	return reply, err
+}
+
+func (c *redisPool) conn(ctx context.Context) (*redisConn, error) {
+	select {
+	case conn := <-c.idle:
+		return conn, nil
+	default:
+	}
+
+	dialer := &net.Dialer{Timeout: c.opts.DialTimeout}
+	var raw net.Conn
+	var err error
+	if c.opts.TLSConfig != nil {
+		raw, err = (&tls.Dialer{NetDialer: dialer, Config: c.opts.TLSConfig}).DialContext(ctx, "tcp", c.opts.Addr)
+	} else {
+		raw, err = dialer.DialContext(ctx, "tcp", c.opts.Addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %v", c.opts.Addr, err)
+	}
+
+	conn := &redisConn{conn: raw, reader: bufio.NewReader(raw)}
+	if c.opts.Password != "" {
+		args := [][]byte{[]byte(c.opts.Password)}
+		if c.opts.Username != "" {
+			args = [][]byte{[]byte(c.opts.Username), []byte(c.opts.Password)}
+		}
+		if _, err := conn.do(ctx, c.opts.IOTimeout, "AUTH", args...); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to authenticate: %v", err)
+		}
+	}
+	if c.opts.DB != 0 {
+		if _, err := conn.do(ctx, c.opts.IOTimeout, "SELECT", []byte(strconv.Itoa(c.opts.DB))); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to select database %d: %v", c.opts.DB, err)
+		}
+	}
+	return conn, nil
+}
+
+type redisConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func (c *redisConn) Close() error {
+	return c.conn.Close()
+}
+
+/*
+Sends the command and reads its reply, waiting at most the timeout or until the deadline of the
+context. Cancelling the context interrupts the command, which leaves the connection unusable.
+*/
+func (c *redisConn) do(ctx context.Context, timeout time.Duration, command string, args ...[]byte) (reply any, err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	// a deadline in the past unblocks the reads and writes once the context is cancelled
+	stop := context.AfterFunc(ctx, func() {
+		c.conn.SetDeadline(time.Unix(1, 0))
+	})
+	defer func() {
+		if !stop() {
+			reply, err = nil, ctx.Err()
+		}
+	}()
+
+	// commands are sent as arrays of bulk strings
+	buf := make([]byte, 0, 64)
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)+1), 10)
+	buf = append(buf, '\r', '\n')
+	for _, item := range append([][]byte{[]byte(command)}, args...) {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(item)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, item...)
+		buf = append(buf, '\r', '\n')
+	}
+	if _, err := c.conn.Write(buf); err != nil {
+		return nil, err
+	}
+	return c.read()
+}
+
+// Reads a reply. Simple strings are returned as strings, bulk strings as bytes, integers as
+// int64 and arrays as []any. Null replies are nil, and error replies are a `RedisError`
+func (c *redisConn) read() (any, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("invalid reply: %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, &RedisError{Message: body}
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		size, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bulk string length: %q", body)
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		value := make([]byte, size+2)
+		if _, err := io.ReadFull(c.reader, value); err != nil {
+			return nil, err
+		}
+		return value[:size], nil
+	case '*':
+		size, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid array length: %q", body)
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		items := make([]any, size)
+		for idx := range items {
+			if items[idx], err = c.read(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("unsupported reply type: %q", kind)
+	}
+}
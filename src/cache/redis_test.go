@@ -0,0 +1,229 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeRedisEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+// Serves the commands the cache sends over RESP, so the client can be tested without a server
+type fakeRedis struct {
+	password string
+
+	mu       sync.Mutex
+	entries  map[string]*fakeRedisEntry
+	commands []string
+}
+
+func newFakeRedis(t *testing.T, password string) (*fakeRedis, string) {
+	server := &fakeRedis{password: password, entries: make(map[string]*fakeRedisEntry)}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server, listener.Addr().String()
+}
+
+func (s *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	authenticated := s.password == ""
+	for {
+		args, err := readFakeCommand(reader)
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.commands = append(s.commands, args[0])
+		reply := "+OK\r\n"
+		switch {
+		case args[0] == "AUTH":
+			if args[len(args)-1] != s.password {
+				reply = "-WRONGPASS invalid username-password pair\r\n"
+			} else {
+				authenticated = true
+			}
+		case !authenticated:
+			reply = "-NOAUTH Authentication required.\r\n"
+		case args[0] == "SELECT":
+		case args[0] == "GET":
+			entry, ok := s.entries[args[1]]
+			if !ok || (!entry.expires.IsZero() && !time.Now().Before(entry.expires)) {
+				reply = "$-1\r\n"
+			} else {
+				reply = fmt.Sprintf("$%d\r\n%s\r\n", len(entry.value), entry.value)
+			}
+		case args[0] == "SET":
+			entry := &fakeRedisEntry{value: []byte(args[2])}
+			if len(args) == 5 && args[3] == "PX" {
+				ms, _ := strconv.Atoi(args[4])
+				entry.expires = time.Now().Add(time.Duration(ms) * time.Millisecond)
+			}
+			s.entries[args[1]] = entry
+		case args[0] == "DEL":
+			deleted := 0
+			for _, key := range args[1:] {
+				if _, ok := s.entries[key]; ok {
+					delete(s.entries, key)
+					deleted++
+				}
+			}
+			reply = fmt.Sprintf(":%d\r\n", deleted)
+		default:
+			reply = "-ERR unknown command\r\n"
+		}
+		s.mu.Unlock()
+		if _, err := conn.Write([]byte(reply)); err != nil {
+			return
+		}
+	}
+}
+
+func readFakeCommand(reader *bufio.Reader) ([]string, error) {
+	var count int
+	if _, err := fmt.Fscanf(reader, "*%d\r\n", &count); err != nil {
+		return nil, err
+	}
+	args := make([]string, count)
+	for idx := range args {
+		var size int
+		if _, err := fmt.Fscanf(reader, "$%d\r\n", &size); err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			return nil, err
+		}
+		args[idx] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func TestRedisCache(t *testing.T) {
+	server, addr := newFakeRedis(t, "secret")
+	c := NewRedisCache(&RedisOpts{Addr: addr, Password: "secret", DB: 2})
+	t.Cleanup(func() { c.Close() })
+	testCache(t, c)
+
+	// connections are authenticated and reused
+	server.mu.Lock()
+	require.Equal(t, []string{"AUTH", "SELECT", "GET"}, server.commands[:3])
+	require.NotContains(t, server.commands[3:], "AUTH")
+	server.mu.Unlock()
+
+	// binary values survive the round trip
+	ctx := context.TODO()
+	require.NoError(t, c.Set(ctx, "binary", []byte{0, '\r', '\n', 255}, 0))
+	value, ok, err := c.Get(ctx, "binary")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, []byte{0, '\r', '\n', 255}, value)
+
+	// the errors of the server are returned
+	c = NewRedisCache(&RedisOpts{Addr: addr, Password: "wrong"})
+	_, _, err = c.Get(ctx, "a")
+	require.ErrorContains(t, err, "WRONGPASS")
+	c = NewRedisCache(&RedisOpts{Addr: addr})
+	_, _, err = c.Get(ctx, "a")
+	require.ErrorContains(t, err, "NOAUTH")
+}
+
+// Stores the values in a map, like an adapter of a client library
+type mapRedisClient struct {
+	entries map[string]*fakeRedisEntry
+	ttls    map[string]time.Duration
+}
+
+func (c *mapRedisClient) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	entry, ok := c.entries[key]
+	if !ok || isExpired(entry.expires) {
+		return nil, false, nil
+	}
+	return entry.value, true, nil
+}
+
+func (c *mapRedisClient) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c.entries[key] = &fakeRedisEntry{value: value, expires: expiresAt(ttl)}
+	c.ttls[key] = ttl
+	return nil
+}
+
+func (c *mapRedisClient) Del(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		delete(c.entries, key)
+	}
+	return nil
+}
+
+func TestRedisCacheFromClient(t *testing.T) {
+	client := &mapRedisClient{entries: make(map[string]*fakeRedisEntry), ttls: make(map[string]time.Duration)}
+	c, err := NewRedisCacheFromClient(client)
+	require.NoError(t, err)
+	testCache(t, c)
+	require.NoError(t, c.Set(context.TODO(), "ttl", []byte("value"), time.Minute))
+	require.Equal(t, time.Minute, client.ttls["ttl"])
+
+	_, err = NewRedisCacheFromClient(nil)
+	require.ErrorContains(t, err, "cannot be nil")
+}
+
+func TestRedisCacheTimeouts(t *testing.T) {
+	// the server reads the commands but never replies
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(io.Discard, conn)
+			}()
+		}
+	}()
+
+	// commands time out without a deadline on the context
+	c := NewRedisCache(&RedisOpts{Addr: listener.Addr().String(), IOTimeout: 50 * time.Millisecond})
+	t.Cleanup(func() { c.Close() })
+	start := time.Now()
+	_, _, err = c.Get(context.Background(), "a")
+	require.ErrorContains(t, err, "i/o timeout")
+	require.Less(t, time.Since(start), time.Second)
+
+	// cancelling the context interrupts the command
+	c = NewRedisCache(&RedisOpts{Addr: listener.Addr().String(), IOTimeout: time.Minute})
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start = time.Now()
+	_, _, err = c.Get(ctx, "a")
+	require.ErrorContains(t, err, context.Canceled.Error())
+	require.Less(t, time.Since(start), time.Second)
+
+	// cancelled contexts fail before connecting
+	_, _, err = c.Get(ctx, "a")
+	require.ErrorContains(t, err, context.Canceled.Error())
+}
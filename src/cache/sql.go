@@ -0,0 +1,153 @@
+package cache
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// The sql dialect of the database, which decides the placeholders and column types
+type Dialect string
+
+const (
+	DialectPostgres Dialect = "postgres"
+	DialectSQLite   Dialect = "sqlite"
+)
+
+const sql_default_table = "gollm_cache"
+
+// Table names can be qualified by a schema, such as `cache.entries`
+var sqlTableName = regexp.MustCompile(`^([a-zA-Z_][a-zA-Z0-9_]*\.)?[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+/*
+Stores the values in a table through any `database/sql` driver, so the cache can be shared between
+processes. Expired rows are ignored when read, and deleted by `Prune`.
+*/
+type SQLCache struct {
+	db   *sql.DB
+	opts *SQLOpts
+}
+
+type SQLOpts struct {
+	// Defaults to `DialectPostgres`
+	Dialect Dialect
+
+	// Defaults to `gollm_cache`
+	Table string
+}
+
+func NewSQLCache(db *sql.DB, opts *SQLOpts) (*SQLCache, error) {
+	if db == nil {
+		return nil, fmt.Errorf("the database cannot be nil")
+	}
+	resp := SQLOpts{}
+	if opts != nil {
+		resp = *opts
+	}
+	if resp.Dialect == "" {
+		resp.Dialect = DialectPostgres
+	}
+	if resp.Dialect != DialectPostgres && resp.Dialect != DialectSQLite {
+		return nil, fmt.Errorf("unsupported dialect: %s", resp.Dialect)
+	}
+	if resp.Table == "" {
+		resp.Table = sql_default_table
+	}
+	if !sqlTableName.MatchString(resp.Table) {
+		return nil, fmt.Errorf("invalid table name: %s", resp.Table)
+	}
+	return &SQLCache{db: db, opts: &resp}, nil
+}
+
+// Returns the placeholder of the nth argument, counting from 1
+func (c *SQLCache) placeholder(n int) string {
+	if c.opts.Dialect == DialectSQLite {
+		return "?"
+	}
+	return fmt.Sprintf("$%d", n)
+}
+
+// Creates the table and the index on the expiry if they do not exist
+func (c *SQLCache) Migrate(ctx context.Context) error {
+	blob := "BYTEA"
+	if c.opts.Dialect == DialectSQLite {
+		blob = "BLOB"
+	}
+	statements := []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	key TEXT PRIMARY KEY,
+	value %s NOT NULL,
+	expires_at BIGINT NOT NULL DEFAULT 0
+)`, c.opts.Table, blob),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_expires_at_idx ON %s (expires_at)", strings.ReplaceAll(c.opts.Table, ".", "_"), c.opts.Table),
+	}
+	for _, statement := range statements {
+		if _, err := c.db.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("there was an issue migrating the cache: %v", err)
+		}
+	}
+	return nil
+}
+
+func (c *SQLCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	statement := fmt.Sprintf("SELECT value, expires_at FROM %s WHERE key = %s", c.opts.Table, c.placeholder(1))
+	var value []byte
+	var expires int64
+	err := c.db.QueryRowContext(ctx, statement, key).Scan(&value, &expires)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("there was an issue reading the cache: %v", err)
+	}
+	if expires != 0 && isExpired(time.Unix(0, expires)) {
+		return nil, false, nil
+	}
+	return value, true, nil
+}
+
+func (c *SQLCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	var expires int64
+	if at := expiresAt(ttl); !at.IsZero() {
+		expires = at.UnixNano()
+	}
+	statement := fmt.Sprintf(`INSERT INTO %s (key, value, expires_at) VALUES (%s, %s, %s)
+ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, expires_at = EXCLUDED.expires_at`,
+		c.opts.Table, c.placeholder(1), c.placeholder(2), c.placeholder(3))
+	if _, err := c.db.ExecContext(ctx, statement, key, value, expires); err != nil {
+		return fmt.Errorf("there was an issue writing the cache: %v", err)
+	}
+	return nil
+}
+
+func (c *SQLCache) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	placeholders := make([]string, len(keys))
+	args := make([]any, len(keys))
+	for idx, key := range keys {
+		placeholders[idx] = c.placeholder(idx + 1)
+		args[idx] = key
+	}
+	statement := fmt.Sprintf("DELETE FROM %s WHERE key IN (%s)", c.opts.Table, strings.Join(placeholders, ", "))
+	if _, err := c.db.ExecContext(ctx, statement, args...); err != nil {
+		return fmt.Errorf("there was an issue deleting from the cache: %v", err)
+	}
+	return nil
+}
+
+// Deletes the expired rows, returning how many were deleted
+func (c *SQLCache) Prune(ctx context.Context) (int, error) {
+	statement := fmt.Sprintf("DELETE FROM %s WHERE expires_at != 0 AND expires_at <= %s", c.opts.Table, c.placeholder(1))
+	result, err := c.db.ExecContext(ctx, statement, time.Now().UnixNano())
+	if err != nil {
+		return 0, fmt.Errorf("there was an issue pruning the cache: %v", err)
+	}
+	deleted, _ := result.RowsAffected()
+	return int(deleted), nil
+}
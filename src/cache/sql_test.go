@@ -0,0 +1,146 @@
+package cache
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// A database/sql driver that records the statements it is sent and returns scripted rows,
+// so the sql of the cache can be tested without a running database
+type fakeConnector struct {
+	statements []string
+	args       [][]driver.NamedValue
+	rows       [][]driver.Value
+}
+
+func (c *fakeConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return &fakeConn{connector: c}, nil
+}
+
+func (c *fakeConnector) Driver() driver.Driver {
+	return nil
+}
+
+type fakeConn struct {
+	connector *fakeConnector
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, fmt.Errorf("prepare is not supported")
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return nil, fmt.Errorf("transactions are not supported")
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.connector.statements = append(c.connector.statements, query)
+	c.connector.args = append(c.connector.args, args)
+	return driver.RowsAffected(3), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.connector.statements = append(c.connector.statements, query)
+	c.connector.args = append(c.connector.args, args)
+	rows := c.connector.rows
+	c.connector.rows = nil
+	return &fakeRows{rows: rows}, nil
+}
+
+type fakeRows struct {
+	rows [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	return []string{"value", "expires_at"}
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func newFakeSQLCache(t *testing.T, opts *SQLOpts) (*SQLCache, *fakeConnector) {
+	connector := &fakeConnector{}
+	db := sql.OpenDB(connector)
+	t.Cleanup(func() { db.Close() })
+	c, err := NewSQLCache(db, opts)
+	require.NoError(t, err)
+	return c, connector
+}
+
+func TestSQLCacheMigrate(t *testing.T) {
+	c, connector := newFakeSQLCache(t, &SQLOpts{Table: "cache.entries"})
+	require.NoError(t, c.Migrate(context.TODO()))
+	require.Contains(t, connector.statements[0], "CREATE TABLE IF NOT EXISTS cache.entries")
+	require.Contains(t, connector.statements[0], "value BYTEA NOT NULL")
+	require.Equal(t, "CREATE INDEX IF NOT EXISTS cache_entries_expires_at_idx ON cache.entries (expires_at)", connector.statements[1])
+
+	c, connector = newFakeSQLCache(t, &SQLOpts{Dialect: DialectSQLite})
+	require.NoError(t, c.Migrate(context.TODO()))
+	require.Contains(t, connector.statements[0], "value BLOB NOT NULL")
+
+	_, err := NewSQLCache(sql.OpenDB(&fakeConnector{}), &SQLOpts{Table: "cache; DROP TABLE users"})
+	require.ErrorContains(t, err, "invalid table name")
+	_, err = NewSQLCache(sql.OpenDB(&fakeConnector{}), &SQLOpts{Dialect: "oracle"})
+	require.ErrorContains(t, err, "unsupported dialect")
+}
+
+func TestSQLCache(t *testing.T) {
+	ctx := context.TODO()
+	c, connector := newFakeSQLCache(t, nil)
+
+	require.NoError(t, c.Set(ctx, "a", []byte("value"), time.Hour))
+	require.Contains(t, connector.statements[0], "INSERT INTO gollm_cache (key, value, expires_at) VALUES ($1, $2, $3)")
+	require.Contains(t, connector.statements[0], "ON CONFLICT (key) DO UPDATE")
+	require.Equal(t, []byte("value"), connector.args[0][1].Value)
+	require.Greater(t, connector.args[0][2].Value.(int64), time.Now().UnixNano())
+
+	connector.rows = [][]driver.Value{{[]byte("value"), int64(0)}}
+	value, ok, err := c.Get(ctx, "a")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "value", string(value))
+	require.Equal(t, "SELECT value, expires_at FROM gollm_cache WHERE key = $1", connector.statements[1])
+
+	// expired and missing rows are misses
+	connector.rows = [][]driver.Value{{[]byte("value"), time.Now().Add(-time.Second).UnixNano()}}
+	_, ok, err = c.Get(ctx, "a")
+	require.NoError(t, err)
+	require.False(t, ok)
+	_, ok, err = c.Get(ctx, "a")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	require.NoError(t, c.Delete(ctx, "a", "b"))
+	require.Equal(t, "DELETE FROM gollm_cache WHERE key IN ($1, $2)", connector.statements[4])
+
+	deleted, err := c.Prune(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 3, deleted)
+	require.Equal(t, "DELETE FROM gollm_cache WHERE expires_at != 0 AND expires_at <= $1", connector.statements[5])
+
+	// sqlite uses question marks
+	c, connector = newFakeSQLCache(t, &SQLOpts{Dialect: DialectSQLite})
+	require.NoError(t, c.Delete(ctx, "a", "b"))
+	require.Equal(t, "DELETE FROM gollm_cache WHERE key IN (?, ?)", connector.statements[0])
+}
@@ -0,0 +1,194 @@
+/*
+Package cassette records the http interactions of tests into json files and replays them, so tests
+of code that calls external apis run offline.
+
+A cassette does not have to come from a recording. The cassettes of the gollm provider tests are
+hand-written fixtures in the same format, and their request bodies are edited along with the code
+that builds them, so replaying them does not show that the requests match the live apis. They
+should be replaced by recordings made with `ModeRecord`.
+*/
+package cassette
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Mode controls whether the recorder talks to the real apis or replays a cassette
+type Mode int
+
+const (
+	// Serve all responses from the cassette file, and fail on requests that were not recorded
+	ModeReplay Mode = iota
+	// Send all requests to the real apis and save the interactions to the cassette file
+	ModeRecord
+	// Send all requests to the real apis without reading or writing the cassette file
+	ModePassthrough
+)
+
+func (m Mode) ToString() string {
+	switch m {
+	case ModeReplay:
+		return "replay"
+	case ModeRecord:
+		return "record"
+	case ModePassthrough:
+		return "passthrough"
+	default:
+		return "unknown"
+	}
+}
+
+// Environment variable read by `ModeFromEnv`
+const ModeEnvVariable = "GOLLM_CASSETTE_MODE"
+
+// Parses the mode from the environment variable `GOLLM_CASSETTE_MODE`, defaulting to `ModeReplay`
+func ModeFromEnv() Mode {
+	switch strings.ToLower(os.Getenv(ModeEnvVariable)) {
+	case "record":
+		return ModeRecord
+	case "passthrough":
+		return ModePassthrough
+	default:
+		return ModeReplay
+	}
+}
+
+// Value that replaces scrubbed secrets in the cassette files
+const scrubbed = "[SCRUBBED]"
+
+// Headers that hold credentials or account details, which are never written to a cassette
+var DefaultScrubHeaders = []string{
+	"Authorization",
+	"x-api-key",
+	"x-goog-api-key",
+	"api-key",
+	"Cookie",
+	"Set-Cookie",
+	"Openai-Organization",
+	"Anthropic-Organization-Id",
+}
+
+// Query parameters that hold credentials, which are never written to a cassette
+var DefaultScrubQueryParams = []string{
+	"key",
+}
+
+// Matches generated identifiers, which differ between runs and would break request matching
+var uuidPattern = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+
+// A cassette holds all of the http interactions recorded during a single test
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+type Interaction struct {
+	Request  *Request  `json:"request"`
+	Response *Response `json:"response"`
+}
+
+type Request struct {
+	Method  string              `json:"method"`
+	URL     string              `json:"url"`
+	Headers map[string][]string `json:"headers,omitempty"`
+	Body    string              `json:"body"`
+}
+
+type Response struct {
+	StatusCode int                 `json:"status_code"`
+	Headers    map[string][]string `json:"headers,omitempty"`
+	Body       string              `json:"body"`
+}
+
+// Reads a cassette from disk
+func Load(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the cassette: %v", err)
+	}
+	var cassette Cassette
+	if err := json.Unmarshal(data, &cassette); err != nil {
+		return nil, fmt.Errorf("failed to parse the cassette %s: %v", path, err)
+	}
+	return &cassette, nil
+}
+
+// Writes the cassette to disk, creating the parent directories if needed
+func (c *Cassette) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create the cassette directory: %v", err)
+	}
+	enc, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode the cassette: %v", err)
+	}
+	return os.WriteFile(path, append(enc, '\n'), 0o644)
+}
+
+// Replaces the values of the scrubbed query parameters in the url
+func scrubURL(raw string, params []string) string {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	query := parsed.Query()
+	changed := false
+	for _, param := range params {
+		if query.Has(param) {
+			query.Set(param, scrubbed)
+			changed = true
+		}
+	}
+	if changed {
+		parsed.RawQuery = query.Encode()
+	}
+	return parsed.String()
+}
+
+// Copies the headers, replacing the values of the scrubbed headers
+func scrubHeaders(headers map[string][]string, scrub []string) map[string][]string {
+	resp := make(map[string][]string, len(headers))
+	for key, values := range headers {
+		resp[key] = values
+		for _, item := range scrub {
+			if strings.EqualFold(key, item) {
+				resp[key] = []string{scrubbed}
+			}
+		}
+	}
+	return resp
+}
+
+/*
+Normalizes a request body for matching. Json bodies are re-encoded with sorted keys so
+formatting differences do not matter, and generated uuids are replaced with a placeholder.
+*/
+func normalizeBody(body string) string {
+	body = uuidPattern.ReplaceAllString(body, "<uuid>")
+
+	var parsed any
+	if err := json.Unmarshal([]byte(body), &parsed); err != nil {
+		return strings.TrimSpace(body)
+	}
+	// encoding/json sorts map keys when marshalling
+	enc, err := json.Marshal(parsed)
+	if err != nil {
+		return body
+	}
+	return string(enc)
+}
+
+// Key used to match a live request against the recorded requests
+func matchKey(method string, rawURL string, body string) string {
+	// re-encoding the query sorts the parameters
+	if parsed, err := url.Parse(rawURL); err == nil {
+		parsed.RawQuery = parsed.Query().Encode()
+		rawURL = parsed.String()
+	}
+	return strings.ToUpper(method) + " " + rawURL + "\n" + normalizeBody(body)
+}
@@ -0,0 +1,189 @@
+package cassette
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// Optional configurations for the recorder
+type Options struct {
+	// Transport used to reach the real apis. Defaults to `http.DefaultTransport`
+	Transport http.RoundTripper
+
+	// Headers that are scrubbed from recorded requests. Defaults to `DefaultScrubHeaders`
+	ScrubHeaders []string
+
+	// Query parameters that are scrubbed from recorded urls. Defaults to `DefaultScrubQueryParams`
+	ScrubQueryParams []string
+}
+
+/*
+An `http.RoundTripper` that records http interactions into a cassette file, or replays them
+from one. Requests are matched on the method, the url and the normalized body. Identical
+requests are replayed in the order they were recorded.
+*/
+type Recorder struct {
+	path string
+	mode Mode
+	opts *Options
+
+	mu       sync.Mutex
+	cassette *Cassette
+	used     map[int]bool
+}
+
+/*
+Creates a recorder for the cassette at `path`. In `ModeReplay`, the cassette must already
+exist. In `ModeRecord`, the cassette is written when `Stop` is called. `opts` can be nil.
+*/
+func New(path string, mode Mode, opts *Options) (*Recorder, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	if opts.Transport == nil {
+		opts.Transport = http.DefaultTransport
+	}
+	if opts.ScrubHeaders == nil {
+		opts.ScrubHeaders = DefaultScrubHeaders
+	}
+	if opts.ScrubQueryParams == nil {
+		opts.ScrubQueryParams = DefaultScrubQueryParams
+	}
+
+	r := &Recorder{
+		path:     path,
+		mode:     mode,
+		opts:     opts,
+		cassette: &Cassette{Interactions: make([]*Interaction, 0)},
+		used:     make(map[int]bool),
+	}
+
+	if mode == ModeReplay {
+		cassette, err := Load(path)
+		if err != nil {
+			return nil, err
+		}
+		r.cassette = cassette
+	}
+
+	return r, nil
+}
+
+func (r *Recorder) Mode() Mode {
+	return r.mode
+}
+
+// Returns an http client that sends all requests through the recorder
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// Saves the cassette when recording. Does nothing in the other modes.
+func (r *Recorder) Stop() error {
+	if r.mode != ModeRecord {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cassette.Save(r.path)
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch r.mode {
+	case ModeReplay:
+		return r.replay(req, body)
+	case ModeRecord:
+		return r.record(req, body)
+	default:
+		return r.opts.Transport.RoundTrip(req)
+	}
+}
+
+func (r *Recorder) replay(req *http.Request, body []byte) (*http.Response, error) {
+	key := matchKey(req.Method, scrubURL(req.URL.String(), r.opts.ScrubQueryParams), string(body))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for idx, item := range r.cassette.Interactions {
+		if r.used[idx] {
+			continue
+		}
+		if matchKey(item.Request.Method, item.Request.URL, item.Request.Body) != key {
+			continue
+		}
+		r.used[idx] = true
+
+		header := make(http.Header)
+		for k, v := range item.Response.Headers {
+			header[k] = v
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", item.Response.StatusCode, http.StatusText(item.Response.StatusCode)),
+			StatusCode:    item.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          io.NopCloser(bytes.NewBufferString(item.Response.Body)),
+			ContentLength: int64(len(item.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("no recorded interaction in %s matches %s %s. Set %s=record to record it", r.path, req.Method, req.URL.Path, ModeEnvVariable)
+}
+
+func (r *Recorder) record(req *http.Request, body []byte) (*http.Response, error) {
+	resp, err := r.opts.Transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the response body: %v", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewBuffer(respBody))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, &Interaction{
+		Request: &Request{
+			Method:  req.Method,
+			URL:     scrubURL(req.URL.String(), r.opts.ScrubQueryParams),
+			Headers: scrubHeaders(req.Header, r.opts.ScrubHeaders),
+			Body:    string(body),
+		},
+		Response: &Response{
+			StatusCode: resp.StatusCode,
+			Headers:    scrubHeaders(resp.Header, r.opts.ScrubHeaders),
+			Body:       string(respBody),
+		},
+	})
+
+	return resp, nil
+}
+
+// Reads the body of the request, and resets it so it can still be sent
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the request body: %v", err)
+	}
+	req.Body.Close()
+	req.Body = io.NopCloser(bytes.NewBuffer(body))
+	return body, nil
+}
@@ -0,0 +1,90 @@
+package cassette
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=abc")
+		w.Write([]byte(`{"echo": ` + string(body) + `}`))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "nested", "TestRecordAndReplay.json")
+
+	// record a request that includes secrets and a generated id
+	recorder, err := New(path, ModeRecord, nil)
+	require.NoError(t, err)
+	req, _ := http.NewRequest("POST", server.URL+"/v1/models/gemini:generateContent?key=secret-key", strings.NewReader(`{"id": "0191f7e4-2a3b-7c4d-8e5f-6a7b8c9d0e1f", "b": 1, "a": 2}`))
+	req.Header.Set("Authorization", "Bearer secret-token")
+	req.Header.Set("x-goog-api-key", "secret-key")
+	resp, err := recorder.Client().Do(req)
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	require.Contains(t, string(body), "echo")
+	require.NoError(t, recorder.Stop())
+	require.Equal(t, 1, calls)
+
+	// ensure no secrets were written
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NotContains(t, string(raw), "secret-key")
+	require.NotContains(t, string(raw), "secret-token")
+	require.NotContains(t, string(raw), "session=abc")
+
+	// replay with a different uuid, key order and formatting
+	replayer, err := New(path, ModeReplay, nil)
+	require.NoError(t, err)
+	req, _ = http.NewRequest("POST", server.URL+"/v1/models/gemini:generateContent?key=other-key", bytes.NewBufferString(`{"a":2,"b":1,"id":"0191f7e4-ffff-7c4d-8e5f-6a7b8c9d0e1f"}`))
+	resp, err = replayer.Client().Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	replayed, _ := io.ReadAll(resp.Body)
+	require.Equal(t, string(body), string(replayed))
+	require.Equal(t, 1, calls)
+
+	// the interaction was used, so a second identical request does not match
+	req, _ = http.NewRequest("POST", server.URL+"/v1/models/gemini:generateContent", bytes.NewBufferString(`{"a":2,"b":1,"id":"0191f7e4-ffff-7c4d-8e5f-6a7b8c9d0e1f"}`))
+	_, err = replayer.Client().Do(req)
+	require.Error(t, err)
+}
+
+func TestReplayMismatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "TestReplayMismatch.json")
+	cassette := &Cassette{Interactions: []*Interaction{{
+		Request:  &Request{Method: "POST", URL: "https://api.openai.com/v1/chat/completions", Body: `{"model":"gpt-3.5-turbo"}`},
+		Response: &Response{StatusCode: 200, Body: `{}`},
+	}}}
+	require.NoError(t, cassette.Save(path))
+
+	replayer, err := New(path, ModeReplay, nil)
+	require.NoError(t, err)
+
+	req, _ := http.NewRequest("POST", "https://api.openai.com/v1/chat/completions", bytes.NewBufferString(`{"model":"gpt-4"}`))
+	_, err = replayer.Client().Do(req)
+	require.ErrorContains(t, err, "no recorded interaction")
+
+	req, _ = http.NewRequest("POST", "https://api.openai.com/v1/chat/completions", bytes.NewBufferString(`{"model":"gpt-3.5-turbo"}`))
+	resp, err := replayer.Client().Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestReplayMissingCassette(t *testing.T) {
+	_, err := New(filepath.Join(t.TempDir(), "missing.json"), ModeReplay, nil)
+	require.Error(t, err)
+}
@@ -0,0 +1,328 @@
+/*
+Package chunking splits text into chunks that fit the context of an embeddings model.
+
+Every chunker measures its chunks in tokens, and returns the byte offsets of every chunk so it
+can be traced back to its span in the source. Chunks never contain text that is not in the
+source, so `input[chunk.Start:chunk.End] == chunk.Text` always holds.
+*/
+package chunking
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const default_size = 512
+
+// A piece of the input, along with where it is in the input
+type Chunk struct {
+	Text  string
+	Start int // byte offset of the chunk in the input
+	End   int // byte offset after the end of the chunk in the input
+
+	// The heading path of a markdown chunk, such as `Install > Linux`, or the declaration a code chunk starts in
+	Section string
+}
+
+type Chunker interface {
+	// Splits the input into chunks, in the order they appear in the input
+	Chunk(input string) ([]*Chunk, error)
+}
+
+// Optional configurations of a chunker. Can be passed in as nil to use the defaults.
+type Options struct {
+	// The most tokens in a chunk. Defaults to 512
+	Size int
+
+	// The tokens repeated from the end of a chunk at the start of the next, so context is not lost
+	// at the boundaries. Defaults to 0, and must be less than `Size`
+	Overlap int
+
+	// Counts the tokens in a string. Defaults to `ApproximateTokens`. Pass the tokenizer of the
+	// embeddings model to fill the chunks exactly
+	Tokenizer func(input string) int
+}
+
+func newOptions(opts *Options) *Options {
+	resp := Options{}
+	if opts != nil {
+		resp = *opts
+	}
+	if resp.Size == 0 {
+		resp.Size = default_size
+	}
+	if resp.Tokenizer == nil {
+		resp.Tokenizer = ApproximateTokens
+	}
+	return &resp
+}
+
+func (o *Options) isValid() error {
+	if o.Size < 0 || o.Overlap < 0 {
+		return fmt.Errorf("the size and overlap cannot be negative")
+	}
+	if o.Overlap >= o.Size {
+		return fmt.Errorf("the overlap (%d) must be less than the size (%d)", o.Overlap, o.Size)
+	}
+	return nil
+}
+
+// Estimates the tokens in a string at 4 characters per token, which is close for english text
+// with the tokenizers of most embeddings models
+func ApproximateTokens(input string) int {
+	return (utf8.RuneCountInString(input) + 3) / 4
+}
+
+// A range of bytes in the input
+type span struct {
+	start int
+	end   int
+}
+
+// Splits a span into smaller spans that cover all of it. Returns a single span when it cannot split it
+type splitFunc func(input string, sp span) []span
+
+// Splits text into pieces that fit in a chunk, then merges the pieces into chunks
+type splitter struct {
+	opts   *Options
+	levels []splitFunc
+}
+
+/*
+Splits the span into spans that fit in a chunk. The levels are tried from the coarsest to the
+finest, such as paragraphs and then sentences. The parts of the first level that splits the span
+are merged back together while they fit, and the parts that do not fit are split with the finer
+levels. A part that no level can split is split at the token limit.
+*/
+func (s *splitter) split(input string, sp span, levels []splitFunc) []span {
+	if s.opts.Tokenizer(input[sp.start:sp.end]) <= s.opts.Size {
+		return []span{sp}
+	}
+
+	for i, level := range levels {
+		parts := level(input, sp)
+		if len(parts) < 2 {
+			continue
+		}
+
+		resp := make([]span, 0)
+		fits := make([]span, 0)
+		for _, part := range parts {
+			if s.opts.Tokenizer(input[part.start:part.end]) <= s.opts.Size {
+				fits = append(fits, part)
+				continue
+			}
+			resp = append(resp, s.merge(input, fits)...)
+			resp = append(resp, s.split(input, part, levels[i+1:])...)
+			fits = fits[:0]
+		}
+		return append(resp, s.merge(input, fits)...)
+	}
+
+	return splitTokens(input, sp, s.opts)
+}
+
+// Merges consecutive parts into spans of at most `Size` tokens, starting every span with up
+// to `Overlap` tokens of parts from the end of the last span
+func (s *splitter) merge(input string, parts []span) []span {
+	resp := make([]span, 0)
+	window := make([]span, 0)
+	counts := make([]int, 0)
+	total := 0
+
+	for _, part := range parts {
+		count := s.opts.Tokenizer(input[part.start:part.end])
+		if len(window) != 0 && total+count > s.opts.Size {
+			resp = append(resp, span{start: window[0].start, end: window[len(window)-1].end})
+			for len(window) != 0 && (total > s.opts.Overlap || total+count > s.opts.Size) {
+				total -= counts[0]
+				window = window[1:]
+				counts = counts[1:]
+			}
+		}
+		window = append(window, part)
+		counts = append(counts, count)
+		total += count
+	}
+	if len(window) != 0 {
+		resp = append(resp, span{start: window[0].start, end: window[len(window)-1].end})
+	}
+
+	return resp
+}
+
+// Chunks a span of the input using the levels of the splitter
+func (s *splitter) chunk(input string, sp span, section string) []*Chunk {
+	chunks := make([]*Chunk, 0)
+	for _, item := range s.split(input, sp, s.levels) {
+		if chunk := newChunk(input, item.start, item.end, section); chunk != nil {
+			chunks = append(chunks, chunk)
+		}
+	}
+	return chunks
+}
+
+// Creates a chunk without the surrounding whitespace, or nil if the chunk is only whitespace
+func newChunk(input string, start int, end int, section string) *Chunk {
+	text := input[start:end]
+	trimmed := strings.TrimLeftFunc(text, unicode.IsSpace)
+	start += len(text) - len(trimmed)
+	trimmed = strings.TrimRightFunc(trimmed, unicode.IsSpace)
+	if trimmed == "" {
+		return nil
+	}
+	return &Chunk{
+		Text:    trimmed,
+		Start:   start,
+		End:     start + len(trimmed),
+		Section: section,
+	}
+}
+
+// Splits a span into the longest pieces that fit in a chunk, without splitting a rune
+func splitTokens(input string, sp span, opts *Options) []span {
+	bounds := make([]int, 0, sp.end-sp.start+1)
+	for idx := range input[sp.start:sp.end] {
+		bounds = append(bounds, sp.start+idx)
+	}
+	bounds = append(bounds, sp.end)
+
+	resp := make([]span, 0)
+	from := 0
+	for from < len(bounds)-1 {
+		// binary search for the last rune that fits, always taking at least one
+		lo, hi := from+1, len(bounds)-1
+		for lo < hi {
+			mid := (lo + hi + 1) / 2
+			if opts.Tokenizer(input[bounds[from]:bounds[mid]]) <= opts.Size {
+				lo = mid
+			} else {
+				hi = mid - 1
+			}
+		}
+		resp = append(resp, span{start: bounds[from], end: bounds[lo]})
+		from = lo
+	}
+	return resp
+}
+
+// Splits after every occurrence of the separator, keeping the separator at the end of the piece
+func splitAfter(separator string) splitFunc {
+	return func(input string, sp span) []span {
+		resp := make([]span, 0)
+		start := sp.start
+		for {
+			idx := strings.Index(input[start:sp.end], separator)
+			if idx < 0 {
+				break
+			}
+			end := start + idx + len(separator)
+			resp = append(resp, span{start: start, end: end})
+			start = end
+		}
+		if start < sp.end {
+			resp = append(resp, span{start: start, end: sp.end})
+		}
+		return resp
+	}
+}
+
+// Splits after every run of whitespace
+func splitWords(input string, sp span) []span {
+	resp := make([]span, 0)
+	start := sp.start
+	inSpace := false
+	for idx, r := range input[sp.start:sp.end] {
+		space := unicode.IsSpace(r)
+		if inSpace && !space {
+			resp = append(resp, span{start: start, end: sp.start + idx})
+			start = sp.start + idx
+		}
+		inSpace = space
+	}
+	if start < sp.end {
+		resp = append(resp, span{start: start, end: sp.end})
+	}
+	return resp
+}
+
+// Splits after every line break
+func splitLines(input string, sp span) []span {
+	return splitAfter("\n")(input, sp)
+}
+
+// Splits after every blank line, which can contain whitespace
+func splitParagraphs(input string, sp span) []span {
+	resp := make([]span, 0)
+	start := sp.start
+	idx := sp.start
+	for idx < sp.end {
+		if input[idx] != '\n' {
+			idx++
+			continue
+		}
+
+		// find the end of the whitespace after the line break, and whether it holds another line break
+		end := idx + 1
+		blank := false
+		for end < sp.end && (input[end] == ' ' || input[end] == '\t' || input[end] == '\r' || input[end] == '\n') {
+			if input[end] == '\n' {
+				blank = true
+			}
+			end++
+		}
+		if blank {
+			resp = append(resp, span{start: start, end: end})
+			start = end
+		}
+		idx = end
+	}
+	if start < sp.end {
+		resp = append(resp, span{start: start, end: sp.end})
+	}
+	return resp
+}
+
+/*
+Splits after every sentence. A sentence ends with `.`, `!` or `?` followed by whitespace, which
+can be after closing quotes or brackets. These simple rules split after some abbreviations, such
+as `e.g.`, which only makes the pieces smaller than they need to be.
+*/
+func splitSentences(input string, sp span) []span {
+	resp := make([]span, 0)
+	start := sp.start
+	idx := sp.start
+	for idx < sp.end {
+		c := input[idx]
+		idx++
+		if c != '.' && c != '!' && c != '?' {
+			continue
+		}
+
+		end := idx
+		for end < sp.end && strings.IndexByte(`.!?"')]`, input[end]) >= 0 {
+			end++
+		}
+		if end < sp.end && !isSpace(input[end]) {
+			// not the end of a sentence, such as a decimal number or a url
+			idx = end
+			continue
+		}
+		for end < sp.end && isSpace(input[end]) {
+			end++
+		}
+		resp = append(resp, span{start: start, end: end})
+		start = end
+		idx = end
+	}
+	if start < sp.end {
+		resp = append(resp, span{start: start, end: sp.end})
+	}
+	return resp
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
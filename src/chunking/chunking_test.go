@@ -0,0 +1,123 @@
+package chunking
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Counts every whitespace separated word as a token, so the expected chunks are easy to reason about
+func wordTokenizer(input string) int {
+	return len(strings.Fields(input))
+}
+
+// Checks that every chunk is the span of the input it claims to be, and fits in the size
+func requireValidChunks(t *testing.T, input string, chunks []*Chunk, opts *Options) {
+	require.NotEmpty(t, chunks)
+	for idx, chunk := range chunks {
+		require.Equal(t, input[chunk.Start:chunk.End], chunk.Text)
+		require.LessOrEqual(t, opts.Tokenizer(chunk.Text), opts.Size, chunk.Text)
+		if idx != 0 {
+			require.Greater(t, chunk.Start, chunks[idx-1].Start)
+		}
+	}
+}
+
+func TestRecursiveChunker(t *testing.T) {
+	opts := &Options{Size: 6, Tokenizer: wordTokenizer}
+	input := "one two three four.\n\nfive six seven eight nine ten eleven twelve.\nthirteen fourteen"
+
+	chunks, err := NewRecursiveChunker(opts).Chunk(input)
+	require.NoError(t, err)
+	requireValidChunks(t, input, chunks, opts)
+
+	texts := make([]string, 0)
+	for _, chunk := range chunks {
+		texts = append(texts, chunk.Text)
+	}
+	require.Equal(t, []string{
+		"one two three four.",
+		"five six seven eight nine ten",
+		"eleven twelve.",
+		"thirteen fourteen",
+	}, texts)
+}
+
+func TestChunkOverlap(t *testing.T) {
+	opts := &Options{Size: 4, Overlap: 2, Tokenizer: wordTokenizer}
+	input := "a b c d e f g h"
+
+	chunks, err := NewRecursiveChunker(opts, " ").Chunk(input)
+	require.NoError(t, err)
+	requireValidChunks(t, input, chunks, opts)
+
+	texts := make([]string, 0)
+	for _, chunk := range chunks {
+		texts = append(texts, chunk.Text)
+	}
+	require.Equal(t, []string{"a b c d", "c d e f", "e f g h"}, texts)
+
+	// the overlap has to leave room for new text
+	_, err = NewRecursiveChunker(&Options{Size: 4, Overlap: 4}).Chunk(input)
+	require.ErrorContains(t, err, "must be less than the size")
+}
+
+func TestChunkOversizedWord(t *testing.T) {
+	// a piece that cannot be split at any separator is split at the token limit, without splitting runes
+	opts := &Options{Size: 3, Tokenizer: ApproximateTokens}
+	input := strings.Repeat("é", 30)
+
+	chunks, err := NewRecursiveChunker(opts).Chunk(input)
+	require.NoError(t, err)
+	requireValidChunks(t, input, chunks, opts)
+	require.Len(t, chunks, 3)
+	require.Equal(t, len(input), chunks[2].End)
+}
+
+func TestSentenceChunker(t *testing.T) {
+	opts := &Options{Size: 8, Tokenizer: wordTokenizer}
+	input := `Pi is about 3.14 today. "Is it?" she asked!  It is. See example.com for more`
+
+	chunks, err := NewSentenceChunker(opts).Chunk(input)
+	require.NoError(t, err)
+	requireValidChunks(t, input, chunks, opts)
+
+	texts := make([]string, 0)
+	for _, chunk := range chunks {
+		texts = append(texts, chunk.Text)
+	}
+	require.Equal(t, []string{
+		`Pi is about 3.14 today. "Is it?"`,
+		`she asked!  It is. See example.com for more`,
+	}, texts)
+}
+
+func TestParagraphChunker(t *testing.T) {
+	opts := &Options{Size: 6, Tokenizer: wordTokenizer}
+	input := "First paragraph here.\n  \nSecond one.\n\nThird paragraph is far too long to fit. So it is split."
+
+	chunks, err := NewParagraphChunker(opts).Chunk(input)
+	require.NoError(t, err)
+	requireValidChunks(t, input, chunks, opts)
+
+	texts := make([]string, 0)
+	for _, chunk := range chunks {
+		texts = append(texts, chunk.Text)
+	}
+	require.Equal(t, []string{
+		"First paragraph here.\n  \nSecond one.",
+		"Third paragraph is far too long",
+		"to fit.",
+		"So it is split.",
+	}, texts)
+}
+
+func TestChunkEmptyInput(t *testing.T) {
+	_, err := NewParagraphChunker(nil).Chunk("")
+	require.ErrorContains(t, err, "empty")
+
+	chunks, err := NewParagraphChunker(nil).Chunk(" \n\n ")
+	require.NoError(t, err)
+	require.Empty(t, chunks)
+}
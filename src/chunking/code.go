@@ -0,0 +1,139 @@
+package chunking
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// A programming language supported by the `CodeChunker`
+type Language string
+
+const (
+	LanguageGo     Language = "go"
+	LanguagePython Language = "python"
+)
+
+// How the top level declarations of a language are found
+type languageSyntax struct {
+	declarations []string // prefixes of the lines that start a declaration
+	comments     []string // prefixes of the lines that are attached to the declaration below them
+}
+
+var languageSyntaxes = map[Language]*languageSyntax{
+	LanguageGo: {
+		declarations: []string{"package ", "import ", "import(", "func ", "func(", "type ", "type(", "var ", "var(", "const ", "const("},
+		comments:     []string{"//"},
+	},
+	LanguagePython: {
+		declarations: []string{"def ", "async def ", "class "},
+		comments:     []string{"#", "@"},
+	},
+}
+
+/*
+Splits source code at its top level declarations, such as functions and types, keeping the
+comments and decorators above a declaration with it. Small declarations are merged into a
+single chunk, and declarations that do not fit in a chunk are split at blank lines, then lines.
+
+Every chunk holds the first line of the declaration it starts in as its `Section`, such as
+`func (c *Client) Do(req *Request) error {`.
+*/
+type CodeChunker struct {
+	syntax   *languageSyntax
+	splitter *splitter
+}
+
+func NewCodeChunker(language Language, opts *Options) (*CodeChunker, error) {
+	syntax, ok := languageSyntaxes[language]
+	if !ok {
+		return nil, fmt.Errorf("unsupported language: %s", language)
+	}
+	return &CodeChunker{
+		syntax: syntax,
+		splitter: &splitter{
+			opts:   newOptions(opts),
+			levels: []splitFunc{splitParagraphs, splitLines, splitWords},
+		},
+	}, nil
+}
+
+func (c *CodeChunker) Chunk(input string) ([]*Chunk, error) {
+	if err := c.splitter.opts.isValid(); err != nil {
+		return nil, err
+	}
+	if input == "" {
+		return nil, fmt.Errorf("the input was empty")
+	}
+
+	// split at the declarations first, then fall back to the levels of the splitter
+	declarations := c.declarations(input)
+	splitter := &splitter{
+		opts: c.splitter.opts,
+		levels: append([]splitFunc{func(input string, sp span) []span {
+			resp := make([]span, 0, len(declarations))
+			for _, item := range declarations {
+				resp = append(resp, item.span)
+			}
+			return resp
+		}}, c.splitter.levels...),
+	}
+	chunks := splitter.chunk(input, span{start: 0, end: len(input)}, "")
+
+	// name every chunk after the declaration that it starts in
+	for _, chunk := range chunks {
+		idx := sort.Search(len(declarations), func(i int) bool {
+			return declarations[i].span.start > chunk.Start
+		})
+		if idx > 0 {
+			chunk.Section = declarations[idx-1].signature
+		}
+	}
+
+	return chunks, nil
+}
+
+type codeDeclaration struct {
+	span      span
+	signature string
+}
+
+// Splits the input into its top level declarations. Any code before the first declaration is its own piece
+func (c *CodeChunker) declarations(input string) []*codeDeclaration {
+	lines := splitLines(input, span{start: 0, end: len(input)})
+	declarations := make([]*codeDeclaration, 0)
+	current := &codeDeclaration{}
+
+	for idx, line := range lines {
+		text := strings.TrimRight(input[line.start:line.end], "\r\n")
+		if !hasAnyPrefix(text, c.syntax.declarations) {
+			continue
+		}
+
+		// pull the comments and decorators directly above the declaration into it
+		start := idx
+		for start > 0 && hasAnyPrefix(input[lines[start-1].start:lines[start-1].end], c.syntax.comments) {
+			start--
+		}
+		current.span.end = lines[start].start
+		if current.span.end > current.span.start {
+			declarations = append(declarations, current)
+		}
+		current = &codeDeclaration{span: span{start: lines[start].start}, signature: strings.TrimSpace(text)}
+	}
+
+	current.span.end = len(input)
+	if current.span.end > current.span.start {
+		declarations = append(declarations, current)
+	}
+	return declarations
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, item := range prefixes {
+		if strings.HasPrefix(s, item) {
+			return true
+		}
+	}
+	return false
+}
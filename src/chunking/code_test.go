@@ -0,0 +1,82 @@
+package chunking
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodeChunkerGo(t *testing.T) {
+	opts := &Options{Size: 18, Tokenizer: wordTokenizer}
+	input := `// Package demo is a demo
+package demo
+
+import "fmt"
+
+// Greets the name
+func Greet(name string) {
+	fmt.Println("hello", name)
+}
+
+type Greeter struct {
+	Name string
+}
+
+// Greets with the name of the greeter, which takes a lot of words to explain
+func (g *Greeter) Greet() {
+	a := 1
+
+	b := 2
+	fmt.Println("hello", g.Name, a, b, "and", "some", "more", "words")
+}
+`
+
+	chunker, err := NewCodeChunker(LanguageGo, opts)
+	require.NoError(t, err)
+	chunks, err := chunker.Chunk(input)
+	require.NoError(t, err)
+	requireValidChunks(t, input, chunks, opts)
+
+	// small declarations are merged, keeping their comments
+	require.Equal(t, "// Package demo is a demo\npackage demo\n\nimport \"fmt\"", chunks[0].Text)
+	require.Equal(t, "package demo", chunks[0].Section)
+	require.Equal(t, "// Greets the name\nfunc Greet(name string) {\n\tfmt.Println(\"hello\", name)\n}\n\ntype Greeter struct {\n\tName string\n}", chunks[1].Text)
+	require.Equal(t, "func Greet(name string) {", chunks[1].Section)
+
+	// a large declaration is split, and every piece is named after it
+	for _, chunk := range chunks[2:] {
+		require.Equal(t, "func (g *Greeter) Greet() {", chunk.Section)
+	}
+	require.Contains(t, chunks[2].Text, "// Greets with the name")
+}
+
+func TestCodeChunkerPython(t *testing.T) {
+	opts := &Options{Size: 10, Tokenizer: wordTokenizer}
+	input := `import os
+
+# a decorated function
+@cache
+def load(path):
+    return open(path).read()
+
+class Store:
+    def get(self, key):
+        return self.items[key]
+`
+
+	chunker, err := NewCodeChunker(LanguagePython, opts)
+	require.NoError(t, err)
+	chunks, err := chunker.Chunk(input)
+	require.NoError(t, err)
+	requireValidChunks(t, input, chunks, opts)
+
+	require.Len(t, chunks, 3)
+	require.Equal(t, "import os", chunks[0].Text)
+	require.Equal(t, "", chunks[0].Section)
+	require.Equal(t, "# a decorated function\n@cache\ndef load(path):\n    return open(path).read()", chunks[1].Text)
+	require.Equal(t, "def load(path):", chunks[1].Section)
+	require.Equal(t, "class Store:", chunks[2].Section)
+
+	_, err = NewCodeChunker("rust", nil)
+	require.ErrorContains(t, err, "unsupported language")
+}
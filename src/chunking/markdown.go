@@ -0,0 +1,129 @@
+package chunking
+
+import (
+	"strings"
+)
+
+/*
+Splits markdown into the sections under every heading, so a chunk never spans two sections.
+Every chunk holds the path of the headings it is under in `Section`, such as `Install > Linux`,
+which can be embedded along with the chunk to give it context.
+
+Sections that do not fit in a chunk are split into paragraphs, then lines and sentences. Headings
+inside fenced code blocks are ignored.
+*/
+type MarkdownChunker struct {
+	splitter *splitter
+}
+
+func NewMarkdownChunker(opts *Options) *MarkdownChunker {
+	return &MarkdownChunker{splitter: &splitter{
+		opts:   newOptions(opts),
+		levels: []splitFunc{splitParagraphs, splitLines, splitSentences, splitWords},
+	}}
+}
+
+func (c *MarkdownChunker) Chunk(input string) ([]*Chunk, error) {
+	if err := c.splitter.opts.isValid(); err != nil {
+		return nil, err
+	}
+
+	chunks := make([]*Chunk, 0)
+	for _, section := range markdownSections(input) {
+		chunks = append(chunks, c.splitter.chunk(input, section.span, section.path)...)
+	}
+	if len(chunks) == 0 {
+		return chunkText(c.splitter, input)
+	}
+	return chunks, nil
+}
+
+type markdownSection struct {
+	span span
+	path string
+}
+
+// Splits the input at every atx heading, such as `## Install`, tracking the headings above each section
+func markdownSections(input string) []*markdownSection {
+	sections := make([]*markdownSection, 0)
+	headings := make([]string, 0, 6)
+	current := &markdownSection{}
+	fence := ""
+
+	for _, line := range splitLines(input, span{start: 0, end: len(input)}) {
+		text := strings.TrimRight(input[line.start:line.end], "\r\n")
+		trimmed := strings.TrimLeft(text, " ")
+
+		// ignore everything inside of code blocks
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:3]
+			continue
+		}
+
+		level, title := markdownHeading(text)
+		if level == 0 {
+			continue
+		}
+
+		// close the previous section, and replace the headings at the same level or below
+		current.span.end = line.start
+		if current.span.end > current.span.start {
+			sections = append(sections, current)
+		}
+		for len(headings) >= level {
+			headings = headings[:len(headings)-1]
+		}
+		for len(headings) < level-1 {
+			headings = append(headings, "")
+		}
+		headings = append(headings, title)
+		current = &markdownSection{span: span{start: line.start}, path: joinHeadings(headings)}
+	}
+
+	current.span.end = len(input)
+	if current.span.end > current.span.start {
+		sections = append(sections, current)
+	}
+	return sections
+}
+
+// Returns the level and title of an atx heading, or a level of 0 if the line is not a heading
+func markdownHeading(line string) (int, string) {
+	// headings can be indented by up to 3 spaces
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 {
+		return 0, ""
+	}
+
+	level := 0
+	for level < len(trimmed) && trimmed[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 {
+		return 0, ""
+	}
+	if level < len(trimmed) && trimmed[level] != ' ' && trimmed[level] != '\t' {
+		return 0, ""
+	}
+
+	title := strings.TrimSpace(trimmed[level:])
+	title = strings.TrimSpace(strings.TrimRight(title, "#"))
+	return level, title
+}
+
+// Joins the headings into a path, skipping the levels that were skipped in the document
+func joinHeadings(headings []string) string {
+	parts := make([]string, 0, len(headings))
+	for _, item := range headings {
+		if item != "" {
+			parts = append(parts, item)
+		}
+	}
+	return strings.Join(parts, " > ")
+}
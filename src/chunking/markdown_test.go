@@ -0,0 +1,57 @@
+package chunking
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarkdownChunker(t *testing.T) {
+	opts := &Options{Size: 16, Tokenizer: wordTokenizer}
+	input := `Intro before any heading.
+
+# Install
+
+Run the installer.
+
+## Linux ##
+
+Use the package manager.
+
+` + "```sh\n# not a heading\napt install gollm\n```" + `
+
+#### Notes
+
+Skipped a level.
+
+# Usage
+#hashtag is not a heading
+`
+
+	chunks, err := NewMarkdownChunker(opts).Chunk(input)
+	require.NoError(t, err)
+	requireValidChunks(t, input, chunks, opts)
+
+	sections := make([]string, 0)
+	for _, chunk := range chunks {
+		sections = append(sections, chunk.Section)
+	}
+	require.Equal(t, []string{"", "Install", "Install > Linux", "Install > Linux > Notes", "Usage"}, sections)
+
+	require.Equal(t, "Intro before any heading.", chunks[0].Text)
+	require.Equal(t, "## Linux ##\n\nUse the package manager.\n\n```sh\n# not a heading\napt install gollm\n```", chunks[2].Text)
+	require.Equal(t, "# Usage\n#hashtag is not a heading", chunks[4].Text)
+}
+
+func TestMarkdownChunkerLongSection(t *testing.T) {
+	opts := &Options{Size: 5, Tokenizer: wordTokenizer}
+	input := "# Title\n\nOne two three four five six.\n\nSeven eight."
+
+	chunks, err := NewMarkdownChunker(opts).Chunk(input)
+	require.NoError(t, err)
+	requireValidChunks(t, input, chunks, opts)
+	for _, chunk := range chunks {
+		require.Equal(t, "Title", chunk.Section)
+	}
+	require.Equal(t, "# Title", chunks[0].Text)
+}
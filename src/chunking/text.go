@@ -0,0 +1,72 @@
+package chunking
+
+import "fmt"
+
+// Splits text at the first separator that breaks it into pieces that fit, recursing into the
+// next separators for the pieces that are still too large
+type RecursiveChunker struct {
+	splitter *splitter
+}
+
+// The separators tried by the `RecursiveChunker` when none are passed
+var DefaultSeparators = []string{"\n\n", "\n", ". ", " "}
+
+// Creates a chunker that splits at the separators in order. Defaults to `DefaultSeparators`
+func NewRecursiveChunker(opts *Options, separators ...string) *RecursiveChunker {
+	if len(separators) == 0 {
+		separators = DefaultSeparators
+	}
+	levels := make([]splitFunc, 0, len(separators))
+	for _, item := range separators {
+		if item != "" {
+			levels = append(levels, splitAfter(item))
+		}
+	}
+	return &RecursiveChunker{splitter: &splitter{opts: newOptions(opts), levels: levels}}
+}
+
+func (c *RecursiveChunker) Chunk(input string) ([]*Chunk, error) {
+	return chunkText(c.splitter, input)
+}
+
+// Fills every chunk with whole sentences, only splitting a sentence when it does not fit in a chunk
+type SentenceChunker struct {
+	splitter *splitter
+}
+
+func NewSentenceChunker(opts *Options) *SentenceChunker {
+	return &SentenceChunker{splitter: &splitter{
+		opts:   newOptions(opts),
+		levels: []splitFunc{splitSentences, splitWords},
+	}}
+}
+
+func (c *SentenceChunker) Chunk(input string) ([]*Chunk, error) {
+	return chunkText(c.splitter, input)
+}
+
+// Fills every chunk with whole paragraphs, falling back to sentences for paragraphs that do not fit in a chunk
+type ParagraphChunker struct {
+	splitter *splitter
+}
+
+func NewParagraphChunker(opts *Options) *ParagraphChunker {
+	return &ParagraphChunker{splitter: &splitter{
+		opts:   newOptions(opts),
+		levels: []splitFunc{splitParagraphs, splitLines, splitSentences, splitWords},
+	}}
+}
+
+func (c *ParagraphChunker) Chunk(input string) ([]*Chunk, error) {
+	return chunkText(c.splitter, input)
+}
+
+func chunkText(s *splitter, input string) ([]*Chunk, error) {
+	if err := s.opts.isValid(); err != nil {
+		return nil, err
+	}
+	if input == "" {
+		return nil, fmt.Errorf("the input was empty")
+	}
+	return s.chunk(input, span{start: 0, end: len(input)}, ""), nil
+}
@@ -6,7 +6,6 @@ import (
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
-	"io"
 	"log/slog"
 	"math/rand"
 	"net/http"
@@ -15,6 +14,7 @@ import (
 	"time"
 
 	"github.com/jake-landersweb/gollm/v2/src/ltypes"
+	"github.com/jake-landersweb/gollm/v2/src/metrics"
 )
 
 func (l *LanguageModel) anthropicCompletion(
@@ -39,9 +39,10 @@ func (l *LanguageModel) anthropicCompletion(
 
 	// parse a system message if exists
 	var msgs []*ltypes.AnthropicMessage
-	systemMsg := ""
+	var system []*ltypes.AnthropicSystemBlock
 	if messages[0].Role == "system" {
-		systemMsg = fmt.Sprintf("%s\n\nFormatting Instructions:\nYou MUST place your response to this message inside <response></response> XML tags. Any context or extra information shall be placed outside these tags, with the <response> XML tag containing exactly what was requested.", messages[0].Content[0].Text)
+		systemMsg := fmt.Sprintf("%s\n\nFormatting Instructions:\nYou MUST place your response to this message inside <response></response> XML tags. Any context or extra information shall be placed outside these tags, with the <response> XML tag containing exactly what was requested.", messages[0].Content[0].Text)
+		system = []*ltypes.AnthropicSystemBlock{{Type: "text", Text: systemMsg, CacheControl: messages[0].Content[0].CacheControl}}
 		msgs = messages[1:] // trim off the first message
 	} else {
 		msgs = messages
@@ -51,7 +52,7 @@ func (l *LanguageModel) anthropicCompletion(
 	comprequest := &ltypes.AnthropicRequest{
 		Model:       model,
 		Messages:    msgs,
-		System:      systemMsg,
+		System:      system,
 		MaxTokens:   l.args.AnthropicMaxTokens,
 		Temperature: temperature,
 		Tools:       tools,
@@ -64,43 +65,36 @@ func (l *LanguageModel) anthropicCompletion(
 		}
 	}
 
-	debugPrint(comprequest)
-
 	// parse and encode the body
 	enc, err := json.Marshal(comprequest)
 	if err != nil {
 		return nil, fmt.Errorf("there was an issue encoding the body: %v", err)
 	}
 
-	// create the request
-	req, err := http.NewRequest("POST", l.args.AnthropicBaseUrl, bytes.NewBuffer(enc))
-	if err != nil {
-		return nil, fmt.Errorf("error creating request: %v", err)
-	}
-	req.Header.Set("x-api-key", apiKey)
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("anthropic-version", l.args.AnthropicVersion)
+	logger.DebugContext(ctx, "Request body", "body", l.redactor.Body(enc, apiKey))
 
 	retries := 3
 	backoff := 1 * time.Second
 
 	for attempt := 0; attempt < retries; attempt++ {
 		logger.InfoContext(ctx, "Sending Anthropic request...")
-		client := &http.Client{}
-		resp, err := client.Do(req)
-		if err != nil {
-			return nil, fmt.Errorf("there was an issue sending the request: %v", err)
-		}
-		defer resp.Body.Close()
-
-		body, err := io.ReadAll(resp.Body)
+		client := l.args.HttpClient
+		statusCode, body, err := sendAttempt(ctx, l.tracer, client, genAISystemAnthropic, model, attempt, func(ctx context.Context) (*http.Request, error) {
+			req, err := http.NewRequestWithContext(ctx, "POST", l.args.AnthropicBaseUrl, bytes.NewBuffer(enc))
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("x-api-key", apiKey)
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("anthropic-version", l.args.AnthropicVersion)
+			return req, nil
+		})
 		if err != nil {
-			return nil, fmt.Errorf("there was an issue parsing the request: %v", err)
+			return nil, err
 		}
 
-		logger.InfoContext(ctx, "Completed request", "statusCode", resp.StatusCode)
-		logger.DebugContext(ctx, "Response body", "body", string(body))
-		fmt.Println(string(body))
+		logger.InfoContext(ctx, "Completed request", "statusCode", statusCode)
+		logger.DebugContext(ctx, "Response body", "body", l.redactor.Body(body, apiKey))
 
 		// parse the request body
 		var response ltypes.AnthropicResponse
@@ -156,21 +150,28 @@ func (l *LanguageModel) anthropicCompletion(
 		// case ltypes.ANTHROPIC_NOT_FOUND_ERROR:
 		case ltypes.ANTHROPIC_OVERLOADED_ERROR:
 			logger.WarnContext(ctx, "The api is overloaded, waiting 2 seconds then trying again ...")
-			time.Sleep(time.Second * 2)
+			if err := sleepContext(ctx, time.Second*2); err != nil {
+				return nil, err
+			}
 		case ltypes.ANTHROPIC_RATE_LIMIT_ERROR:
 			logger.WarnContext(ctx, "Rate limit hit, waiting 2 seconds then trying again ...")
-			time.Sleep(time.Second * 2)
+			if err := sleepContext(ctx, time.Second*2); err != nil {
+				return nil, err
+			}
 		default:
 			return nil, fmt.Errorf("there was an unknown issue with the request: [%s]: %s", response.Error.Type, response.Error.Message)
 		}
 
+		recordRetryableError(ctx, l.metrics, metrics.OperationCompletion, genAISystemAnthropic, model, attempt, string(response.Error.Type))
+
 		if attempt < retries-1 {
 			sleep := backoff + time.Duration(rand.Intn(1000))*time.Millisecond // Add jitter
-			time.Sleep(sleep)
+			if err := sleepContext(ctx, sleep); err != nil {
+				return nil, err
+			}
 			backoff *= 2 // Double the backoff interval
-		} else if resp != nil && resp.StatusCode != 200 {
-			body, _ := io.ReadAll(resp.Body)
-			return nil, fmt.Errorf("there was an issue with the request and could not recover: %s", string(body))
+		} else if statusCode != 200 {
+			return nil, fmt.Errorf("there was an issue with the request and could not recover: %s", l.redactor.Body(body, apiKey))
 		}
 	}
 
@@ -5,9 +5,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/jake-landersweb/gollm/v2/src/ltypes"
+	"github.com/jake-landersweb/gollm/v2/src/tokens"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -22,7 +25,7 @@ func TestAnthropicTextCompletion(t *testing.T) {
 	raw = append(raw, NewUserMessage("Please respond with a single sentence."))
 	messages := MessagesToAnthropic(raw)
 
-	llm := NewLanguageModel(test_user_id, logger, nil)
+	llm := newTestLanguageModel(t, logger)
 	response, err := llm.anthropicCompletion(ctx, logger, anthropic_claude3, 0.5, false, "", messages, nil, false, "")
 	assert.Nil(t, err)
 	if err != nil {
@@ -46,7 +49,7 @@ func TestAnthropicJSONCompletion(t *testing.T) {
 
 	fmt.Println(*messages[0])
 
-	llm := NewLanguageModel(test_user_id, logger, nil)
+	llm := newTestLanguageModel(t, logger)
 	response, err := llm.anthropicCompletion(ctx, logger, anthropic_claude3, 0.5, true, schema, messages, nil, false, "")
 	assert.Nil(t, err)
 	if err != nil {
@@ -98,7 +101,7 @@ func TestAnthropicToolUse(t *testing.T) {
 	debugPrint(MessagesToAnthropic(messages))
 
 	// send the tool use request
-	llm := NewLanguageModel(test_user_id, logger, nil)
+	llm := newTestLanguageModel(t, logger)
 	response, err := llm.anthropicCompletion(context.TODO(), logger, anthropic_claude3, 0.5, false, "", MessagesToAnthropic(messages), ToolsToAnthropic(tools), true, tools[0].Title)
 	require.NoError(t, err)
 
@@ -111,7 +114,7 @@ func TestAnthropicToolUse(t *testing.T) {
 	require.Equal(t, RoleToolCall, latestMessage.Role)
 
 	fmt.Println("INTERNAL MESSAGES")
-	PrintConversation(messages)
+	llm.LogConversation(context.TODO(), messages)
 
 	// add a tool response
 	messages = append(messages, NewToolResultMessage(latestMessage.ToolUseID, latestMessage.ToolName, "35 degrees"))
@@ -132,5 +135,58 @@ func TestAnthropicToolUse(t *testing.T) {
 	debugPrint(MessagesToAnthropic(messages))
 
 	fmt.Println("CONVERSATION:")
-	PrintConversation(messages)
+	llm.LogConversation(context.TODO(), messages)
+}
+
+func TestAnthropicPromptCaching(t *testing.T) {
+	requests := &testRequests{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.record(r)
+		w.Write([]byte(`{"id": "msg_1", "type": "message", "role": "assistant", "model": "claude-3-5-sonnet-20240620", "content": [{"type": "text", "text": "<response>Hello!</response>"}], "stop_reason": "end_turn", "usage": {"input_tokens": 20, "output_tokens": 5, "cache_creation_input_tokens": 1000, "cache_read_input_tokens": 3000}}`))
+	}))
+	defer server.Close()
+
+	llm := NewLanguageModel(test_user_id, nil, &NewLanguageModelArgs{
+		AnthropicBaseUrl: server.URL,
+		AnthropicApiKey:  "test",
+	})
+	system := NewSystemMessage("You are a support bot.")
+	system.CacheBreakpoint = true
+	document := NewUserMessage("A long document.")
+	document.CacheBreakpoint = true
+	tool := &Tool{Title: "search", Description: "Searches the documents", Schema: &ltypes.ToolSchema{Type: "object"}, CacheBreakpoint: true}
+
+	response, err := llm.Completion(context.TODO(), &CompletionInput{
+		Model:        "claude-3-5-sonnet-20240620",
+		Conversation: []*Message{system, document, NewAssistantMessage("Ok."), NewUserMessage("Summarize it.")},
+		Tools:        []*Tool{tool},
+	})
+	require.NoError(t, err)
+
+	// breakpoints map to cache_control on the system block, the message content and the tool
+	body := requests.body(t)
+	ephemeral := map[string]any{"type": "ephemeral"}
+	systemBlocks := body["system"].([]any)
+	require.Len(t, systemBlocks, 1)
+	require.Equal(t, ephemeral, systemBlocks[0].(map[string]any)["cache_control"])
+	messages := body["messages"].([]any)
+	require.Len(t, messages, 3)
+	content := messages[0].(map[string]any)["content"].([]any)
+	require.Equal(t, ephemeral, content[len(content)-1].(map[string]any)["cache_control"])
+	for _, item := range messages[1:] {
+		for _, block := range item.(map[string]any)["content"].([]any) {
+			require.NotContains(t, block.(map[string]any), "cache_control")
+		}
+	}
+	require.Equal(t, ephemeral, body["tools"].([]any)[0].(map[string]any)["cache_control"])
+
+	// cached tokens are counted in the input and priced separately
+	record := response.UsageRecord
+	require.Equal(t, 4020, record.InputTokens)
+	require.Equal(t, 4025, record.TotalTokens)
+	require.Equal(t, 1000, record.CacheCreationInputTokens)
+	require.Equal(t, 3000, record.CacheReadInputTokens)
+	cost, ok := tokens.EstimateCost(record)
+	require.True(t, ok)
+	require.InDelta(t, (20*3+1000*3.75+3000*0.3+5*15)/1_000_000.0, cost, 1e-12)
 }
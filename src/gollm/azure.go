@@ -0,0 +1,184 @@
+package gollm
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/jake-landersweb/gollm/v2/src/ltypes"
+)
+
+/*
+Configures access to Azure OpenAI. Models are routed to Azure with the `azure/` prefix, such as
+`azure/gpt-4o`, and the model is mapped to a deployment through `Deployments`.
+
+Requests are authenticated with the `api-key` header, or with an Entra ID bearer token when
+`TokenProvider` is set.
+*/
+type AzureOpenAIConfig struct {
+	// Endpoint of the resource, such as `https://my-resource.openai.azure.com`.
+	// If not defined, the env variable `AZURE_OPENAI_ENDPOINT` will be used
+	Endpoint string
+
+	// Version of the api. Defaults to `2024-06-01`
+	ApiVersion string
+
+	// If not defined, the env variable `AZURE_OPENAI_API_KEY` will be used. Ignored when `TokenProvider` is set
+	ApiKey string
+
+	// Optionally authenticate with Entra ID. Called before every request, so it should cache its tokens,
+	// such as a wrapped `azidentity` credential scoped to `https://cognitiveservices.azure.com/.default`
+	TokenProvider func(ctx context.Context) (string, error)
+
+	// Maps model names to the names of their deployments. Models without an entry use the model name as the deployment
+	Deployments map[string]string
+}
+
+func (c *AzureOpenAIConfig) endpoint() (string, error) {
+	endpoint := c.Endpoint
+	if endpoint == "" {
+		endpoint = os.Getenv("AZURE_OPENAI_ENDPOINT")
+		if endpoint == "" {
+			return "", fmt.Errorf("the env variable `AZURE_OPENAI_ENDPOINT` is required to be set")
+		}
+	}
+	return strings.TrimSuffix(endpoint, "/"), nil
+}
+
+// Builds the url of an operation, such as `chat/completions`, on the deployment of the model
+func (c *AzureOpenAIConfig) deploymentUrl(model string, operation string) (string, error) {
+	endpoint, err := c.endpoint()
+	if err != nil {
+		return "", err
+	}
+	deployment, ok := c.Deployments[model]
+	if !ok {
+		deployment = model
+	}
+	version := c.ApiVersion
+	if version == "" {
+		version = azure_api_version
+	}
+	return fmt.Sprintf("%s/openai/deployments/%s/%s?api-version=%s", endpoint, url.PathEscape(deployment), operation, url.QueryEscape(version)), nil
+}
+
+// Headers that authenticate a request, along with the secret to redact from logs
+func (c *AzureOpenAIConfig) authHeaders(ctx context.Context) (map[string]string, string, error) {
+	if c.TokenProvider != nil {
+		token, err := c.TokenProvider(ctx)
+		if err != nil {
+			return nil, "", fmt.Errorf("there was an issue getting the entra token: %v", err)
+		}
+		return map[string]string{"Authorization": "Bearer " + token}, token, nil
+	}
+
+	apiKey := c.ApiKey
+	if apiKey == "" {
+		apiKey = os.Getenv("AZURE_OPENAI_API_KEY")
+		if apiKey == "" || apiKey == "null" {
+			return nil, "", fmt.Errorf("the env variable `AZURE_OPENAI_API_KEY` is required to be set")
+		}
+	}
+	return map[string]string{"api-key": apiKey}, apiKey, nil
+}
+
+// Builds a provider that sends the chat completion to the deployment of the model
+func (l *LanguageModel) azureProvider(ctx context.Context, model string) (*OpenAICompatibleProvider, error) {
+	if l.args.Azure == nil {
+		return nil, fmt.Errorf("`Azure` must be configured to use the model: %s", azure_model_prefix+model)
+	}
+	endpoint, err := l.args.Azure.deploymentUrl(model, "chat/completions")
+	if err != nil {
+		return nil, err
+	}
+	headers, secret, err := l.args.Azure.authHeaders(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &OpenAICompatibleProvider{
+		Name:                genAISystemAzureOpenAI,
+		BaseUrl:             endpoint,
+		Headers:             headers,
+		secret:              secret,
+		contentFilterErrors: true,
+	}, nil
+}
+
+/*
+Returned when Azure OpenAI blocks the prompt or the completion with its content filter.
+Use `errors.As` to inspect the categories that were filtered.
+*/
+type ContentFilterError struct {
+	// Either `prompt` or `completion`
+	Source string
+
+	// The message returned by the api, if any
+	Message string
+
+	// The results of every category, keyed by the category name, such as `hate` or `jailbreak`
+	Results map[string]*ltypes.AzureContentFilterResult
+}
+
+// The names of the categories that were filtered, sorted
+func (e *ContentFilterError) Categories() []string {
+	resp := make([]string, 0)
+	for category, result := range e.Results {
+		if result != nil && result.Filtered {
+			resp = append(resp, category)
+		}
+	}
+	slices.Sort(resp)
+	return resp
+}
+
+func (e *ContentFilterError) Error() string {
+	return fmt.Sprintf("the %s was blocked by the content filter %v: %s", e.Source, e.Categories(), e.Message)
+}
+
+// Parses a content filter error from a prompt that was rejected
+func newPromptContentFilterError(apiErr *ltypes.GPTError) *ContentFilterError {
+	resp := &ContentFilterError{
+		Source:  "prompt",
+		Message: apiErr.Message,
+	}
+	if apiErr.InnerError != nil {
+		resp.Results = apiErr.InnerError.ContentFilterResult
+	}
+	return resp
+}
+
+// Embeddings created with an embeddings model deployed on Azure OpenAI
+type AzureOpenAIEmbeddings struct {
+	*OpenAIEmbeddings
+}
+
+/*
+Creates embeddings using Azure OpenAI. `opts.Model` is mapped to a deployment through
+`config.Deployments`, and `opts.BaseUrl` and `opts.OpenAIApiKey` are ignored.
+*/
+func NewAzureOpenAIEmbeddings(userId string, config *AzureOpenAIConfig, opts *OpenAIEmbeddingsOpts) *AzureOpenAIEmbeddings {
+	if config == nil {
+		config = &AzureOpenAIConfig{}
+	}
+	e := NewOpenAIEmbeddings(userId, opts)
+	e.system = genAISystemAzureOpenAI
+	e.target = func(ctx context.Context) (*embeddingsTarget, error) {
+		endpoint, err := config.deploymentUrl(e.opts.Model, "embeddings")
+		if err != nil {
+			return nil, err
+		}
+		headers, secret, err := config.authHeaders(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return &embeddingsTarget{
+			url:     endpoint,
+			headers: headers,
+			secret:  secret,
+		}, nil
+	}
+	return &AzureOpenAIEmbeddings{OpenAIEmbeddings: e}
+}
@@ -0,0 +1,173 @@
+package gollm
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jake-landersweb/gollm/v2/src/cache"
+	"github.com/stretchr/testify/require"
+)
+
+const azure_prompt_filtered_response = `{"error": {"message": "The response was filtered due to the prompt triggering Azure OpenAI's content management policy.", "type": null, "param": "prompt", "code": "content_filter", "status": 400, "innererror": {"code": "ResponsibleAIPolicyViolation", "content_filter_result": {"hate": {"filtered": false, "severity": "safe"}, "jailbreak": {"filtered": true, "detected": true}, "violence": {"filtered": true, "severity": "high"}}}}}`
+
+const azure_completion_filtered_response = `{"id": "chatcmpl-1", "object": "chat.completion", "model": "gpt-4o", "choices": [{"index": 0, "message": {"role": "assistant", "content": null}, "finish_reason": "content_filter", "content_filter_results": {"hate": {"filtered": true, "severity": "medium"}, "sexual": {"filtered": false, "severity": "safe"}}}], "usage": {"prompt_tokens": 10, "completion_tokens": 2, "total_tokens": 12}}`
+
+func TestAzureCompletion(t *testing.T) {
+	logger := defaultLogger(slog.LevelDebug).With("test", "TestAzureCompletion")
+	server, requests := newTestServer(t, respondWith(200, compatible_test_response))
+
+	llm := NewLanguageModel(test_user_id, logger, &NewLanguageModelArgs{
+		Azure: &AzureOpenAIConfig{
+			Endpoint:    server.URL + "/",
+			ApiVersion:  "2024-10-21",
+			ApiKey:      "azure-secret-key",
+			Deployments: map[string]string{"gpt-4o": "prod-gpt4o"},
+		},
+	})
+	response, err := llm.Completion(context.TODO(), &CompletionInput{
+		Model:        "azure/gpt-4o",
+		Conversation: []*Message{NewUserMessage("Hello")},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "Hello!", response.Message.Message)
+	require.Equal(t, "azure/gpt-4o", response.UsageRecord.Model)
+
+	request := requests.last(t)
+	require.Equal(t, "/openai/deployments/prod-gpt4o/chat/completions", request.URL.Path)
+	require.Equal(t, "2024-10-21", request.URL.Query().Get("api-version"))
+	require.Equal(t, "azure-secret-key", request.Header.Get("api-key"))
+	require.Empty(t, request.Header.Get("Authorization"))
+}
+
+func TestAzureEntraToken(t *testing.T) {
+	logger := defaultLogger(slog.LevelDebug).With("test", "TestAzureEntraToken")
+	server, requests := newTestServer(t, respondWith(200, compatible_test_response))
+
+	calls := 0
+	llm := NewLanguageModel(test_user_id, logger, &NewLanguageModelArgs{
+		Azure: &AzureOpenAIConfig{
+			Endpoint: server.URL,
+			TokenProvider: func(ctx context.Context) (string, error) {
+				calls++
+				return "entra-token", nil
+			},
+		},
+	})
+	_, err := llm.Completion(context.TODO(), &CompletionInput{
+		Model:        "azure/gpt-4o-mini",
+		Conversation: []*Message{NewUserMessage("Hello")},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, calls)
+
+	// models without a deployment mapping use the model name
+	request := requests.last(t)
+	require.Equal(t, "/openai/deployments/gpt-4o-mini/chat/completions", request.URL.Path)
+	require.Equal(t, azure_api_version, request.URL.Query().Get("api-version"))
+	require.Equal(t, "Bearer entra-token", request.Header.Get("Authorization"))
+	require.Empty(t, request.Header.Get("api-key"))
+
+	// errors from the token provider are returned
+	llm = NewLanguageModel(test_user_id, logger, &NewLanguageModelArgs{
+		Azure: &AzureOpenAIConfig{
+			Endpoint: server.URL,
+			TokenProvider: func(ctx context.Context) (string, error) {
+				return "", errors.New("no credential")
+			},
+		},
+	})
+	_, err = llm.Completion(context.TODO(), &CompletionInput{
+		Model:        "azure/gpt-4o-mini",
+		Conversation: []*Message{NewUserMessage("Hello")},
+	})
+	require.ErrorContains(t, err, "no credential")
+}
+
+func TestAzureContentFilter(t *testing.T) {
+	logger := defaultLogger(slog.LevelDebug).With("test", "TestAzureContentFilter")
+
+	tests := []struct {
+		name       string
+		status     int
+		body       string
+		source     string
+		categories []string
+	}{
+		{"prompt", 400, azure_prompt_filtered_response, "prompt", []string{"jailbreak", "violence"}},
+		{"completion", 200, azure_completion_filtered_response, "completion", []string{"hate"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(test.status)
+				w.Write([]byte(test.body))
+			}))
+			defer server.Close()
+
+			llm := NewLanguageModel(test_user_id, logger, &NewLanguageModelArgs{
+				Azure: &AzureOpenAIConfig{Endpoint: server.URL, ApiKey: "azure-secret-key"},
+			})
+			_, err := llm.Completion(context.TODO(), &CompletionInput{
+				Model:        "azure/gpt-4o",
+				Conversation: []*Message{NewUserMessage("Hello")},
+			})
+
+			var filterErr *ContentFilterError
+			require.ErrorAs(t, err, &filterErr)
+			require.Equal(t, test.source, filterErr.Source)
+			require.Equal(t, test.categories, filterErr.Categories())
+		})
+	}
+}
+
+func TestAzureNotConfigured(t *testing.T) {
+	llm := NewLanguageModel(test_user_id, nil, nil)
+	_, err := llm.Completion(context.TODO(), &CompletionInput{
+		Model:        "azure/gpt-4o",
+		Conversation: []*Message{NewUserMessage("Hello")},
+	})
+	require.ErrorContains(t, err, "`Azure` must be configured")
+}
+
+func TestAzureOpenAIEmbeddings(t *testing.T) {
+	logger := defaultLogger(slog.LevelDebug)
+	server, requests := newTestServer(t, respondWith(200, `{"object": "list", "data": [{"object": "embedding", "embedding": [0.5, 0.25], "index": 0}], "model": "text-embedding-3-small", "usage": {"prompt_tokens": 4, "total_tokens": 4}}`))
+
+	embeddings := NewAzureOpenAIEmbeddings(test_user_id, &AzureOpenAIConfig{
+		Endpoint:    server.URL,
+		ApiKey:      "azure-secret-key",
+		Deployments: map[string]string{OPENAI_EMBEDDINGS_MODEL: "embeddings-small"},
+	}, nil)
+
+	response, err := embeddings.Embed(context.TODO(), logger, &EmbedArgs{Input: "Hello world"})
+	require.NoError(t, err)
+	require.Equal(t, []float32{0.5, 0.25}, response.Embeddings[0].Embedding)
+	require.Equal(t, 4, response.Usage.InputTokens)
+	require.Len(t, embeddings.GetUsageRecords(), 1)
+
+	request := requests.last(t)
+	require.Equal(t, "/openai/deployments/embeddings-small/embeddings", request.URL.Path)
+	require.Equal(t, azure_api_version, request.URL.Query().Get("api-version"))
+	require.Equal(t, "azure-secret-key", request.Header.Get("api-key"))
+
+	// the embeddings can be cached, apart from the same model on OpenAI
+	store := cache.NewMemoryCache(nil)
+	cached, err := NewCachedEmbeddings(embeddings, store, nil)
+	require.NoError(t, err)
+	_, err = cached.Embed(context.TODO(), logger, &EmbedArgs{Input: "Hello world"})
+	require.NoError(t, err)
+	response, err = cached.Embed(context.TODO(), logger, &EmbedArgs{Input: "Hello world"})
+	require.NoError(t, err)
+	require.Equal(t, []float32{0.5, 0.25}, response.Embeddings[0].Embedding)
+	require.Equal(t, OPENAI_EMBEDDINGS_MODEL, response.Usage.Model)
+	require.Len(t, requests.all(), 2)
+
+	openai, err := NewCachedEmbeddings(NewOpenAIEmbeddings(test_user_id, nil), store, nil)
+	require.NoError(t, err)
+	require.NotEqual(t, cached.key("Hello world"), openai.key("Hello world"))
+}
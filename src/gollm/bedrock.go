@@ -0,0 +1,298 @@
+package gollm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jake-landersweb/gollm/v2/src/ltypes"
+	"github.com/jake-landersweb/gollm/v2/src/metrics"
+	"go.opentelemetry.io/otel/trace"
+)
+
+/*
+Configures access to AWS Bedrock. Models are routed to Bedrock with the `bedrock/` prefix
+followed by the model id, such as `bedrock/anthropic.claude-3-haiku-20240307-v1:0`, and
+completions use the Converse api so every model family shares the same request shape.
+
+Requests are signed with SigV4 using static credentials, or the standard AWS env variables.
+*/
+type BedrockConfig struct {
+	// Region of the Bedrock runtime, such as `us-east-1`.
+	// If not defined, the env variable `AWS_REGION` and then `AWS_DEFAULT_REGION` will be used
+	Region string
+
+	// If not defined, the env variables `AWS_ACCESS_KEY_ID`, `AWS_SECRET_ACCESS_KEY` and `AWS_SESSION_TOKEN` will be used
+	AccessKeyId     string
+	SecretAccessKey string
+	SessionToken    string
+
+	// Optionally override the runtime endpoint, such as a VPC endpoint or a local stand-in server.
+	// Defaults to `https://bedrock-runtime.{region}.amazonaws.com`
+	BaseUrl string
+
+	// Maximum tokens to generate in a completion. Defaults to 4096
+	MaxTokens int
+}
+
+func (c *BedrockConfig) region() (string, error) {
+	if c.Region != "" {
+		return c.Region, nil
+	}
+	for _, key := range []string{"AWS_REGION", "AWS_DEFAULT_REGION"} {
+		if region := os.Getenv(key); region != "" {
+			return region, nil
+		}
+	}
+	return "", fmt.Errorf("the env variable `AWS_REGION` is required to be set")
+}
+
+func (c *BedrockConfig) credentials() (*awsCredentials, error) {
+	if c.AccessKeyId != "" {
+		return &awsCredentials{
+			accessKeyId:     c.AccessKeyId,
+			secretAccessKey: c.SecretAccessKey,
+			sessionToken:    c.SessionToken,
+		}, nil
+	}
+
+	creds := &awsCredentials{
+		accessKeyId:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.accessKeyId == "" || creds.secretAccessKey == "" {
+		return nil, fmt.Errorf("the env variables `AWS_ACCESS_KEY_ID` and `AWS_SECRET_ACCESS_KEY` are required to be set")
+	}
+	return creds, nil
+}
+
+// Builds the url of an operation, such as `converse` or `invoke`, on the model
+func (c *BedrockConfig) modelUrl(region string, model string, operation string) string {
+	baseUrl := c.BaseUrl
+	if baseUrl == "" {
+		baseUrl = fmt.Sprintf("https://bedrock-runtime.%s.amazonaws.com", region)
+	}
+	// model ids contain `:`, which must stay escaped for the signature to match
+	return fmt.Sprintf("%s/model/%s/%s", strings.TrimSuffix(baseUrl, "/"), awsEscape(model), operation)
+}
+
+/*
+Sends a signed request to a model on Bedrock, retrying when the api is throttled or unavailable.
+Bedrock reports the type of an error in the `x-amzn-ErrorType` header, with only a message in the body.
+Returns the headers and body of a successful response.
+*/
+func sendBedrockRequest(
+	ctx context.Context,
+	logger *slog.Logger,
+	c *bedrockClient,
+	operation string,
+	model string,
+	enc []byte,
+) (http.Header, []byte, error) {
+	region, err := c.config.region()
+	if err != nil {
+		return nil, nil, err
+	}
+	creds, err := c.config.credentials()
+	if err != nil {
+		return nil, nil, err
+	}
+	url := c.config.modelUrl(region, model, operation)
+
+	retries := 3
+	backoff := 1 * time.Second
+
+	for attempt := 0; attempt < retries; attempt++ {
+		logger.InfoContext(ctx, "Sending Bedrock request...")
+		statusCode, header, body, err := sendAttemptWithHeaders(ctx, c.tracer, c.client, genAISystemBedrock, model, attempt, func(ctx context.Context) (*http.Request, error) {
+			req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(enc))
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Accept", "application/json")
+			// sign every attempt, as the signature is only valid for a few minutes
+			signV4(req, enc, creds, region, bedrock_service, time.Now())
+			return req, nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		logger.InfoContext(ctx, "Completed request", "statusCode", statusCode)
+		logger.DebugContext(ctx, "Response body", "body", c.redactor.Body(body, creds.secretAccessKey, creds.sessionToken))
+
+		if statusCode == 200 {
+			return header, body, nil
+		}
+
+		// the header may contain extra details after a colon, such as `ThrottlingException:http://internal.amazon.com/...`
+		errType := ltypes.BedrockErrorType(strings.SplitN(header.Get("X-Amzn-ErrorType"), ":", 2)[0])
+		var apiErr ltypes.BedrockConverseResponse
+		json.Unmarshal(body, &apiErr)
+
+		logger.ErrorContext(ctx, "there was an api error", "type", errType, "message", apiErr.Message)
+
+		switch errType {
+		case ltypes.BEDROCK_ACCESS_DENIED_EXCEPTION, ltypes.BEDROCK_UNRECOGNIZED_CLIENT_EXCEPTION, ltypes.BEDROCK_EXPIRED_TOKEN_EXCEPTION:
+			return nil, nil, fmt.Errorf("there was an issue authenticating: %s", apiErr.Message)
+		case ltypes.BEDROCK_VALIDATION_EXCEPTION:
+			return nil, nil, fmt.Errorf("there was a validation error: %s", apiErr.Message)
+		case ltypes.BEDROCK_RESOURCE_NOT_FOUND_EXCEPTION:
+			return nil, nil, fmt.Errorf("the model was not found, access may need to be requested first: %s", apiErr.Message)
+		case ltypes.BEDROCK_THROTTLING_EXCEPTION:
+			logger.WarnContext(ctx, "Rate limit hit, waiting 2 seconds then trying again ...")
+			if err := sleepContext(ctx, time.Second*2); err != nil {
+				return nil, nil, err
+			}
+		case ltypes.BEDROCK_MODEL_NOT_READY_EXCEPTION, ltypes.BEDROCK_MODEL_TIMEOUT_EXCEPTION, ltypes.BEDROCK_SERVICE_UNAVAILABLE_EXCEPTION, ltypes.BEDROCK_INTERNAL_SERVER_EXCEPTION:
+			logger.WarnContext(ctx, "The model is unavailable, waiting 2 seconds then trying again ...")
+			if err := sleepContext(ctx, time.Second*2); err != nil {
+				return nil, nil, err
+			}
+		default:
+			return nil, nil, fmt.Errorf("there was an unknown issue with the request: [%d] [%s]: %s", statusCode, errType, apiErr.Message)
+		}
+
+		recordRetryableError(ctx, c.metrics, c.operation, genAISystemBedrock, model, attempt, string(errType))
+
+		if attempt < retries-1 {
+			sleep := backoff + time.Duration(rand.Intn(1000))*time.Millisecond // Add jitter
+			if err := sleepContext(ctx, sleep); err != nil {
+				return nil, nil, err
+			}
+			backoff *= 2 // Double the backoff interval
+		} else {
+			return nil, nil, fmt.Errorf("there was an issue with the request and could not recover: %s", c.redactor.Body(body, creds.secretAccessKey, creds.sessionToken))
+		}
+	}
+
+	return nil, nil, err
+}
+
+func (l *LanguageModel) bedrockCompletion(
+	ctx context.Context,
+	logger *slog.Logger,
+	model string,
+	temperature float64,
+	jsonMode bool,
+	jsonSchema string,
+	messages []*ltypes.BedrockMessage,
+	tools []*ltypes.BedrockTool,
+	prohibitTool bool,
+	toolChoice string,
+) (*ltypes.BedrockConverseResponse, error) {
+	if l.args.Bedrock == nil {
+		return nil, fmt.Errorf("`Bedrock` must be configured to use the model: %s", bedrock_model_prefix+model)
+	}
+
+	// compose the request body. System messages are sent separately, and the remaining
+	// messages must alternate roles, so consecutive messages of the same role are merged
+	comprequest := &ltypes.BedrockConverseRequest{
+		Messages: make([]*ltypes.BedrockMessage, 0),
+		InferenceConfig: &ltypes.BedrockInferenceConfig{
+			MaxTokens:   l.bedrockMaxTokens(),
+			Temperature: temperature,
+		},
+	}
+	for _, item := range messages {
+		if item.Role == "system" {
+			comprequest.System = append(comprequest.System, item.Content...)
+			continue
+		}
+		if last := len(comprequest.Messages) - 1; last >= 0 && comprequest.Messages[last].Role == item.Role {
+			comprequest.Messages[last].Content = append(comprequest.Messages[last].Content, item.Content...)
+			continue
+		}
+		comprequest.Messages = append(comprequest.Messages, &ltypes.BedrockMessage{
+			Role:    item.Role,
+			Content: append([]*ltypes.BedrockContentBlock{}, item.Content...),
+		})
+	}
+
+	// the tools are always sent, as converse rejects tool blocks in the conversation without them
+	if len(tools) != 0 {
+		comprequest.ToolConfig = &ltypes.BedrockToolConfig{Tools: tools}
+		if !prohibitTool && toolChoice != "" {
+			comprequest.ToolConfig.ToolChoice = &ltypes.BedrockToolChoice{
+				Tool: &ltypes.BedrockToolChoiceTool{Name: toolChoice},
+			}
+		}
+	}
+
+	// parse for json mode
+	if jsonMode {
+		if jsonSchema == "" {
+			return nil, fmt.Errorf("please provide a valid json schema")
+		}
+		logger.DebugContext(ctx, "Running with json mode ENABLED")
+
+		// add json instructions onto the end of the request
+		last := comprequest.Messages[len(comprequest.Messages)-1]
+		last.Content = append(last.Content, &ltypes.BedrockContentBlock{
+			Text: fmt.Sprintf("Please respond to this message ONLY with the given json schema. This schema should be parsed as valid json, and shall NOT contain backticks (`).\n\nJSON SCHEMA:\n%s", jsonSchema),
+		})
+	} else {
+		logger.DebugContext(ctx, "Running with json mode DISABLED")
+	}
+
+	// parse and encode the body
+	enc, err := json.Marshal(comprequest)
+	if err != nil {
+		return nil, fmt.Errorf("there was an issue encoding the body: %v", err)
+	}
+
+	logger.DebugContext(ctx, "Request body", "body", l.redactor.Body(enc))
+
+	_, body, err := sendBedrockRequest(ctx, logger, &bedrockClient{
+		config:    l.args.Bedrock,
+		client:    l.args.HttpClient,
+		tracer:    l.tracer,
+		metrics:   l.metrics,
+		redactor:  l.redactor,
+		operation: metrics.OperationCompletion,
+	}, "converse", model, enc)
+	if err != nil {
+		return nil, err
+	}
+
+	var response ltypes.BedrockConverseResponse
+	if err = json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("there was an issue parsing the response body: %v", err)
+	}
+	if response.Output == nil || response.Output.Message == nil || response.Usage == nil {
+		return nil, fmt.Errorf("the response did not contain a message: %s", l.redactor.Body(body))
+	}
+	return &response, nil
+}
+
+// The shared dependencies of the requests sent to Bedrock by completions and embeddings
+type bedrockClient struct {
+	config    *BedrockConfig
+	client    *http.Client
+	tracer    trace.Tracer
+	metrics   metrics.Metrics
+	redactor  *redactor
+	operation string
+}
+
+// Bedrock hosts many model families with different tokenizers, so use the same approximation as gpt
+func bedrockTokenizerApproximate(input string) (int, error) {
+	return gptTokenizerApproximate("avg", input)
+}
+
+// Returns the max tokens sent to Bedrock, which requires one
+func (l *LanguageModel) bedrockMaxTokens() int {
+	if l.args.Bedrock == nil || l.args.Bedrock.MaxTokens == 0 {
+		return bedrock_max_tokens
+	}
+	return l.args.Bedrock.MaxTokens
+}
@@ -0,0 +1,239 @@
+package gollm
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jake-landersweb/gollm/v2/src/ltypes"
+	"github.com/stretchr/testify/require"
+)
+
+const bedrock_test_model = "anthropic.claude-3-haiku-20240307-v1:0"
+
+const bedrock_test_response = `{"output": {"message": {"role": "assistant", "content": [{"text": "Hello!"}]}}, "stopReason": "end_turn", "usage": {"inputTokens": 10, "outputTokens": 2, "totalTokens": 12}, "metrics": {"latencyMs": 120}}`
+
+const bedrock_tool_response = `{"output": {"message": {"role": "assistant", "content": [{"text": "Let me check the weather."}, {"toolUse": {"toolUseId": "tooluse_1", "name": "get_weather", "input": {"city": "Seattle"}}}]}}, "stopReason": "tool_use", "usage": {"inputTokens": 40, "outputTokens": 12, "totalTokens": 52}}`
+
+// Returns the last request received by a test server standing in for Bedrock, with its decoded body
+func lastBedrockRequest(t *testing.T, requests *testRequests) (*testRequest, *ltypes.BedrockConverseRequest) {
+	t.Helper()
+	request := requests.last(t)
+	var body ltypes.BedrockConverseRequest
+	require.NoError(t, json.Unmarshal(request.Body, &body))
+	return request, &body
+}
+
+func bedrockTestConfig(baseUrl string) *BedrockConfig {
+	return &BedrockConfig{
+		Region:          "us-west-2",
+		AccessKeyId:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		BaseUrl:         baseUrl,
+	}
+}
+
+func TestBedrockCompletion(t *testing.T) {
+	logger := defaultLogger(slog.LevelDebug).With("test", "TestBedrockCompletion")
+	server, requests := newTestServer(t, func(w http.ResponseWriter) {
+		w.Write([]byte(bedrock_test_response))
+	})
+
+	llm := NewLanguageModel(test_user_id, logger, &NewLanguageModelArgs{Bedrock: bedrockTestConfig(server.URL)})
+	response, err := llm.Completion(context.TODO(), &CompletionInput{
+		Model: bedrock_model_prefix + bedrock_test_model,
+		Conversation: []*Message{
+			NewSystemMessage("You are a helpful assistant."),
+			NewUserMessage("Hello"),
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "Hello!", response.Message.Message)
+	require.Equal(t, "end_turn", response.StopReason)
+	require.Equal(t, bedrock_model_prefix+bedrock_test_model, response.UsageRecord.Model)
+	require.Equal(t, 10, response.UsageRecord.InputTokens)
+	require.Equal(t, 2, response.UsageRecord.OutputTokens)
+	require.Equal(t, 12, response.UsageRecord.TotalTokens)
+
+	r, body := lastBedrockRequest(t, requests)
+	require.Equal(t, "/model/anthropic.claude-3-haiku-20240307-v1%3A0/converse", r.URL.EscapedPath())
+	require.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/"))
+	require.Contains(t, r.Header.Get("Authorization"), "/us-west-2/bedrock/aws4_request")
+	require.Contains(t, r.Header.Get("Authorization"), "SignedHeaders=content-type;host;x-amz-date")
+	require.NotEmpty(t, r.Header.Get("X-Amz-Date"))
+
+	// the system message is sent separately
+	require.Len(t, body.System, 1)
+	require.Equal(t, "You are a helpful assistant.", body.System[0].Text)
+	require.Len(t, body.Messages, 1)
+	require.Equal(t, "user", body.Messages[0].Role)
+	require.Equal(t, "Hello", body.Messages[0].Content[0].Text)
+	require.Equal(t, bedrock_max_tokens, body.InferenceConfig.MaxTokens)
+	require.Nil(t, body.ToolConfig)
+}
+
+func TestBedrockTools(t *testing.T) {
+	logger := defaultLogger(slog.LevelDebug).With("test", "TestBedrockTools")
+	tool := &Tool{
+		Title:       "get_weather",
+		Description: "Get the current weather of a city",
+		Schema: &ltypes.ToolSchema{
+			Type:       "object",
+			Properties: map[string]*ltypes.ToolSchema{"city": {Type: "string"}},
+			Required:   []string{"city"},
+		},
+	}
+
+	server, requests := newTestServer(t, func(w http.ResponseWriter) {
+		w.Write([]byte(bedrock_tool_response))
+	})
+	llm := NewLanguageModel(test_user_id, logger, &NewLanguageModelArgs{Bedrock: bedrockTestConfig(server.URL)})
+
+	// the model calls the tool
+	conversation := []*Message{NewUserMessage("What is the weather in Seattle?")}
+	response, err := llm.Completion(context.TODO(), &CompletionInput{
+		Model:        bedrock_model_prefix + bedrock_test_model,
+		Conversation: conversation,
+		Tools:        []*Tool{tool},
+		RequiredTool: tool,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "tool_use", response.StopReason)
+	require.Equal(t, RoleToolCall, response.Message.Role)
+	require.Equal(t, "tooluse_1", response.Message.ToolUseID)
+	require.Equal(t, "get_weather", response.Message.ToolName)
+	require.Equal(t, map[string]any{"city": "Seattle"}, response.Message.ToolArguments)
+
+	_, last := lastBedrockRequest(t, requests)
+	require.Len(t, last.ToolConfig.Tools, 1)
+	require.Equal(t, "get_weather", last.ToolConfig.Tools[0].ToolSpec.Name)
+	require.Equal(t, "object", last.ToolConfig.Tools[0].ToolSpec.InputSchema.Json.Type)
+	require.Equal(t, "get_weather", last.ToolConfig.ToolChoice.Tool.Name)
+
+	// the tool result is sent back as a user message
+	conversation = append(conversation, response.Message, NewToolResultMessage("tooluse_1", "get_weather", "72 and sunny"), NewUserMessage("Thanks!"))
+	_, err = llm.Completion(context.TODO(), &CompletionInput{
+		Model:        bedrock_model_prefix + bedrock_test_model,
+		Conversation: conversation,
+		Tools:        []*Tool{tool},
+		ProhibitTool: true,
+	})
+	require.NoError(t, err)
+	_, last = lastBedrockRequest(t, requests)
+	require.Nil(t, last.ToolConfig.ToolChoice)
+	require.Len(t, last.Messages, 3)
+
+	call := last.Messages[1]
+	require.Equal(t, "assistant", call.Role)
+	require.Equal(t, "Let me check the weather.", call.Content[0].Text)
+	require.Equal(t, "tooluse_1", call.Content[1].ToolUse.ToolUseId)
+
+	// consecutive user messages are merged, as converse requires alternating roles
+	result := last.Messages[2]
+	require.Equal(t, "user", result.Role)
+	require.Len(t, result.Content, 2)
+	require.Equal(t, "tooluse_1", result.Content[0].ToolResult.ToolUseId)
+	require.Equal(t, "72 and sunny", result.Content[0].ToolResult.Content[0].Text)
+	require.Equal(t, "Thanks!", result.Content[1].Text)
+}
+
+func TestBedrockErrors(t *testing.T) {
+	logger := defaultLogger(slog.LevelDebug).With("test", "TestBedrockErrors")
+	server, _ := newTestServer(t, func(w http.ResponseWriter) {
+		w.Header().Set("X-Amzn-ErrorType", "ValidationException:http://internal.amazon.com/coral/com.amazon.bedrock/")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"message": "The provided model identifier is invalid."}`))
+	})
+
+	llm := NewLanguageModel(test_user_id, logger, &NewLanguageModelArgs{Bedrock: bedrockTestConfig(server.URL)})
+	_, err := llm.Completion(context.TODO(), &CompletionInput{
+		Model:        bedrock_model_prefix + "unknown-model",
+		Conversation: []*Message{NewUserMessage("Hello")},
+	})
+	require.ErrorContains(t, err, "The provided model identifier is invalid.")
+
+	// bedrock must be configured
+	llm = NewLanguageModel(test_user_id, logger, nil)
+	_, err = llm.Completion(context.TODO(), &CompletionInput{
+		Model:        bedrock_model_prefix + bedrock_test_model,
+		Conversation: []*Message{NewUserMessage("Hello")},
+	})
+	require.ErrorContains(t, err, "`Bedrock` must be configured")
+}
+
+func TestBedrockEnvCredentials(t *testing.T) {
+	logger := defaultLogger(slog.LevelDebug).With("test", "TestBedrockEnvCredentials")
+	server, requests := newTestServer(t, func(w http.ResponseWriter) {
+		w.Write([]byte(bedrock_test_response))
+	})
+
+	t.Setenv("AWS_REGION", "eu-central-1")
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDENV")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "env-secret")
+	t.Setenv("AWS_SESSION_TOKEN", "session-token")
+
+	llm := NewLanguageModel(test_user_id, logger, &NewLanguageModelArgs{Bedrock: &BedrockConfig{BaseUrl: server.URL}})
+	_, err := llm.Completion(context.TODO(), &CompletionInput{
+		Model:        bedrock_model_prefix + bedrock_test_model,
+		Conversation: []*Message{NewUserMessage("Hello")},
+	})
+	require.NoError(t, err)
+
+	header := requests.header(t)
+	require.Contains(t, header.Get("Authorization"), "Credential=AKIDENV/")
+	require.Contains(t, header.Get("Authorization"), "/eu-central-1/bedrock/aws4_request")
+	require.Contains(t, header.Get("Authorization"), "x-amz-security-token")
+	require.Equal(t, "session-token", header.Get("X-Amz-Security-Token"))
+}
+
+func TestBedrockEmbeddings(t *testing.T) {
+	logger := defaultLogger(slog.LevelDebug)
+
+	t.Run("titan", func(t *testing.T) {
+		server, requests := newTestServer(t, func(w http.ResponseWriter) {
+			w.Write([]byte(`{"embedding": [0.5, 0.25], "inputTextTokenCount": 3}`))
+		})
+
+		embeddings := NewBedrockEmbeddings(bedrockTestConfig(server.URL), &BedrockEmbeddingsOpts{EmbeddingsDimentions: 256})
+		response, err := embeddings.Embed(context.TODO(), logger, &EmbedArgs{InputChunks: []string{"Hello", "World"}})
+		require.NoError(t, err)
+		require.Len(t, requests.all(), 2)
+		for _, item := range requests.all() {
+			require.Equal(t, "/model/amazon.titan-embed-text-v2%3A0/invoke", item.URL.EscapedPath())
+			require.Contains(t, item.Header.Get("Authorization"), "/us-west-2/bedrock/aws4_request")
+			var body ltypes.BedrockTitanEmbeddingRequest
+			require.NoError(t, json.Unmarshal(item.Body, &body))
+			require.Equal(t, 256, body.Dimensions)
+		}
+		require.Len(t, response.Embeddings, 2)
+		require.Equal(t, []float32{0.5, 0.25}, response.Embeddings[1].Embedding)
+		require.Equal(t, 6, response.Usage.InputTokens)
+		require.Len(t, embeddings.GetUsageRecords(), 1)
+	})
+
+	t.Run("cohere", func(t *testing.T) {
+		server, requests := newTestServer(t, func(w http.ResponseWriter) {
+			w.Header().Set("X-Amzn-Bedrock-Input-Token-Count", "4")
+			w.Write([]byte(`{"id": "1", "embeddings": [[0.5], [0.25]], "texts": ["Hello", "World"], "response_type": "embeddings_floats"}`))
+		})
+
+		embeddings := NewBedrockEmbeddings(bedrockTestConfig(server.URL), &BedrockEmbeddingsOpts{
+			Model:           BEDROCK_COHERE_EMBEDDINGS_MODEL,
+			CohereInputType: "search_query",
+		})
+		response, err := embeddings.Embed(context.TODO(), logger, &EmbedArgs{InputChunks: []string{"Hello", "World"}})
+		require.NoError(t, err)
+		require.Equal(t, []float32{0.25}, response.Embeddings[1].Embedding)
+		require.Equal(t, 4, response.Usage.InputTokens)
+
+		request := requests.last(t)
+		require.Equal(t, "/model/cohere.embed-english-v3/invoke", request.URL.EscapedPath())
+		var body ltypes.BedrockCohereEmbeddingRequest
+		require.NoError(t, json.Unmarshal(request.Body, &body))
+		require.Equal(t, []string{"Hello", "World"}, body.Texts)
+		require.Equal(t, "search_query", body.InputType)
+	})
+}
@@ -0,0 +1,166 @@
+package gollm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jake-landersweb/gollm/v2/src/ltypes"
+	"github.com/jake-landersweb/gollm/v2/src/metrics"
+)
+
+func (l *LanguageModel) cohereCompletion(
+	ctx context.Context,
+	logger *slog.Logger,
+	model string,
+	temperature float64,
+	jsonMode bool,
+	jsonSchema string,
+	messages []*ltypes.CohereMessage,
+	tools []*ltypes.CohereTool,
+	prohibitTool bool,
+	toolChoice string,
+) (*ltypes.CohereChatResponse, error) {
+	apiKey := l.args.CohereApiKey
+	if apiKey == "" {
+		apiKey = os.Getenv("CO_API_KEY")
+		if apiKey == "" || apiKey == "null" {
+			return nil, fmt.Errorf("the environment variable `CO_API_KEY` is required")
+		}
+	}
+
+	// create the body
+	comprequest := ltypes.CohereChatRequest{
+		Model:       strings.TrimPrefix(model, cohere_model_prefix),
+		Messages:    messages,
+		Temperature: temperature,
+		MaxTokens:   l.args.CohereMaxTokens,
+	}
+
+	// cohere can only require that some tool is called, so requiring a tool only sends that tool to the model
+	if len(tools) != 0 {
+		comprequest.Tools = tools
+		if prohibitTool {
+			comprequest.ToolChoice = "NONE"
+		} else if toolChoice != "" {
+			comprequest.Tools = nil
+			for _, item := range tools {
+				if item.Function.Name == toolChoice {
+					comprequest.Tools = []*ltypes.CohereTool{item}
+				}
+			}
+			if comprequest.Tools == nil {
+				return nil, fmt.Errorf("the required tool was not found in the tool list: %s", toolChoice)
+			}
+			comprequest.ToolChoice = "REQUIRED"
+		}
+	}
+
+	if jsonMode {
+		if jsonSchema == "" {
+			return nil, fmt.Errorf("please provide a valid json schema for the model to follow")
+		}
+		logger.DebugContext(ctx, "Running with json mode ENABLED")
+
+		// the schema is a description rather than a json schema, so it is added to the prompt
+		comprequest.ResponseFormat = &ltypes.CohereResponseFormat{Type: "json_object"}
+		comprequest.Messages[len(comprequest.Messages)-1].Content = fmt.Sprintf("%s\n\nPlease respond to this message ONLY with the given JSON schema.\n\nJSON SCHEMA:\n%s", comprequest.Messages[len(comprequest.Messages)-1].Content, jsonSchema)
+	} else {
+		logger.DebugContext(ctx, "Running with json mode DISABLED")
+	}
+
+	enc, err := json.Marshal(&comprequest)
+	if err != nil {
+		return nil, fmt.Errorf("there was an issue encoding the body into json: %v", err)
+	}
+
+	logger.DebugContext(ctx, "Request body", "body", l.redactor.Body(enc, apiKey))
+
+	// send the request
+	client := l.args.HttpClient
+
+	retries := 3
+	backoff := 1 * time.Second
+
+	for attempt := 0; attempt < retries; attempt++ {
+		logger.InfoContext(ctx, "Sending Cohere request...")
+		statusCode, body, err := sendAttempt(ctx, l.tracer, client, genAISystemCohere, model, attempt, func(ctx context.Context) (*http.Request, error) {
+			req, err := http.NewRequestWithContext(ctx, "POST", l.args.CohereBaseUrl, bytes.NewBuffer(enc))
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Accept", "application/json")
+			req.Header.Set("Authorization", "Bearer "+apiKey)
+			return req, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		logger.InfoContext(ctx, "Completed request", "statusCode", statusCode)
+		logger.DebugContext(ctx, "Response body", "body", l.redactor.Body(body, apiKey))
+
+		if statusCode == 200 {
+			var completion ltypes.CohereChatResponse
+			if err = json.Unmarshal(body, &completion); err != nil {
+				return nil, fmt.Errorf("there was an issue unmarshalling the request body: %v", err)
+			}
+			if completion.Message == nil || completion.Usage == nil {
+				return nil, fmt.Errorf("the response did not contain a message: %s", l.redactor.Body(body, apiKey))
+			}
+			return &completion, nil
+		}
+
+		// cohere only returns an error message, so act based on the status code
+		var apiErr ltypes.CohereError
+		json.Unmarshal(body, &apiErr)
+
+		switch {
+		case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden || statusCode == 498:
+			return nil, fmt.Errorf("there was an issue authenticating: %s", apiErr.Message)
+		case statusCode == http.StatusBadRequest || statusCode == http.StatusUnprocessableEntity:
+			return nil, fmt.Errorf("there was a validation error: %s", apiErr.Message)
+		case statusCode == http.StatusNotFound:
+			return nil, fmt.Errorf("the model was not found: %s", apiErr.Message)
+		case statusCode == http.StatusTooManyRequests:
+			logger.WarnContext(ctx, "Rate limit hit, waiting 2 seconds then trying again ...")
+			if err := sleepContext(ctx, time.Second*2); err != nil {
+				return nil, err
+			}
+		case statusCode >= 500:
+			logger.WarnContext(ctx, "There was a server error, waiting 2 seconds then trying again ...")
+			if err := sleepContext(ctx, time.Second*2); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("there was an unknown error: [%d]: %s", statusCode, apiErr.Message)
+		}
+
+		recordRetryableError(ctx, l.metrics, metrics.OperationCompletion, genAISystemCohere, model, attempt, http.StatusText(statusCode))
+
+		if attempt < retries-1 {
+			sleep := backoff + time.Duration(rand.Intn(1000))*time.Millisecond // Add jitter
+			if err := sleepContext(ctx, sleep); err != nil {
+				return nil, err
+			}
+			backoff *= 2 // Double the backoff interval
+		} else {
+			return nil, fmt.Errorf("there was an issue with the request and could not recover: %s", l.redactor.Body(body, apiKey))
+		}
+	}
+
+	return nil, err
+}
+
+// Cohere does not publish a tokenizer for go, so use the same approximation as gpt
+func cohereTokenizerApproximate(input string) (int, error) {
+	return gptTokenizerApproximate("avg", input)
+}
@@ -0,0 +1,100 @@
+package gollm
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const cohere_test_response = `{"id": "c14c80c3", "finish_reason": "COMPLETE", "message": {"role": "assistant", "content": [{"type": "text", "text": "Hello!"}]}, "usage": {"billed_units": {"input_tokens": 5, "output_tokens": 2}, "tokens": {"input_tokens": 71, "output_tokens": 2}}}`
+
+const cohere_tool_response = `{"id": "d28b1e4a", "finish_reason": "TOOL_CALL", "message": {"role": "assistant", "tool_plan": "I will look up the weather in Paris.", "tool_calls": [{"id": "get_weather_1byjy32y4hvq", "type": "function", "function": {"name": "get_weather", "arguments": "{\"city\":\"Paris\"}"}}]}, "usage": {"billed_units": {"input_tokens": 37, "output_tokens": 21}, "tokens": {"input_tokens": 913, "output_tokens": 54}}}`
+
+func TestCohereCompletion(t *testing.T) {
+	logger := defaultLogger(slog.LevelDebug).With("test", "TestCohereCompletion")
+	server, requests := newTestServer(t, respondWith(200, cohere_test_response))
+
+	llm := NewLanguageModel(test_user_id, logger, &NewLanguageModelArgs{CohereBaseUrl: server.URL, CohereApiKey: "cohere-secret-key"})
+	response, err := llm.Completion(context.TODO(), &CompletionInput{
+		Model:        "cohere/command-r-plus",
+		Json:         true,
+		JsonSchema:   `{"message": string}`,
+		Conversation: []*Message{NewSystemMessage("You are a helpful assistant."), NewUserMessage("Hello")},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "Hello!", response.Message.Message)
+	require.Equal(t, "COMPLETE", response.StopReason)
+
+	// the billed units are used for the usage
+	require.Equal(t, "cohere/command-r-plus", response.UsageRecord.Model)
+	require.Equal(t, 5, response.UsageRecord.InputTokens)
+	require.Equal(t, 2, response.UsageRecord.OutputTokens)
+	require.Equal(t, 7, response.UsageRecord.TotalTokens)
+
+	require.Equal(t, "Bearer cohere-secret-key", requests.header(t).Get("Authorization"))
+	require.Equal(t, "command-r-plus", requests.body(t)["model"])
+	require.Equal(t, map[string]any{"type": "json_object"}, requests.body(t)["response_format"])
+	messages := requests.body(t)["messages"].([]any)
+	require.Equal(t, "system", messages[0].(map[string]any)["role"])
+	require.Contains(t, messages[1].(map[string]any)["content"], `{"message": string}`)
+}
+
+func TestCohereTools(t *testing.T) {
+	logger := defaultLogger(slog.LevelDebug).With("test", "TestCohereTools")
+	server, requests := newTestServer(t, respondWith(200, cohere_tool_response))
+	llm := NewLanguageModel(test_user_id, logger, &NewLanguageModelArgs{CohereBaseUrl: server.URL, CohereApiKey: "cohere-secret-key"})
+
+	otherTool := &Tool{Title: "get_time", Description: "Get the current time"}
+	response, err := llm.Completion(context.TODO(), &CompletionInput{
+		Model:        "cohere/command-r-plus",
+		Conversation: []*Message{NewUserMessage("What is the weather in Paris?")},
+		Tools:        []*Tool{otherTool, test_weather_tool},
+		RequiredTool: test_weather_tool,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "TOOL_CALL", response.StopReason)
+	require.Equal(t, RoleToolCall, response.Message.Role)
+	require.Equal(t, "get_weather_1byjy32y4hvq", response.Message.ToolUseID)
+	require.Equal(t, "I will look up the weather in Paris.", response.Message.Message)
+	require.Equal(t, map[string]any{"city": "Paris"}, response.Message.ToolArguments)
+
+	// only the required tool is sent
+	require.Equal(t, "REQUIRED", requests.body(t)["tool_choice"])
+	require.Len(t, requests.body(t)["tools"], 1)
+
+	// the tool call and result are sent back
+	_, err = llm.Completion(context.TODO(), &CompletionInput{
+		Model:        "cohere/command-r-plus",
+		Conversation: []*Message{NewUserMessage("What is the weather in Paris?"), response.Message, NewToolResultMessage(response.Message.ToolUseID, "get_weather", "Sunny")},
+		Tools:        []*Tool{otherTool, test_weather_tool},
+		ProhibitTool: true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "NONE", requests.body(t)["tool_choice"])
+	require.Len(t, requests.body(t)["tools"], 2)
+
+	messages := requests.body(t)["messages"].([]any)
+	call := messages[1].(map[string]any)
+	require.Equal(t, "assistant", call["role"])
+	require.Equal(t, "I will look up the weather in Paris.", call["tool_plan"])
+	require.NotContains(t, call, "content")
+	require.Equal(t, `{"city":"Paris"}`, call["tool_calls"].([]any)[0].(map[string]any)["function"].(map[string]any)["arguments"])
+	result := messages[2].(map[string]any)
+	require.Equal(t, "tool", result["role"])
+	require.Equal(t, "get_weather_1byjy32y4hvq", result["tool_call_id"])
+	require.Equal(t, "Sunny", result["content"])
+}
+
+func TestCohereErrors(t *testing.T) {
+	logger := defaultLogger(slog.LevelDebug).With("test", "TestCohereErrors")
+	server, _ := newTestServer(t, respondWith(400, `{"id": "1", "message": "invalid request: model 'command-x' not found"}`))
+
+	llm := NewLanguageModel(test_user_id, logger, &NewLanguageModelArgs{CohereBaseUrl: server.URL, CohereApiKey: "cohere-secret-key"})
+	_, err := llm.Completion(context.TODO(), &CompletionInput{
+		Model:        "cohere/command-x",
+		Conversation: []*Message{NewUserMessage("Hello")},
+	})
+	require.ErrorContains(t, err, "model 'command-x' not found")
+}
@@ -0,0 +1,187 @@
+package gollm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jake-landersweb/gollm/v2/src/cache"
+	"github.com/jake-landersweb/gollm/v2/src/metrics"
+	"github.com/jake-landersweb/gollm/v2/src/tokens"
+	"go.opentelemetry.io/otel/trace"
+)
+
+/*
+Caches completions so identical requests, such as the prompts of evals and batch jobs, are only
+paid for once. Responses are keyed by the model, temperature, json schema, max tokens, tools and the
+conversation as it is sent to the provider, so inputs that only differ in ways the provider does
+not see share an entry.
+*/
+type CompletionCacheOpts struct {
+	// Stores the responses, such as a `cache.MemoryCache`, `cache.RedisCache` or `cache.SQLCache`. Required
+	Store cache.Cache
+
+	// How long responses are cached. Defaults to 0, which caches them until they are evicted
+	TTL time.Duration
+
+	// Responses larger than this many bytes when encoded are not cached. Defaults to 0, which caches
+	// every response
+	MaxResponseBytes int
+
+	// Optionally separate the entries of applications that share a store
+	Namespace string
+}
+
+// The parts of a response that are cached
+type cachedCompletion struct {
+	Model      string   `json:"model"`
+	StopReason string   `json:"stop_reason"`
+	Message    *Message `json:"message"`
+}
+
+// Everything that changes the response of a completion, in the format sent to the provider
+type completionCacheKey struct {
+	Namespace    string  `json:"namespace"`
+	Model        string  `json:"model"`
+	Temperature  float64 `json:"temperature"`
+	Json         bool    `json:"json"`
+	JsonSchema   string  `json:"json_schema"`
+	MaxTokens    int     `json:"max_tokens"`
+	Messages     any     `json:"messages"`
+	Tools        any     `json:"tools"`
+	RequiredTool string  `json:"required_tool"`
+	ProhibitTool bool    `json:"prohibit_tool"`
+}
+
+// Returns the options of the cache, or nil when completions are not cached for the input
+func (l *LanguageModel) completionCache(input *CompletionInput) *CompletionCacheOpts {
+	if l.args.Cache == nil || l.args.Cache.Store == nil || input.SkipCache {
+		return nil
+	}
+	return l.args.Cache
+}
+
+// The parts of a request that depend on the provider the model routes to
+type providerRequest struct {
+	// The provider, as reported in traces and metrics. Empty when the model does not route to one
+	system string
+
+	// The conversation and tools in the format of the provider
+	messages any
+	tools    any
+
+	// The max tokens configured for the provider, or 0 when the model default is used
+	maxTokens int
+}
+
+// Converts the request to the format of the provider the model routes to, in the order `Completion` routes models
+func (l *LanguageModel) providerRequest(input *CompletionInput, conversation []*Message) *providerRequest {
+	model := input.Model
+	if compatible, _ := l.openAICompatibleProvider(model); compatible != nil {
+		return &providerRequest{compatible.Name, MessagesToOpenAI(conversation), ToolsToOpenAI(input.Tools), l.args.GptMaxTokens}
+	} else if strings.HasPrefix(model, azure_model_prefix) {
+		return &providerRequest{genAISystemAzureOpenAI, MessagesToOpenAI(conversation), ToolsToOpenAI(input.Tools), l.args.GptMaxTokens}
+	} else if strings.HasPrefix(model, "gpt") {
+		return &providerRequest{genAISystemOpenAI, MessagesToOpenAI(conversation), ToolsToOpenAI(input.Tools), l.args.GptMaxTokens}
+	} else if strings.HasPrefix(model, vertex_model_prefix) {
+		return &providerRequest{genAISystemVertexAI, MessagesToGemini(conversation), ToolsToGemini(input.Tools), 0}
+	} else if strings.HasPrefix(model, "gemini") {
+		return &providerRequest{genAISystemGemini, MessagesToGemini(conversation), ToolsToGemini(input.Tools), 0}
+	} else if strings.HasPrefix(model, "claude") {
+		return &providerRequest{genAISystemAnthropic, MessagesToAnthropic(conversation), ToolsToAnthropic(input.Tools), l.args.AnthropicMaxTokens}
+	} else if strings.HasPrefix(model, ollama_model_prefix) {
+		return &providerRequest{genAISystemOllama, MessagesToOllama(conversation), ToolsToOllama(input.Tools), l.args.OllamaMaxTokens}
+	} else if strings.HasPrefix(model, bedrock_model_prefix) {
+		return &providerRequest{genAISystemBedrock, MessagesToBedrock(conversation), ToolsToBedrock(input.Tools), l.bedrockMaxTokens()}
+	} else if strings.HasPrefix(model, mistral_model_prefix) {
+		return &providerRequest{genAISystemMistral, MessagesToMistral(conversation), ToolsToMistral(input.Tools), l.args.MistralMaxTokens}
+	} else if strings.HasPrefix(model, cohere_model_prefix) {
+		return &providerRequest{genAISystemCohere, MessagesToCohere(conversation), ToolsToCohere(input.Tools), l.args.CohereMaxTokens}
+	}
+	return &providerRequest{messages: conversation, tools: input.Tools}
+}
+
+// Builds the cache key of the completion from a canonical encoding of the request
+func (l *LanguageModel) completionCacheKey(opts *CompletionCacheOpts, input *CompletionInput, conversation []*Message) (string, error) {
+	request := l.providerRequest(input, conversation)
+	key := completionCacheKey{
+		Namespace:    opts.Namespace,
+		Model:        input.Model,
+		Temperature:  input.Temperature,
+		Json:         input.Json,
+		JsonSchema:   input.JsonSchema,
+		MaxTokens:    request.maxTokens,
+		Messages:     request.messages,
+		Tools:        request.tools,
+		ProhibitTool: input.ProhibitTool,
+	}
+	if input.RequiredTool != nil {
+		key.RequiredTool = input.RequiredTool.Title
+	}
+
+	// structs encode their fields in order and maps sort their keys, so the encoding is stable
+	enc, err := json.Marshal(&key)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode the cache key: %v", err)
+	}
+	return "completions:" + cache.Key(string(enc)), nil
+}
+
+/*
+Records a response read from a cache on the span, in the metrics as a cache hit of the provider the
+model routes to, and in the usage records
+*/
+func (l *LanguageModel) cachedResponse(span trace.Span, input *CompletionInput, start time.Time, response *CompletionResponse) *CompletionResponse {
+	span.SetAttributes(attrCacheHit.Bool(true))
+	span.SetAttributes(attrGenAIResponseFinishReasons.StringSlice([]string{response.StopReason}))
+	if system := l.providerRequest(input, nil).system; system != "" {
+		l.metrics.ObserveRequest(metrics.OperationCompletion, system, input.Model, metrics.StatusCacheHit, time.Since(start))
+	}
+	l.usageRecords = append(l.usageRecords, response.UsageRecord)
+	return response
+}
+
+// Returns the cached response of the key, or nil when it is not cached
+func (l *LanguageModel) readCompletionCache(ctx context.Context, opts *CompletionCacheOpts, key string, input *CompletionInput) *CompletionResponse {
+	value, ok, err := opts.Store.Get(ctx, key)
+	if err != nil {
+		l.logger.WarnContext(ctx, "There was an issue reading the completion cache", "error", err)
+		return nil
+	}
+	if !ok {
+		return nil
+	}
+	var cached cachedCompletion
+	if err := json.Unmarshal(value, &cached); err != nil || cached.Message == nil {
+		l.logger.WarnContext(ctx, "Ignoring an invalid completion cache entry", "error", err)
+		return nil
+	}
+	return &CompletionResponse{
+		Model:       cached.Model,
+		StopReason:  cached.StopReason,
+		Message:     cached.Message,
+		UsageRecord: tokens.NewUsageRecord(input.Model, 0, 0, 0),
+		Cached:      true,
+	}
+}
+
+func (l *LanguageModel) writeCompletionCache(ctx context.Context, opts *CompletionCacheOpts, key string, response *CompletionResponse) {
+	value, err := json.Marshal(&cachedCompletion{
+		Model:      response.Model,
+		StopReason: response.StopReason,
+		Message:    response.Message,
+	})
+	if err != nil {
+		l.logger.WarnContext(ctx, "There was an issue encoding the completion for the cache", "error", err)
+		return
+	}
+	if opts.MaxResponseBytes > 0 && len(value) > opts.MaxResponseBytes {
+		l.logger.DebugContext(ctx, "The completion is too large to cache", "bytes", len(value))
+		return
+	}
+	if err := opts.Store.Set(ctx, key, value, opts.TTL); err != nil {
+		l.logger.WarnContext(ctx, "There was an issue writing the completion cache", "error", err)
+	}
+}
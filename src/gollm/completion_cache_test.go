@@ -0,0 +1,114 @@
+package gollm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jake-landersweb/gollm/v2/src/cache"
+	"github.com/jake-landersweb/gollm/v2/src/ltypes"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompletionCache(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write([]byte(compatible_test_response))
+	}))
+	defer server.Close()
+
+	store := cache.NewMemoryCache(nil)
+	m := &recordingMetrics{}
+	llm := NewLanguageModel(test_user_id, nil, &NewLanguageModelArgs{
+		GptBaseUrl:   server.URL,
+		OpenAIApiKey: "test",
+		Cache:        &CompletionCacheOpts{Store: store},
+		Metrics:      m,
+	})
+	ctx := context.TODO()
+	input := func() *CompletionInput {
+		return &CompletionInput{
+			Model:        "gpt-4o-mini",
+			Conversation: []*Message{NewSystemMessage("Be brief."), NewUserMessage("Hi")},
+		}
+	}
+
+	response, err := llm.Completion(ctx, input())
+	require.NoError(t, err)
+	require.False(t, response.Cached)
+	require.Equal(t, 12, response.UsageRecord.TotalTokens)
+
+	// the same request is read from the cache, without usage
+	response, err = llm.Completion(ctx, input())
+	require.NoError(t, err)
+	require.True(t, response.Cached)
+	require.Equal(t, "Hello!", response.Message.Message)
+	require.Equal(t, RoleAI, response.Message.Role)
+	require.Equal(t, "stop", response.StopReason)
+	require.Equal(t, 0, response.UsageRecord.TotalTokens)
+	require.Equal(t, "gpt-4o-mini", response.UsageRecord.Model)
+	require.EqualValues(t, 1, requests.Load())
+	require.Len(t, llm.GetUsageRecords(), 2)
+
+	// cache hits are reported apart from the requests, without usage
+	require.Equal(t, []string{"completion/openai/gpt-4o-mini/success", "completion/openai/gpt-4o-mini/cache_hit"}, m.requests)
+	require.Len(t, m.usage, 1)
+
+	// anything the provider sees changes the key
+	changed := input()
+	changed.Temperature = 0.5
+	_, err = llm.Completion(ctx, changed)
+	require.NoError(t, err)
+	changed = input()
+	changed.Tools = []*Tool{{Title: "get_weather", Description: "Gets the weather", Schema: &ltypes.ToolSchema{Type: "object"}}}
+	_, err = llm.Completion(ctx, changed)
+	require.NoError(t, err)
+	changed = input()
+	changed.Conversation[0].Message = "Be verbose."
+	_, err = llm.Completion(ctx, changed)
+	require.NoError(t, err)
+	require.EqualValues(t, 4, requests.Load())
+	require.Equal(t, 4, store.Len())
+
+	// skipped inputs are neither read nor written, and refreshed inputs are written but not read
+	skipped := input()
+	skipped.SkipCache = true
+	response, err = llm.Completion(ctx, skipped)
+	require.NoError(t, err)
+	require.False(t, response.Cached)
+	refreshed := input()
+	refreshed.RefreshCache = true
+	response, err = llm.Completion(ctx, refreshed)
+	require.NoError(t, err)
+	require.False(t, response.Cached)
+	require.EqualValues(t, 6, requests.Load())
+	require.Equal(t, 4, store.Len())
+
+	// a different max tokens can truncate the response differently, so it changes the key
+	limited := NewLanguageModel(test_user_id, nil, &NewLanguageModelArgs{
+		GptBaseUrl:   server.URL,
+		GptMaxTokens: 16,
+		OpenAIApiKey: "test",
+		Cache:        &CompletionCacheOpts{Store: store},
+	})
+	response, err = limited.Completion(ctx, input())
+	require.NoError(t, err)
+	require.False(t, response.Cached)
+	require.EqualValues(t, 7, requests.Load())
+	require.Equal(t, 5, store.Len())
+
+	// responses over the size limit are not cached
+	llm = NewLanguageModel(test_user_id, nil, &NewLanguageModelArgs{
+		GptBaseUrl:   server.URL,
+		OpenAIApiKey: "test",
+		Cache:        &CompletionCacheOpts{Store: cache.NewMemoryCache(nil), MaxResponseBytes: 10},
+	})
+	_, err = llm.Completion(ctx, input())
+	require.NoError(t, err)
+	response, err = llm.Completion(ctx, input())
+	require.NoError(t, err)
+	require.False(t, response.Cached)
+}
@@ -9,6 +9,11 @@ const gpt_max_tokens = 8096
 const gemini_base_url = "https://generativelanguage.googleapis.com/v1beta/models"
 const gemini_model = "gemini-1.5-flash"
 
+const vertex_model_prefix = "vertex/"
+const vertex_region = "us-central1"
+const google_token_uri = "https://oauth2.googleapis.com/token"
+const google_cloud_platform_scope = "https://www.googleapis.com/auth/cloud-platform"
+
 const anthropic_base_url = "https://api.anthropic.com/v1/messages"
 const anthropic_version = "2023-06-01"
 const anthropic_claude3 = "claude-3-haiku-20240307"
@@ -16,8 +21,35 @@ const anthropic_claude3 = "claude-3-haiku-20240307"
 // const anthropic_claude_instant = "claude-instant-1.2"
 const anthropic_max_tokens = 4096
 
+const azure_model_prefix = "azure/"
+const azure_api_version = "2024-06-01"
+
+const bedrock_model_prefix = "bedrock/"
+const bedrock_service = "bedrock"
+const bedrock_max_tokens = 4096
+
+const mistral_base_url = "https://api.mistral.ai/v1/chat/completions"
+const mistral_model_prefix = "mistral/"
+
+const cohere_base_url = "https://api.cohere.com/v2/chat"
+const cohere_model_prefix = "cohere/"
+
+const ollama_base_url = "http://localhost:11434"
+const ollama_model_prefix = "ollama/"
+const ollama_chat_path = "/api/chat"
+const ollama_embed_path = "/api/embed"
+
 const openai_embeddings_base_url = "https://api.openai.com/v1/embeddings"
+const voyage_embeddings_base_url = "https://api.voyageai.com/v1/embeddings"
+const cohere_embed_base_url = "https://api.cohere.com/v2/embed"
+const cohere_rerank_base_url = "https://api.cohere.com/v2/rerank"
+const jina_rerank_base_url = "https://api.jina.ai/v1/rerank"
+const voyage_rerank_base_url = "https://api.voyageai.com/v1/rerank"
+const llm_rerank_batch_size = 10
 const openai_embeddings_dimensions = 512
+const openai_embeddings_batch_size = 2048
+const openai_embeddings_batch_tokens = 300_000
+const openai_embeddings_concurrency = 4
 const embeddings_chunk_size_default = 1024
 const embeddings_chunk_overlap_default = 200
 const OPENAI_EMBEDDINGS_INPUT_MAX = 8191
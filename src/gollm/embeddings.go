@@ -4,16 +4,30 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"sync"
 
+	"github.com/jake-landersweb/gollm/v2/src/chunking"
+	"github.com/jake-landersweb/gollm/v2/src/ltypes"
 	"github.com/jake-landersweb/gollm/v2/src/tokens"
 )
 
-type ChunkingFunction func(s string, n int) []string
+// Splits the input of an embeddings request into chunks
+type ChunkingFunction func(input string) ([]string, error)
 
 type EmbedArgs struct {
 	Input            string
 	InputChunks      []string
-	ChunkingFunction func(input string) ([]string, error)
+	ChunkingFunction ChunkingFunction
+
+	// Optionally chunk the input with a chunker from the `chunking` package, which sets the offsets of
+	// every chunk in the input on the embeddings. Takes precedence over `ChunkingFunction`
+	Chunker chunking.Chunker
+
+	// Optionally identify the document the input came from, which is set on every embedding
+	SourceID string
+
+	// Counts the tokens in every chunk for `EmbeddingsData.Tokens`. Defaults to `chunking.ApproximateTokens`
+	Tokenizer func(input string) int
 }
 
 func (args *EmbedArgs) IsValid() error {
@@ -26,9 +40,67 @@ func (args *EmbedArgs) IsValid() error {
 	if args.ChunkingFunction == nil {
 		args.ChunkingFunction = ChunkStringEqualUntilN
 	}
+	if args.Tokenizer == nil {
+		args.Tokenizer = chunking.ApproximateTokens
+	}
 	return nil
 }
 
+// Chunks the input. The chunks passed in `InputChunks` or created by `ChunkingFunction` have no offsets.
+// Returns an error when there are no chunks, so the providers never send an empty request
+func (args *EmbedArgs) chunk() ([]*chunking.Chunk, error) {
+	texts := args.InputChunks
+	if len(texts) == 0 {
+		if args.Chunker != nil {
+			chunks, err := args.Chunker.Chunk(args.Input)
+			if err != nil {
+				return nil, err
+			}
+			if len(chunks) == 0 {
+				return nil, fmt.Errorf("no chunks to embed")
+			}
+			return chunks, nil
+		}
+
+		var err error
+		texts, err = args.ChunkingFunction(args.Input)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(texts) == 0 {
+		return nil, fmt.Errorf("no chunks to embed")
+	}
+
+	chunks := make([]*chunking.Chunk, len(texts))
+	for idx, item := range texts {
+		chunks[idx] = &chunking.Chunk{Text: item}
+	}
+	return chunks, nil
+}
+
+func chunkTexts(chunks []*chunking.Chunk) []string {
+	texts := make([]string, len(chunks))
+	for idx, item := range chunks {
+		texts[idx] = item.Text
+	}
+	return texts
+}
+
+// Builds the embedding of the chunk at idx in the input
+func (args *EmbedArgs) embeddingsData(idx int, chunk *chunking.Chunk, vector []float32) *ltypes.EmbeddingsData {
+	return &ltypes.EmbeddingsData{
+		Raw:       chunk.Text,
+		Index:     idx,
+		Start:     chunk.Start,
+		End:       chunk.End,
+		Section:   chunk.Section,
+		Tokens:    args.Tokenizer(chunk.Text),
+		SourceID:  args.SourceID,
+		Embedding: vector,
+	}
+}
+
 type Embeddings interface {
 	// Create the embdeddings using the provider
 	Embed(
@@ -40,3 +112,160 @@ type Embeddings interface {
 	// optionally store token records state inside the object as well
 	GetUsageRecords() []*tokens.UsageRecord
 }
+
+// A chunk that could not be embedded
+type EmbedChunkError struct {
+	Index int // position of the chunk in the input
+	Raw   string
+	Err   error
+}
+
+func (e *EmbedChunkError) Error() string {
+	return fmt.Sprintf("failed to embed chunk %d: %v", e.Index, e.Err)
+}
+
+func (e *EmbedChunkError) Unwrap() error {
+	return e.Err
+}
+
+/*
+Returned along with the response when only some of the chunks could be embedded. The response
+holds the embeddings and usage of the chunks that succeeded, in the order of the input.
+*/
+type PartialEmbedError struct {
+	Chunks []*EmbedChunkError
+}
+
+func (e *PartialEmbedError) Error() string {
+	return fmt.Sprintf("failed to embed %d chunks: %v", len(e.Chunks), e.Chunks[0])
+}
+
+func (e *PartialEmbedError) Unwrap() []error {
+	errs := make([]error, len(e.Chunks))
+	for i, item := range e.Chunks {
+		errs[i] = item
+	}
+	return errs
+}
+
+// A range of chunks that are sent in a single request
+type embeddingsBatch struct {
+	start int
+	end   int
+}
+
+/*
+Splits the chunks into batches that stay under the input and token limits of a request.
+Chunks that are estimated to be over `maxInputTokens` are sent on their own, so if the api
+rejects them, the rest of the chunks are still embedded.
+*/
+func splitEmbeddingsBatches(chunks []string, maxInputs int, maxTokens int, maxInputTokens int) []embeddingsBatch {
+	batches := make([]embeddingsBatch, 0)
+	current := embeddingsBatch{}
+	currentTokens := 0
+
+	for idx, chunk := range chunks {
+		// over-estimate so a batch is never rejected for its size
+		count, _ := gptTokenizerApproximate("max", chunk)
+
+		if count > maxInputTokens {
+			if current.end > current.start {
+				batches = append(batches, current)
+			}
+			batches = append(batches, embeddingsBatch{start: idx, end: idx + 1})
+			current = embeddingsBatch{start: idx + 1, end: idx + 1}
+			currentTokens = 0
+			continue
+		}
+
+		if current.end > current.start && (current.end-current.start >= maxInputs || currentTokens+count > maxTokens) {
+			batches = append(batches, current)
+			current = embeddingsBatch{start: idx, end: idx}
+			currentTokens = 0
+		}
+		current.end = idx + 1
+		currentTokens += count
+	}
+	if current.end > current.start {
+		batches = append(batches, current)
+	}
+
+	return batches
+}
+
+// Sends a single batch to a provider, returning a vector for every chunk of the batch in the order of the chunks
+type embeddingsBatchSender func(ctx context.Context, logger *slog.Logger, batch []string) ([][]float32, *tokens.UsageRecord, error)
+
+/*
+Embeds the chunks by sending the batches with at most `concurrency` requests at the same time.
+Returns the vectors in the order of the chunks, which are nil for the chunks that failed, the usage
+of every batch, which is nil for the batches that failed, and the errors of the chunks that failed.
+*/
+func embedInBatches(
+	ctx context.Context,
+	logger *slog.Logger,
+	chunks []string,
+	batches []embeddingsBatch,
+	concurrency int,
+	send embeddingsBatchSender,
+) ([][]float32, []*tokens.UsageRecord, []*EmbedChunkError) {
+	if len(batches) > 1 {
+		logger.InfoContext(ctx, "Splitting the chunks into batches", "chunks", len(chunks), "batches", len(batches))
+	}
+
+	vectors := make([][]float32, len(chunks))
+	records := make([]*tokens.UsageRecord, len(batches))
+	errs := make([]error, len(batches))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, max(concurrency, 1))
+batching:
+	for idx, batch := range batches {
+		// stop sending once the context is done, failing the batches that were not sent
+		if ctx.Err() == nil {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			for i := idx; i < len(batches); i++ {
+				errs[i] = err
+			}
+			break batching
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			response, usage, err := send(ctx, logger, chunks[batch.start:batch.end])
+			if err == nil && len(response) != batch.end-batch.start {
+				err = fmt.Errorf("expected %d embeddings, received %d", batch.end-batch.start, len(response))
+			}
+			if err != nil {
+				errs[idx] = err
+				return
+			}
+			copy(vectors[batch.start:batch.end], response)
+			records[idx] = usage
+		}()
+	}
+	wg.Wait()
+
+	failures := make([]*EmbedChunkError, 0)
+	for idx, batch := range batches {
+		for i := batch.start; i < batch.end; i++ {
+			err := errs[idx]
+			if err == nil && vectors[i] == nil {
+				err = fmt.Errorf("the response did not contain an embedding for the chunk")
+			}
+			if err != nil {
+				failures = append(failures, &EmbedChunkError{Index: i, Raw: chunks[i], Err: err})
+			}
+		}
+	}
+
+	return vectors, records, failures
+}
@@ -0,0 +1,246 @@
+package gollm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jake-landersweb/gollm/v2/src/ltypes"
+	"github.com/jake-landersweb/gollm/v2/src/metrics"
+	"github.com/jake-landersweb/gollm/v2/src/tokens"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type ModelBedrockEmbeddings = string
+
+const (
+	BEDROCK_TITAN_EMBEDDINGS_MODEL  ModelBedrockEmbeddings = "amazon.titan-embed-text-v2:0"
+	BEDROCK_COHERE_EMBEDDINGS_MODEL ModelBedrockEmbeddings = "cohere.embed-english-v3"
+)
+
+// The Cohere models accept at most 96 texts per request
+const bedrock_cohere_batch_size = 96
+
+// Struct to handle the creation lifecycle when using the Titan or Cohere embeddings models on AWS Bedrock
+type BedrockEmbeddings struct {
+	config   *BedrockConfig
+	opts     *BedrockEmbeddingsOpts
+	tracer   trace.Tracer
+	metrics  metrics.Metrics
+	redactor *redactor
+
+	usageRecords []*tokens.UsageRecord
+}
+
+// Optional configurations to customize the usage of the model.
+// This struct can be passed in as nil, and reasonable and functional defaults will be used.
+type BedrockEmbeddingsOpts struct {
+	// Bedrock model id of a Titan or Cohere embeddings model. Defaults to `amazon.titan-embed-text-v2:0`
+	Model ModelBedrockEmbeddings
+
+	// Optionally reduce the size of the vectors. Only supported by Titan v2, which accepts 256, 512 or 1024
+	EmbeddingsDimentions int
+
+	// How Cohere models should optimize the vectors, such as `search_query`. Defaults to `search_document`
+	CohereInputType string
+
+	// Optionally pass the http client used to send all requests, such as one with a custom transport.
+	HttpClient *http.Client
+
+	// Optionally trace embeddings with OpenTelemetry. If not specified, the global provider will be used.
+	TracerProvider trace.TracerProvider
+
+	// Optionally collect metrics on embeddings. If not specified, no metrics are collected.
+	Metrics metrics.Metrics
+
+	// Optionally configure what is masked from logged requests and responses. Credentials are always masked.
+	Redaction *RedactionOpts
+}
+
+// Creates embeddings using AWS Bedrock. The region and credentials are read from `config`, in the same way as completions.
+func NewBedrockEmbeddings(config *BedrockConfig, opts *BedrockEmbeddingsOpts) *BedrockEmbeddings {
+	if config == nil {
+		config = &BedrockConfig{}
+	}
+	if opts == nil {
+		opts = &BedrockEmbeddingsOpts{}
+	}
+	if opts.Model == "" {
+		opts.Model = BEDROCK_TITAN_EMBEDDINGS_MODEL
+	}
+	if opts.CohereInputType == "" {
+		opts.CohereInputType = "search_document"
+	}
+	if opts.HttpClient == nil {
+		opts.HttpClient = &http.Client{}
+	}
+
+	return &BedrockEmbeddings{
+		config:   config,
+		opts:     opts,
+		tracer:   newTracer(opts.TracerProvider),
+		metrics:  metrics.OrNoop(opts.Metrics),
+		redactor: newRedactor(opts.Redaction),
+	}
+}
+
+func (e *BedrockEmbeddings) Embed(
+	ctx context.Context,
+	logger *slog.Logger,
+	args *EmbedArgs,
+) (*EmbedResponse, error) {
+	if logger == nil {
+		logger = discardLogger()
+	}
+
+	ctx, span := e.tracer.Start(ctx, fmt.Sprintf("%s %s", genAIOperationEmbeddings, e.opts.Model),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attrGenAIOperationName.String(genAIOperationEmbeddings),
+			attrGenAISystem.String(genAISystemBedrock),
+			attrGenAIRequestModel.String(e.opts.Model),
+		),
+	)
+	defer span.End()
+
+	// chunk the input
+	if err := args.IsValid(); err != nil {
+		err = fmt.Errorf("invalid arguments: %s", err)
+		recordSpanError(span, err)
+		return nil, err
+	}
+
+	pieces, err := args.chunk()
+	if err != nil {
+		err = fmt.Errorf("failed to chunk the content: %s", err)
+		recordSpanError(span, err)
+		return nil, err
+	}
+	chunks := chunkTexts(pieces)
+	span.SetAttributes(attrEmbeddingsChunks.Int(len(chunks)))
+	e.metrics.ObserveEmbeddingChunks(genAISystemBedrock, e.opts.Model, len(chunks))
+
+	start := time.Now()
+	var vectors [][]float32
+	var inputTokens int
+	switch {
+	case strings.HasPrefix(e.opts.Model, "amazon.titan-embed"):
+		vectors, inputTokens, err = e.titanEmbed(ctx, logger, chunks)
+	case strings.HasPrefix(e.opts.Model, "cohere.embed"):
+		vectors, inputTokens, err = e.cohereEmbed(ctx, logger, chunks)
+	default:
+		err = fmt.Errorf("unsupported bedrock embeddings model: %s", e.opts.Model)
+	}
+	if err == nil && len(vectors) != len(chunks) {
+		err = fmt.Errorf("expected %d embeddings, received %d", len(chunks), len(vectors))
+	}
+	if err != nil {
+		observeRequest(e.metrics, metrics.OperationEmbeddings, genAISystemBedrock, e.opts.Model, start, nil, err)
+		recordSpanError(span, err)
+		return nil, err
+	}
+
+	// track token usage
+	usageRecord := tokens.NewUsageRecord(e.opts.Model, inputTokens, 0, inputTokens)
+	e.usageRecords = append(e.usageRecords, usageRecord)
+	span.SetAttributes(usageAttributes(usageRecord)...)
+	observeRequest(e.metrics, metrics.OperationEmbeddings, genAISystemBedrock, e.opts.Model, start, usageRecord, nil)
+
+	// pair the vectors with their chunks
+	list := make([]*ltypes.EmbeddingsData, 0)
+	for idx := range chunks {
+		list = append(list, args.embeddingsData(idx, pieces[idx], vectors[idx]))
+	}
+
+	return &EmbedResponse{
+		Embeddings: list,
+		Usage:      usageRecord,
+	}, nil
+}
+
+func (e *BedrockEmbeddings) GetUsageRecords() []*tokens.UsageRecord {
+	return e.usageRecords
+}
+
+func (e *BedrockEmbeddings) client() *bedrockClient {
+	return &bedrockClient{
+		config:    e.config,
+		client:    e.opts.HttpClient,
+		tracer:    e.tracer,
+		metrics:   e.metrics,
+		redactor:  e.redactor,
+		operation: metrics.OperationEmbeddings,
+	}
+}
+
+// Titan only embeds a single input per request, so a request is sent for every chunk
+func (e *BedrockEmbeddings) titanEmbed(ctx context.Context, logger *slog.Logger, input []string) ([][]float32, int, error) {
+	vectors := make([][]float32, 0, len(input))
+	inputTokens := 0
+
+	for _, chunk := range input {
+		enc, err := json.Marshal(&ltypes.BedrockTitanEmbeddingRequest{
+			InputText:  chunk,
+			Dimensions: e.opts.EmbeddingsDimentions,
+		})
+		if err != nil {
+			return nil, 0, fmt.Errorf("there was an issue encoding the body into json: %v", err)
+		}
+
+		logger.DebugContext(ctx, "Request body", "body", e.redactor.Body(enc))
+
+		_, body, err := sendBedrockRequest(ctx, logger, e.client(), "invoke", e.opts.Model, enc)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		var response ltypes.BedrockTitanEmbeddingResponse
+		if err = json.Unmarshal(body, &response); err != nil {
+			return nil, 0, fmt.Errorf("there was an issue unmarshalling the request body: %v", err)
+		}
+		vectors = append(vectors, response.Embedding)
+		inputTokens += response.InputTextTokenCount
+	}
+
+	return vectors, inputTokens, nil
+}
+
+// Cohere embeds the chunks in batches. The token count is only reported through the response headers
+func (e *BedrockEmbeddings) cohereEmbed(ctx context.Context, logger *slog.Logger, input []string) ([][]float32, int, error) {
+	vectors := make([][]float32, 0, len(input))
+	inputTokens := 0
+
+	for start := 0; start < len(input); start += bedrock_cohere_batch_size {
+		batch := input[start:min(start+bedrock_cohere_batch_size, len(input))]
+		enc, err := json.Marshal(&ltypes.BedrockCohereEmbeddingRequest{
+			Texts:     batch,
+			InputType: e.opts.CohereInputType,
+			Truncate:  "END",
+		})
+		if err != nil {
+			return nil, 0, fmt.Errorf("there was an issue encoding the body into json: %v", err)
+		}
+
+		logger.DebugContext(ctx, "Request body", "body", e.redactor.Body(enc))
+
+		header, body, err := sendBedrockRequest(ctx, logger, e.client(), "invoke", e.opts.Model, enc)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		var response ltypes.BedrockCohereEmbeddingResponse
+		if err = json.Unmarshal(body, &response); err != nil {
+			return nil, 0, fmt.Errorf("there was an issue unmarshalling the request body: %v", err)
+		}
+		vectors = append(vectors, response.Embeddings...)
+		count, _ := strconv.Atoi(header.Get("X-Amzn-Bedrock-Input-Token-Count"))
+		inputTokens += count
+	}
+
+	return vectors, inputTokens, nil
+}
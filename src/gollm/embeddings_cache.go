@@ -0,0 +1,273 @@
+package gollm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jake-landersweb/gollm/v2/src/cache"
+	"github.com/jake-landersweb/gollm/v2/src/ltypes"
+	"github.com/jake-landersweb/gollm/v2/src/tokens"
+)
+
+/*
+Wraps an `Embeddings` implementation and caches the vectors of every chunk by the provider, model,
+dimensions and other settings that change the vectors, and the SHA-256 of the text. Only the
+chunks that are not cached are sent to the wrapped implementation.
+*/
+type CachedEmbeddings struct {
+	embeddings Embeddings
+	cache      cache.Cache
+	opts       *CachedEmbeddingsOpts
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+type CachedEmbeddingsOpts struct {
+	/*
+		Identifies the provider, model and settings of the wrapped embeddings in the keys. Derived from
+		the options of the embeddings of this package, and required for other implementations.
+		Different settings must use different namespaces, or the cache will return the vectors of
+		the other settings.
+	*/
+	Namespace string
+
+	// How long vectors are cached. Defaults to 0, which caches them until they are evicted
+	TTL time.Duration
+}
+
+func NewCachedEmbeddings(embeddings Embeddings, store cache.Cache, opts *CachedEmbeddingsOpts) (*CachedEmbeddings, error) {
+	if embeddings == nil {
+		return nil, fmt.Errorf("the embeddings cannot be nil")
+	}
+	if store == nil {
+		return nil, fmt.Errorf("the cache cannot be nil")
+	}
+	resp := CachedEmbeddingsOpts{}
+	if opts != nil {
+		resp = *opts
+	}
+	if resp.Namespace == "" {
+		resp.Namespace = embeddingsNamespace(embeddings)
+	}
+	if resp.Namespace == "" {
+		return nil, fmt.Errorf("`Namespace` is required for embeddings of type %T", embeddings)
+	}
+	return &CachedEmbeddings{embeddings: embeddings, cache: store, opts: &resp}, nil
+}
+
+// Describes the provider and the settings that change the vectors of the embeddings of this package
+func embeddingsNamespace(embeddings Embeddings) string {
+	parts := func(items ...any) string {
+		values := make([]string, len(items))
+		for idx, item := range items {
+			values[idx] = fmt.Sprint(item)
+		}
+		return strings.Join(values, "|")
+	}
+
+	switch e := embeddings.(type) {
+	case *OpenAIEmbeddings:
+		return parts(e.system, e.opts.Model, e.opts.EmbeddingsDimentions)
+	case *AzureOpenAIEmbeddings:
+		return embeddingsNamespace(e.OpenAIEmbeddings)
+	case *GeminiEmbeddings:
+		return parts(genAISystemGemini, e.opts.Model, e.opts.EmbeddingsDimentions, e.opts.TaskType, e.opts.Title)
+	case *VoyageEmbeddings:
+		return parts(genAISystemVoyage, e.opts.Model, e.opts.EmbeddingsDimentions, e.opts.InputType, e.opts.EmbeddingType, e.opts.DisableTruncation)
+	case *CohereEmbeddings:
+		return parts(genAISystemCohere, e.opts.Model, e.opts.InputType, e.opts.EmbeddingType, e.opts.Truncate)
+	case *BedrockEmbeddings:
+		return parts(genAISystemBedrock, e.opts.Model, e.opts.EmbeddingsDimentions, e.opts.CohereInputType)
+	case *OllamaEmbeddings:
+		return parts(genAISystemOllama, e.opts.Model, e.opts.EmbeddingsDimentions)
+	default:
+		return ""
+	}
+}
+
+// Builds the cache key of a chunk
+func (e *CachedEmbeddings) key(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return "embeddings:" + cache.Key(e.opts.Namespace, hex.EncodeToString(sum[:]))
+}
+
+/*
+Returns the cached vectors and embeds the rest with the wrapped embeddings, in the order of the
+chunks. The usage only counts the chunks that were embedded. When the wrapped embeddings return a
+`PartialEmbedError`, the chunks that succeeded are cached and the error is returned with the
+indexes of the failed chunks in this input.
+*/
+func (e *CachedEmbeddings) Embed(
+	ctx context.Context,
+	logger *slog.Logger,
+	args *EmbedArgs,
+) (*EmbedResponse, error) {
+	if logger == nil {
+		logger = discardLogger()
+	}
+	if err := args.IsValid(); err != nil {
+		return nil, fmt.Errorf("invalid arguments: %s", err)
+	}
+	pieces, err := args.chunk()
+	if err != nil {
+		return nil, fmt.Errorf("failed to chunk the content: %s", err)
+	}
+
+	// read the cache, and send every distinct missing text once
+	vectors := make([][]float32, len(pieces))
+	keys := make([]string, len(pieces))
+	missing := make([]string, 0)
+	positions := make(map[string][]int)
+	for idx, item := range pieces {
+		keys[idx] = e.key(item.Text)
+		value, ok, err := e.cache.Get(ctx, keys[idx])
+		if err != nil {
+			logger.WarnContext(ctx, "There was an issue reading the embeddings cache", "error", err)
+		}
+		if ok {
+			if vector, valid := decodeVector(value); valid {
+				vectors[idx] = vector
+				continue
+			}
+		}
+		if _, ok := positions[item.Text]; !ok {
+			missing = append(missing, item.Text)
+		}
+		positions[item.Text] = append(positions[item.Text], idx)
+	}
+
+	hits := len(pieces)
+	for _, items := range positions {
+		hits -= len(items)
+	}
+	e.hits.Add(int64(hits))
+	e.misses.Add(int64(len(pieces) - hits))
+	logger.DebugContext(ctx, "Read the embeddings cache", "hits", hits, "misses", len(pieces)-hits)
+
+	var usage *tokens.UsageRecord
+	var failures []*EmbedChunkError
+	if len(missing) != 0 {
+		response, err := e.embeddings.Embed(ctx, logger, &EmbedArgs{InputChunks: missing})
+		var partial *PartialEmbedError
+		if err != nil && (response == nil || !errors.As(err, &partial)) {
+			return nil, err
+		}
+		usage = response.Usage
+
+		// the response skips the failed chunks
+		failed := make(map[int]bool)
+		if partial != nil {
+			for _, item := range partial.Chunks {
+				failed[item.Index] = true
+			}
+		}
+		next := 0
+		for idx, text := range missing {
+			if failed[idx] {
+				continue
+			}
+			if next >= len(response.Embeddings) {
+				return nil, fmt.Errorf("expected %d embeddings, received %d", len(missing)-len(failed), len(response.Embeddings))
+			}
+			vector := response.Embeddings[next].Embedding
+			next++
+			if err := e.cache.Set(ctx, e.key(text), encodeVector(vector), e.opts.TTL); err != nil {
+				logger.WarnContext(ctx, "There was an issue writing the embeddings cache", "error", err)
+			}
+			for _, position := range positions[text] {
+				vectors[position] = vector
+			}
+		}
+		if partial != nil {
+			for _, item := range partial.Chunks {
+				for _, position := range positions[missing[item.Index]] {
+					failures = append(failures, &EmbedChunkError{Index: position, Raw: item.Raw, Err: item.Err})
+				}
+			}
+		}
+	}
+	if usage == nil {
+		usage = tokens.NewUsageRecord(embeddingsModel(e.embeddings), 0, 0, 0)
+	}
+
+	list := make([]*ltypes.EmbeddingsData, 0, len(pieces))
+	for idx, item := range pieces {
+		if vectors[idx] == nil {
+			continue
+		}
+		list = append(list, args.embeddingsData(idx, item, vectors[idx]))
+	}
+
+	response := &EmbedResponse{Embeddings: list, Usage: usage}
+	if len(failures) != 0 {
+		return response, &PartialEmbedError{Chunks: failures}
+	}
+	return response, nil
+}
+
+// Returns the usage records of the wrapped embeddings
+func (e *CachedEmbeddings) GetUsageRecords() []*tokens.UsageRecord {
+	return e.embeddings.GetUsageRecords()
+}
+
+// Returns the number of chunks that were read from the cache and the number that were embedded
+func (e *CachedEmbeddings) Stats() (int, int) {
+	return int(e.hits.Load()), int(e.misses.Load())
+}
+
+// Returns the model of the embeddings of this package, for the usage of requests that were fully cached
+func embeddingsModel(embeddings Embeddings) string {
+	switch e := embeddings.(type) {
+	case *OpenAIEmbeddings:
+		return e.opts.Model
+	case *AzureOpenAIEmbeddings:
+		return e.opts.Model
+	case *GeminiEmbeddings:
+		return e.opts.Model
+	case *VoyageEmbeddings:
+		return e.opts.Model
+	case *CohereEmbeddings:
+		return e.opts.Model
+	case *BedrockEmbeddings:
+		return e.opts.Model
+	case *OllamaEmbeddings:
+		return e.opts.Model
+	default:
+		return ""
+	}
+}
+
+// Encodes the vector as its dimensions followed by the little endian bits of its values
+func encodeVector(vector []float32) []byte {
+	buf := make([]byte, 4+4*len(vector))
+	binary.LittleEndian.PutUint32(buf, uint32(len(vector)))
+	for idx, item := range vector {
+		binary.LittleEndian.PutUint32(buf[4+4*idx:], math.Float32bits(item))
+	}
+	return buf
+}
+
+func decodeVector(buf []byte) ([]float32, bool) {
+	if len(buf) < 4 {
+		return nil, false
+	}
+	dimensions := int(binary.LittleEndian.Uint32(buf))
+	if dimensions == 0 || len(buf) != 4+4*dimensions {
+		return nil, false
+	}
+	vector := make([]float32, dimensions)
+	for idx := range vector {
+		vector[idx] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4+4*idx:]))
+	}
+	return vector, true
+}
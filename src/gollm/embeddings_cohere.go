@@ -0,0 +1,295 @@
+package gollm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/jake-landersweb/gollm/v2/src/ltypes"
+	"github.com/jake-landersweb/gollm/v2/src/metrics"
+	"github.com/jake-landersweb/gollm/v2/src/tokens"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type ModelCohereEmbeddings = string
+
+const (
+	COHERE_EMBEDDINGS_MODEL ModelCohereEmbeddings = "embed-english-v3.0"
+)
+
+// Cohere accepts at most 96 texts per request
+const cohere_embeddings_batch_size = 96
+
+// Struct to handle the creation lifecycle when using Cohere Embeddings
+type CohereEmbeddings struct {
+	opts     *CohereEmbeddingsOpts
+	tracer   trace.Tracer
+	metrics  metrics.Metrics
+	redactor *redactor
+
+	usageRecords []*tokens.UsageRecord
+}
+
+// Optional configurations to customize the usage of the model.
+// This struct can be passed in as nil, and reasonable and functional defaults will be used.
+type CohereEmbeddingsOpts struct {
+	// Defaults to `embed-english-v3.0`
+	Model ModelCohereEmbeddings
+
+	// What the embeddings will be used for. Defaults to `search_document`, so use a separate
+	// `CohereEmbeddings` with `search_query` to embed search queries
+	InputType ltypes.CohereInputType
+
+	// How inputs longer than the context length of the model are handled: `NONE`, `START` or `END`.
+	// Defaults to `END`. With `NONE`, long inputs are an error
+	Truncate string
+
+	// The data type of the vectors. Defaults to `float`
+	EmbeddingType ltypes.EmbeddingType
+
+	// Defaults to `https://api.cohere.com/v2/embed`
+	BaseUrl string
+
+	// Optionally pass the http client used to send all requests, such as one with a custom transport.
+	HttpClient *http.Client
+
+	// Optionally pass in an api key. If not specified, the environment variable `CO_API_KEY` will be read.
+	CohereApiKey string
+
+	// Optionally trace embeddings with OpenTelemetry. If not specified, the global provider will be used.
+	TracerProvider trace.TracerProvider
+
+	// Optionally collect metrics on embeddings. If not specified, no metrics are collected.
+	Metrics metrics.Metrics
+
+	// Optionally configure what is masked from logged requests and responses. Api keys are always masked.
+	Redaction *RedactionOpts
+}
+
+func NewCohereEmbeddings(opts *CohereEmbeddingsOpts) *CohereEmbeddings {
+	if opts == nil {
+		opts = &CohereEmbeddingsOpts{}
+	}
+	if opts.Model == "" {
+		opts.Model = COHERE_EMBEDDINGS_MODEL
+	}
+	if opts.InputType == "" {
+		opts.InputType = ltypes.COHERE_INPUT_SEARCH_DOCUMENT
+	}
+	if opts.EmbeddingType == "" {
+		opts.EmbeddingType = ltypes.EMBEDDING_TYPE_FLOAT
+	}
+	if opts.BaseUrl == "" {
+		opts.BaseUrl = cohere_embed_base_url
+	}
+	if opts.HttpClient == nil {
+		opts.HttpClient = &http.Client{}
+	}
+
+	return &CohereEmbeddings{
+		opts:     opts,
+		tracer:   newTracer(opts.TracerProvider),
+		metrics:  metrics.OrNoop(opts.Metrics),
+		redactor: newRedactor(opts.Redaction),
+	}
+}
+
+func (e *CohereEmbeddings) Embed(
+	ctx context.Context,
+	logger *slog.Logger,
+	args *EmbedArgs,
+) (*EmbedResponse, error) {
+	if logger == nil {
+		logger = discardLogger()
+	}
+
+	ctx, span := e.tracer.Start(ctx, fmt.Sprintf("%s %s", genAIOperationEmbeddings, e.opts.Model),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attrGenAIOperationName.String(genAIOperationEmbeddings),
+			attrGenAISystem.String(genAISystemCohere),
+			attrGenAIRequestModel.String(e.opts.Model),
+		),
+	)
+	defer span.End()
+
+	// chunk the input
+	if err := args.IsValid(); err != nil {
+		err = fmt.Errorf("invalid arguments: %s", err)
+		recordSpanError(span, err)
+		return nil, err
+	}
+
+	pieces, err := args.chunk()
+	if err != nil {
+		err = fmt.Errorf("failed to chunk the content: %s", err)
+		recordSpanError(span, err)
+		return nil, err
+	}
+	chunks := chunkTexts(pieces)
+	span.SetAttributes(attrEmbeddingsChunks.Int(len(chunks)))
+	e.metrics.ObserveEmbeddingChunks(genAISystemCohere, e.opts.Model, len(chunks))
+
+	start := time.Now()
+	// cohere truncates long inputs instead of limiting the tokens of a request, so only the count is limited
+	batches := splitEmbeddingsBatches(chunks, cohere_embeddings_batch_size, math.MaxInt, math.MaxInt)
+	vectors, records, failures := embedInBatches(ctx, logger, chunks, batches, 1, e.sendBatch)
+	if len(chunks) > 0 && len(failures) == len(chunks) {
+		// nothing was embedded, so report the error of the first batch
+		err = failures[0].Err
+		observeRequest(e.metrics, metrics.OperationEmbeddings, genAISystemCohere, e.opts.Model, start, nil, err)
+		recordSpanError(span, err)
+		return nil, err
+	}
+
+	// track token usage of the batches that succeeded
+	usageRecord := tokens.MergeUsageRecords(records...)
+	e.usageRecords = append(e.usageRecords, usageRecord)
+	span.SetAttributes(usageAttributes(usageRecord)...)
+
+	if len(failures) != 0 {
+		err = &PartialEmbedError{Chunks: failures}
+		recordSpanError(span, err)
+	}
+	observeRequest(e.metrics, metrics.OperationEmbeddings, genAISystemCohere, e.opts.Model, start, usageRecord, err)
+
+	// pair the vectors with their chunks
+	list := make([]*ltypes.EmbeddingsData, 0)
+	for idx := range chunks {
+		if vectors[idx] == nil {
+			continue
+		}
+		list = append(list, args.embeddingsData(idx, pieces[idx], vectors[idx]))
+	}
+
+	return &EmbedResponse{
+		Embeddings: list,
+		Usage:      usageRecord,
+	}, err
+}
+
+// Sends a batch of chunks to Cohere, which returns the vectors of the requested type in the order of the texts
+func (e *CohereEmbeddings) sendBatch(ctx context.Context, logger *slog.Logger, batch []string) ([][]float32, *tokens.UsageRecord, error) {
+	response, err := e.cohereEmbed(ctx, logger, batch)
+	if err != nil {
+		return nil, nil, err
+	}
+	usage := &ltypes.CohereUsage{}
+	if response.Meta != nil {
+		usage.BilledUnits = response.Meta.BilledUnits
+	}
+	return response.Embeddings[e.opts.EmbeddingType], tokens.NewUsageRecordFromCohereUsage(e.opts.Model, usage), nil
+}
+
+func (e *CohereEmbeddings) GetUsageRecords() []*tokens.UsageRecord {
+	return e.usageRecords
+}
+
+func (e *CohereEmbeddings) cohereEmbed(
+	ctx context.Context,
+	logger *slog.Logger,
+	input []string,
+) (*ltypes.CohereEmbedResponse, error) {
+	apiKey := e.opts.CohereApiKey
+	if apiKey == "" {
+		apiKey = os.Getenv("CO_API_KEY")
+		if apiKey == "" || apiKey == "null" {
+			return nil, fmt.Errorf("the environment variable `CO_API_KEY` is required")
+		}
+	}
+
+	// create the body
+	comprequest := ltypes.CohereEmbedRequest{
+		Model:          e.opts.Model,
+		Texts:          input,
+		InputType:      e.opts.InputType,
+		EmbeddingTypes: []ltypes.EmbeddingType{e.opts.EmbeddingType},
+		Truncate:       e.opts.Truncate,
+	}
+
+	enc, err := json.Marshal(&comprequest)
+	if err != nil {
+		return nil, fmt.Errorf("there was an issue encoding the body into json: %v", err)
+	}
+
+	logger.DebugContext(ctx, "Request body", "body", e.redactor.Body(enc, apiKey))
+
+	// send the request
+	client := e.opts.HttpClient
+
+	retries := 3
+	backoff := 1 * time.Second
+
+	for attempt := 0; attempt < retries; attempt++ {
+		logger.InfoContext(ctx, "Sending embeddings request...", "chunks", len(input))
+		statusCode, body, err := sendAttempt(ctx, e.tracer, client, genAISystemCohere, e.opts.Model, attempt, func(ctx context.Context) (*http.Request, error) {
+			req, err := http.NewRequestWithContext(ctx, "POST", e.opts.BaseUrl, bytes.NewBuffer(enc))
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Accept", "application/json")
+			req.Header.Set("Authorization", "Bearer "+apiKey)
+			return req, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		logger.InfoContext(ctx, "Completed request", "statusCode", statusCode)
+
+		if statusCode == 200 {
+			var response ltypes.CohereEmbedResponse
+			if err = json.Unmarshal(body, &response); err != nil {
+				return nil, fmt.Errorf("there was an issue unmarshalling the request body: %v", err)
+			}
+			return &response, nil
+		}
+
+		// cohere only returns an error message, so act based on the status code
+		var apiErr ltypes.CohereError
+		json.Unmarshal(body, &apiErr)
+
+		switch {
+		case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden || statusCode == 498:
+			return nil, fmt.Errorf("there was an issue authenticating: %s", apiErr.Message)
+		case statusCode == http.StatusBadRequest || statusCode == http.StatusUnprocessableEntity:
+			return nil, fmt.Errorf("there was a validation error: %s", apiErr.Message)
+		case statusCode == http.StatusNotFound:
+			return nil, fmt.Errorf("the model was not found: %s", apiErr.Message)
+		case statusCode == http.StatusTooManyRequests:
+			logger.WarnContext(ctx, "Rate limit hit, waiting 2 seconds then trying again ...")
+			if err := sleepContext(ctx, time.Second*2); err != nil {
+				return nil, err
+			}
+		case statusCode >= 500:
+			logger.WarnContext(ctx, "There was a server error, waiting 2 seconds then trying again ...")
+			if err := sleepContext(ctx, time.Second*2); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("there was an unknown error: [%d]: %s", statusCode, apiErr.Message)
+		}
+
+		recordRetryableError(ctx, e.metrics, metrics.OperationEmbeddings, genAISystemCohere, e.opts.Model, attempt, http.StatusText(statusCode))
+
+		if attempt < retries-1 {
+			sleep := backoff + time.Duration(rand.Intn(1000))*time.Millisecond // Add jitter
+			if err := sleepContext(ctx, sleep); err != nil {
+				return nil, err
+			}
+			backoff *= 2 // Double the backoff interval
+		} else {
+			return nil, fmt.Errorf("there was an issue with the request and could not recover: %s", e.redactor.Body(body, apiKey))
+		}
+	}
+
+	return nil, err
+}
@@ -0,0 +1,291 @@
+package gollm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/jake-landersweb/gollm/v2/src/ltypes"
+	"github.com/jake-landersweb/gollm/v2/src/metrics"
+	"github.com/jake-landersweb/gollm/v2/src/tokens"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type ModelGeminiEmbeddings = string
+
+const (
+	GEMINI_EMBEDDINGS_MODEL ModelGeminiEmbeddings = "text-embedding-004"
+)
+
+// `batchEmbedContents` accepts at most 100 requests per batch
+const gemini_embeddings_batch_size = 100
+
+// Struct to handle the creation lifecycle when using Gemini Embeddings.
+// The api does not report token usage, so the usage records are approximated and marked `Estimated`
+type GeminiEmbeddings struct {
+	opts     *GeminiEmbeddingsOpts
+	tracer   trace.Tracer
+	metrics  metrics.Metrics
+	redactor *redactor
+
+	usageRecords []*tokens.UsageRecord
+}
+
+// Optional configurations to customize the usage of the model.
+// This struct can be passed in as nil, and reasonable and functional defaults will be used.
+type GeminiEmbeddingsOpts struct {
+	// Defaults to `text-embedding-004`
+	Model ModelGeminiEmbeddings
+
+	// Optionally truncate the vectors to this size. If not specified, the full 768 dimensions are returned.
+	EmbeddingsDimentions int
+
+	// What the embeddings will be used for. Defaults to `RETRIEVAL_DOCUMENT`, so use a separate
+	// `GeminiEmbeddings` with `RETRIEVAL_QUERY` to embed search queries
+	TaskType ltypes.GemTaskType
+
+	// Optionally the title of the document the chunks belong to, which improves retrieval quality.
+	// Only sent with the `RETRIEVAL_DOCUMENT` task type
+	Title string
+
+	// Defaults to `https://generativelanguage.googleapis.com/v1beta/models`
+	BaseUrl string
+
+	// Optionally pass the http client used to send all requests, such as one with a custom transport.
+	HttpClient *http.Client
+
+	// Optionally pass in an api key. If not specified, the environment variable `GEMINI_API_KEY` will be read.
+	GeminiApiKey string
+
+	// Optionally trace embeddings with OpenTelemetry. If not specified, the global provider will be used.
+	TracerProvider trace.TracerProvider
+
+	// Optionally collect metrics on embeddings. If not specified, no metrics are collected.
+	Metrics metrics.Metrics
+
+	// Optionally configure what is masked from logged requests and responses. Api keys are always masked.
+	Redaction *RedactionOpts
+}
+
+func NewGeminiEmbeddings(opts *GeminiEmbeddingsOpts) *GeminiEmbeddings {
+	if opts == nil {
+		opts = &GeminiEmbeddingsOpts{}
+	}
+	if opts.Model == "" {
+		opts.Model = GEMINI_EMBEDDINGS_MODEL
+	}
+	if opts.TaskType == "" {
+		opts.TaskType = ltypes.GEM_TASK_RETRIEVAL_DOCUMENT
+	}
+	if opts.BaseUrl == "" {
+		opts.BaseUrl = gemini_base_url
+	}
+	if opts.HttpClient == nil {
+		opts.HttpClient = &http.Client{}
+	}
+
+	return &GeminiEmbeddings{
+		opts:     opts,
+		tracer:   newTracer(opts.TracerProvider),
+		metrics:  metrics.OrNoop(opts.Metrics),
+		redactor: newRedactor(opts.Redaction),
+	}
+}
+
+func (e *GeminiEmbeddings) Embed(
+	ctx context.Context,
+	logger *slog.Logger,
+	args *EmbedArgs,
+) (*EmbedResponse, error) {
+	if logger == nil {
+		logger = discardLogger()
+	}
+
+	ctx, span := e.tracer.Start(ctx, fmt.Sprintf("%s %s", genAIOperationEmbeddings, e.opts.Model),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attrGenAIOperationName.String(genAIOperationEmbeddings),
+			attrGenAISystem.String(genAISystemGemini),
+			attrGenAIRequestModel.String(e.opts.Model),
+		),
+	)
+	defer span.End()
+
+	// chunk the input
+	if err := args.IsValid(); err != nil {
+		err = fmt.Errorf("invalid arguments: %s", err)
+		recordSpanError(span, err)
+		return nil, err
+	}
+
+	pieces, err := args.chunk()
+	if err != nil {
+		err = fmt.Errorf("failed to chunk the content: %s", err)
+		recordSpanError(span, err)
+		return nil, err
+	}
+	chunks := chunkTexts(pieces)
+	span.SetAttributes(attrEmbeddingsChunks.Int(len(chunks)))
+	e.metrics.ObserveEmbeddingChunks(genAISystemGemini, e.opts.Model, len(chunks))
+
+	start := time.Now()
+	vectors := make([][]float32, 0, len(chunks))
+	for batch := 0; batch < len(chunks); batch += gemini_embeddings_batch_size {
+		response, err := e.geminiEmbed(ctx, logger, chunks[batch:min(batch+gemini_embeddings_batch_size, len(chunks))])
+		if err != nil {
+			observeRequest(e.metrics, metrics.OperationEmbeddings, genAISystemGemini, e.opts.Model, start, nil, err)
+			recordSpanError(span, err)
+			return nil, err
+		}
+		for _, item := range response.Embeddings {
+			vectors = append(vectors, item.Values)
+		}
+	}
+	if len(vectors) != len(chunks) {
+		err = fmt.Errorf("expected %d embeddings, received %d", len(chunks), len(vectors))
+		observeRequest(e.metrics, metrics.OperationEmbeddings, genAISystemGemini, e.opts.Model, start, nil, err)
+		recordSpanError(span, err)
+		return nil, err
+	}
+
+	// gemini does not report the token usage of embeddings, so estimate it
+	inputTokens := 0
+	for _, chunk := range chunks {
+		count, _ := gptTokenizerApproximate("avg", chunk)
+		inputTokens += count
+	}
+	usageRecord := tokens.NewUsageRecord(e.opts.Model, inputTokens, 0, inputTokens)
+	usageRecord.Estimated = true
+	e.usageRecords = append(e.usageRecords, usageRecord)
+	span.SetAttributes(usageAttributes(usageRecord)...)
+	observeRequest(e.metrics, metrics.OperationEmbeddings, genAISystemGemini, e.opts.Model, start, usageRecord, nil)
+
+	// pair the vectors with their chunks
+	list := make([]*ltypes.EmbeddingsData, 0)
+	for idx := range chunks {
+		list = append(list, args.embeddingsData(idx, pieces[idx], vectors[idx]))
+	}
+
+	return &EmbedResponse{
+		Embeddings: list,
+		Usage:      usageRecord,
+	}, nil
+}
+
+func (e *GeminiEmbeddings) GetUsageRecords() []*tokens.UsageRecord {
+	return e.usageRecords
+}
+
+func (e *GeminiEmbeddings) geminiEmbed(
+	ctx context.Context,
+	logger *slog.Logger,
+	input []string,
+) (*ltypes.GemBatchEmbedResponse, error) {
+	apiKey := e.opts.GeminiApiKey
+	if apiKey == "" {
+		apiKey = os.Getenv("GEMINI_API_KEY")
+		if apiKey == "" || apiKey == "null" {
+			return nil, fmt.Errorf("the environment variable `GEMINI_API_KEY` is required")
+		}
+	}
+
+	// create the body, with a request for every chunk
+	title := ""
+	if e.opts.TaskType == ltypes.GEM_TASK_RETRIEVAL_DOCUMENT {
+		title = e.opts.Title
+	}
+	comprequest := ltypes.GemBatchEmbedRequest{
+		Requests: make([]*ltypes.GemEmbedContentRequest, 0, len(input)),
+	}
+	for _, chunk := range input {
+		comprequest.Requests = append(comprequest.Requests, &ltypes.GemEmbedContentRequest{
+			Model:                "models/" + e.opts.Model,
+			Content:              &ltypes.GemContent{Parts: []ltypes.GemPart{{Text: chunk}}},
+			TaskType:             e.opts.TaskType,
+			Title:                title,
+			OutputDimensionality: e.opts.EmbeddingsDimentions,
+		})
+	}
+
+	enc, err := json.Marshal(&comprequest)
+	if err != nil {
+		return nil, fmt.Errorf("there was an issue encoding the body into json: %v", err)
+	}
+
+	logger.DebugContext(ctx, "Request body", "body", e.redactor.Body(enc, apiKey))
+
+	// send the request
+	client := e.opts.HttpClient
+	url := fmt.Sprintf("%s/%s:batchEmbedContents", e.opts.BaseUrl, e.opts.Model)
+
+	retries := 3
+	backoff := 1 * time.Second
+
+	for attempt := 0; attempt < retries; attempt++ {
+		logger.InfoContext(ctx, "Sending embeddings request...", "chunks", len(input))
+		statusCode, body, err := sendAttempt(ctx, e.tracer, client, genAISystemGemini, e.opts.Model, attempt, func(ctx context.Context) (*http.Request, error) {
+			req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(enc))
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("x-goog-api-key", apiKey)
+			return req, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		logger.InfoContext(ctx, "Completed request", "statusCode", statusCode)
+
+		// parse into the embeddings response object
+		var response ltypes.GemBatchEmbedResponse
+		if err = json.Unmarshal(body, &response); err != nil {
+			return nil, fmt.Errorf("there was an issue unmarshalling the request body: %v", err)
+		}
+
+		if response.Error == nil {
+			return &response, nil
+		}
+
+		// parse the errror
+		switch response.Error.Status {
+		case ltypes.GEM_ERROR_UNAUTHENTICATED, ltypes.GEM_ERROR_PERMISSION_DENIED:
+			return nil, fmt.Errorf("the user is not authenticated: %s", response.Error.Message)
+		case ltypes.GEM_ERROR_INVALID_ARGUMENT:
+			return nil, fmt.Errorf("there was a validation error: %s", response.Error.Message)
+		case ltypes.GEM_ERROR_RESOURCE_EXHAUSTED:
+			logger.WarnContext(ctx, "The model is exhasted, waiting 2 seconds before trying again")
+			if err := sleepContext(ctx, time.Second*2); err != nil {
+				return nil, err
+			}
+		case ltypes.GEM_ERROR_INTERNAL, ltypes.GEM_ERROR_UNAVAILABLE:
+			logger.WarnContext(ctx, "there was an internal error. waiting 2 seconds before trying again")
+			if err := sleepContext(ctx, time.Second*2); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("there was an unknown issue with the request: [%s]: %s", response.Error.Status, response.Error.Message)
+		}
+
+		recordRetryableError(ctx, e.metrics, metrics.OperationEmbeddings, genAISystemGemini, e.opts.Model, attempt, string(response.Error.Status))
+
+		if attempt < retries-1 {
+			sleep := backoff + time.Duration(rand.Intn(1000))*time.Millisecond // Add jitter
+			if err := sleepContext(ctx, sleep); err != nil {
+				return nil, err
+			}
+			backoff *= 2 // Double the backoff interval
+		} else if statusCode != 200 {
+			return nil, fmt.Errorf("there was an issue with the request and could not recover: %s", e.redactor.Body(body, apiKey))
+		}
+	}
+
+	return nil, err
+}
@@ -0,0 +1,235 @@
+package gollm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/jake-landersweb/gollm/v2/src/ltypes"
+	"github.com/jake-landersweb/gollm/v2/src/metrics"
+	"github.com/jake-landersweb/gollm/v2/src/tokens"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const OLLAMA_EMBEDDINGS_MODEL = "nomic-embed-text"
+
+// Struct to handle the creation lifecycle when using embeddings models served by Ollama
+type OllamaEmbeddings struct {
+	opts     *OllamaEmbeddingsOpts
+	tracer   trace.Tracer
+	metrics  metrics.Metrics
+	redactor *redactor
+
+	usageRecords []*tokens.UsageRecord
+}
+
+// Optional configurations to customize the usage of the model.
+// This struct can be passed in as nil, and reasonable and functional defaults will be used.
+type OllamaEmbeddingsOpts struct {
+	// Name of the model as known by Ollama, without the `ollama/` prefix. Defaults to `nomic-embed-text`
+	Model string
+
+	// Optionally reduce the size of the vectors. Only supported by some models
+	EmbeddingsDimentions int
+
+	// Defaults to `http://localhost:11434`
+	BaseUrl string
+
+	// How long the model stays loaded after a request, such as `10m`. If not specified, the server default is used.
+	KeepAlive string
+
+	// Optionally pass the http client used to send all requests, such as one with a custom transport.
+	HttpClient *http.Client
+
+	// Optionally trace embeddings with OpenTelemetry. If not specified, the global provider will be used.
+	TracerProvider trace.TracerProvider
+
+	// Optionally collect metrics on embeddings. If not specified, no metrics are collected.
+	Metrics metrics.Metrics
+
+	// Optionally configure what is masked from logged requests and responses.
+	Redaction *RedactionOpts
+}
+
+func NewOllamaEmbeddings(opts *OllamaEmbeddingsOpts) *OllamaEmbeddings {
+	if opts == nil {
+		opts = &OllamaEmbeddingsOpts{}
+	}
+	if opts.Model == "" {
+		opts.Model = OLLAMA_EMBEDDINGS_MODEL
+	}
+	if opts.BaseUrl == "" {
+		opts.BaseUrl = ollama_base_url
+	}
+	if opts.HttpClient == nil {
+		opts.HttpClient = &http.Client{}
+	}
+
+	return &OllamaEmbeddings{
+		opts:     opts,
+		tracer:   newTracer(opts.TracerProvider),
+		metrics:  metrics.OrNoop(opts.Metrics),
+		redactor: newRedactor(opts.Redaction),
+	}
+}
+
+func (e *OllamaEmbeddings) Embed(
+	ctx context.Context,
+	logger *slog.Logger,
+	args *EmbedArgs,
+) (*EmbedResponse, error) {
+	if logger == nil {
+		logger = discardLogger()
+	}
+
+	ctx, span := e.tracer.Start(ctx, fmt.Sprintf("%s %s", genAIOperationEmbeddings, e.opts.Model),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attrGenAIOperationName.String(genAIOperationEmbeddings),
+			attrGenAISystem.String(genAISystemOllama),
+			attrGenAIRequestModel.String(e.opts.Model),
+		),
+	)
+	defer span.End()
+
+	// chunk the input
+	if err := args.IsValid(); err != nil {
+		err = fmt.Errorf("invalid arguments: %s", err)
+		recordSpanError(span, err)
+		return nil, err
+	}
+
+	pieces, err := args.chunk()
+	if err != nil {
+		err = fmt.Errorf("failed to chunk the content: %s", err)
+		recordSpanError(span, err)
+		return nil, err
+	}
+	chunks := chunkTexts(pieces)
+	span.SetAttributes(attrEmbeddingsChunks.Int(len(chunks)))
+	e.metrics.ObserveEmbeddingChunks(genAISystemOllama, e.opts.Model, len(chunks))
+
+	start := time.Now()
+	response, err := e.ollamaEmbed(ctx, logger, chunks)
+	if err != nil {
+		observeRequest(e.metrics, metrics.OperationEmbeddings, genAISystemOllama, e.opts.Model, start, nil, err)
+		recordSpanError(span, err)
+		return nil, err
+	}
+	if len(response.Embeddings) != len(chunks) {
+		err = fmt.Errorf("expected %d embeddings, received %d", len(chunks), len(response.Embeddings))
+		observeRequest(e.metrics, metrics.OperationEmbeddings, genAISystemOllama, e.opts.Model, start, nil, err)
+		recordSpanError(span, err)
+		return nil, err
+	}
+
+	// track token usage
+	usageRecord := tokens.NewUsageRecordFromOllamaUsage(e.opts.Model, &response.OllamaUsage)
+	e.usageRecords = append(e.usageRecords, usageRecord)
+	span.SetAttributes(usageAttributes(usageRecord)...)
+	observeRequest(e.metrics, metrics.OperationEmbeddings, genAISystemOllama, e.opts.Model, start, usageRecord, nil)
+
+	// pair the vectors with their chunks
+	list := make([]*ltypes.EmbeddingsData, 0)
+	for idx := range chunks {
+		list = append(list, args.embeddingsData(idx, pieces[idx], response.Embeddings[idx]))
+	}
+
+	return &EmbedResponse{
+		Embeddings: list,
+		Usage:      usageRecord,
+	}, nil
+}
+
+func (e *OllamaEmbeddings) GetUsageRecords() []*tokens.UsageRecord {
+	return e.usageRecords
+}
+
+func (e *OllamaEmbeddings) ollamaEmbed(
+	ctx context.Context,
+	logger *slog.Logger,
+	input []string,
+) (*ltypes.OllamaEmbeddingResponse, error) {
+	// create the body
+	comprequest := ltypes.OllamaEmbeddingRequest{
+		Model:      e.opts.Model,
+		Input:      input,
+		Dimensions: e.opts.EmbeddingsDimentions,
+		KeepAlive:  e.opts.KeepAlive,
+	}
+
+	enc, err := json.Marshal(&comprequest)
+	if err != nil {
+		return nil, fmt.Errorf("there was an issue encoding the body into json: %v", err)
+	}
+
+	logger.DebugContext(ctx, "Request body", "body", e.redactor.Body(enc))
+
+	// send the request
+	client := e.opts.HttpClient
+	url := e.opts.BaseUrl + ollama_embed_path
+
+	retries := 3
+	backoff := 1 * time.Second
+
+	for attempt := 0; attempt < retries; attempt++ {
+		logger.InfoContext(ctx, "Sending embeddings request...", "chunks", len(input))
+		statusCode, body, err := sendAttempt(ctx, e.tracer, client, genAISystemOllama, e.opts.Model, attempt, func(ctx context.Context) (*http.Request, error) {
+			req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(enc))
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Content-Type", "application/json")
+			return req, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		logger.InfoContext(ctx, "Completed request", "statusCode", statusCode)
+
+		// parse into the embeddings response object
+		var response ltypes.OllamaEmbeddingResponse
+		if err = json.Unmarshal(body, &response); err != nil {
+			return nil, fmt.Errorf("there was an issue unmarshalling the request body: %v", err)
+		}
+
+		if statusCode == 200 && response.Error == "" {
+			return &response, nil
+		}
+
+		// act based on the status code
+		switch statusCode {
+		case http.StatusBadRequest:
+			return nil, fmt.Errorf("there was a validation error: %s", response.Error)
+		case http.StatusNotFound:
+			return nil, fmt.Errorf("the model was not found, it may need to be pulled first: %s", response.Error)
+		case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+			logger.WarnContext(ctx, "The Ollama server is busy. Waiting for an additional 2 seconds...")
+			if err := sleepContext(ctx, time.Second*2); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("there was an unknown error: [%d]: %s", statusCode, response.Error)
+		}
+
+		recordRetryableError(ctx, e.metrics, metrics.OperationEmbeddings, genAISystemOllama, e.opts.Model, attempt, http.StatusText(statusCode))
+
+		if attempt < retries-1 {
+			sleep := backoff + time.Duration(rand.Intn(1000))*time.Millisecond // Add jitter
+			if err := sleepContext(ctx, sleep); err != nil {
+				return nil, err
+			}
+			backoff *= 2 // Double the backoff interval
+		} else if statusCode != 200 {
+			return nil, fmt.Errorf("there was an issue with the request and could not recover: %s", e.redactor.Body(body))
+		}
+	}
+
+	return nil, err
+}
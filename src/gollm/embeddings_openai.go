@@ -5,7 +5,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log/slog"
 	"math/rand"
 	"net/http"
@@ -13,14 +12,20 @@ import (
 	"time"
 
 	"github.com/jake-landersweb/gollm/v2/src/ltypes"
+	"github.com/jake-landersweb/gollm/v2/src/metrics"
 	"github.com/jake-landersweb/gollm/v2/src/tokens"
-	"github.com/pgvector/pgvector-go"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Struct to handle the creation lifecycle when using OpenAI Embeddings
 type OpenAIEmbeddings struct {
-	userId string
-	opts   *OpenAIEmbeddingsOpts
+	userId   string
+	opts     *OpenAIEmbeddingsOpts
+	system   string
+	target   func(ctx context.Context) (*embeddingsTarget, error)
+	tracer   trace.Tracer
+	metrics  metrics.Metrics
+	redactor *redactor
 
 	usageRecords []*tokens.UsageRecord
 }
@@ -32,8 +37,33 @@ type OpenAIEmbeddingsOpts struct {
 	EmbeddingsDimentions int
 	BaseUrl              string
 
+	// The most chunks sent in a single request. Defaults to 2048, the limit of the api
+	MaxBatchSize int
+
+	// The most estimated tokens sent in a single request. Defaults to 300,000, the limit of the api
+	MaxBatchTokens int
+
+	// The most requests sent at the same time when the chunks are split into batches. Defaults to 4
+	MaxConcurrency int
+
+	// Request the vectors as base64 encoded float32 values instead of lists of numbers, which makes
+	// the responses about a quarter of the size
+	Base64 bool
+
+	// Optionally pass the http client used to send all requests, such as one with a custom transport.
+	HttpClient *http.Client
+
 	// Optionally pass in an api key. If not specified, the environment variable `OPENAI_API_KEY` will be read.
 	OpenAIApiKey string
+
+	// Optionally trace embeddings with OpenTelemetry. If not specified, the global provider will be used.
+	TracerProvider trace.TracerProvider
+
+	// Optionally collect metrics on embeddings. If not specified, no metrics are collected.
+	Metrics metrics.Metrics
+
+	// Optionally configure what is masked from logged requests and responses. Api keys are always masked.
+	Redaction *RedactionOpts
 }
 
 func NewOpenAIEmbeddings(userId string, opts *OpenAIEmbeddingsOpts) *OpenAIEmbeddings {
@@ -49,11 +79,51 @@ func NewOpenAIEmbeddings(userId string, opts *OpenAIEmbeddingsOpts) *OpenAIEmbed
 	if opts.BaseUrl == "" {
 		opts.BaseUrl = openai_embeddings_base_url
 	}
+	if opts.MaxBatchSize == 0 {
+		opts.MaxBatchSize = openai_embeddings_batch_size
+	}
+	if opts.MaxBatchTokens == 0 {
+		opts.MaxBatchTokens = openai_embeddings_batch_tokens
+	}
+	if opts.MaxConcurrency == 0 {
+		opts.MaxConcurrency = openai_embeddings_concurrency
+	}
+	if opts.HttpClient == nil {
+		opts.HttpClient = &http.Client{}
+	}
+
+	e := &OpenAIEmbeddings{
+		userId:   userId,
+		opts:     opts,
+		system:   genAISystemOpenAI,
+		tracer:   newTracer(opts.TracerProvider),
+		metrics:  metrics.OrNoop(opts.Metrics),
+		redactor: newRedactor(opts.Redaction),
+	}
+	e.target = e.openAITarget
+	return e
+}
+
+// Where and how an embeddings request is sent
+type embeddingsTarget struct {
+	url     string
+	headers map[string]string
+	secret  string // redacted from logs
+}
 
-	return &OpenAIEmbeddings{
-		userId: userId,
-		opts:   opts,
+func (e *OpenAIEmbeddings) openAITarget(ctx context.Context) (*embeddingsTarget, error) {
+	apiKey := e.opts.OpenAIApiKey
+	if apiKey == "" {
+		apiKey = os.Getenv("OPENAI_API_KEY")
+		if apiKey == "" || apiKey == "null" {
+			return nil, fmt.Errorf("the env variable `OPENAI_API_KEY` is required to be set")
+		}
 	}
+	return &embeddingsTarget{
+		url:     e.opts.BaseUrl,
+		headers: map[string]string{"Authorization": "Bearer " + apiKey},
+		secret:  apiKey,
+	}, nil
 }
 
 type EmbedResponse struct {
@@ -66,42 +136,88 @@ func (e *OpenAIEmbeddings) Embed(
 	logger *slog.Logger,
 	args *EmbedArgs,
 ) (*EmbedResponse, error) {
+	if logger == nil {
+		logger = discardLogger()
+	}
+
+	ctx, span := e.tracer.Start(ctx, fmt.Sprintf("%s %s", genAIOperationEmbeddings, e.opts.Model),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attrGenAIOperationName.String(genAIOperationEmbeddings),
+			attrGenAISystem.String(e.system),
+			attrGenAIRequestModel.String(e.opts.Model),
+		),
+	)
+	defer span.End()
+
 	// chunk the input
 	if err := args.IsValid(); err != nil {
-		return nil, fmt.Errorf("invalid arguments: %s", err)
+		err = fmt.Errorf("invalid arguments: %s", err)
+		recordSpanError(span, err)
+		return nil, err
 	}
 
-	var err error
-	chunks := args.InputChunks
-	if len(chunks) == 0 {
-		chunks, err = args.ChunkingFunction(args.Input)
-		if err != nil {
-			return nil, fmt.Errorf("failed to chunk the content: %s", err)
-		}
+	pieces, err := args.chunk()
+	if err != nil {
+		err = fmt.Errorf("failed to chunk the content: %s", err)
+		recordSpanError(span, err)
+		return nil, err
 	}
+	chunks := chunkTexts(pieces)
+	span.SetAttributes(attrEmbeddingsChunks.Int(len(chunks)))
+	e.metrics.ObserveEmbeddingChunks(e.system, e.opts.Model, len(chunks))
 
-	response, err := e.openAIEmbed(ctx, logger, chunks)
-	if err != nil {
+	start := time.Now()
+	batches := splitEmbeddingsBatches(chunks, e.opts.MaxBatchSize, e.opts.MaxBatchTokens, OPENAI_EMBEDDINGS_INPUT_MAX)
+	vectors, records, failures := embedInBatches(ctx, logger, chunks, batches, e.opts.MaxConcurrency, e.sendBatch)
+	if len(chunks) > 0 && len(failures) == len(chunks) {
+		// nothing was embedded, so report the error of the first batch
+		err = failures[0].Err
+		observeRequest(e.metrics, metrics.OperationEmbeddings, e.system, e.opts.Model, start, nil, err)
+		recordSpanError(span, err)
 		return nil, err
 	}
 
-	// track token usage
-	usageRecord := tokens.NewUsageRecordFromGPTUsage(e.opts.Model, &response.Usage)
+	// track token usage of the batches that succeeded
+	usageRecord := tokens.MergeUsageRecords(records...)
 	e.usageRecords = append(e.usageRecords, usageRecord)
+	span.SetAttributes(usageAttributes(usageRecord)...)
+
+	if len(failures) != 0 {
+		err = &PartialEmbedError{Chunks: failures}
+		recordSpanError(span, err)
+	}
+	observeRequest(e.metrics, metrics.OperationEmbeddings, e.system, e.opts.Model, start, usageRecord, err)
 
-	// convert openai response into pgvector data types
+	// pair the vectors with their chunks
 	list := make([]*ltypes.EmbeddingsData, 0)
 	for idx := range chunks {
-		list = append(list, &ltypes.EmbeddingsData{
-			Raw:       chunks[idx],
-			Embedding: pgvector.NewVector(convertSlice(response.Data[idx].Embedding, func(i float64) float32 { return float32(i) })),
-		})
+		if vectors[idx] == nil {
+			continue
+		}
+		list = append(list, args.embeddingsData(idx, pieces[idx], vectors[idx]))
 	}
 
 	return &EmbedResponse{
 		Embeddings: list,
 		Usage:      usageRecord,
-	}, nil
+	}, err
+}
+
+// Sends a batch of chunks to OpenAI, placing the vectors by their index as the data is not guaranteed to be in order
+func (e *OpenAIEmbeddings) sendBatch(ctx context.Context, logger *slog.Logger, batch []string) ([][]float32, *tokens.UsageRecord, error) {
+	response, err := e.openAIEmbed(ctx, logger, batch)
+	if err != nil {
+		return nil, nil, err
+	}
+	vectors := make([][]float32, len(batch))
+	for _, item := range response.Data {
+		if item.Index < 0 || item.Index >= len(batch) {
+			return nil, nil, fmt.Errorf("the response contained an embedding for an unknown index: %d", item.Index)
+		}
+		vectors[item.Index] = item.Embedding
+	}
+	return vectors, tokens.NewUsageRecordFromGPTUsage(e.opts.Model, &response.Usage), nil
 }
 
 func (e *OpenAIEmbeddings) GetUsageRecords() []*tokens.UsageRecord {
@@ -113,13 +229,9 @@ func (e *OpenAIEmbeddings) openAIEmbed(
 	logger *slog.Logger,
 	input []string,
 ) (*ltypes.OpenAIEmbeddingResponse, error) {
-	apiKey := e.opts.OpenAIApiKey
-	if apiKey == "" {
-		logger.DebugContext(ctx, "Reading api key from the environment")
-		apiKey = os.Getenv("OPENAI_API_KEY")
-		if apiKey == "" || apiKey == "null" {
-			return nil, fmt.Errorf("the env variable `OPENAI_API_KEY` is required to be set")
-		}
+	target, err := e.target(ctx)
+	if err != nil {
+		return nil, err
 	}
 
 	// create the body
@@ -129,41 +241,41 @@ func (e *OpenAIEmbeddings) openAIEmbed(
 		Dimensions: e.opts.EmbeddingsDimentions,
 		User:       e.userId,
 	}
+	if e.opts.Base64 {
+		comprequest.EncodingFormat = "base64"
+	}
 
 	enc, err := json.Marshal(&comprequest)
 	if err != nil {
 		return nil, fmt.Errorf("there was an issue encoding the body into json: %v", err)
 	}
 
-	// create the request
-	req, err := http.NewRequest("POST", e.opts.BaseUrl, bytes.NewBuffer(enc))
-	if err != nil {
-		return nil, fmt.Errorf("there was an issue creating the http request: %v", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+apiKey)
+	logger.DebugContext(ctx, "Request body", "body", e.redactor.Body(enc, target.secret))
 
 	// send the request
-	client := &http.Client{}
+	client := e.opts.HttpClient
 
 	retries := 3
 	backoff := 1 * time.Second
 
 	for attempt := 0; attempt < retries; attempt++ {
 		logger.InfoContext(ctx, "Sending embeddings request...", "chunks", len(input))
-		resp, err := client.Do(req)
-		if err != nil {
-			return nil, fmt.Errorf("there was an unknown issue with the request: %v", err)
-		}
-
-		// read the body
-		defer resp.Body.Close()
-		body, err := io.ReadAll(resp.Body)
+		statusCode, body, err := sendAttempt(ctx, e.tracer, client, e.system, e.opts.Model, attempt, func(ctx context.Context) (*http.Request, error) {
+			req, err := http.NewRequestWithContext(ctx, "POST", target.url, bytes.NewBuffer(enc))
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Content-Type", "application/json")
+			for key, value := range target.headers {
+				req.Header.Set(key, value)
+			}
+			return req, nil
+		})
 		if err != nil {
-			return nil, fmt.Errorf("there was an issue reading the body: %v", err)
+			return nil, err
 		}
 
-		logger.InfoContext(ctx, "Completed request", "statusCode", resp.StatusCode)
+		logger.InfoContext(ctx, "Completed request", "statusCode", statusCode)
 
 		// parse into the completion response object
 		var response ltypes.OpenAIEmbeddingResponse
@@ -180,34 +292,48 @@ func (e *OpenAIEmbeddings) openAIEmbed(
 			// act based on the error
 			switch response.Error.Type {
 			case ltypes.GPT_ERROR_INVALID:
-				return nil, fmt.Errorf("there was a validation error: %s", string(body))
+				return nil, fmt.Errorf("there was a validation error: %s", e.redactor.Body(body, target.secret))
 			case ltypes.GPT_ERROR_RATE_LIMIT:
 				// rate limit, so wait some extra time and continue
 				logger.WarnContext(ctx, "Rate limit error hit. Waiting for an additional 2 seconds...")
-				time.Sleep(time.Second * 2)
+				if err := sleepContext(ctx, time.Second*2); err != nil {
+					return nil, err
+				}
 			// case ltypes.GPT_ERROR_TOKENS_LIMIT:
 			case ltypes.GPT_ERROR_AUTH:
-				return nil, fmt.Errorf("the user is not authenticated: %s", string(body))
+				return nil, fmt.Errorf("the user is not authenticated: %s", e.redactor.Body(body, target.secret))
 			case ltypes.GPT_ERROR_NOT_FOUND:
-				return nil, fmt.Errorf("the requested resource was not found: %s", string(body))
+				return nil, fmt.Errorf("the requested resource was not found: %s", e.redactor.Body(body, target.secret))
 			case ltypes.GPT_ERROR_SERVER:
 				// internal server error, wait and try again
-				logger.WarnContext(ctx, "There was an issue on OpenAI's side. Waiting 2 seconds and trying again ...", "body", string(body))
-				time.Sleep(time.Second * 2)
+				logger.WarnContext(ctx, "There was an issue on OpenAI's side. Waiting 2 seconds and trying again ...", "body", e.redactor.Body(body, target.secret))
+				if err := sleepContext(ctx, time.Second*2); err != nil {
+					return nil, err
+				}
 			case ltypes.GPT_ERROR_PERMISSION:
-				return nil, fmt.Errorf("the requested resource was not found: %s", string(body))
+				return nil, fmt.Errorf("the requested resource was not found: %s", e.redactor.Body(body, target.secret))
 			default:
-				return nil, fmt.Errorf("there was an unknown error: %s", string(body))
+				// azure does not always send an error type, so retry based on the status code
+				if statusCode != http.StatusTooManyRequests && statusCode < 500 {
+					return nil, fmt.Errorf("there was an unknown error: %s", e.redactor.Body(body, target.secret))
+				}
+				logger.WarnContext(ctx, "The provider returned a retryable status. Waiting 2 seconds and trying again ...", "statusCode", statusCode)
+				if err := sleepContext(ctx, time.Second*2); err != nil {
+					return nil, err
+				}
 			}
+
+			recordRetryableError(ctx, e.metrics, metrics.OperationEmbeddings, e.system, e.opts.Model, attempt, string(response.Error.Type))
 		}
 
 		if attempt < retries-1 {
 			sleep := backoff + time.Duration(rand.Intn(1000))*time.Millisecond // Add jitter
-			time.Sleep(sleep)
+			if err := sleepContext(ctx, sleep); err != nil {
+				return nil, err
+			}
 			backoff *= 2 // Double the backoff interval
-		} else if resp != nil && resp.StatusCode != 200 {
-			body, _ := io.ReadAll(resp.Body)
-			return nil, fmt.Errorf("there was an issue with the request and could not recover: %s", string(body))
+		} else if statusCode != 200 {
+			return nil, fmt.Errorf("there was an issue with the request and could not recover: %s", e.redactor.Body(body, target.secret))
 		}
 	}
 
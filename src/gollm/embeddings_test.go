@@ -2,9 +2,20 @@ package gollm
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/jake-landersweb/gollm/v2/src/cache"
+	"github.com/jake-landersweb/gollm/v2/src/chunking"
+	"github.com/jake-landersweb/gollm/v2/src/ltypes"
+	"github.com/jake-landersweb/gollm/v2/src/tokens"
 	"github.com/stretchr/testify/require"
 )
 
@@ -14,7 +25,7 @@ func TestOpenAIEmbeddings(t *testing.T) {
 	input := "Hello world, this is a string that I am going to convert into an embedding!"
 
 	// send the embeddings request
-	embeddings := NewOpenAIEmbeddings(test_user_id, nil)
+	embeddings := newTestOpenAIEmbeddings(t)
 	response, err := embeddings.Embed(ctx, logger, &EmbedArgs{
 		Input: input,
 	})
@@ -23,3 +34,601 @@ func TestOpenAIEmbeddings(t *testing.T) {
 	require.Equal(t, 1, len(response.Embeddings))
 	require.NotNil(t, response.Usage)
 }
+
+func TestOpenAIEmbeddingsBatching(t *testing.T) {
+	ctx := context.TODO()
+	logger := defaultLogger(slog.LevelInfo)
+
+	var mu sync.Mutex
+	inFlight, maxInFlight, requests := 0, 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		requests++
+		maxInFlight = max(maxInFlight, inFlight)
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+		}()
+		time.Sleep(20 * time.Millisecond)
+
+		var request ltypes.OpenAIEmbeddingRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// respond out of order, so the vectors have to be placed by their index
+		response := ltypes.OpenAIEmbeddingResponse{Usage: ltypes.GPTUsage{PromptTokens: len(request.Input), TotalTokens: len(request.Input)}}
+		for idx := len(request.Input) - 1; idx >= 0; idx-- {
+			var value float64
+			fmt.Sscanf(request.Input[idx], "chunk %f", &value)
+			response.Data = append(response.Data, ltypes.OpenAIEmbeddingData{Embedding: []float32{float32(value)}, Index: idx})
+		}
+		json.NewEncoder(w).Encode(&response)
+	}))
+	defer server.Close()
+
+	embeddings := NewOpenAIEmbeddings(test_user_id, &OpenAIEmbeddingsOpts{
+		BaseUrl:        server.URL,
+		OpenAIApiKey:   "test",
+		MaxBatchSize:   10,
+		MaxConcurrency: 2,
+	})
+
+	chunks := make([]string, 45)
+	for i := range chunks {
+		chunks[i] = fmt.Sprintf("chunk %d", i)
+	}
+	response, err := embeddings.Embed(ctx, logger, &EmbedArgs{InputChunks: chunks})
+	require.NoError(t, err)
+
+	require.Equal(t, 5, requests)
+	require.Equal(t, 2, maxInFlight)
+	require.Len(t, response.Embeddings, 45)
+	for i, item := range response.Embeddings {
+		require.Equal(t, chunks[i], item.Raw)
+		require.Equal(t, []float32{float32(i)}, item.Embedding)
+	}
+
+	// the usage of every batch is merged into one record
+	require.Equal(t, 45, response.Usage.InputTokens)
+	require.Len(t, embeddings.GetUsageRecords(), 1)
+}
+
+func TestOpenAIEmbeddingsPartialFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request ltypes.OpenAIEmbeddingRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, item := range request.Input {
+			if item == "bad" {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error": {"message": "invalid input", "type": "invalid_request_error"}}`))
+				return
+			}
+		}
+
+		response := ltypes.OpenAIEmbeddingResponse{Usage: ltypes.GPTUsage{PromptTokens: 1, TotalTokens: 1}}
+		for idx := range request.Input {
+			response.Data = append(response.Data, ltypes.OpenAIEmbeddingData{Embedding: []float32{0.5}, Index: idx})
+		}
+		json.NewEncoder(w).Encode(&response)
+	}))
+	defer server.Close()
+
+	embeddings := NewOpenAIEmbeddings(test_user_id, &OpenAIEmbeddingsOpts{
+		BaseUrl:      server.URL,
+		OpenAIApiKey: "test",
+		MaxBatchSize: 2,
+	})
+	response, err := embeddings.Embed(context.TODO(), nil, &EmbedArgs{InputChunks: []string{"a", "b", "c", "bad", "e"}})
+
+	// the chunks of the failed batch are reported, and the rest are returned
+	var partial *PartialEmbedError
+	require.ErrorAs(t, err, &partial)
+	require.Len(t, partial.Chunks, 2)
+	require.Equal(t, 2, partial.Chunks[0].Index)
+	require.Equal(t, "bad", partial.Chunks[1].Raw)
+	require.ErrorContains(t, partial.Chunks[1], "validation error")
+
+	require.NotNil(t, response)
+	require.Len(t, response.Embeddings, 3)
+	require.Equal(t, "e", response.Embeddings[2].Raw)
+	require.Equal(t, 4, response.Embeddings[2].Index)
+	require.Equal(t, 2, response.Usage.InputTokens)
+
+	// when every chunk fails, only the error is returned
+	response, err = embeddings.Embed(context.TODO(), nil, &EmbedArgs{InputChunks: []string{"bad"}})
+	require.ErrorContains(t, err, "validation error")
+	require.Nil(t, response)
+}
+
+func TestOpenAIEmbeddingsBase64(t *testing.T) {
+	// 0.5 and -2 as little endian float32 values
+	server, requests := newTestServer(t, respondWith(200, `{"object": "list", "data": [{"object": "embedding", "index": 0, "embedding": "AAAAPwAAAMA="}], "model": "text-embedding-3-small", "usage": {"prompt_tokens": 2, "total_tokens": 2}}`))
+	embeddings := NewOpenAIEmbeddings(test_user_id, &OpenAIEmbeddingsOpts{
+		BaseUrl:      server.URL,
+		OpenAIApiKey: "test",
+		Base64:       true,
+	})
+	response, err := embeddings.Embed(context.TODO(), nil, &EmbedArgs{Input: "hello"})
+	require.NoError(t, err)
+	require.Equal(t, "base64", requests.body(t)["encoding_format"])
+	require.Equal(t, []float32{0.5, -2}, response.Embeddings[0].Embedding)
+
+	// lists of numbers are still decoded, and invalid strings fail
+	var data ltypes.OpenAIEmbeddingData
+	require.NoError(t, json.Unmarshal([]byte(`{"embedding": [0.25, 1]}`), &data))
+	require.Equal(t, ltypes.OpenAIEmbeddingVector{0.25, 1}, data.Embedding)
+	require.Error(t, json.Unmarshal([]byte(`{"embedding": "AAA="}`), &data))
+}
+
+func TestEmbeddingsNoChunks(t *testing.T) {
+	server, requests := newTestServer(t, respondWith(200, `{"data": [], "usage": {"prompt_tokens": 0, "total_tokens": 0}}`))
+	embeddings := NewOpenAIEmbeddings(test_user_id, &OpenAIEmbeddingsOpts{BaseUrl: server.URL, OpenAIApiKey: "test"})
+
+	// a chunking function that returns nothing is an error instead of an empty request
+	_, err := embeddings.Embed(context.TODO(), nil, &EmbedArgs{
+		Input:            "Hello world",
+		ChunkingFunction: func(input string) ([]string, error) { return nil, nil },
+	})
+	require.ErrorContains(t, err, "no chunks to embed")
+	require.Empty(t, requests.all())
+}
+
+func TestSplitEmbeddingsBatches(t *testing.T) {
+	long := strings.Repeat("word ", 10_000)
+	chunks := []string{"a", "b", long, "c", "d", "e"}
+
+	// chunks over the input limit are sent on their own
+	batches := splitEmbeddingsBatches(chunks, 2, openai_embeddings_batch_tokens, OPENAI_EMBEDDINGS_INPUT_MAX)
+	require.Equal(t, []embeddingsBatch{{0, 2}, {2, 3}, {3, 5}, {5, 6}}, batches)
+
+	// batches are cut at the token limit
+	batches = splitEmbeddingsBatches([]string{"hello world", "hello world", "hello world"}, 10, 8, OPENAI_EMBEDDINGS_INPUT_MAX)
+	require.Equal(t, []embeddingsBatch{{0, 2}, {2, 3}}, batches)
+}
+
+func TestEmbedInBatchesCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	logger := slog.Default()
+
+	chunks := []string{"a", "b", "c", "d"}
+	batches := []embeddingsBatch{{0, 1}, {1, 2}, {2, 3}, {3, 4}}
+
+	// the first batch cancels the context, so the remaining batches are never sent
+	sent := 0
+	vectors, records, failures := embedInBatches(ctx, logger, chunks, batches, 1, func(ctx context.Context, logger *slog.Logger, batch []string) ([][]float32, *tokens.UsageRecord, error) {
+		sent += 1
+		cancel()
+		return [][]float32{{0.1}}, &tokens.UsageRecord{InputTokens: 1}, nil
+	})
+
+	require.Equal(t, 1, sent)
+	require.Equal(t, []float32{0.1}, vectors[0])
+	require.NotNil(t, records[0])
+	require.Len(t, failures, 3)
+	for i, failure := range failures {
+		require.Equal(t, i+1, failure.Index)
+		require.ErrorIs(t, failure.Err, context.Canceled)
+	}
+}
+
+func TestOllamaEmbeddings(t *testing.T) {
+	ctx := context.TODO()
+	logger := defaultLogger(slog.LevelInfo)
+
+	requests := &testRequests{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.record(r)
+		w.Write([]byte(`{"model": "nomic-embed-text", "embeddings": [[0.1, 0.2, 0.3], [0.4, 0.5, 0.6]], "prompt_eval_count": 12}`))
+	}))
+	defer server.Close()
+
+	embeddings := NewOllamaEmbeddings(&OllamaEmbeddingsOpts{BaseUrl: server.URL})
+	response, err := embeddings.Embed(ctx, logger, &EmbedArgs{
+		InputChunks: []string{"Hello world", "Goodbye world"},
+	})
+	require.Nil(t, err)
+
+	sent := requests.last(t)
+	require.Equal(t, ollama_embed_path, sent.URL.Path)
+	var request ltypes.OllamaEmbeddingRequest
+	require.NoError(t, json.Unmarshal(sent.Body, &request))
+	require.Equal(t, OLLAMA_EMBEDDINGS_MODEL, request.Model)
+	require.Equal(t, []string{"Hello world", "Goodbye world"}, request.Input)
+
+	require.Equal(t, 2, len(response.Embeddings))
+	require.Equal(t, "Goodbye world", response.Embeddings[1].Raw)
+	require.Equal(t, []float32{0.4, 0.5, 0.6}, response.Embeddings[1].Embedding)
+	require.Equal(t, 12, response.Usage.InputTokens)
+	require.Len(t, embeddings.GetUsageRecords(), 1)
+}
+
+func TestEmbeddingsChunker(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request ltypes.OllamaEmbeddingRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		response := map[string]any{"model": request.Model, "prompt_eval_count": 1}
+		vectors := make([][]float32, 0)
+		for range request.Input {
+			vectors = append(vectors, []float32{0.5})
+		}
+		response["embeddings"] = vectors
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	input := "# Intro\n\nHello world.\n\n# Usage\n\nCall embed."
+	embeddings := NewOllamaEmbeddings(&OllamaEmbeddingsOpts{BaseUrl: server.URL})
+	response, err := embeddings.Embed(context.TODO(), nil, &EmbedArgs{
+		Input:     input,
+		Chunker:   chunking.NewMarkdownChunker(nil),
+		SourceID:  "readme",
+		Tokenizer: func(input string) int { return len(strings.Fields(input)) },
+	})
+	require.NoError(t, err)
+
+	// the embeddings point back to their span of the input
+	require.Len(t, response.Embeddings, 2)
+	for idx, item := range response.Embeddings {
+		require.Equal(t, input[item.Start:item.End], item.Raw)
+		require.Equal(t, idx, item.Index)
+		require.Equal(t, "readme", item.SourceID)
+	}
+	require.Equal(t, "# Usage\n\nCall embed.", response.Embeddings[1].Raw)
+	require.Equal(t, "Usage", response.Embeddings[1].Section)
+	require.Equal(t, 4, response.Embeddings[1].Tokens)
+}
+
+func TestGeminiEmbeddings(t *testing.T) {
+	ctx := context.TODO()
+	logger := defaultLogger(slog.LevelInfo)
+
+	recorded := &testRequests{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request ltypes.GemBatchEmbedRequest
+		if err := json.Unmarshal(recorded.record(r).Body, &request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		response := ltypes.GemBatchEmbedResponse{}
+		for range request.Requests {
+			response.Embeddings = append(response.Embeddings, &ltypes.GemContentEmbedding{Values: []float32{0.5, 0.25}})
+		}
+		json.NewEncoder(w).Encode(&response)
+	}))
+	defer server.Close()
+
+	embeddings := NewGeminiEmbeddings(&GeminiEmbeddingsOpts{
+		BaseUrl:              server.URL,
+		GeminiApiKey:         "gemini-secret-key",
+		EmbeddingsDimentions: 256,
+		Title:                "Greetings",
+	})
+
+	// chunks are sent in batches of 100
+	chunks := make([]string, 150)
+	for i := range chunks {
+		chunks[i] = fmt.Sprintf("Hello world %d", i)
+	}
+	response, err := embeddings.Embed(ctx, logger, &EmbedArgs{InputChunks: chunks})
+	require.NoError(t, err)
+	for _, item := range recorded.all() {
+		require.Equal(t, "/text-embedding-004:batchEmbedContents", item.URL.Path)
+		require.Equal(t, "gemini-secret-key", item.Header.Get("x-goog-api-key"))
+	}
+	requests := decodeRequests[ltypes.GemBatchEmbedRequest](t, recorded)
+	require.Len(t, requests, 2)
+	require.Len(t, requests[0].Requests, 100)
+	require.Len(t, requests[1].Requests, 50)
+
+	first := requests[0].Requests[0]
+	require.Equal(t, "models/text-embedding-004", first.Model)
+	require.Equal(t, "Hello world 0", first.Content.Parts[0].Text)
+	require.Equal(t, ltypes.GEM_TASK_RETRIEVAL_DOCUMENT, first.TaskType)
+	require.Equal(t, "Greetings", first.Title)
+	require.Equal(t, 256, first.OutputDimensionality)
+
+	require.Len(t, response.Embeddings, 150)
+	require.Equal(t, "Hello world 149", response.Embeddings[149].Raw)
+	require.Equal(t, []float32{0.5, 0.25}, response.Embeddings[149].Embedding)
+	require.Greater(t, response.Usage.InputTokens, 0)
+	require.True(t, response.Usage.Estimated)
+
+	// the title is only sent for documents
+	queries := NewGeminiEmbeddings(&GeminiEmbeddingsOpts{
+		BaseUrl:      server.URL,
+		GeminiApiKey: "gemini-secret-key",
+		TaskType:     ltypes.GEM_TASK_RETRIEVAL_QUERY,
+		Title:        "Greetings",
+	})
+	_, err = queries.Embed(ctx, logger, &EmbedArgs{Input: "Hello"})
+	require.NoError(t, err)
+	requests = decodeRequests[ltypes.GemBatchEmbedRequest](t, recorded)
+	require.Equal(t, ltypes.GEM_TASK_RETRIEVAL_QUERY, requests[2].Requests[0].TaskType)
+	require.Empty(t, requests[2].Requests[0].Title)
+}
+
+func TestGeminiEmbeddingsError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": {"code": 400, "message": "API key not valid. Please pass a valid API key.", "status": "INVALID_ARGUMENT"}}`))
+	}))
+	defer server.Close()
+
+	embeddings := NewGeminiEmbeddings(&GeminiEmbeddingsOpts{BaseUrl: server.URL, GeminiApiKey: "invalid"})
+	_, err := embeddings.Embed(context.TODO(), nil, &EmbedArgs{Input: "Hello"})
+	require.ErrorContains(t, err, "API key not valid")
+}
+
+func TestVoyageEmbeddings(t *testing.T) {
+	ctx := context.TODO()
+	logger := defaultLogger(slog.LevelInfo)
+
+	recorded := &testRequests{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request ltypes.VoyageEmbeddingRequest
+		if err := json.Unmarshal(recorded.record(r).Body, &request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		response := ltypes.VoyageEmbeddingResponse{Usage: &ltypes.VoyageUsage{TotalTokens: 3 * len(request.Input)}}
+		for idx := range request.Input {
+			response.Data = append(response.Data, ltypes.VoyageEmbeddingData{Embedding: []float32{-12, 127}, Index: idx})
+		}
+		json.NewEncoder(w).Encode(&response)
+	}))
+	defer server.Close()
+
+	embeddings := NewVoyageEmbeddings(&VoyageEmbeddingsOpts{
+		BaseUrl:           server.URL,
+		VoyageApiKey:      "voyage-secret-key",
+		InputType:         ltypes.VOYAGE_INPUT_DOCUMENT,
+		EmbeddingType:     ltypes.EMBEDDING_TYPE_INT8,
+		DisableTruncation: true,
+	})
+
+	// chunks are sent in batches of 128
+	chunks := make([]string, 200)
+	for i := range chunks {
+		chunks[i] = fmt.Sprintf("Hello world %d", i)
+	}
+	response, err := embeddings.Embed(ctx, logger, &EmbedArgs{InputChunks: chunks})
+	require.NoError(t, err)
+	for _, item := range recorded.all() {
+		require.Equal(t, "Bearer voyage-secret-key", item.Header.Get("Authorization"))
+	}
+	requests := decodeRequests[ltypes.VoyageEmbeddingRequest](t, recorded)
+	require.Len(t, requests, 2)
+	require.Len(t, requests[0].Input, 128)
+	require.Len(t, requests[1].Input, 72)
+
+	require.Equal(t, VOYAGE_EMBEDDINGS_MODEL, requests[0].Model)
+	require.Equal(t, ltypes.VOYAGE_INPUT_DOCUMENT, requests[0].InputType)
+	require.Equal(t, ltypes.EMBEDDING_TYPE_INT8, requests[0].OutputDtype)
+	require.NotNil(t, requests[0].Truncation)
+	require.False(t, *requests[0].Truncation)
+
+	require.Len(t, response.Embeddings, 200)
+	require.Equal(t, "Hello world 199", response.Embeddings[199].Raw)
+	require.Equal(t, []float32{-12, 127}, response.Embeddings[199].Embedding)
+	require.Equal(t, 600, response.Usage.InputTokens)
+	require.Equal(t, 600, response.Usage.TotalTokens)
+	require.Len(t, embeddings.GetUsageRecords(), 1)
+}
+
+func TestVoyageEmbeddingsBatching(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request ltypes.VoyageEmbeddingRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// respond out of order, so the vectors have to be placed by their index
+		response := ltypes.VoyageEmbeddingResponse{Usage: &ltypes.VoyageUsage{TotalTokens: 1}}
+		for idx := len(request.Input) - 1; idx >= 0; idx-- {
+			response.Data = append(response.Data, ltypes.VoyageEmbeddingData{Embedding: []float32{float32(len(request.Input[idx]))}, Index: idx})
+		}
+		json.NewEncoder(w).Encode(&response)
+	}))
+	defer server.Close()
+
+	// batches are also cut at the token limit of a request
+	embeddings := NewVoyageEmbeddings(&VoyageEmbeddingsOpts{BaseUrl: server.URL, VoyageApiKey: "test", MaxBatchTokens: 20})
+	chunks := []string{"a", "bb", strings.Repeat("word ", 10), "ccc", "dddd"}
+	response, err := embeddings.Embed(context.TODO(), nil, &EmbedArgs{InputChunks: chunks})
+	require.NoError(t, err)
+
+	require.Len(t, response.Embeddings, 5)
+	for i, item := range response.Embeddings {
+		require.Equal(t, chunks[i], item.Raw)
+		require.Equal(t, []float32{float32(len(chunks[i]))}, item.Embedding)
+	}
+
+	// every request reports a single token
+	batches := splitEmbeddingsBatches(chunks, voyage_embeddings_batch_size, 20, voyage_embeddings_input_max)
+	require.Greater(t, len(batches), 1)
+	require.Equal(t, len(batches), response.Usage.InputTokens)
+}
+
+func TestVoyageEmbeddingsError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"detail": "Provided API key is invalid."}`))
+	}))
+	defer server.Close()
+
+	embeddings := NewVoyageEmbeddings(&VoyageEmbeddingsOpts{BaseUrl: server.URL, VoyageApiKey: "invalid"})
+	_, err := embeddings.Embed(context.TODO(), nil, &EmbedArgs{Input: "Hello"})
+	require.ErrorContains(t, err, "Provided API key is invalid")
+}
+
+func TestCohereEmbeddings(t *testing.T) {
+	ctx := context.TODO()
+	logger := defaultLogger(slog.LevelInfo)
+
+	recorded := &testRequests{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request ltypes.CohereEmbedRequest
+		if err := json.Unmarshal(recorded.record(r).Body, &request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		vectors := make([][]float32, 0)
+		for range request.Texts {
+			vectors = append(vectors, []float32{-86, 101})
+		}
+		json.NewEncoder(w).Encode(&ltypes.CohereEmbedResponse{
+			Embeddings: map[ltypes.EmbeddingType][][]float32{ltypes.EMBEDDING_TYPE_BINARY: vectors},
+			Meta:       &ltypes.CohereEmbedMeta{BilledUnits: &ltypes.CohereUsageUnits{InputTokens: float64(2 * len(request.Texts))}},
+		})
+	}))
+	defer server.Close()
+
+	embeddings := NewCohereEmbeddings(&CohereEmbeddingsOpts{
+		BaseUrl:       server.URL,
+		CohereApiKey:  "cohere-secret-key",
+		EmbeddingType: ltypes.EMBEDDING_TYPE_BINARY,
+		Truncate:      "START",
+	})
+
+	// chunks are sent in batches of 96
+	chunks := make([]string, 100)
+	for i := range chunks {
+		chunks[i] = fmt.Sprintf("Hello world %d", i)
+	}
+	response, err := embeddings.Embed(ctx, logger, &EmbedArgs{InputChunks: chunks})
+	require.NoError(t, err)
+	for _, item := range recorded.all() {
+		require.Equal(t, "Bearer cohere-secret-key", item.Header.Get("Authorization"))
+	}
+	requests := decodeRequests[ltypes.CohereEmbedRequest](t, recorded)
+	require.Len(t, requests, 2)
+	require.Len(t, requests[0].Texts, 96)
+	require.Len(t, requests[1].Texts, 4)
+
+	require.Equal(t, COHERE_EMBEDDINGS_MODEL, requests[0].Model)
+	require.Equal(t, ltypes.COHERE_INPUT_SEARCH_DOCUMENT, requests[0].InputType)
+	require.Equal(t, []ltypes.EmbeddingType{ltypes.EMBEDDING_TYPE_BINARY}, requests[0].EmbeddingTypes)
+	require.Equal(t, "START", requests[0].Truncate)
+
+	require.Len(t, response.Embeddings, 100)
+	require.Equal(t, "Hello world 99", response.Embeddings[99].Raw)
+	require.Equal(t, []float32{-86, 101}, response.Embeddings[99].Embedding)
+	require.Equal(t, 200, response.Usage.InputTokens)
+}
+
+func TestCohereEmbeddingsError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"id": "b1c2", "message": "invalid request: input_type must be provided"}`))
+	}))
+	defer server.Close()
+
+	embeddings := NewCohereEmbeddings(&CohereEmbeddingsOpts{BaseUrl: server.URL, CohereApiKey: "cohere-secret-key"})
+	_, err := embeddings.Embed(context.TODO(), nil, &EmbedArgs{Input: "Hello"})
+	require.ErrorContains(t, err, "input_type must be provided")
+}
+
+func TestCachedEmbeddings(t *testing.T) {
+	var mu sync.Mutex
+	sent := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request ltypes.OpenAIEmbeddingRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		sent = append(sent, request.Input...)
+		mu.Unlock()
+
+		response := ltypes.OpenAIEmbeddingResponse{Usage: ltypes.GPTUsage{PromptTokens: len(request.Input), TotalTokens: len(request.Input)}}
+		for idx, item := range request.Input {
+			if item == "bad" {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error": {"message": "invalid input", "type": "invalid_request_error"}}`))
+				return
+			}
+			response.Data = append(response.Data, ltypes.OpenAIEmbeddingData{Embedding: []float32{float32(len(item)), 0.5}, Index: idx})
+		}
+		json.NewEncoder(w).Encode(&response)
+	}))
+	defer server.Close()
+
+	store := cache.NewMemoryCache(nil)
+	embeddings, err := NewCachedEmbeddings(NewOpenAIEmbeddings(test_user_id, &OpenAIEmbeddingsOpts{
+		BaseUrl:      server.URL,
+		OpenAIApiKey: "test",
+		MaxBatchSize: 1,
+	}), store, nil)
+	require.NoError(t, err)
+
+	// repeated chunks are only sent once
+	response, err := embeddings.Embed(context.TODO(), nil, &EmbedArgs{InputChunks: []string{"a", "bb", "a"}})
+	require.NoError(t, err)
+	require.Len(t, response.Embeddings, 3)
+	require.Equal(t, []float32{1, 0.5}, response.Embeddings[2].Embedding)
+	require.Equal(t, 2, response.Usage.TotalTokens)
+	require.Equal(t, 2, store.Len())
+
+	// only the misses are sent, and the results keep the order of the input
+	response, err = embeddings.Embed(context.TODO(), nil, &EmbedArgs{InputChunks: []string{"bb", "ccc", "a"}})
+	require.NoError(t, err)
+	require.Equal(t, []string{"bb", "ccc", "a"}, []string{response.Embeddings[0].Raw, response.Embeddings[1].Raw, response.Embeddings[2].Raw})
+	require.Equal(t, []float32{3, 0.5}, response.Embeddings[1].Embedding)
+	require.Equal(t, 1, response.Usage.TotalTokens)
+	require.ElementsMatch(t, []string{"a", "bb", "ccc"}, sent)
+
+	// fully cached requests do not call the provider, and have no usage
+	response, err = embeddings.Embed(context.TODO(), nil, &EmbedArgs{InputChunks: []string{"ccc"}})
+	require.NoError(t, err)
+	require.Equal(t, 0, response.Usage.TotalTokens)
+	require.Equal(t, OPENAI_EMBEDDINGS_MODEL, response.Usage.Model)
+	require.Len(t, sent, 3)
+	hits, misses := embeddings.Stats()
+	require.Equal(t, 3, hits)
+	require.Equal(t, 4, misses)
+
+	// partial failures keep the positions of the input
+	response, err = embeddings.Embed(context.TODO(), nil, &EmbedArgs{InputChunks: []string{"a", "bad", "dddd"}})
+	var partial *PartialEmbedError
+	require.ErrorAs(t, err, &partial)
+	require.Len(t, partial.Chunks, 1)
+	require.Equal(t, 1, partial.Chunks[0].Index)
+	require.Len(t, response.Embeddings, 2)
+	require.Equal(t, "dddd", response.Embeddings[1].Raw)
+	_, ok, _ := store.Get(context.TODO(), embeddings.key("dddd"))
+	require.True(t, ok)
+
+	// the settings of the embeddings are part of the key
+	other, err := NewCachedEmbeddings(NewOpenAIEmbeddings(test_user_id, &OpenAIEmbeddingsOpts{EmbeddingsDimentions: 256}), store, nil)
+	require.NoError(t, err)
+	require.NotEqual(t, embeddings.key("a"), other.key("a"))
+	_, err = NewCachedEmbeddings(&fakeEmbeddings{}, store, nil)
+	require.ErrorContains(t, err, "`Namespace` is required")
+}
+
+type fakeEmbeddings struct{}
+
+func (e *fakeEmbeddings) Embed(ctx context.Context, logger *slog.Logger, args *EmbedArgs) (*EmbedResponse, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (e *fakeEmbeddings) GetUsageRecords() []*tokens.UsageRecord {
+	return nil
+}
@@ -0,0 +1,311 @@
+package gollm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/jake-landersweb/gollm/v2/src/ltypes"
+	"github.com/jake-landersweb/gollm/v2/src/metrics"
+	"github.com/jake-landersweb/gollm/v2/src/tokens"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type ModelVoyageEmbeddings = string
+
+const (
+	VOYAGE_EMBEDDINGS_MODEL ModelVoyageEmbeddings = "voyage-3"
+)
+
+// Voyage accepts at most 128 texts per request
+const voyage_embeddings_batch_size = 128
+
+// The token limit of a request to the largest models, such as `voyage-3-large`
+const voyage_embeddings_batch_tokens = 120_000
+
+// The context length of the models, longer texts are sent on their own
+const voyage_embeddings_input_max = 32_000
+
+// Struct to handle the creation lifecycle when using Voyage AI Embeddings
+type VoyageEmbeddings struct {
+	opts     *VoyageEmbeddingsOpts
+	tracer   trace.Tracer
+	metrics  metrics.Metrics
+	redactor *redactor
+
+	usageRecords []*tokens.UsageRecord
+}
+
+// Optional configurations to customize the usage of the model.
+// This struct can be passed in as nil, and reasonable and functional defaults will be used.
+type VoyageEmbeddingsOpts struct {
+	// Defaults to `voyage-3`
+	Model ModelVoyageEmbeddings
+
+	// Optionally reduce the size of the vectors. Only supported by some models, such as `voyage-3-large`
+	EmbeddingsDimentions int
+
+	// What the embeddings will be used for. Use `query` for search queries and `document` for the content
+	// being searched. If not specified, the text is embedded without a prompt.
+	InputType ltypes.VoyageInputType
+
+	// Return an error instead of truncating inputs that are longer than the context length of the model
+	DisableTruncation bool
+
+	// The data type of the vectors. Defaults to `float`
+	EmbeddingType ltypes.EmbeddingType
+
+	// The most estimated tokens sent in a single request. Defaults to 120,000, the limit of the largest models
+	MaxBatchTokens int
+
+	// Defaults to `https://api.voyageai.com/v1/embeddings`
+	BaseUrl string
+
+	// Optionally pass the http client used to send all requests, such as one with a custom transport.
+	HttpClient *http.Client
+
+	// Optionally pass in an api key. If not specified, the environment variable `VOYAGE_API_KEY` will be read.
+	VoyageApiKey string
+
+	// Optionally trace embeddings with OpenTelemetry. If not specified, the global provider will be used.
+	TracerProvider trace.TracerProvider
+
+	// Optionally collect metrics on embeddings. If not specified, no metrics are collected.
+	Metrics metrics.Metrics
+
+	// Optionally configure what is masked from logged requests and responses. Api keys are always masked.
+	Redaction *RedactionOpts
+}
+
+func NewVoyageEmbeddings(opts *VoyageEmbeddingsOpts) *VoyageEmbeddings {
+	if opts == nil {
+		opts = &VoyageEmbeddingsOpts{}
+	}
+	if opts.Model == "" {
+		opts.Model = VOYAGE_EMBEDDINGS_MODEL
+	}
+	if opts.EmbeddingType == "" {
+		opts.EmbeddingType = ltypes.EMBEDDING_TYPE_FLOAT
+	}
+	if opts.MaxBatchTokens == 0 {
+		opts.MaxBatchTokens = voyage_embeddings_batch_tokens
+	}
+	if opts.BaseUrl == "" {
+		opts.BaseUrl = voyage_embeddings_base_url
+	}
+	if opts.HttpClient == nil {
+		opts.HttpClient = &http.Client{}
+	}
+
+	return &VoyageEmbeddings{
+		opts:     opts,
+		tracer:   newTracer(opts.TracerProvider),
+		metrics:  metrics.OrNoop(opts.Metrics),
+		redactor: newRedactor(opts.Redaction),
+	}
+}
+
+func (e *VoyageEmbeddings) Embed(
+	ctx context.Context,
+	logger *slog.Logger,
+	args *EmbedArgs,
+) (*EmbedResponse, error) {
+	if logger == nil {
+		logger = discardLogger()
+	}
+
+	ctx, span := e.tracer.Start(ctx, fmt.Sprintf("%s %s", genAIOperationEmbeddings, e.opts.Model),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attrGenAIOperationName.String(genAIOperationEmbeddings),
+			attrGenAISystem.String(genAISystemVoyage),
+			attrGenAIRequestModel.String(e.opts.Model),
+		),
+	)
+	defer span.End()
+
+	// chunk the input
+	if err := args.IsValid(); err != nil {
+		err = fmt.Errorf("invalid arguments: %s", err)
+		recordSpanError(span, err)
+		return nil, err
+	}
+
+	pieces, err := args.chunk()
+	if err != nil {
+		err = fmt.Errorf("failed to chunk the content: %s", err)
+		recordSpanError(span, err)
+		return nil, err
+	}
+	chunks := chunkTexts(pieces)
+	span.SetAttributes(attrEmbeddingsChunks.Int(len(chunks)))
+	e.metrics.ObserveEmbeddingChunks(genAISystemVoyage, e.opts.Model, len(chunks))
+
+	start := time.Now()
+	batches := splitEmbeddingsBatches(chunks, voyage_embeddings_batch_size, e.opts.MaxBatchTokens, voyage_embeddings_input_max)
+	vectors, records, failures := embedInBatches(ctx, logger, chunks, batches, 1, e.sendBatch)
+	if len(chunks) > 0 && len(failures) == len(chunks) {
+		// nothing was embedded, so report the error of the first batch
+		err = failures[0].Err
+		observeRequest(e.metrics, metrics.OperationEmbeddings, genAISystemVoyage, e.opts.Model, start, nil, err)
+		recordSpanError(span, err)
+		return nil, err
+	}
+
+	// track token usage of the batches that succeeded
+	usageRecord := tokens.MergeUsageRecords(records...)
+	e.usageRecords = append(e.usageRecords, usageRecord)
+	span.SetAttributes(usageAttributes(usageRecord)...)
+
+	if len(failures) != 0 {
+		err = &PartialEmbedError{Chunks: failures}
+		recordSpanError(span, err)
+	}
+	observeRequest(e.metrics, metrics.OperationEmbeddings, genAISystemVoyage, e.opts.Model, start, usageRecord, err)
+
+	// pair the vectors with their chunks
+	list := make([]*ltypes.EmbeddingsData, 0)
+	for idx := range chunks {
+		if vectors[idx] == nil {
+			continue
+		}
+		list = append(list, args.embeddingsData(idx, pieces[idx], vectors[idx]))
+	}
+
+	return &EmbedResponse{
+		Embeddings: list,
+		Usage:      usageRecord,
+	}, err
+}
+
+// Sends a batch of chunks to Voyage, placing the vectors by their index
+func (e *VoyageEmbeddings) sendBatch(ctx context.Context, logger *slog.Logger, batch []string) ([][]float32, *tokens.UsageRecord, error) {
+	response, err := e.voyageEmbed(ctx, logger, batch)
+	if err != nil {
+		return nil, nil, err
+	}
+	vectors := make([][]float32, len(batch))
+	for _, item := range response.Data {
+		if item.Index < 0 || item.Index >= len(batch) {
+			return nil, nil, fmt.Errorf("the response contained an embedding for an unknown index: %d", item.Index)
+		}
+		vectors[item.Index] = item.Embedding
+	}
+	usage := response.Usage
+	if usage == nil {
+		usage = &ltypes.VoyageUsage{}
+	}
+	return vectors, tokens.NewUsageRecordFromVoyageUsage(e.opts.Model, usage), nil
+}
+
+func (e *VoyageEmbeddings) GetUsageRecords() []*tokens.UsageRecord {
+	return e.usageRecords
+}
+
+func (e *VoyageEmbeddings) voyageEmbed(
+	ctx context.Context,
+	logger *slog.Logger,
+	input []string,
+) (*ltypes.VoyageEmbeddingResponse, error) {
+	apiKey := e.opts.VoyageApiKey
+	if apiKey == "" {
+		apiKey = os.Getenv("VOYAGE_API_KEY")
+		if apiKey == "" || apiKey == "null" {
+			return nil, fmt.Errorf("the environment variable `VOYAGE_API_KEY` is required")
+		}
+	}
+
+	// create the body
+	comprequest := ltypes.VoyageEmbeddingRequest{
+		Input:           input,
+		Model:           e.opts.Model,
+		InputType:       e.opts.InputType,
+		OutputDimension: e.opts.EmbeddingsDimentions,
+		OutputDtype:     e.opts.EmbeddingType,
+	}
+	if e.opts.DisableTruncation {
+		truncation := false
+		comprequest.Truncation = &truncation
+	}
+
+	enc, err := json.Marshal(&comprequest)
+	if err != nil {
+		return nil, fmt.Errorf("there was an issue encoding the body into json: %v", err)
+	}
+
+	logger.DebugContext(ctx, "Request body", "body", e.redactor.Body(enc, apiKey))
+
+	// send the request
+	client := e.opts.HttpClient
+
+	retries := 3
+	backoff := 1 * time.Second
+
+	for attempt := 0; attempt < retries; attempt++ {
+		logger.InfoContext(ctx, "Sending embeddings request...", "chunks", len(input))
+		statusCode, body, err := sendAttempt(ctx, e.tracer, client, genAISystemVoyage, e.opts.Model, attempt, func(ctx context.Context) (*http.Request, error) {
+			req, err := http.NewRequestWithContext(ctx, "POST", e.opts.BaseUrl, bytes.NewBuffer(enc))
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+apiKey)
+			return req, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		logger.InfoContext(ctx, "Completed request", "statusCode", statusCode)
+
+		// parse into the embeddings response object
+		var response ltypes.VoyageEmbeddingResponse
+		if statusCode == 200 {
+			if err = json.Unmarshal(body, &response); err != nil {
+				return nil, fmt.Errorf("there was an issue unmarshalling the request body: %v", err)
+			}
+			return &response, nil
+		}
+
+		// voyage only returns an error message, so act based on the status code
+		json.Unmarshal(body, &response)
+		switch {
+		case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+			return nil, fmt.Errorf("the user is not authenticated: %s", response.Detail)
+		case statusCode == http.StatusBadRequest || statusCode == http.StatusUnprocessableEntity:
+			return nil, fmt.Errorf("there was a validation error: %s", response.Detail)
+		case statusCode == http.StatusTooManyRequests:
+			logger.WarnContext(ctx, "Rate limit hit, waiting 2 seconds then trying again ...")
+			if err := sleepContext(ctx, time.Second*2); err != nil {
+				return nil, err
+			}
+		case statusCode >= 500:
+			logger.WarnContext(ctx, "There was a server error, waiting 2 seconds then trying again ...")
+			if err := sleepContext(ctx, time.Second*2); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("there was an unknown error: [%d]: %s", statusCode, response.Detail)
+		}
+
+		recordRetryableError(ctx, e.metrics, metrics.OperationEmbeddings, genAISystemVoyage, e.opts.Model, attempt, http.StatusText(statusCode))
+
+		if attempt < retries-1 {
+			sleep := backoff + time.Duration(rand.Intn(1000))*time.Millisecond // Add jitter
+			if err := sleepContext(ctx, sleep); err != nil {
+				return nil, err
+			}
+			backoff *= 2 // Double the backoff interval
+		} else {
+			return nil, fmt.Errorf("there was an issue with the request and could not recover: %s", e.redactor.Body(body, apiKey))
+		}
+	}
+
+	return nil, err
+}
@@ -9,10 +9,10 @@ import (
 	"log/slog"
 	"math/rand"
 	"net/http"
-	"os"
 	"time"
 
 	"github.com/jake-landersweb/gollm/v2/src/ltypes"
+	"github.com/jake-landersweb/gollm/v2/src/metrics"
 )
 
 func (l *LanguageModel) geminiCompletion(
@@ -27,12 +27,9 @@ func (l *LanguageModel) geminiCompletion(
 	prohibitTool bool,
 	toolChoice string,
 ) (*ltypes.GemCompletionResponse, error) {
-	apiKey := l.args.GeminiApiKey
-	if apiKey == "" {
-		apiKey = os.Getenv("GEMINI_API_KEY")
-		if apiKey == "" {
-			return nil, fmt.Errorf("the environment variable `GEMINI_API_KEY` is required")
-		}
+	target, err := l.geminiTarget(ctx, model, "generateContent")
+	if err != nil {
+		return nil, err
 	}
 
 	// parse a system message if exists
@@ -112,33 +109,31 @@ func (l *LanguageModel) geminiCompletion(
 		return nil, fmt.Errorf("there was an issue encoding the body: %v", err)
 	}
 
-	// create the request
-	url := fmt.Sprintf("%s/%s:generateContent?key=%s", l.args.GeminiBaseUrl, model, apiKey)
-	fmt.Print(url)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(enc))
-	if err != nil {
-		return nil, fmt.Errorf("error creating request: %v", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
+	logger.DebugContext(ctx, "Request body", "body", l.redactor.Body(enc, target.secret))
 
 	retries := 3
 	backoff := 1 * time.Second
 
 	for attempt := 0; attempt < retries; attempt++ {
 		logger.InfoContext(ctx, "Sending Gemini request...")
-		client := &http.Client{}
-		resp, err := client.Do(req)
-		if err != nil {
-			return nil, fmt.Errorf("there was an issue sending the request: %v", err)
-		}
-		defer resp.Body.Close()
-
-		body, err := io.ReadAll(resp.Body)
+		client := l.args.HttpClient
+		statusCode, body, err := sendAttempt(ctx, l.tracer, client, target.system, model, attempt, func(ctx context.Context) (*http.Request, error) {
+			req, err := http.NewRequestWithContext(ctx, "POST", target.url, bytes.NewBuffer(enc))
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Content-Type", "application/json")
+			for key, value := range target.headers {
+				req.Header.Set(key, value)
+			}
+			return req, nil
+		})
 		if err != nil {
-			return nil, fmt.Errorf("there was an issue parsing the request: %v", err)
+			return nil, err
 		}
 
-		logger.InfoContext(ctx, "Completed request", "statusCode", resp.StatusCode)
+		logger.InfoContext(ctx, "Completed request", "statusCode", statusCode)
+		logger.DebugContext(ctx, "Response body", "body", l.redactor.Body(body, target.secret))
 
 		// parse the request body
 		var response ltypes.GemCompletionResponse
@@ -165,40 +160,49 @@ func (l *LanguageModel) geminiCompletion(
 			return nil, fmt.Errorf("the user is not authenticated: %s", response.Error.Message)
 		case ltypes.GEM_ERROR_RESOURCE_EXHAUSTED:
 			logger.WarnContext(ctx, "The model is exhasted, waiting 2 seconds before trying again")
-			time.Sleep(time.Second * 2)
+			if err := sleepContext(ctx, time.Second*2); err != nil {
+				return nil, err
+			}
 		case ltypes.GEM_ERROR_FAILED_PRECONDITION:
 			return nil, fmt.Errorf("there was a failed pre-condition: %s", response.Error.Message)
 		case ltypes.GEM_ERROR_ABORTED:
 			logger.WarnContext(ctx, "the response was aborted, waiting 2 seconds before trying again")
-			time.Sleep(time.Second * 2)
+			if err := sleepContext(ctx, time.Second*2); err != nil {
+				return nil, err
+			}
 		// case ltypes.GEM_ERROR_OUT_OF_RANGE:
 		// case ltypes.GEM_ERROR_UNIMPLEMENTED:
 		case ltypes.GEM_ERROR_INTERNAL:
 			logger.WarnContext(ctx, "there was an internal error. waiting 2 seconds before trying again")
-			time.Sleep(time.Second * 2)
+			if err := sleepContext(ctx, time.Second*2); err != nil {
+				return nil, err
+			}
 		// case ltypes.GEM_ERROR_UNAVAILABLE:
 		// case ltypes.GEM_ERROR_DATA_LOSS:
 		default:
 			return nil, fmt.Errorf("there was an unknown issue with the request: [%s]: %s", response.Error.Status, response.Error.Message)
 		}
 
+		recordRetryableError(ctx, l.metrics, metrics.OperationCompletion, target.system, model, attempt, string(response.Error.Status))
+
 		if attempt < retries-1 {
 			sleep := backoff + time.Duration(rand.Intn(1000))*time.Millisecond // Add jitter
-			time.Sleep(sleep)
+			if err := sleepContext(ctx, sleep); err != nil {
+				return nil, err
+			}
 			backoff *= 2 // Double the backoff interval
-		} else if resp != nil && resp.StatusCode != 200 {
-			body, _ := io.ReadAll(resp.Body)
-			return nil, fmt.Errorf("there was an issue with the request and could not recover: %s", string(body))
+		} else if statusCode != 200 {
+			return nil, fmt.Errorf("there was an issue with the request and could not recover: %s", l.redactor.Body(body, target.secret))
 		}
 	}
 
 	return nil, err
 }
 
-func geminiTokenizerAccurate(input string, model string) (int, error) {
-	apiKey := os.Getenv("GEMINI_API_KEY")
-	if apiKey == "" || apiKey == "null" {
-		return 0, fmt.Errorf("the environment variable `GEMINI_API_KEY` is required")
+func (l *LanguageModel) geminiTokenizerAccurate(ctx context.Context, input string, model string) (int, error) {
+	target, err := l.geminiTarget(ctx, model, "countTokens")
+	if err != nil {
+		return 0, err
 	}
 
 	// create the body
@@ -219,16 +223,17 @@ func geminiTokenizerAccurate(input string, model string) (int, error) {
 	}
 
 	// create the request
-	url := fmt.Sprintf("%s/%s:countTokens?key=%s", gemini_base_url, model, apiKey)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(enc))
+	req, err := http.NewRequestWithContext(ctx, "POST", target.url, bytes.NewBuffer(enc))
 	if err != nil {
 		return 0, fmt.Errorf("error creating request: %v", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
+	for key, value := range target.headers {
+		req.Header.Set(key, value)
+	}
 
 	// send the request
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := l.args.HttpClient.Do(req)
 	if err != nil {
 		return 0, fmt.Errorf("there was an issue sending the request: %v", err)
 	}
@@ -240,7 +245,7 @@ func geminiTokenizerAccurate(input string, model string) (int, error) {
 	}
 
 	if resp.StatusCode != 200 {
-		return 0, fmt.Errorf("the response was not 200: %s", string(data))
+		return 0, fmt.Errorf("the response was not 200: %s", l.redactor.Body(data, target.secret))
 	}
 
 	// parse the tokens from the body
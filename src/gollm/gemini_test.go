@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/jake-landersweb/gollm/v2/src/ltypes"
@@ -13,7 +15,9 @@ import (
 )
 
 func TestGeminiTokens(t *testing.T) {
-	tokens, err := geminiTokenizerAccurate("This is an input string where I would like to know how many tokens make it up. Some grammer, can also be us'ed potentially (hopefully): yes.", gemini_model)
+	logger := defaultLogger(slog.LevelDebug).With("test", "TestGeminiTokens")
+	llm := newTestLanguageModel(t, logger)
+	tokens, err := llm.geminiTokenizerAccurate(context.TODO(), "This is an input string where I would like to know how many tokens make it up. Some grammer, can also be us'ed potentially (hopefully): yes.", gemini_model)
 	assert.Nil(t, err)
 	if err != nil {
 		return
@@ -32,7 +36,7 @@ func TestGeminiTextCompletion(t *testing.T) {
 	raw = append(raw, NewUserMessage("Please respond with a single sentence."))
 	messages := MessagesToGemini(raw)
 
-	llm := NewLanguageModel(test_user_id, logger, nil)
+	llm := newTestLanguageModel(t, logger)
 	response, err := llm.geminiCompletion(ctx, logger, gemini_model, 0.5, false, "", messages, nil, false, "")
 	assert.Nil(t, err)
 	if err != nil {
@@ -56,7 +60,7 @@ func TestGeminiJSONCompletion(t *testing.T) {
 
 	fmt.Println(*messages[0])
 
-	llm := NewLanguageModel(test_user_id, logger, nil)
+	llm := newTestLanguageModel(t, logger)
 	response, err := llm.geminiCompletion(ctx, logger, gemini_model, 0.5, true, schema, messages, nil, false, "")
 	assert.Nil(t, err)
 	if err != nil {
@@ -104,7 +108,7 @@ func TestGeminiToolUse(t *testing.T) {
 	fmt.Println("Gem Messages:")
 	debugPrint(MessagesToGemini(messages))
 
-	llm := NewLanguageModel(test_user_id, logger, nil)
+	llm := newTestLanguageModel(t, logger)
 	response, err := llm.geminiCompletion(context.TODO(), logger, gemini_model, 0.5, false, "", MessagesToGemini(messages), ToolsToGemini(tools), true, tools[0].Title)
 	require.Nil(t, err)
 	fmt.Println("Gem Response:")
@@ -112,7 +116,7 @@ func TestGeminiToolUse(t *testing.T) {
 
 	latestMessage := NewMessageFromGemini(&response.Candidates[0].Content)
 	messages = append(messages, latestMessage)
-	PrintConversation(messages)
+	llm.LogConversation(context.TODO(), messages)
 	require.Equal(t, latestMessage.Role, RoleToolCall)
 
 	// create a fake tool response
@@ -131,5 +135,24 @@ func TestGeminiToolUse(t *testing.T) {
 	latestMessage = NewMessageFromGemini(&response.Candidates[0].Content)
 	messages = append(messages, latestMessage)
 	require.Equal(t, RoleAI, latestMessage.Role)
-	PrintConversation(messages)
+	llm.LogConversation(context.TODO(), messages)
+}
+
+func TestGeminiCachedTokens(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"candidates": [{"content": {"role": "model", "parts": [{"text": "Hello!"}]}, "finishReason": "STOP"}], "usageMetadata": {"promptTokenCount": 40000, "candidatesTokenCount": 2, "totalTokenCount": 40002, "cachedContentTokenCount": 32768}}`))
+	}))
+	defer server.Close()
+
+	llm := NewLanguageModel(test_user_id, nil, &NewLanguageModelArgs{
+		GeminiBaseUrl: server.URL,
+		GeminiApiKey:  "test",
+	})
+	response, err := llm.Completion(context.TODO(), &CompletionInput{
+		Model:        gemini_model,
+		Conversation: []*Message{NewUserMessage("Hello")},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 40000, response.UsageRecord.InputTokens)
+	require.Equal(t, 32768, response.UsageRecord.CacheReadInputTokens)
 }
@@ -5,16 +5,15 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log/slog"
 	"math"
 	"math/rand"
 	"net/http"
-	"os"
 	"strings"
 	"time"
 
 	"github.com/jake-landersweb/gollm/v2/src/ltypes"
+	"github.com/jake-landersweb/gollm/v2/src/metrics"
 )
 
 func (l *LanguageModel) gptCompletion(
@@ -30,12 +29,27 @@ func (l *LanguageModel) gptCompletion(
 	prohibitTool bool,
 	toolChoice string,
 ) (*ltypes.GPTCompletionResponse, error) {
-	apiKey := l.args.OpenAIApiKey
-	if apiKey == "" {
-		apiKey = os.Getenv("OPENAI_API_KEY")
-		if apiKey == "" || apiKey == "null" {
-			return nil, fmt.Errorf("the env variable `OPENAI_API_KEY` is required to be set")
-		}
+	return l.openAICompatibleCompletion(ctx, logger, l.openAIProvider(), userId, model, temperature, jsonMode, jsonSchema, messages, tools, prohibitTool, toolChoice)
+}
+
+// Sends a chat completion in the OpenAI format to the provider. Used for OpenAI and all OpenAI compatible providers.
+func (l *LanguageModel) openAICompatibleCompletion(
+	ctx context.Context,
+	logger *slog.Logger,
+	provider *OpenAICompatibleProvider,
+	userId string,
+	model string,
+	temperature float64,
+	jsonMode bool,
+	jsonSchema string,
+	messages []*ltypes.GPTCompletionMessage,
+	tools []*ltypes.GPTTool,
+	prohibitTool bool,
+	toolChoice string,
+) (*ltypes.GPTCompletionResponse, error) {
+	apiKey, err := provider.apiKey()
+	if err != nil {
+		return nil, err
 	}
 
 	// create the body
@@ -43,10 +57,12 @@ func (l *LanguageModel) gptCompletion(
 		Messages:    messages,
 		Model:       model,
 		Temperature: temperature,
-		User:        userId,
 		N:           1,
 		Stream:      false,
 	}
+	if !provider.NoUser {
+		comprequest.User = userId
+	}
 
 	// add the tools if necessary
 	if tools != nil {
@@ -72,11 +88,15 @@ func (l *LanguageModel) gptCompletion(
 		logger.DebugContext(ctx, "Running with json mode ENABLED")
 
 		// add the required json validation text and schema for the model to follow
-		comprequest.ResponseFormat = ltypes.GPTRespFormat{Type: "json_object"}
+		if !provider.NoResponseFormat {
+			comprequest.ResponseFormat = ltypes.GPTRespFormat{Type: "json_object"}
+		}
 		comprequest.Messages[len(comprequest.Messages)-1].Content = fmt.Sprintf("%s\n\nPlease respond to this message ONLY with the given JSON schema.\n\nJSON SCHEMA:\n%s", comprequest.Messages[len(comprequest.Messages)-1].Content, jsonSchema)
 	} else {
 		logger.DebugContext(ctx, "Running with json mode DISABLED")
-		comprequest.ResponseFormat = ltypes.GPTRespFormat{Type: "text"}
+		if !provider.NoResponseFormat {
+			comprequest.ResponseFormat = ltypes.GPTRespFormat{Type: "text"}
+		}
 	}
 
 	enc, err := json.Marshal(&comprequest)
@@ -84,88 +104,116 @@ func (l *LanguageModel) gptCompletion(
 		return nil, fmt.Errorf("there was an issue encoding the body into json: %v", err)
 	}
 
-	// create the request
-	req, err := http.NewRequest("POST", l.args.GptBaseUrl, bytes.NewBuffer(enc))
-	if err != nil {
-		return nil, fmt.Errorf("there was an issue creating the http request: %v", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+apiKey)
+	logger.DebugContext(ctx, "Request body", "body", l.redactor.Body(enc, apiKey, provider.secret))
 
 	// send the request
-	client := &http.Client{}
+	client := l.args.HttpClient
 
 	retries := 3
 	backoff := 1 * time.Second
 
 	for attempt := 0; attempt < retries; attempt++ {
-		logger.InfoContext(ctx, "Sending GPT request...")
-		resp, err := client.Do(req)
-		if err != nil {
-			return nil, fmt.Errorf("there was an unknown issue with the request: %v", err)
-		}
-
-		// read the body
-		defer resp.Body.Close()
-		body, err := io.ReadAll(resp.Body)
+		logger.InfoContext(ctx, "Sending GPT request...", "provider", provider.Name)
+		statusCode, body, err := sendAttempt(ctx, l.tracer, client, provider.Name, model, attempt, func(ctx context.Context) (*http.Request, error) {
+			req, err := http.NewRequestWithContext(ctx, "POST", provider.BaseUrl, bytes.NewBuffer(enc))
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Content-Type", "application/json")
+			if apiKey != "" {
+				req.Header.Set("Authorization", "Bearer "+apiKey)
+			}
+			for key, value := range provider.Headers {
+				req.Header.Set(key, value)
+			}
+			return req, nil
+		})
 		if err != nil {
-			return nil, fmt.Errorf("there was an issue reading the body: %v", err)
+			return nil, err
 		}
 
-		logger.InfoContext(ctx, "Completed request", "statusCode", resp.StatusCode)
-		logger.DebugContext(ctx, "Response body", "body", string(body))
+		logger.InfoContext(ctx, "Completed request", "statusCode", statusCode)
+		logger.DebugContext(ctx, "Response body", "body", l.redactor.Body(body, apiKey, provider.secret))
 
 		// parse into the completion response object
 		var completion ltypes.GPTCompletionResponse
 		err = json.Unmarshal(body, &completion)
-		if err != nil {
+		if err != nil && statusCode == 200 {
 			return nil, fmt.Errorf("there was an issue unmarshalling the request body: %v", err)
 		}
 
+		// some compatible servers and proxies do not nest their errors or answer in plain text or
+		// html, so rely on the status code
+		if completion.Error == nil && statusCode != 200 {
+			completion.Error = &ltypes.GPTError{Message: string(body)}
+		}
+
 		// act based on the error
 		if completion.Error == nil {
 			// success. Relay to the user
 			if len(completion.Choices) == 0 {
 				return nil, fmt.Errorf("the completion list was 0")
 			}
+			if provider.contentFilterErrors && completion.Choices[0].FinishReason == ltypes.AZURE_FINISH_REASON_CONTENT_FILTER {
+				return nil, &ContentFilterError{
+					Source:  "completion",
+					Results: completion.Choices[0].ContentFilterResults,
+				}
+			}
 			return &completion, nil
 
 		} else {
+			if provider.contentFilterErrors && completion.Error.Code == ltypes.AZURE_ERROR_CONTENT_FILTER {
+				return nil, newPromptContentFilterError(completion.Error)
+			}
+
 			// act based on the error
 			switch completion.Error.Type {
 			case ltypes.GPT_ERROR_INVALID:
-				return nil, fmt.Errorf("there was a validation error: %s", string(body))
+				return nil, fmt.Errorf("there was a validation error: %s", l.redactor.Body(body, apiKey, provider.secret))
 			case ltypes.GPT_ERROR_RATE_LIMIT:
 				// rate limit, so wait some extra time and continue
 				logger.WarnContext(ctx, "Rate limit error hit. Waiting for an additional 2 seconds...")
-				time.Sleep(time.Second * 2)
+				if err := sleepContext(ctx, time.Second*2); err != nil {
+					return nil, err
+				}
 			case ltypes.GPT_ERROR_TOKENS_LIMIT:
-				// too many tokens, trim the message and try again
-				tmp := messages[len(messages)-1]
-				tmp.Content = tmp.Content[:l.args.GptMaxTokens]
+				// the same request would exceed the limit again, so leave trimming the conversation to the caller
+				return nil, fmt.Errorf("the request exceeds the token limit of the model: %s", l.redactor.Body(body, apiKey, provider.secret))
 			case ltypes.GPT_ERROR_AUTH:
-				return nil, fmt.Errorf("the user is not authenticated: %s", string(body))
+				return nil, fmt.Errorf("the user is not authenticated: %s", l.redactor.Body(body, apiKey, provider.secret))
 			case ltypes.GPT_ERROR_NOT_FOUND:
-				return nil, fmt.Errorf("the requested resource was not found: %s", string(body))
+				return nil, fmt.Errorf("the requested resource was not found: %s", l.redactor.Body(body, apiKey, provider.secret))
 			case ltypes.GPT_ERROR_SERVER:
 				// internal server error, wait and try again
-				logger.WarnContext(ctx, "There was an issue on OpenAI's side. Waiting 2 seconds and trying again ...", "body", string(body))
-				time.Sleep(time.Second * 2)
+				logger.WarnContext(ctx, "There was an issue on OpenAI's side. Waiting 2 seconds and trying again ...", "body", l.redactor.Body(body, apiKey, provider.secret))
+				if err := sleepContext(ctx, time.Second*2); err != nil {
+					return nil, err
+				}
 			case ltypes.GPT_ERROR_PERMISSION:
-				return nil, fmt.Errorf("the requested resource was not found: %s", string(body))
+				return nil, fmt.Errorf("the requested resource was not found: %s", l.redactor.Body(body, apiKey, provider.secret))
 			default:
-				return nil, fmt.Errorf("there was an unknown error: %s", string(body))
+				// compatible providers do not always send an error type, so retry based on the status code
+				if statusCode != http.StatusTooManyRequests && statusCode < 500 {
+					return nil, fmt.Errorf("there was an unknown error: %s", l.redactor.Body(body, apiKey, provider.secret))
+				}
+				logger.WarnContext(ctx, "The provider returned a retryable status. Waiting 2 seconds and trying again ...", "statusCode", statusCode)
+				if err := sleepContext(ctx, time.Second*2); err != nil {
+					return nil, err
+				}
 			}
 
+			recordRetryableError(ctx, l.metrics, metrics.OperationCompletion, provider.Name, model, attempt, string(completion.Error.Type))
 		}
 
 		if attempt < retries-1 {
 			sleep := backoff + time.Duration(rand.Intn(1000))*time.Millisecond // Add jitter
-			time.Sleep(sleep)
+			if err := sleepContext(ctx, sleep); err != nil {
+				return nil, err
+			}
 			backoff *= 2 // Double the backoff interval
-		} else if resp != nil && resp.StatusCode != 200 {
-			body, _ := io.ReadAll(resp.Body)
-			return nil, fmt.Errorf("there was an issue with the request and could not recover: %s", string(body))
+		} else if statusCode != 200 {
+			return nil, fmt.Errorf("there was an issue with the request and could not recover: %s", l.redactor.Body(body, apiKey, provider.secret))
 		}
 	}
 
@@ -23,7 +23,7 @@ func TestGPTTextCompletion(t *testing.T) {
 		Role:    "user",
 	})
 
-	llm := NewLanguageModel(test_user_id, logger, nil)
+	llm := newTestLanguageModel(t, logger)
 	response, err := llm.gptCompletion(context.TODO(), logger, test_user_id, gpt3_model, 1.0, false, "", messages, nil, false, "")
 	require.Nil(t, err)
 
@@ -48,7 +48,7 @@ func TestGPTJSONCompletion(t *testing.T) {
 		Role:    "user",
 	})
 
-	llm := NewLanguageModel(test_user_id, logger, nil)
+	llm := newTestLanguageModel(t, logger)
 	response, err := llm.gptCompletion(context.TODO(), logger, test_user_id, gpt3_model, 1.0, true, schema, messages, nil, false, "")
 	require.Nil(t, err)
 
@@ -99,7 +99,7 @@ func TestGPTToolUsage(t *testing.T) {
 	messages = append(messages, NewSystemMessage("You are a model in a testing environment to test the implementation of tool use for language models. Act as normal."))
 	messages = append(messages, NewUserMessage("What is the weather in San Francisco today?"))
 
-	llm := NewLanguageModel(test_user_id, logger, nil)
+	llm := newTestLanguageModel(t, logger)
 	response, err := llm.gptCompletion(context.TODO(), logger, test_user_id, gpt3_model, 1.0, false, "", MessagesToOpenAI(messages), ToolsToOpenAI(tools), true, tools[0].Title)
 	require.Nil(t, err)
 
@@ -130,5 +130,21 @@ func TestGPTToolUsage(t *testing.T) {
 	enc, _ = json.MarshalIndent(MessagesToOpenAI(messages), "", "    ")
 	fmt.Println(string(enc))
 
-	PrintConversation(messages)
+	llm.LogConversation(context.TODO(), messages)
+}
+
+func TestGPTCachedTokens(t *testing.T) {
+	server, _ := newTestServer(t, respondWith(200, `{"id": "chatcmpl-1", "object": "chat.completion", "model": "gpt-4o-mini", "choices": [{"index": 0, "message": {"role": "assistant", "content": "Hello!"}, "finish_reason": "stop"}], "usage": {"prompt_tokens": 2000, "completion_tokens": 2, "total_tokens": 2002, "prompt_tokens_details": {"cached_tokens": 1536}}}`))
+	llm := NewLanguageModel(test_user_id, nil, &NewLanguageModelArgs{
+		GptBaseUrl:   server.URL,
+		OpenAIApiKey: "test",
+	})
+	response, err := llm.Completion(context.TODO(), &CompletionInput{
+		Model:        "gpt-4o-mini",
+		Conversation: []*Message{NewUserMessage("Hello")},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2000, response.UsageRecord.InputTokens)
+	require.Equal(t, 1536, response.UsageRecord.CacheReadInputTokens)
+	require.Equal(t, 0, response.UsageRecord.CacheCreationInputTokens)
 }
@@ -2,17 +2,25 @@ package gollm
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"strings"
+	"time"
 
+	"github.com/jake-landersweb/gollm/v2/src/metrics"
 	"github.com/jake-landersweb/gollm/v2/src/tokens"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type LanguageModel struct {
-	userId string
-	logger *slog.Logger
-	args   *NewLanguageModelArgs
+	userId   string
+	logger   *slog.Logger
+	tracer   trace.Tracer
+	metrics  metrics.Metrics
+	redactor *redactor
+	args     *NewLanguageModelArgs
 
 	// store token records internally incase users want to store state inside the record
 	usageRecords []*tokens.UsageRecord
@@ -27,6 +35,12 @@ type CompletionInput struct {
 	Tools        []*Tool
 	RequiredTool *Tool
 	ProhibitTool bool // if set to true, will not use tools
+
+	// When the language model caches completions, neither read nor write the cache for this input
+	SkipCache bool
+
+	// When the language model caches completions, send the request even if it is cached and replace the cached response
+	RefreshCache bool
 }
 
 // Valiate the completion input
@@ -51,6 +65,12 @@ type CompletionResponse struct {
 	StopReason  string
 	Message     *Message
 	UsageRecord *tokens.UsageRecord
+
+	// Set when the response was read from the completion or semantic cache, in which case the usage record is empty
+	Cached bool
+
+	// The cosine similarity of the question to the cached question, when the response was read from the semantic cache
+	Similarity float64
 }
 
 type NewLanguageModelArgs struct {
@@ -63,11 +83,57 @@ type NewLanguageModelArgs struct {
 	GeminiBaseUrl string
 	GeminiApiKey  string // If not defined, the env variable `GEMINI_API_KEY` will be used
 
+	// Vertex AI Configs. Gemini models are routed to Vertex with the `vertex/` prefix, such as `vertex/gemini-1.5-flash`
+	Vertex *VertexAIConfig
+
 	// Anthropic Configs
 	AnthropicBaseUrl   string
 	AnthropicVersion   string
 	AnthropicMaxTokens int
 	AnthropicApiKey    string // If not defined, the env variable `ANTHROPIC_API_KEY` will be used
+
+	// Mistral Configs. Models are routed to Mistral with the `mistral/` prefix, such as `mistral/mistral-large-latest`
+	MistralBaseUrl   string
+	MistralMaxTokens int    // If not defined, the model default is used
+	MistralApiKey    string // If not defined, the env variable `MISTRAL_API_KEY` will be used
+
+	// Cohere Configs. Models are routed to Cohere with the `cohere/` prefix, such as `cohere/command-r-plus`
+	CohereBaseUrl   string
+	CohereMaxTokens int    // If not defined, the model default is used
+	CohereApiKey    string // If not defined, the env variable `CO_API_KEY` will be used
+
+	// Providers that speak the OpenAI chat completions format, such as Groq, Together, vLLM, OpenRouter or LM Studio.
+	// These are checked before the built in providers, so a listed model always routes to its provider
+	OpenAICompatibleProviders []*OpenAICompatibleProvider
+
+	// Azure OpenAI Configs. Models are routed to Azure with the `azure/` prefix, such as `azure/gpt-4o`
+	Azure *AzureOpenAIConfig
+
+	// AWS Bedrock Configs. Models are routed to Bedrock with the `bedrock/` prefix, such as `bedrock/meta.llama3-1-70b-instruct-v1:0`
+	Bedrock *BedrockConfig
+
+	// Ollama Configs. Models are routed to Ollama with the `ollama/` prefix, such as `ollama/llama3.1`
+	OllamaBaseUrl   string // Defaults to `http://localhost:11434`
+	OllamaMaxTokens int    // If not defined, the model default is used
+	OllamaKeepAlive string // How long the model stays loaded after a request, such as `10m`. If not defined, the server default is used
+
+	// Optionally pass the http client used to send all requests, such as one with a custom transport
+	HttpClient *http.Client
+
+	// Optionally trace completions with OpenTelemetry. If not defined, the global provider will be used
+	TracerProvider trace.TracerProvider
+
+	// Optionally collect metrics on completions. If not defined, no metrics are collected
+	Metrics metrics.Metrics
+
+	// Optionally configure what is masked from logged requests and responses. Api keys are always masked
+	Redaction *RedactionOpts
+
+	// Optionally cache completions, so identical requests are only sent once. If not defined, nothing is cached
+	Cache *CompletionCacheOpts
+
+	// Optionally reuse the responses of similar questions. Checked after `Cache`. If not defined, nothing is reused
+	SemanticCache *SemanticCache
 }
 
 func parseArguments(args *NewLanguageModelArgs) *NewLanguageModelArgs {
@@ -92,6 +158,18 @@ func parseArguments(args *NewLanguageModelArgs) *NewLanguageModelArgs {
 	if args.AnthropicMaxTokens == 0 {
 		args.AnthropicMaxTokens = anthropic_max_tokens
 	}
+	if args.MistralBaseUrl == "" {
+		args.MistralBaseUrl = mistral_base_url
+	}
+	if args.CohereBaseUrl == "" {
+		args.CohereBaseUrl = cohere_base_url
+	}
+	if args.OllamaBaseUrl == "" {
+		args.OllamaBaseUrl = ollama_base_url
+	}
+	if args.HttpClient == nil {
+		args.HttpClient = &http.Client{}
+	}
 	return args
 }
 
@@ -102,10 +180,16 @@ func NewLanguageModel(
 	args *NewLanguageModelArgs,
 ) *LanguageModel {
 	args = parseArguments(args)
+	if logger == nil {
+		logger = discardLogger()
+	}
 
 	return &LanguageModel{
 		userId:       userId,
 		logger:       logger,
+		tracer:       newTracer(args.TracerProvider),
+		metrics:      metrics.OrNoop(args.Metrics),
+		redactor:     newRedactor(args.Redaction),
 		args:         args,
 		usageRecords: make([]*tokens.UsageRecord, 0),
 	}
@@ -115,19 +199,46 @@ func NewLanguageModel(
 Estimates the token usage for a given input request. The accuracy can vary based
 on what model you are using:
 
-- GPT3/4: Rough approximation, but should NOT be used for billing reasons
+- GPT3/4, Azure OpenAI and OpenAI compatible providers: Rough approximation, but should NOT be used for billing reasons
 
-- Gemini: Uses the production tokenization endpoint, will be exact token counts.
+- Gemini and Vertex AI: Uses the production tokenization endpoint, will be exact token counts.
+Vertex AI models need the `Vertex` configuration, so they can only be estimated through the
+`TokenEstimate` method of a configured language model, and return an error here.
 
 - Anthropic: Uses approximate function, should NOT be used for billing reasons
+
+- Ollama: Uses the same approximation as GPT, as the tokenizer depends on the model
+
+- Bedrock: Uses the same approximation as GPT, as the tokenizer depends on the model
+
+- Mistral and Cohere: Uses the same approximation as GPT, should NOT be used for billing reasons
 */
 func TokenEstimate(model string, message string) (int, error) {
-	if strings.HasPrefix(model, "gpt") {
+	return NewLanguageModel("", nil, nil).TokenEstimate(model, message)
+}
+
+// Estimates the token usage for a given input request, using the configuration of the language model.
+// See the package level `TokenEstimate` for the accuracy of each model.
+func (l *LanguageModel) TokenEstimate(model string, message string) (int, error) {
+	return l.TokenEstimateContext(context.Background(), model, message)
+}
+
+// Same as `TokenEstimate`, but the requests to the tokenization endpoints are bound to the context
+func (l *LanguageModel) TokenEstimateContext(ctx context.Context, model string, message string) (int, error) {
+	if compatible, _ := l.openAICompatibleProvider(model); compatible != nil || strings.HasPrefix(model, "gpt") || strings.HasPrefix(model, azure_model_prefix) {
 		return gptTokenizerApproximate("avg", message)
-	} else if strings.HasPrefix(model, "gemini") {
-		return geminiTokenizerAccurate(message, model)
+	} else if strings.HasPrefix(model, "gemini") || strings.HasPrefix(model, vertex_model_prefix) {
+		return l.geminiTokenizerAccurate(ctx, message, model)
 	} else if strings.HasPrefix(model, "claude") {
 		return anthropicTokenizerAproximate(message), nil
+	} else if strings.HasPrefix(model, ollama_model_prefix) {
+		return ollamaTokenizerApproximate(message)
+	} else if strings.HasPrefix(model, bedrock_model_prefix) {
+		return bedrockTokenizerApproximate(message)
+	} else if strings.HasPrefix(model, mistral_model_prefix) {
+		return mistralTokenizerApproximate(message)
+	} else if strings.HasPrefix(model, cohere_model_prefix) {
+		return cohereTokenizerApproximate(message)
 	} else {
 		return 0, fmt.Errorf("invalid model: %s", model)
 	}
@@ -143,6 +254,16 @@ func (l *LanguageModel) Completion(ctx context.Context, input *CompletionInput)
 		return nil, err
 	}
 
+	ctx, span := l.tracer.Start(ctx, fmt.Sprintf("%s %s", genAIOperationChat, input.Model),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attrGenAIOperationName.String(genAIOperationChat),
+			attrGenAIRequestModel.String(input.Model),
+			attrGenAIRequestTemperature.Float64(input.Temperature),
+		),
+	)
+	defer span.End()
+
 	// create a copy of the conversation
 	conversation := make([]*Message, len(input.Conversation))
 	copy(conversation, input.Conversation)
@@ -150,26 +271,124 @@ func (l *LanguageModel) Completion(ctx context.Context, input *CompletionInput)
 	// check the token usage and trim the conversation if needed
 	// TODO --
 
+	// return the cached response, if any
+	lookupStart := time.Now()
+	cacheOpts := l.completionCache(input)
+	cacheKey := ""
+	if cacheOpts != nil {
+		key, err := l.completionCacheKey(cacheOpts, input, conversation)
+		if err != nil {
+			l.logger.WarnContext(ctx, "There was an issue building the completion cache key", "error", err)
+			cacheOpts = nil
+		}
+		cacheKey = key
+	}
+	if cacheOpts != nil && !input.RefreshCache {
+		if response := l.readCompletionCache(ctx, cacheOpts, cacheKey, input); response != nil {
+			l.logger.DebugContext(ctx, "Read the completion from the cache", "model", input.Model)
+			return l.cachedResponse(span, input, lookupStart, response), nil
+		}
+	}
+
+	// return the response to a similar question, if any
+	semantic := l.args.SemanticCache
+	if input.SkipCache {
+		semantic = nil
+	}
+	var semanticVector []float32
+	if semantic != nil {
+		cached, vector, usage, err := semantic.lookup(ctx, l.logger, input)
+		if usage != nil {
+			l.usageRecords = append(l.usageRecords, usage)
+		}
+		if err != nil {
+			l.logger.WarnContext(ctx, "There was an issue reading the semantic cache", "error", err)
+		}
+		if cached != nil {
+			l.logger.DebugContext(ctx, "Read the completion from the semantic cache", "model", input.Model, "similarity", cached.Similarity)
+			return l.cachedResponse(span, input, lookupStart, cached), nil
+		}
+		semanticVector = vector
+	}
+
 	var response *CompletionResponse
 	var err error
 
-	if strings.HasPrefix(input.Model, "gpt") {
+	start := time.Now()
+	provider := ""
+
+	if compatible, model := l.openAICompatibleProvider(input.Model); compatible != nil {
+		provider = compatible.Name
+		span.SetAttributes(attrGenAISystem.String(provider))
+		response, err = l.openAICompatible(ctx, input, conversation, compatible, model)
+	} else if model, ok := strings.CutPrefix(input.Model, azure_model_prefix); ok {
+		provider = genAISystemAzureOpenAI
+		span.SetAttributes(attrGenAISystem.String(provider))
+		response, err = l.azure(ctx, input, conversation, model)
+	} else if strings.HasPrefix(input.Model, "gpt") {
+		provider = genAISystemOpenAI
+		span.SetAttributes(attrGenAISystem.String(provider))
 		response, err = l.gpt(ctx, input, conversation)
+	} else if strings.HasPrefix(input.Model, vertex_model_prefix) {
+		provider = genAISystemVertexAI
+		span.SetAttributes(attrGenAISystem.String(provider))
+		response, err = l.gemini(ctx, input, conversation)
 	} else if strings.HasPrefix(input.Model, "gemini") {
+		provider = genAISystemGemini
+		span.SetAttributes(attrGenAISystem.String(provider))
 		response, err = l.gemini(ctx, input, conversation)
 	} else if strings.HasPrefix(input.Model, "claude") {
+		provider = genAISystemAnthropic
+		span.SetAttributes(attrGenAISystem.String(provider))
 		response, err = l.anthropic(ctx, input, conversation)
+	} else if strings.HasPrefix(input.Model, ollama_model_prefix) {
+		provider = genAISystemOllama
+		span.SetAttributes(attrGenAISystem.String(provider))
+		response, err = l.ollama(ctx, input, conversation)
+	} else if model, ok := strings.CutPrefix(input.Model, bedrock_model_prefix); ok {
+		provider = genAISystemBedrock
+		span.SetAttributes(attrGenAISystem.String(provider))
+		response, err = l.bedrock(ctx, input, conversation, model)
+	} else if strings.HasPrefix(input.Model, mistral_model_prefix) {
+		provider = genAISystemMistral
+		span.SetAttributes(attrGenAISystem.String(provider))
+		response, err = l.mistral(ctx, input, conversation)
+	} else if strings.HasPrefix(input.Model, cohere_model_prefix) {
+		provider = genAISystemCohere
+		span.SetAttributes(attrGenAISystem.String(provider))
+		response, err = l.cohere(ctx, input, conversation)
 	} else {
-		return nil, fmt.Errorf("invalid model type: %s", input.Model)
+		err = fmt.Errorf("invalid model type: %s", input.Model)
+	}
+
+	if provider != "" {
+		var record *tokens.UsageRecord
+		if response != nil {
+			record = response.UsageRecord
+		}
+		observeRequest(l.metrics, metrics.OperationCompletion, provider, input.Model, start, record, err)
 	}
 
 	if err != nil {
+		recordSpanError(span, err)
 		return nil, err
 	}
 
+	span.SetAttributes(attrGenAIResponseFinishReasons.StringSlice([]string{response.StopReason}))
+	span.SetAttributes(usageAttributes(response.UsageRecord)...)
+
 	// trim the leading and trailing whitespaces, if any, from the message
 	response.Message.Message = strings.TrimSpace(response.Message.Message)
 
+	if cacheOpts != nil {
+		l.writeCompletionCache(ctx, cacheOpts, cacheKey, response)
+	}
+	if semantic != nil {
+		if err := semantic.add(ctx, l.logger, input, semanticVector, response); err != nil {
+			l.logger.WarnContext(ctx, "There was an issue writing the semantic cache", "error", err)
+		}
+	}
+
 	// store the token record internally as well
 	l.usageRecords = append(l.usageRecords, response.UsageRecord)
 	return response, nil
@@ -178,7 +397,21 @@ func (l *LanguageModel) Completion(ctx context.Context, input *CompletionInput)
 // Perform a completion specifically using OpenAI as the provider.
 // To be used only when wanting a direct gpt completion. Otherwise, use `DynamicCompletion`.
 func (l *LanguageModel) gpt(ctx context.Context, input *CompletionInput, conversation []*Message) (*CompletionResponse, error) {
-	logger := l.logger.With("model", input.Model, "temperature", input.Temperature, "json", input.Json, "jsonSchema", input.JsonSchema)
+	return l.openAICompatible(ctx, input, conversation, l.openAIProvider(), input.Model)
+}
+
+// Perform a completion using Azure OpenAI. `model` is the model without the `azure/` prefix, which is mapped to a deployment.
+func (l *LanguageModel) azure(ctx context.Context, input *CompletionInput, conversation []*Message, model string) (*CompletionResponse, error) {
+	provider, err := l.azureProvider(ctx, model)
+	if err != nil {
+		return nil, err
+	}
+	return l.openAICompatible(ctx, input, conversation, provider, model)
+}
+
+// Perform a completion using a provider that speaks the OpenAI format. `model` is the name of the model sent to the provider.
+func (l *LanguageModel) openAICompatible(ctx context.Context, input *CompletionInput, conversation []*Message, provider *OpenAICompatibleProvider, model string) (*CompletionResponse, error) {
+	logger := l.logger.With("model", input.Model, "provider", provider.Name, "temperature", input.Temperature, "json", input.Json, "jsonSchema", input.JsonSchema)
 	logger.InfoContext(ctx, "Beginning GPT completion ...")
 
 	requiredTool := ""
@@ -187,11 +420,12 @@ func (l *LanguageModel) gpt(ctx context.Context, input *CompletionInput, convers
 	}
 
 	// send the request
-	response, err := l.gptCompletion(
+	response, err := l.openAICompatibleCompletion(
 		ctx,
 		logger,
+		provider,
 		l.userId,
-		input.Model,
+		model,
 		input.Temperature,
 		input.Json,
 		input.JsonSchema,
@@ -201,7 +435,7 @@ func (l *LanguageModel) gpt(ctx context.Context, input *CompletionInput, convers
 		requiredTool,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("there was an issue sending the request: %v", err)
+		return nil, fmt.Errorf("there was an issue sending the request: %w", err)
 	}
 
 	// add the response message to the conversation
@@ -211,7 +445,7 @@ func (l *LanguageModel) gpt(ctx context.Context, input *CompletionInput, convers
 	tokenRecord := tokens.NewUsageRecordFromGPTUsage(input.Model, &response.Usage)
 
 	logger.InfoContext(ctx, "Completed GPT completion")
-	logger.DebugContext(ctx, "GPT completion stats", "response", response, "inTokens", response.Usage.PromptTokens, "outTokens", response.Usage.CompletionTokens, "totalTokens", response.Usage.TotalTokens)
+	logger.DebugContext(ctx, "GPT completion stats", "inTokens", response.Usage.PromptTokens, "outTokens", response.Usage.CompletionTokens, "totalTokens", response.Usage.TotalTokens)
 
 	// return the text string of the completion to let the caller parse as needed
 	return &CompletionResponse{
@@ -222,7 +456,7 @@ func (l *LanguageModel) gpt(ctx context.Context, input *CompletionInput, convers
 	}, nil
 }
 
-// Perform a completion specifically using Google as the provider.
+// Perform a completion specifically using Google as the provider, through either AI Studio or Vertex AI.
 // To be used only when wanting a direct gpt completion. Otherwise, use `DynamicCompletion`.
 func (l *LanguageModel) gemini(ctx context.Context, input *CompletionInput, conversation []*Message) (*CompletionResponse, error) {
 	logger := l.logger.With("model", input.Model, "temperature", input.Temperature, "json", input.Json, "jsonSchema", input.JsonSchema)
@@ -299,7 +533,7 @@ func (l *LanguageModel) anthropic(ctx context.Context, input *CompletionInput, c
 	tokenRecord := tokens.NewUsageRecordFromAnthropicUsage(input.Model, response.Usage)
 
 	logger.InfoContext(ctx, "Completed Anthropic completion")
-	logger.DebugContext(ctx, "Anthropic completion stats", "response", response, "inTokens", response.Usage.InputTokens, "outTokens", response.Usage.OutputTokens, "totalTokens", response.Usage.InputTokens+response.Usage.OutputTokens)
+	logger.DebugContext(ctx, "Anthropic completion stats", "inTokens", response.Usage.InputTokens, "outTokens", response.Usage.OutputTokens, "totalTokens", response.Usage.InputTokens+response.Usage.OutputTokens)
 
 	// return the text string of the completion to let the caller parse as needed
 	return &CompletionResponse{
@@ -310,32 +544,211 @@ func (l *LanguageModel) anthropic(ctx context.Context, input *CompletionInput, c
 	}, nil
 }
 
+// Perform a completion specifically using Ollama as the provider.
+// To be used only when wanting a direct ollama completion. Otherwise, use `Completion`.
+func (l *LanguageModel) ollama(ctx context.Context, input *CompletionInput, conversation []*Message) (*CompletionResponse, error) {
+	logger := l.logger.With("model", input.Model, "temperature", input.Temperature, "json", input.Json, "jsonSchema", input.JsonSchema)
+	logger.InfoContext(ctx, "Beginning Ollama completion ...")
+
+	requiredTool := ""
+	if input.RequiredTool != nil {
+		requiredTool = input.RequiredTool.Title
+	}
+
+	// send the request
+	response, err := l.ollamaCompletion(
+		ctx,
+		logger,
+		input.Model,
+		input.Temperature,
+		input.Json,
+		input.JsonSchema,
+		MessagesToOllama(conversation),
+		ToolsToOllama(input.Tools),
+		input.ProhibitTool,
+		requiredTool,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("there was an issue sending the request: %v", err)
+	}
+
+	// Create a token record for this request
+	tokenRecord := tokens.NewUsageRecordFromOllamaUsage(input.Model, &response.OllamaUsage)
+
+	logger.InfoContext(ctx, "Completed Ollama completion")
+	logger.DebugContext(ctx, "Ollama completion stats", "inTokens", response.PromptEvalCount, "outTokens", response.EvalCount, "totalDuration", time.Duration(response.TotalDuration))
+
+	stopReason := response.DoneReason
+	if len(response.Message.ToolCalls) != 0 {
+		// ollama reports `stop` for tool calls, so match the other providers
+		stopReason = "tool_calls"
+	}
+
+	return &CompletionResponse{
+		Model:       input.Model,
+		StopReason:  stopReason,
+		Message:     NewMessageFromOllama(&response.Message),
+		UsageRecord: tokenRecord,
+	}, nil
+}
+
+// Perform a completion using AWS Bedrock. `model` is the Bedrock model id without the `bedrock/` prefix.
+// To be used only when wanting a direct bedrock completion. Otherwise, use `Completion`.
+func (l *LanguageModel) bedrock(ctx context.Context, input *CompletionInput, conversation []*Message, model string) (*CompletionResponse, error) {
+	logger := l.logger.With("model", input.Model, "temperature", input.Temperature, "json", input.Json, "jsonSchema", input.JsonSchema)
+	logger.InfoContext(ctx, "Beginning Bedrock completion ...")
+
+	requiredTool := ""
+	if input.RequiredTool != nil {
+		requiredTool = input.RequiredTool.Title
+	}
+
+	// send the request
+	response, err := l.bedrockCompletion(
+		ctx,
+		logger,
+		model,
+		input.Temperature,
+		input.Json,
+		input.JsonSchema,
+		MessagesToBedrock(conversation),
+		ToolsToBedrock(input.Tools),
+		input.ProhibitTool,
+		requiredTool,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("there was an issue sending the request: %v", err)
+	}
+
+	// Create a token record for this request
+	tokenRecord := tokens.NewUsageRecordFromBedrockUsage(input.Model, response.Usage)
+
+	logger.InfoContext(ctx, "Completed Bedrock completion")
+	logger.DebugContext(ctx, "Bedrock completion stats", "inTokens", response.Usage.InputTokens, "outTokens", response.Usage.OutputTokens, "totalTokens", response.Usage.TotalTokens)
+
+	return &CompletionResponse{
+		Model:       input.Model,
+		StopReason:  response.StopReason,
+		Message:     NewMessageFromBedrock(response.Output.Message),
+		UsageRecord: tokenRecord,
+	}, nil
+}
+
+// Perform a completion specifically using Mistral as the provider.
+// To be used only when wanting a direct mistral completion. Otherwise, use `Completion`.
+func (l *LanguageModel) mistral(ctx context.Context, input *CompletionInput, conversation []*Message) (*CompletionResponse, error) {
+	logger := l.logger.With("model", input.Model, "temperature", input.Temperature, "json", input.Json, "jsonSchema", input.JsonSchema)
+	logger.InfoContext(ctx, "Beginning Mistral completion ...")
+
+	requiredTool := ""
+	if input.RequiredTool != nil {
+		requiredTool = input.RequiredTool.Title
+	}
+
+	// send the request
+	response, err := l.mistralCompletion(
+		ctx,
+		logger,
+		input.Model,
+		input.Temperature,
+		input.Json,
+		input.JsonSchema,
+		MessagesToMistral(conversation),
+		ToolsToMistral(input.Tools),
+		input.ProhibitTool,
+		requiredTool,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("there was an issue sending the request: %v", err)
+	}
+
+	choice := response.Choices[0]
+
+	// Create a token record for this request
+	tokenRecord := tokens.NewUsageRecordFromMistralUsage(input.Model, response.Usage)
+
+	logger.InfoContext(ctx, "Completed Mistral completion")
+	logger.DebugContext(ctx, "Mistral completion stats", "inTokens", response.Usage.PromptTokens, "outTokens", response.Usage.CompletionTokens, "totalTokens", response.Usage.TotalTokens)
+
+	return &CompletionResponse{
+		Model:       input.Model,
+		StopReason:  choice.FinishReason,
+		Message:     NewMessageFromMistral(&choice.Message),
+		UsageRecord: tokenRecord,
+	}, nil
+}
+
+// Perform a completion specifically using Cohere as the provider.
+// To be used only when wanting a direct cohere completion. Otherwise, use `Completion`.
+func (l *LanguageModel) cohere(ctx context.Context, input *CompletionInput, conversation []*Message) (*CompletionResponse, error) {
+	logger := l.logger.With("model", input.Model, "temperature", input.Temperature, "json", input.Json, "jsonSchema", input.JsonSchema)
+	logger.InfoContext(ctx, "Beginning Cohere completion ...")
+
+	requiredTool := ""
+	if input.RequiredTool != nil {
+		requiredTool = input.RequiredTool.Title
+	}
+
+	// send the request
+	response, err := l.cohereCompletion(
+		ctx,
+		logger,
+		input.Model,
+		input.Temperature,
+		input.Json,
+		input.JsonSchema,
+		MessagesToCohere(conversation),
+		ToolsToCohere(input.Tools),
+		input.ProhibitTool,
+		requiredTool,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("there was an issue sending the request: %v", err)
+	}
+
+	// Create a token record for this request
+	tokenRecord := tokens.NewUsageRecordFromCohereUsage(input.Model, response.Usage)
+
+	logger.InfoContext(ctx, "Completed Cohere completion")
+	logger.DebugContext(ctx, "Cohere completion stats", "inTokens", tokenRecord.InputTokens, "outTokens", tokenRecord.OutputTokens, "totalTokens", tokenRecord.TotalTokens)
+
+	return &CompletionResponse{
+		Model:       input.Model,
+		StopReason:  response.FinishReason,
+		Message:     NewMessageFromCohere(response.Message),
+		UsageRecord: tokenRecord,
+	}, nil
+}
+
+// Logs the conversation at the debug level through the logger of the model, with the content redacted the same as logged bodies
+func (l *LanguageModel) LogConversation(ctx context.Context, conversation []*Message) {
+	logConversation(ctx, l.logger, slog.LevelDebug, l.redactor, conversation)
+}
+
+/*
+Logs the conversation at the info level through the default slog logger.
+
+Deprecated: use `LanguageModel.LogConversation`, which logs through the logger of the model and
+applies its redaction options.
+*/
 func PrintConversation(conversation []*Message) {
-	fmt.Println("\n\n --- LLM Conversation --- ")
-	for _, item := range conversation {
-		fmt.Println("[[", item.Role.ToString(), "]]")
+	logConversation(context.Background(), slog.Default(), slog.LevelInfo, newRedactor(nil), conversation)
+}
+
+// Logs a record for every message of the conversation
+func logConversation(ctx context.Context, logger *slog.Logger, level slog.Level, r *redactor, conversation []*Message) {
+	for idx, item := range conversation {
+		attrs := []any{"index", idx, "role", item.Role.ToString()}
 		switch item.Role {
-		case RoleSystem:
-			fallthrough
-		case RoleAI:
-			fallthrough
-		case RoleUser:
-			fmt.Println(">", item.Message)
 		case RoleToolCall:
-			fmt.Printf("Tool Call ID: %s\n", item.ToolUseID)
-			// compose a function list
-			calls := ""
-			for k, v := range item.ToolArguments {
-				calls += fmt.Sprintf("%s: \"%s\", ", k, v)
-			}
-			calls = calls[:len(calls)-2]
-			fmt.Printf("%s(%s)\n", item.ToolName, calls)
+			args, _ := json.Marshal(item.ToolArguments)
+			attrs = append(attrs, "id", item.ToolUseID, "name", item.ToolName, "arguments", r.Content(string(args)))
 		case RoleToolResult:
-			fmt.Printf("Tool Call ID: %s\n", item.ToolUseID)
-			fmt.Printf("Tool Name: %s\n", item.ToolName)
-			fmt.Printf("Result: %s\n", item.Message)
+			attrs = append(attrs, "id", item.ToolUseID, "name", item.ToolName, "result", r.Content(item.Message))
+		default:
+			attrs = append(attrs, "message", r.Content(item.Message))
 		}
-		fmt.Println("")
+		logger.Log(ctx, level, "Conversation message", attrs...)
 	}
 }
 
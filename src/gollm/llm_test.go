@@ -14,7 +14,7 @@ func TestLLMGPT(t *testing.T) {
 	logger := defaultLogger(slog.LevelDebug).With("test", "TestLLMGPT")
 	ctx := context.Background()
 
-	model := NewLanguageModel(test_user_id, logger, nil)
+	model := newTestLanguageModel(t, logger)
 	conversation := NewConversation("You are being used in a go test environment to validate your API calls are working.")
 	conversation = append(conversation, NewUserMessage("Testing 1,2,3 ..."))
 
@@ -31,7 +31,7 @@ func TestLLMGemini(t *testing.T) {
 	logger := defaultLogger(slog.LevelDebug).With("test", "TestLLMGemini")
 	ctx := context.Background()
 
-	model := NewLanguageModel(test_user_id, logger, nil)
+	model := newTestLanguageModel(t, logger)
 	conversation := NewConversation("You are being used in a go test environment to validate your API calls are working.")
 	conversation = append(conversation, NewUserMessage("Testing 1,2,3 ..."))
 
@@ -49,7 +49,7 @@ func TestLLMAnthropic(t *testing.T) {
 	logger := defaultLogger(slog.LevelDebug).With("test", "TestLLMAnthropic")
 	ctx := context.Background()
 
-	model := NewLanguageModel(test_user_id, logger, nil)
+	model := newTestLanguageModel(t, logger)
 	conversation := NewConversation("You are being used in a go test environment to validate your API calls are working.")
 	conversation = append(conversation, NewUserMessage("Testing 1,2,3 ..."))
 
@@ -66,7 +66,7 @@ func TestLLMMulti(t *testing.T) {
 	logger := defaultLogger(slog.LevelDebug).With("test", "TestLLMMulti")
 	ctx := context.Background()
 
-	model := NewLanguageModel(test_user_id, logger, nil)
+	model := newTestLanguageModel(t, logger)
 	conversation := NewConversation("You are a pirate on a deserted island")
 	conversation = append(conversation, NewUserMessage("Where is the treasure matey?"))
 
@@ -77,7 +77,7 @@ func TestLLMMulti(t *testing.T) {
 		Json:         false,
 		Conversation: conversation,
 	}
-	_, err = TokenEstimate(input1.Model, input1.Conversation[len(input1.Conversation)-1].Message)
+	_, err = model.TokenEstimate(input1.Model, input1.Conversation[len(input1.Conversation)-1].Message)
 	require.Nil(t, err)
 	// run a gpt completion
 	response1, err := model.Completion(ctx, input1)
@@ -94,7 +94,7 @@ func TestLLMMulti(t *testing.T) {
 		Json:         false,
 		Conversation: conversation,
 	}
-	_, err = TokenEstimate(input2.Model, input2.Conversation[len(input2.Conversation)-1].Message)
+	_, err = model.TokenEstimate(input2.Model, input2.Conversation[len(input2.Conversation)-1].Message)
 	require.Nil(t, err)
 
 	// run a gemini completion
@@ -113,7 +113,7 @@ func TestLLMMulti(t *testing.T) {
 		Json:         false,
 		Conversation: conversation,
 	}
-	_, err = TokenEstimate(input3.Model, input3.Conversation[len(input3.Conversation)-1].Message)
+	_, err = model.TokenEstimate(input3.Model, input3.Conversation[len(input3.Conversation)-1].Message)
 	require.Nil(t, err)
 
 	// run an anthropic completion
@@ -124,7 +124,7 @@ func TestLLMMulti(t *testing.T) {
 	}
 	conversation = append(conversation, response3.Message)
 
-	PrintConversation(conversation)
+	model.LogConversation(context.TODO(), conversation)
 	require.Equal(t, 7, len(conversation))
 }
 
@@ -146,7 +146,7 @@ func TestLLMToolUse(t *testing.T) {
 	})
 
 	// create an llm
-	llm := NewLanguageModel(test_user_id, logger, nil)
+	llm := newTestLanguageModel(t, logger)
 
 	// function to get seeded message array
 	runCombo := func(model1 string, model2 string) {
@@ -182,7 +182,7 @@ func TestLLMToolUse(t *testing.T) {
 		require.Equal(t, RoleAI, messages[len(messages)-1].Role)
 
 		fmt.Printf("MODEL 1: %s\nMODEL 2: %s\n", model1, model2)
-		PrintConversation(messages)
+		llm.LogConversation(context.TODO(), messages)
 	}
 
 	t.Run("ToolUse_OpenAI_Anthropic", func(t *testing.T) {
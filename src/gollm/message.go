@@ -1,6 +1,8 @@
 package gollm
 
 import (
+	"encoding/json"
+
 	"github.com/google/uuid"
 	"github.com/jake-landersweb/gollm/v2/src/ltypes"
 )
@@ -39,6 +41,11 @@ type Message struct {
 	ToolUseID     string         `json:"id"`        // If applicable - ID of the tool call
 	ToolName      string         `json:"name"`      // If applicable - Name of the tool call
 	ToolArguments map[string]any `json:"arguments"` // If applicable - Argments of the tool call. This will only be set on the role: `RoleToolCall`
+
+	// Marks the end of a prefix of the conversation for the provider to cache, such as a long system
+	// prompt or document. Maps to Anthropic `cache_control`, and is ignored by the providers that
+	// cache prompts automatically
+	CacheBreakpoint bool `json:"cache_breakpoint,omitempty"`
 }
 
 func (m *Message) GetToolCall() *ToolCall {
@@ -407,6 +414,300 @@ func MessagesToAnthropic(messages []*Message) []*ltypes.AnthropicMessage {
 				Content: content,
 			})
 		}
+
+		// the cache covers the request up to the last block of the message
+		if len(content) != 0 {
+			content[len(content)-1].CacheControl = anthropicCacheControl(msg.CacheBreakpoint)
+		}
+	}
+
+	return resp
+}
+
+func anthropicCacheControl(breakpoint bool) *ltypes.AnthropicCacheControl {
+	if !breakpoint {
+		return nil
+	}
+	return &ltypes.AnthropicCacheControl{Type: "ephemeral"}
+}
+
+/*
+Parses the response message of the Ollama api into a `Message`. Ollama does not assign ids to
+tool calls, so one is generated the same way as Gemini.
+*/
+func NewMessageFromOllama(input *ltypes.OllamaMessage) *Message {
+	return MessagesFromOllama([]*ltypes.OllamaMessage{input})[0]
+}
+
+func MessagesFromOllama(messages []*ltypes.OllamaMessage) []*Message {
+	resp := make([]*Message, 0)
+
+	for index, item := range messages {
+		switch item.Role {
+		case "system":
+			resp = append(resp, NewSystemMessage(item.Content))
+		case "assistant":
+			if len(item.ToolCalls) != 0 {
+				resp = append(resp, NewToolCallMessage(uuid.New().String(), item.ToolCalls[0].Function.Name, item.ToolCalls[0].Function.Arguments, ""))
+			} else {
+				resp = append(resp, NewAssistantMessage(item.Content))
+			}
+		case "tool":
+			// parse the tool use id from the previous message
+			toolUseId := ""
+			if index > 0 {
+				toolUseId = resp[index-1].ToolUseID
+			}
+			resp = append(resp, NewToolResultMessage(toolUseId, item.ToolName, item.Content))
+		default:
+			resp = append(resp, NewUserMessage(item.Content))
+		}
+	}
+
+	return resp
+}
+
+func MessagesToOllama(messages []*Message) []*ltypes.OllamaMessage {
+	resp := make([]*ltypes.OllamaMessage, 0)
+
+	for _, item := range messages {
+		message := &ltypes.OllamaMessage{
+			Content: item.Message,
+		}
+		switch item.Role {
+		case RoleSystem:
+			message.Role = "system"
+		case RoleAI:
+			message.Role = "assistant"
+		case RoleToolCall:
+			message.Role = "assistant"
+			message.ToolCalls = item.GetToolCall().ToOllama()
+		case RoleToolResult:
+			message.Role = "tool"
+			message.ToolName = item.ToolName
+		default:
+			message.Role = "user"
+		}
+
+		resp = append(resp, message)
+	}
+
+	return resp
+}
+
+/*
+Parses the output message of the Bedrock Converse api into a `Message`. When the model
+calls a tool, the tool call is returned along with any text the model wrote before it.
+*/
+func NewMessageFromBedrock(input *ltypes.BedrockMessage) *Message {
+	return MessagesFromBedrock([]*ltypes.BedrockMessage{input})[0]
+}
+
+func MessagesFromBedrock(messages []*ltypes.BedrockMessage) []*Message {
+	resp := make([]*Message, 0)
+
+	// tool results only carry the id of the call, so track the names of the calls
+	toolNames := make(map[string]string)
+
+	for _, msg := range messages {
+		text := ""
+		var toolCall *Message
+		for _, item := range msg.Content {
+			switch {
+			case item.ToolUse != nil:
+				toolNames[item.ToolUse.ToolUseId] = item.ToolUse.Name
+				toolCall = NewToolCallMessage(item.ToolUse.ToolUseId, item.ToolUse.Name, item.ToolUse.Input, "")
+			case item.ToolResult != nil:
+				result := ""
+				for _, content := range item.ToolResult.Content {
+					result += content.Text
+				}
+				resp = append(resp, NewToolResultMessage(item.ToolResult.ToolUseId, toolNames[item.ToolResult.ToolUseId], result))
+			default:
+				text += item.Text
+			}
+		}
+
+		switch {
+		case toolCall != nil:
+			toolCall.Message = text
+			resp = append(resp, toolCall)
+		case msg.Role == "system":
+			resp = append(resp, NewSystemMessage(text))
+		case msg.Role == "assistant":
+			resp = append(resp, NewAssistantMessage(text))
+		case text != "":
+			resp = append(resp, NewUserMessage(text))
+		}
+	}
+
+	return resp
+}
+
+func MessagesToBedrock(messages []*Message) []*ltypes.BedrockMessage {
+	resp := make([]*ltypes.BedrockMessage, 0)
+
+	for _, msg := range messages {
+		content := make([]*ltypes.BedrockContentBlock, 0)
+
+		switch msg.Role {
+		case RoleSystem:
+			// the system message is parsed from the message array during the request
+			// because the Converse api does not handle system messages the same way
+			content = append(content, &ltypes.BedrockContentBlock{Text: msg.Message})
+			resp = append(resp, &ltypes.BedrockMessage{
+				Role:    "system",
+				Content: content,
+			})
+		case RoleAI:
+			content = append(content, &ltypes.BedrockContentBlock{Text: msg.Message})
+			resp = append(resp, &ltypes.BedrockMessage{
+				Role:    "assistant",
+				Content: content,
+			})
+		case RoleToolCall:
+			// text blocks cannot be empty
+			if msg.Message != "" {
+				content = append(content, &ltypes.BedrockContentBlock{Text: msg.Message})
+			}
+			content = append(content, msg.GetToolCall().ToBedrock())
+			resp = append(resp, &ltypes.BedrockMessage{
+				Role:    "assistant",
+				Content: content,
+			})
+		case RoleToolResult:
+			// add tool call results as user messages
+			content = append(content, &ltypes.BedrockContentBlock{
+				ToolResult: &ltypes.BedrockToolResult{
+					ToolUseId: msg.ToolUseID,
+					Content:   []*ltypes.BedrockToolResultContent{{Text: msg.Message}},
+					Status:    "success",
+				},
+			})
+			resp = append(resp, &ltypes.BedrockMessage{
+				Role:    "user",
+				Content: content,
+			})
+		default:
+			content = append(content, &ltypes.BedrockContentBlock{Text: msg.Message})
+			resp = append(resp, &ltypes.BedrockMessage{
+				Role:    "user",
+				Content: content,
+			})
+		}
+	}
+
+	return resp
+}
+
+// Creates a new `Message` from the response message of the Mistral api
+func NewMessageFromMistral(input *ltypes.MistralMessage) *Message {
+	return MessagesFromMistral([]*ltypes.MistralMessage{input})[0]
+}
+
+func MessagesFromMistral(messages []*ltypes.MistralMessage) []*Message {
+	resp := make([]*Message, 0)
+
+	for _, item := range messages {
+		switch item.Role {
+		case "system":
+			resp = append(resp, NewSystemMessage(item.Content))
+		case "assistant":
+			if len(item.ToolCalls) != 0 {
+				args := make(map[string]any)
+				json.Unmarshal([]byte(item.ToolCalls[0].Function.Arguments), &args)
+				resp = append(resp, NewToolCallMessage(item.ToolCalls[0].ID, item.ToolCalls[0].Function.Name, args, item.Content))
+			} else {
+				resp = append(resp, NewAssistantMessage(item.Content))
+			}
+		case "tool":
+			resp = append(resp, NewToolResultMessage(item.ToolCallId, item.Name, item.Content))
+		default:
+			resp = append(resp, NewUserMessage(item.Content))
+		}
+	}
+
+	return resp
+}
+
+/*
+Converts the conversation into Mistral messages. Mistral only accepts tool call ids made of
+exactly 9 letters and digits, so ids created by other providers are mapped to a valid id.
+The mapping is deterministic, so a tool call and its result always share the same id.
+*/
+func MessagesToMistral(messages []*Message) []*ltypes.MistralMessage {
+	resp := make([]*ltypes.MistralMessage, 0)
+
+	for _, item := range messages {
+		message := &ltypes.MistralMessage{
+			Content: item.Message,
+		}
+		switch item.Role {
+		case RoleSystem:
+			message.Role = "system"
+		case RoleAI:
+			message.Role = "assistant"
+		case RoleToolCall:
+			message.Role = "assistant"
+			message.ToolCalls = item.GetToolCall().ToMistral()
+		case RoleToolResult:
+			message.Role = "tool"
+			message.ToolCallId = mistralToolCallId(item.ToolUseID)
+			message.Name = item.ToolName
+		default:
+			message.Role = "user"
+		}
+
+		resp = append(resp, message)
+	}
+
+	return resp
+}
+
+// Creates a new `Message` from the response message of the Cohere v2 chat api
+func NewMessageFromCohere(input *ltypes.CohereResponseMessage) *Message {
+	if len(input.ToolCalls) != 0 {
+		args := make(map[string]any)
+		json.Unmarshal([]byte(input.ToolCalls[0].Function.Arguments), &args)
+		// the tool plan holds the reasoning of the model before the call
+		return NewToolCallMessage(input.ToolCalls[0].ID, input.ToolCalls[0].Function.Name, args, input.ToolPlan)
+	}
+
+	text := ""
+	for _, item := range input.Content {
+		if item.Type == "text" {
+			text += item.Text
+		}
+	}
+	return NewAssistantMessage(text)
+}
+
+func MessagesToCohere(messages []*Message) []*ltypes.CohereMessage {
+	resp := make([]*ltypes.CohereMessage, 0)
+
+	for _, item := range messages {
+		message := &ltypes.CohereMessage{
+			Content: item.Message,
+		}
+		switch item.Role {
+		case RoleSystem:
+			message.Role = "system"
+		case RoleAI:
+			message.Role = "assistant"
+		case RoleToolCall:
+			// assistant messages with tool calls carry the reasoning as the tool plan
+			message.Role = "assistant"
+			message.Content = ""
+			message.ToolPlan = item.Message
+			message.ToolCalls = item.GetToolCall().ToCohere()
+		case RoleToolResult:
+			message.Role = "tool"
+			message.ToolCallId = item.ToolUseID
+		default:
+			message.Role = "user"
+		}
+
+		resp = append(resp, message)
 	}
 
 	return resp
@@ -0,0 +1,31 @@
+package gollm
+
+import (
+	"time"
+
+	"github.com/jake-landersweb/gollm/v2/src/metrics"
+	"github.com/jake-landersweb/gollm/v2/src/tokens"
+)
+
+// Records the outcome, latency and token usage of a finished request
+func observeRequest(
+	m metrics.Metrics,
+	operation string,
+	provider string,
+	model string,
+	start time.Time,
+	record *tokens.UsageRecord,
+	err error,
+) {
+	status := metrics.StatusSuccess
+	if err != nil {
+		status = metrics.StatusError
+	}
+	m.ObserveRequest(operation, provider, model, status, time.Since(start))
+
+	// failed requests can still be billed, such as the batches that succeeded before a partial failure
+	if record != nil {
+		cost, _ := tokens.EstimateCost(record)
+		m.ObserveUsage(operation, provider, record, cost)
+	}
+}
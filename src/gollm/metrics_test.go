@@ -0,0 +1,139 @@
+package gollm
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jake-landersweb/gollm/v2/src/metrics"
+	"github.com/jake-landersweb/gollm/v2/src/tokens"
+	"github.com/stretchr/testify/require"
+)
+
+// Metrics implementation that stores every observation for assertions
+type recordingMetrics struct {
+	mu       sync.Mutex
+	requests []string
+	retries  []string
+	usage    []*tokens.UsageRecord
+	costs    []float64
+	chunks   []int
+}
+
+func (m *recordingMetrics) ObserveRequest(operation string, provider string, model string, status string, latency time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests = append(m.requests, operation+"/"+provider+"/"+model+"/"+status)
+}
+
+func (m *recordingMetrics) ObserveRetry(operation string, provider string, model string, errorType string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.retries = append(m.retries, operation+"/"+provider+"/"+model+"/"+errorType)
+}
+
+func (m *recordingMetrics) ObserveUsage(operation string, provider string, record *tokens.UsageRecord, cost float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.usage = append(m.usage, record)
+	m.costs = append(m.costs, cost)
+}
+
+func (m *recordingMetrics) ObserveEmbeddingChunks(provider string, model string, chunks int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.chunks = append(m.chunks, chunks)
+}
+
+func TestMetricsCompletion(t *testing.T) {
+	skipRetryDelays(t)
+	logger := defaultLogger(slog.LevelDebug).With("test", "TestMetricsCompletion")
+	recorder := &recordingMetrics{}
+
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error": {"message": "slow down", "type": "rate_limit_error"}}`))
+			return
+		}
+		w.Write([]byte(tracingGPTResponse))
+	}))
+	defer server.Close()
+
+	model := NewLanguageModel(test_user_id, logger, &NewLanguageModelArgs{
+		GptBaseUrl:   server.URL,
+		OpenAIApiKey: "test",
+		Metrics:      recorder,
+	})
+	_, err := model.Completion(context.Background(), &CompletionInput{
+		Model:        gpt3_model,
+		Conversation: []*Message{NewUserMessage("Hello")},
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"completion/openai/gpt-3.5-turbo/success"}, recorder.requests)
+	require.Equal(t, []string{"completion/openai/gpt-3.5-turbo/rate_limit_error"}, recorder.retries)
+	require.Len(t, recorder.usage, 1)
+	require.Equal(t, 12, recorder.usage[0].InputTokens)
+	require.InDelta(t, (12*0.5+3*1.5)/1_000_000, recorder.costs[0], 1e-12)
+}
+
+func TestMetricsEmbeddings(t *testing.T) {
+	logger := defaultLogger(slog.LevelDebug).With("test", "TestMetricsEmbeddings")
+	recorder := &recordingMetrics{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error": {"message": "bad key", "type": "authentication_error"}}`))
+	}))
+	defer server.Close()
+
+	embeddings := NewOpenAIEmbeddings(test_user_id, &OpenAIEmbeddingsOpts{
+		BaseUrl:      server.URL,
+		OpenAIApiKey: "test",
+		Metrics:      recorder,
+	})
+	_, err := embeddings.Embed(context.Background(), logger, &EmbedArgs{InputChunks: []string{"a", "b"}})
+	require.Error(t, err)
+
+	require.Equal(t, []int{2}, recorder.chunks)
+	require.Equal(t, []string{"embeddings/openai/text-embedding-3-small/error"}, recorder.requests)
+	require.Empty(t, recorder.usage)
+
+	// the usage of the batches that succeeded is observed along with a partial failure
+	recorder = &recordingMetrics{}
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), "bad") {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": {"message": "invalid input", "type": "invalid_request_error"}}`))
+			return
+		}
+		w.Write([]byte(`{"object": "list", "data": [{"object": "embedding", "embedding": [0.5], "index": 0}], "model": "text-embedding-3-small", "usage": {"prompt_tokens": 4, "total_tokens": 4}}`))
+	}))
+	defer server.Close()
+	embeddings = NewOpenAIEmbeddings(test_user_id, &OpenAIEmbeddingsOpts{
+		BaseUrl:      server.URL,
+		OpenAIApiKey: "test",
+		MaxBatchSize: 1,
+		Metrics:      recorder,
+	})
+	_, err = embeddings.Embed(context.Background(), logger, &EmbedArgs{InputChunks: []string{"a", "bad"}})
+	var partial *PartialEmbedError
+	require.ErrorAs(t, err, &partial)
+	require.Equal(t, []string{"embeddings/openai/text-embedding-3-small/error"}, recorder.requests)
+	require.Len(t, recorder.usage, 1)
+	require.Equal(t, 4, recorder.usage[0].InputTokens)
+}
+
+func TestMetricsNoop(t *testing.T) {
+	require.Equal(t, metrics.NoopMetrics{}, metrics.OrNoop(nil))
+}
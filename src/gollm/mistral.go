@@ -0,0 +1,188 @@
+package gollm
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"math/rand"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jake-landersweb/gollm/v2/src/ltypes"
+	"github.com/jake-landersweb/gollm/v2/src/metrics"
+)
+
+func (l *LanguageModel) mistralCompletion(
+	ctx context.Context,
+	logger *slog.Logger,
+	model string,
+	temperature float64,
+	jsonMode bool,
+	jsonSchema string,
+	messages []*ltypes.MistralMessage,
+	tools []*ltypes.MistralTool,
+	prohibitTool bool,
+	toolChoice string,
+) (*ltypes.MistralChatResponse, error) {
+	apiKey := l.args.MistralApiKey
+	if apiKey == "" {
+		apiKey = os.Getenv("MISTRAL_API_KEY")
+		if apiKey == "" || apiKey == "null" {
+			return nil, fmt.Errorf("the environment variable `MISTRAL_API_KEY` is required")
+		}
+	}
+
+	// create the body
+	comprequest := ltypes.MistralChatRequest{
+		Model:       strings.TrimPrefix(model, mistral_model_prefix),
+		Messages:    messages,
+		Temperature: temperature,
+		MaxTokens:   l.args.MistralMaxTokens,
+	}
+
+	// the tools are always sent, as mistral rejects tool messages in the conversation without them
+	if len(tools) != 0 {
+		comprequest.Tools = tools
+		if prohibitTool {
+			comprequest.ToolChoice = "none"
+		} else if toolChoice != "" {
+			comprequest.ToolChoice = &ltypes.MistralToolChoice{
+				Type:     "function",
+				Function: &ltypes.GPTToolChoiceFunction{Name: toolChoice},
+			}
+		}
+	}
+
+	if jsonMode {
+		if jsonSchema == "" {
+			return nil, fmt.Errorf("please provide a valid json schema for the model to follow")
+		}
+		logger.DebugContext(ctx, "Running with json mode ENABLED")
+
+		// json mode guarantees valid json, so add the schema for the model to follow
+		comprequest.ResponseFormat = &ltypes.MistralResponseFormat{Type: "json_object"}
+		comprequest.Messages[len(comprequest.Messages)-1].Content = fmt.Sprintf("%s\n\nPlease respond to this message ONLY with the given JSON schema.\n\nJSON SCHEMA:\n%s", comprequest.Messages[len(comprequest.Messages)-1].Content, jsonSchema)
+	} else {
+		logger.DebugContext(ctx, "Running with json mode DISABLED")
+	}
+
+	enc, err := json.Marshal(&comprequest)
+	if err != nil {
+		return nil, fmt.Errorf("there was an issue encoding the body into json: %v", err)
+	}
+
+	logger.DebugContext(ctx, "Request body", "body", l.redactor.Body(enc, apiKey))
+
+	// send the request
+	client := l.args.HttpClient
+
+	retries := 3
+	backoff := 1 * time.Second
+
+	for attempt := 0; attempt < retries; attempt++ {
+		logger.InfoContext(ctx, "Sending Mistral request...")
+		statusCode, body, err := sendAttempt(ctx, l.tracer, client, genAISystemMistral, model, attempt, func(ctx context.Context) (*http.Request, error) {
+			req, err := http.NewRequestWithContext(ctx, "POST", l.args.MistralBaseUrl, bytes.NewBuffer(enc))
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Accept", "application/json")
+			req.Header.Set("Authorization", "Bearer "+apiKey)
+			return req, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		logger.InfoContext(ctx, "Completed request", "statusCode", statusCode)
+		logger.DebugContext(ctx, "Response body", "body", l.redactor.Body(body, apiKey))
+
+		// parse into the completion response object
+		var completion ltypes.MistralChatResponse
+		if err = json.Unmarshal(body, &completion); err != nil {
+			return nil, fmt.Errorf("there was an issue unmarshalling the request body: %v", err)
+		}
+
+		if statusCode == 200 && len(completion.Choices) != 0 && completion.Usage != nil {
+			return &completion, nil
+		}
+
+		// mistral does not always send an error type, so act based on the status code
+		message := mistralErrorMessage(completion.Message)
+		switch {
+		case statusCode == http.StatusUnauthorized:
+			return nil, fmt.Errorf("there was an issue authenticating: %s", message)
+		case statusCode == http.StatusBadRequest || statusCode == http.StatusUnprocessableEntity:
+			return nil, fmt.Errorf("there was a validation error: %s", message)
+		case statusCode == http.StatusTooManyRequests:
+			logger.WarnContext(ctx, "Rate limit hit, waiting 2 seconds then trying again ...")
+			if err := sleepContext(ctx, time.Second*2); err != nil {
+				return nil, err
+			}
+		case statusCode >= 500:
+			logger.WarnContext(ctx, "There was a server error, waiting 2 seconds then trying again ...")
+			if err := sleepContext(ctx, time.Second*2); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("there was an unknown error: [%d]: %s", statusCode, message)
+		}
+
+		recordRetryableError(ctx, l.metrics, metrics.OperationCompletion, genAISystemMistral, model, attempt, http.StatusText(statusCode))
+
+		if attempt < retries-1 {
+			sleep := backoff + time.Duration(rand.Intn(1000))*time.Millisecond // Add jitter
+			if err := sleepContext(ctx, sleep); err != nil {
+				return nil, err
+			}
+			backoff *= 2 // Double the backoff interval
+		} else {
+			return nil, fmt.Errorf("there was an issue with the request and could not recover: %s", l.redactor.Body(body, apiKey))
+		}
+	}
+
+	return nil, err
+}
+
+// Mistral sends the message of an error as a string, or as an object for validation errors
+func mistralErrorMessage(raw json.RawMessage) string {
+	var message string
+	if err := json.Unmarshal(raw, &message); err == nil {
+		return message
+	}
+	return string(raw)
+}
+
+const mistral_tool_call_id_length = 9
+const mistral_tool_call_id_alphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// Maps a tool call id to one that Mistral accepts. Valid ids are kept, and others are hashed into a valid id
+func mistralToolCallId(id string) string {
+	if len(id) == mistral_tool_call_id_length && strings.Trim(id, mistral_tool_call_id_alphabet) == "" {
+		return id
+	}
+
+	hash := sha256.Sum256([]byte(id))
+	value := new(big.Int).SetBytes(hash[:])
+	base := big.NewInt(int64(len(mistral_tool_call_id_alphabet)))
+	mod := new(big.Int)
+
+	resp := make([]byte, mistral_tool_call_id_length)
+	for i := range resp {
+		value.DivMod(value, base, mod)
+		resp[i] = mistral_tool_call_id_alphabet[mod.Int64()]
+	}
+	return string(resp)
+}
+
+// Mistral does not publish a tokenizer for go, so use the same approximation as gpt
+func mistralTokenizerApproximate(input string) (int, error) {
+	return gptTokenizerApproximate("avg", input)
+}
@@ -0,0 +1,130 @@
+package gollm
+
+import (
+	"context"
+	"log/slog"
+	"regexp"
+	"testing"
+
+	"github.com/jake-landersweb/gollm/v2/src/ltypes"
+	"github.com/stretchr/testify/require"
+)
+
+const mistral_test_response = `{"id": "cmpl-1", "object": "chat.completion", "model": "mistral-small-latest", "created": 1720000000, "choices": [{"index": 0, "message": {"role": "assistant", "content": "Hello!", "tool_calls": null}, "finish_reason": "stop"}], "usage": {"prompt_tokens": 10, "completion_tokens": 2, "total_tokens": 12}}`
+
+const mistral_tool_response = `{"id": "cmpl-2", "object": "chat.completion", "model": "mistral-small-latest", "created": 1720000000, "choices": [{"index": 0, "message": {"role": "assistant", "content": "", "tool_calls": [{"id": "D681PevKs", "type": "function", "function": {"name": "get_weather", "arguments": "{\"city\": \"Paris\"}"}}]}, "finish_reason": "tool_calls"}], "usage": {"prompt_tokens": 40, "completion_tokens": 10, "total_tokens": 50}}`
+
+var test_weather_tool = &Tool{
+	Title:       "get_weather",
+	Description: "Get the current weather of a city",
+	Schema: &ltypes.ToolSchema{
+		Type:       "object",
+		Properties: map[string]*ltypes.ToolSchema{"city": {Type: "string"}},
+		Required:   []string{"city"},
+	},
+}
+
+func TestMistralCompletion(t *testing.T) {
+	logger := defaultLogger(slog.LevelDebug).With("test", "TestMistralCompletion")
+	server, requests := newTestServer(t, respondWith(200, mistral_test_response))
+
+	llm := NewLanguageModel(test_user_id, logger, &NewLanguageModelArgs{MistralBaseUrl: server.URL, MistralApiKey: "mistral-secret-key"})
+	response, err := llm.Completion(context.TODO(), &CompletionInput{
+		Model:        "mistral/mistral-small-latest",
+		Json:         true,
+		JsonSchema:   `{"message": string}`,
+		Conversation: []*Message{NewSystemMessage("You are a helpful assistant."), NewUserMessage("Hello")},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "Hello!", response.Message.Message)
+	require.Equal(t, "stop", response.StopReason)
+	require.Equal(t, "mistral/mistral-small-latest", response.UsageRecord.Model)
+	require.Equal(t, 10, response.UsageRecord.InputTokens)
+	require.Equal(t, 2, response.UsageRecord.OutputTokens)
+
+	require.Equal(t, "Bearer mistral-secret-key", requests.header(t).Get("Authorization"))
+	require.Equal(t, "mistral-small-latest", requests.body(t)["model"])
+	require.Equal(t, map[string]any{"type": "json_object"}, requests.body(t)["response_format"])
+	messages := requests.body(t)["messages"].([]any)
+	require.Equal(t, "system", messages[0].(map[string]any)["role"])
+	require.Contains(t, messages[1].(map[string]any)["content"], `{"message": string}`)
+}
+
+func TestMistralTools(t *testing.T) {
+	logger := defaultLogger(slog.LevelDebug).With("test", "TestMistralTools")
+	server, requests := newTestServer(t, respondWith(200, mistral_tool_response))
+	llm := NewLanguageModel(test_user_id, logger, &NewLanguageModelArgs{MistralBaseUrl: server.URL, MistralApiKey: "mistral-secret-key"})
+
+	response, err := llm.Completion(context.TODO(), &CompletionInput{
+		Model:        "mistral/mistral-small-latest",
+		Conversation: []*Message{NewUserMessage("What is the weather in Paris?")},
+		Tools:        []*Tool{test_weather_tool},
+		RequiredTool: test_weather_tool,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "tool_calls", response.StopReason)
+	require.Equal(t, RoleToolCall, response.Message.Role)
+	require.Equal(t, "D681PevKs", response.Message.ToolUseID)
+	require.Equal(t, map[string]any{"city": "Paris"}, response.Message.ToolArguments)
+	require.Equal(t, map[string]any{"type": "function", "function": map[string]any{"name": "get_weather"}}, requests.body(t)["tool_choice"])
+
+	// tool calls created by other providers are mapped to valid ids
+	conversation := []*Message{
+		NewUserMessage("What is the weather in Paris?"),
+		NewToolCallMessage("call_abc123-openai", "get_weather", map[string]any{"city": "Paris"}, ""),
+		NewToolResultMessage("call_abc123-openai", "get_weather", "Sunny"),
+	}
+	_, err = llm.Completion(context.TODO(), &CompletionInput{
+		Model:        "mistral/mistral-small-latest",
+		Conversation: conversation,
+		Tools:        []*Tool{test_weather_tool},
+		ProhibitTool: true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "none", requests.body(t)["tool_choice"])
+
+	messages := requests.body(t)["messages"].([]any)
+	callId := messages[1].(map[string]any)["tool_calls"].([]any)[0].(map[string]any)["id"]
+	resultId := messages[2].(map[string]any)["tool_call_id"]
+	require.Regexp(t, regexp.MustCompile(`^[a-zA-Z0-9]{9}$`), callId)
+	require.Equal(t, callId, resultId)
+	require.Equal(t, "get_weather", messages[2].(map[string]any)["name"])
+}
+
+func TestMistralToolCallId(t *testing.T) {
+	// valid ids are kept
+	require.Equal(t, "D681PevKs", mistralToolCallId("D681PevKs"))
+
+	// invalid ids are mapped deterministically
+	for _, id := range []string{"", "call_abc123", "toolu_01A09q90qw90lq917835lq9", "0f8fad5b-d9cb-469f-a165-70867728950e"} {
+		mapped := mistralToolCallId(id)
+		require.Regexp(t, regexp.MustCompile(`^[a-zA-Z0-9]{9}$`), mapped)
+		require.Equal(t, mapped, mistralToolCallId(id))
+	}
+	require.NotEqual(t, mistralToolCallId("call_1"), mistralToolCallId("call_2"))
+}
+
+func TestMistralErrors(t *testing.T) {
+	logger := defaultLogger(slog.LevelDebug).With("test", "TestMistralErrors")
+
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		expected string
+	}{
+		{"unauthorized", 401, `{"message": "Unauthorized", "request_id": "1"}`, "Unauthorized"},
+		{"validation", 422, `{"object": "error", "message": {"detail": [{"type": "missing", "msg": "Field required"}]}, "type": "invalid_request_error"}`, "Field required"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, _ := newTestServer(t, respondWith(test.status, test.body))
+			llm := NewLanguageModel(test_user_id, logger, &NewLanguageModelArgs{MistralBaseUrl: server.URL, MistralApiKey: "mistral-secret-key"})
+			_, err := llm.Completion(context.TODO(), &CompletionInput{
+				Model:        "mistral/mistral-small-latest",
+				Conversation: []*Message{NewUserMessage("Hello")},
+			})
+			require.ErrorContains(t, err, test.expected)
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package gollm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jake-landersweb/gollm/v2/src/ltypes"
+	"github.com/jake-landersweb/gollm/v2/src/metrics"
+)
+
+func (l *LanguageModel) ollamaCompletion(
+	ctx context.Context,
+	logger *slog.Logger,
+	model string,
+	temperature float64,
+	jsonMode bool,
+	jsonSchema string,
+	messages []*ltypes.OllamaMessage,
+	tools []*ltypes.OllamaTool,
+	prohibitTool bool,
+	toolChoice string,
+) (*ltypes.OllamaChatResponse, error) {
+	// create the body
+	comprequest := ltypes.OllamaChatRequest{
+		Model:     strings.TrimPrefix(model, ollama_model_prefix),
+		Messages:  messages,
+		Stream:    false,
+		KeepAlive: l.args.OllamaKeepAlive,
+		Options: &ltypes.OllamaOptions{
+			Temperature: temperature,
+			NumPredict:  l.args.OllamaMaxTokens,
+		},
+	}
+
+	// ollama has no tool choice, so prohibiting a tool removes all tools, and requiring
+	// a tool only sends that tool to the model
+	if len(tools) != 0 && !prohibitTool {
+		if toolChoice != "" {
+			for _, item := range tools {
+				if item.Function.Name == toolChoice {
+					comprequest.Tools = []*ltypes.OllamaTool{item}
+				}
+			}
+			if comprequest.Tools == nil {
+				return nil, fmt.Errorf("the required tool was not found in the tool list: %s", toolChoice)
+			}
+		} else {
+			comprequest.Tools = tools
+		}
+	}
+
+	if jsonMode {
+		if jsonSchema == "" {
+			return nil, fmt.Errorf("please provide a valid json schema for the model to follow")
+		}
+		logger.DebugContext(ctx, "Running with json mode ENABLED")
+
+		// constrain the output to json, and add the schema for the model to follow
+		comprequest.Format = "json"
+		comprequest.Messages[len(comprequest.Messages)-1].Content = fmt.Sprintf("%s\n\nPlease respond to this message ONLY with the given JSON schema.\n\nJSON SCHEMA:\n%s", comprequest.Messages[len(comprequest.Messages)-1].Content, jsonSchema)
+	} else {
+		logger.DebugContext(ctx, "Running with json mode DISABLED")
+	}
+
+	enc, err := json.Marshal(&comprequest)
+	if err != nil {
+		return nil, fmt.Errorf("there was an issue encoding the body into json: %v", err)
+	}
+
+	logger.DebugContext(ctx, "Request body", "body", l.redactor.Body(enc))
+
+	// send the request
+	client := l.args.HttpClient
+	url := l.args.OllamaBaseUrl + ollama_chat_path
+
+	retries := 3
+	backoff := 1 * time.Second
+
+	for attempt := 0; attempt < retries; attempt++ {
+		logger.InfoContext(ctx, "Sending Ollama request...")
+		statusCode, body, err := sendAttempt(ctx, l.tracer, client, genAISystemOllama, model, attempt, func(ctx context.Context) (*http.Request, error) {
+			req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(enc))
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Content-Type", "application/json")
+			return req, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		logger.InfoContext(ctx, "Completed request", "statusCode", statusCode)
+		logger.DebugContext(ctx, "Response body", "body", l.redactor.Body(body))
+
+		// parse into the completion response object
+		var completion ltypes.OllamaChatResponse
+		if err = json.Unmarshal(body, &completion); err != nil {
+			return nil, fmt.Errorf("there was an issue unmarshalling the request body: %v", err)
+		}
+
+		if statusCode == 200 && completion.Error == "" {
+			return &completion, nil
+		}
+
+		// ollama only returns an error message, so act based on the status code
+		switch statusCode {
+		case http.StatusBadRequest:
+			return nil, fmt.Errorf("there was a validation error: %s", completion.Error)
+		case http.StatusNotFound:
+			return nil, fmt.Errorf("the model was not found, it may need to be pulled first: %s", completion.Error)
+		case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+			// the server queue is full, so wait some extra time and continue
+			logger.WarnContext(ctx, "The Ollama server is busy. Waiting for an additional 2 seconds...")
+			if err := sleepContext(ctx, time.Second*2); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("there was an unknown error: [%d]: %s", statusCode, completion.Error)
+		}
+
+		recordRetryableError(ctx, l.metrics, metrics.OperationCompletion, genAISystemOllama, model, attempt, http.StatusText(statusCode))
+
+		if attempt < retries-1 {
+			sleep := backoff + time.Duration(rand.Intn(1000))*time.Millisecond // Add jitter
+			if err := sleepContext(ctx, sleep); err != nil {
+				return nil, err
+			}
+			backoff *= 2 // Double the backoff interval
+		} else if statusCode != 200 {
+			return nil, fmt.Errorf("there was an issue with the request and could not recover: %s", l.redactor.Body(body))
+		}
+	}
+
+	return nil, err
+}
+
+// Ollama runs many model families with different tokenizers, so use the same approximation as gpt
+func ollamaTokenizerApproximate(input string) (int, error) {
+	return gptTokenizerApproximate("avg", input)
+}
@@ -0,0 +1,160 @@
+package gollm
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/jake-landersweb/gollm/v2/src/ltypes"
+	"github.com/stretchr/testify/require"
+)
+
+const ollama_test_model = "ollama/llama3.1"
+
+// Decodes the last chat request received by the server
+func lastOllamaRequest(t *testing.T, requests *testRequests) *ltypes.OllamaChatRequest {
+	t.Helper()
+	sent := requests.last(t)
+	require.Equal(t, ollama_chat_path, sent.URL.Path)
+	var request ltypes.OllamaChatRequest
+	require.NoError(t, json.Unmarshal(sent.Body, &request))
+	return &request
+}
+
+func TestOllamaTextCompletion(t *testing.T) {
+	logger := defaultLogger(slog.LevelDebug).With("test", "TestOllamaTextCompletion")
+	server, requests := newTestServer(t, respondWith(200, `{"model": "llama3.1", "message": {"role": "assistant", "content": " Hello there! "}, "done": true, "done_reason": "stop", "prompt_eval_count": 26, "eval_count": 4, "total_duration": 5000000}`))
+
+	llm := NewLanguageModel(test_user_id, logger, &NewLanguageModelArgs{OllamaBaseUrl: server.URL})
+	response, err := llm.Completion(context.TODO(), &CompletionInput{
+		Model:        ollama_test_model,
+		Temperature:  0,
+		Conversation: append(NewConversation("You are a helpful assistant."), NewUserMessage("Say hello.")),
+	})
+	require.NoError(t, err)
+
+	request := lastOllamaRequest(t, requests)
+	require.Equal(t, "llama3.1", request.Model)
+	require.False(t, request.Stream)
+	require.Equal(t, 0.0, request.Options.Temperature)
+	require.Len(t, request.Messages, 2)
+	require.Equal(t, "system", request.Messages[0].Role)
+
+	require.Equal(t, RoleAI, response.Message.Role)
+	require.Equal(t, "Hello there!", response.Message.Message)
+	require.Equal(t, "stop", response.StopReason)
+	require.Equal(t, ollama_test_model, response.UsageRecord.Model)
+	require.Equal(t, 26, response.UsageRecord.InputTokens)
+	require.Equal(t, 4, response.UsageRecord.OutputTokens)
+	require.Equal(t, 30, response.UsageRecord.TotalTokens)
+}
+
+func TestOllamaJSONCompletion(t *testing.T) {
+	logger := defaultLogger(slog.LevelDebug).With("test", "TestOllamaJSONCompletion")
+	server, requests := newTestServer(t, respondWith(200, `{"model": "llama3.1", "message": {"role": "assistant", "content": "{\"message\": \"hi\", \"date\": 1}"}, "done": true, "done_reason": "stop", "prompt_eval_count": 40, "eval_count": 12}`))
+
+	llm := NewLanguageModel(test_user_id, logger, &NewLanguageModelArgs{OllamaBaseUrl: server.URL})
+	response, err := llm.Completion(context.TODO(), &CompletionInput{
+		Model:        ollama_test_model,
+		Json:         true,
+		JsonSchema:   `{"message": string, "date": int}`,
+		Conversation: []*Message{NewUserMessage("Please give a reasonable response.")},
+	})
+	require.NoError(t, err)
+
+	request := lastOllamaRequest(t, requests)
+	require.Equal(t, "json", request.Format)
+	require.Contains(t, request.Messages[0].Content, `{"message": string, "date": int}`)
+
+	var parsed map[string]any
+	require.NoError(t, json.Unmarshal([]byte(response.Message.Message), &parsed))
+	require.Equal(t, "hi", parsed["message"])
+}
+
+func TestOllamaToolUsage(t *testing.T) {
+	logger := defaultLogger(slog.LevelDebug).With("test", "TestOllamaToolUsage")
+	tool := &Tool{
+		Title:       "get_weather",
+		Description: "Get the weather for a city",
+		Schema: &ltypes.ToolSchema{
+			Type:       "object",
+			Properties: map[string]*ltypes.ToolSchema{"city": {Type: "string"}},
+			Required:   []string{"city"},
+		},
+	}
+
+	server, requests := newTestServer(t, respondWith(200, `{"model": "llama3.1", "message": {"role": "assistant", "content": "", "tool_calls": [{"function": {"name": "get_weather", "arguments": {"city": "Paris"}}}]}, "done": true, "done_reason": "stop", "prompt_eval_count": 80, "eval_count": 20}`))
+
+	llm := NewLanguageModel(test_user_id, logger, &NewLanguageModelArgs{OllamaBaseUrl: server.URL})
+	conversation := []*Message{NewUserMessage("What is the weather in Paris?")}
+	response, err := llm.Completion(context.TODO(), &CompletionInput{
+		Model:        ollama_test_model,
+		Conversation: conversation,
+		Tools:        []*Tool{tool},
+	})
+	require.NoError(t, err)
+
+	request := lastOllamaRequest(t, requests)
+	require.Len(t, request.Tools, 1)
+	require.Equal(t, "function", request.Tools[0].Type)
+	require.Equal(t, "get_weather", request.Tools[0].Function.Name)
+
+	require.Equal(t, RoleToolCall, response.Message.Role)
+	require.Equal(t, "tool_calls", response.StopReason)
+	require.NotEmpty(t, response.Message.ToolUseID)
+	require.Equal(t, "get_weather", response.Message.ToolName)
+	require.Equal(t, "Paris", response.Message.ToolArguments["city"])
+
+	// send the tool result back, and make sure tools can be prohibited
+	conversation = append(conversation, response.Message, NewToolResultMessage(response.Message.ToolUseID, "get_weather", "Sunny, 24C"))
+	server, requests = newTestServer(t, respondWith(200, `{"model": "llama3.1", "message": {"role": "assistant", "content": "It is sunny in Paris."}, "done": true, "done_reason": "stop", "prompt_eval_count": 100, "eval_count": 8}`))
+
+	llm = NewLanguageModel(test_user_id, logger, &NewLanguageModelArgs{OllamaBaseUrl: server.URL})
+	response, err = llm.Completion(context.TODO(), &CompletionInput{
+		Model:        ollama_test_model,
+		Conversation: conversation,
+		Tools:        []*Tool{tool},
+		ProhibitTool: true,
+	})
+	require.NoError(t, err)
+	request = lastOllamaRequest(t, requests)
+	require.Empty(t, request.Tools)
+	require.Len(t, request.Messages, 3)
+	require.Equal(t, "Paris", request.Messages[1].ToolCalls[0].Function.Arguments["city"])
+	require.Equal(t, "tool", request.Messages[2].Role)
+	require.Equal(t, "get_weather", request.Messages[2].ToolName)
+	require.Equal(t, "Sunny, 24C", request.Messages[2].Content)
+	require.Equal(t, "It is sunny in Paris.", response.Message.Message)
+}
+
+func TestOllamaModelNotFound(t *testing.T) {
+	logger := defaultLogger(slog.LevelDebug).With("test", "TestOllamaModelNotFound")
+	server, _ := newTestServer(t, respondWith(404, `{"error": "model \"llama3.1\" not found, try pulling it first"}`))
+
+	llm := NewLanguageModel(test_user_id, logger, &NewLanguageModelArgs{OllamaBaseUrl: server.URL})
+	_, err := llm.Completion(context.TODO(), &CompletionInput{
+		Model:        ollama_test_model,
+		Conversation: []*Message{NewUserMessage("Hello")},
+	})
+	require.ErrorContains(t, err, "try pulling it first")
+}
+
+func TestOllamaMessageConversion(t *testing.T) {
+	messages := []*Message{
+		NewSystemMessage("system"),
+		NewUserMessage("user"),
+		NewToolCallMessage("id-1", "get_weather", map[string]any{"city": "Paris"}, ""),
+		NewToolResultMessage("id-1", "get_weather", "Sunny"),
+		NewAssistantMessage("assistant"),
+	}
+
+	converted := MessagesFromOllama(MessagesToOllama(messages))
+	require.Len(t, converted, len(messages))
+	for i := range messages {
+		require.Equal(t, messages[i].Role, converted[i].Role)
+		require.Equal(t, messages[i].Message, converted[i].Message)
+		require.Equal(t, messages[i].ToolName, converted[i].ToolName)
+	}
+	require.Equal(t, converted[2].ToolUseID, converted[3].ToolUseID)
+}
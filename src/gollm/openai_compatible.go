@@ -0,0 +1,95 @@
+package gollm
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+/*
+A named provider that speaks the OpenAI chat completions format, such as Groq, Together,
+vLLM, OpenRouter or LM Studio. Models are routed to the provider in `Completion` either
+by prefixing the model with the name of the provider, such as `groq/llama-3.1-70b-versatile`,
+or by listing the model in `Models`. The prefix is removed before the request is sent.
+
+	&OpenAICompatibleProvider{
+		Name:      "groq",
+		BaseUrl:   "https://api.groq.com/openai/v1/chat/completions",
+		ApiKeyEnv: "GROQ_API_KEY",
+	}
+*/
+type OpenAICompatibleProvider struct {
+	// Name of the provider. Used as the model prefix, and reported as the provider in traces and metrics
+	Name string
+
+	// Full url of the chat completions endpoint
+	BaseUrl string
+
+	// Api key sent as a bearer token. If not defined, `ApiKeyEnv` is read. If both are empty, no key is sent,
+	// which is what local servers such as vLLM and LM Studio expect
+	ApiKey    string
+	ApiKeyEnv string
+
+	// Extra headers sent with every request, such as `HTTP-Referer` for OpenRouter
+	Headers map[string]string
+
+	// Models that are routed to this provider without needing the prefix
+	Models []string
+
+	// Quirks of the provider. Set these when the server rejects fields that OpenAI accepts
+	NoResponseFormat bool // Do not send `response_format`. Json mode then relies only on the prompt
+	NoUser           bool // Do not send the `user` field
+
+	// Credential sent through `Headers`, which is redacted from logs
+	secret string
+
+	// Return a `ContentFilterError` when the prompt or completion is filtered, as Azure does
+	contentFilterErrors bool
+}
+
+// Reads the api key of the provider, falling back to the environment variable
+func (p *OpenAICompatibleProvider) apiKey() (string, error) {
+	if p.ApiKey != "" {
+		return p.ApiKey, nil
+	}
+	if p.ApiKeyEnv == "" {
+		return "", nil
+	}
+	apiKey := os.Getenv(p.ApiKeyEnv)
+	if apiKey == "" || apiKey == "null" {
+		return "", fmt.Errorf("the env variable `%s` is required to be set", p.ApiKeyEnv)
+	}
+	return apiKey, nil
+}
+
+// Whether the model is routed to this provider, and the name of the model to send to the provider
+func (p *OpenAICompatibleProvider) match(model string) (string, bool) {
+	if after, ok := strings.CutPrefix(model, p.Name+"/"); ok {
+		return after, true
+	}
+	if slices.Contains(p.Models, model) {
+		return model, true
+	}
+	return "", false
+}
+
+// The built in OpenAI provider, configured from the language model arguments
+func (l *LanguageModel) openAIProvider() *OpenAICompatibleProvider {
+	return &OpenAICompatibleProvider{
+		Name:      genAISystemOpenAI,
+		BaseUrl:   l.args.GptBaseUrl,
+		ApiKey:    l.args.OpenAIApiKey,
+		ApiKeyEnv: "OPENAI_API_KEY",
+	}
+}
+
+// Finds the compatible provider the model is routed to, returning nil if there is none
+func (l *LanguageModel) openAICompatibleProvider(model string) (*OpenAICompatibleProvider, string) {
+	for _, provider := range l.args.OpenAICompatibleProviders {
+		if name, ok := provider.match(model); ok {
+			return provider, name
+		}
+	}
+	return nil, ""
+}
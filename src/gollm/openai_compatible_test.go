@@ -0,0 +1,175 @@
+package gollm
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const compatible_test_response = `{"id": "chatcmpl-1", "object": "chat.completion", "model": "llama-3.1-8b-instant", "choices": [{"index": 0, "message": {"role": "assistant", "content": "Hello!"}, "finish_reason": "stop"}], "usage": {"prompt_tokens": 10, "completion_tokens": 2, "total_tokens": 12}}`
+
+func TestOpenAICompatibleRouting(t *testing.T) {
+	logger := defaultLogger(slog.LevelDebug).With("test", "TestOpenAICompatibleRouting")
+	server, requests := newTestServer(t, respondWith(200, compatible_test_response))
+
+	t.Setenv("GOLLM_TEST_GROQ_KEY", "groq-secret-key")
+	llm := NewLanguageModel(test_user_id, logger, &NewLanguageModelArgs{
+		OpenAICompatibleProviders: []*OpenAICompatibleProvider{
+			{
+				Name:      "groq",
+				BaseUrl:   server.URL,
+				ApiKeyEnv: "GOLLM_TEST_GROQ_KEY",
+				Headers:   map[string]string{"X-Title": "gollm"},
+				Models:    []string{"llama-3.1-8b-instant"},
+			},
+		},
+	})
+
+	// routed by prefix
+	response, err := llm.Completion(context.TODO(), &CompletionInput{
+		Model:        "groq/llama-3.1-70b-versatile",
+		Conversation: []*Message{NewUserMessage("Hello")},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "Hello!", response.Message.Message)
+	require.Equal(t, "groq/llama-3.1-70b-versatile", response.UsageRecord.Model)
+	require.Equal(t, 12, response.UsageRecord.TotalTokens)
+	require.Equal(t, "llama-3.1-70b-versatile", requests.body(t)["model"])
+	require.Equal(t, test_user_id, requests.body(t)["user"])
+	require.Equal(t, map[string]any{"type": "text"}, requests.body(t)["response_format"])
+	require.Equal(t, "Bearer groq-secret-key", requests.header(t).Get("Authorization"))
+	require.Equal(t, "gollm", requests.header(t).Get("X-Title"))
+
+	// routed by the model list
+	_, err = llm.Completion(context.TODO(), &CompletionInput{
+		Model:        "llama-3.1-8b-instant",
+		Conversation: []*Message{NewUserMessage("Hello")},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "llama-3.1-8b-instant", requests.body(t)["model"])
+
+	// unknown models are still rejected
+	_, err = llm.Completion(context.TODO(), &CompletionInput{
+		Model:        "together/llama",
+		Conversation: []*Message{NewUserMessage("Hello")},
+	})
+	require.Error(t, err)
+}
+
+func TestOpenAICompatibleQuirks(t *testing.T) {
+	logger := defaultLogger(slog.LevelDebug).With("test", "TestOpenAICompatibleQuirks")
+	server, requests := newTestServer(t, respondWith(200, compatible_test_response))
+
+	llm := NewLanguageModel(test_user_id, logger, &NewLanguageModelArgs{
+		OpenAICompatibleProviders: []*OpenAICompatibleProvider{
+			{
+				Name:             "vllm",
+				BaseUrl:          server.URL,
+				NoResponseFormat: true,
+				NoUser:           true,
+			},
+		},
+	})
+
+	_, err := llm.Completion(context.TODO(), &CompletionInput{
+		Model:        "vllm/meta-llama/Llama-3.1-8B-Instruct",
+		Json:         true,
+		JsonSchema:   `{"message": string}`,
+		Conversation: []*Message{NewUserMessage("Hello")},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "meta-llama/Llama-3.1-8B-Instruct", requests.body(t)["model"])
+	require.NotContains(t, requests.body(t), "response_format")
+	require.NotContains(t, requests.body(t), "user")
+	require.Empty(t, requests.header(t).Get("Authorization"))
+
+	// the schema is still added to the prompt
+	messages := requests.body(t)["messages"].([]any)
+	require.Contains(t, messages[0].(map[string]any)["content"], `{"message": string}`)
+}
+
+func TestOpenAICompatibleErrors(t *testing.T) {
+	logger := defaultLogger(slog.LevelDebug).With("test", "TestOpenAICompatibleErrors")
+
+	// vLLM does not nest its errors under `error`
+	server, _ := newTestServer(t, respondWith(400, `{"object": "error", "message": "max_tokens is too large", "type": "BadRequestError", "code": 400}`))
+	llm := NewLanguageModel(test_user_id, logger, &NewLanguageModelArgs{
+		OpenAICompatibleProviders: []*OpenAICompatibleProvider{{Name: "vllm", BaseUrl: server.URL}},
+	})
+	_, err := llm.Completion(context.TODO(), &CompletionInput{
+		Model:        "vllm/llama",
+		Conversation: []*Message{NewUserMessage("Hello")},
+	})
+	require.ErrorContains(t, err, "max_tokens is too large")
+
+	// a missing key env variable is reported
+	llm = NewLanguageModel(test_user_id, logger, &NewLanguageModelArgs{
+		OpenAICompatibleProviders: []*OpenAICompatibleProvider{{Name: "together", BaseUrl: server.URL, ApiKeyEnv: "GOLLM_TEST_MISSING_KEY"}},
+	})
+	_, err = llm.Completion(context.TODO(), &CompletionInput{
+		Model:        "together/llama",
+		Conversation: []*Message{NewUserMessage("Hello")},
+	})
+	require.ErrorContains(t, err, "GOLLM_TEST_MISSING_KEY")
+
+	// token limit errors are returned instead of retried
+	server, requests := newTestServer(t, respondWith(400, `{"error": {"message": "too many tokens", "type": "tokens_exceeded_error"}}`))
+	llm = NewLanguageModel(test_user_id, logger, &NewLanguageModelArgs{
+		OpenAICompatibleProviders: []*OpenAICompatibleProvider{{Name: "vllm", BaseUrl: server.URL}},
+		GptMaxTokens:              4096,
+	})
+	_, err = llm.Completion(context.TODO(), &CompletionInput{
+		Model:        "vllm/llama",
+		Conversation: []*Message{NewUserMessage("Hello")},
+	})
+	require.ErrorContains(t, err, "exceeds the token limit")
+	require.Len(t, requests.all(), 1)
+}
+
+func TestOpenAICompatibleRetriesPlainErrors(t *testing.T) {
+	skipRetryDelays(t)
+	logger := defaultLogger(slog.LevelDebug).With("test", "TestOpenAICompatibleRetriesPlainErrors")
+
+	// proxies answer with html or plain text, which is retried by the status code
+	requests := &testRequests{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.record(r)
+		switch len(requests.all()) {
+		case 1:
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte("<html><body>502 Bad Gateway</body></html>"))
+		case 2:
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte("slow down"))
+		default:
+			w.Write([]byte(compatible_test_response))
+		}
+	}))
+	defer server.Close()
+
+	llm := NewLanguageModel(test_user_id, logger, &NewLanguageModelArgs{
+		OpenAICompatibleProviders: []*OpenAICompatibleProvider{{Name: "vllm", BaseUrl: server.URL}},
+	})
+	response, err := llm.Completion(context.TODO(), &CompletionInput{
+		Model:        "vllm/llama",
+		Conversation: []*Message{NewUserMessage("Hello")},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "Hello!", response.Message.Message)
+	require.Len(t, requests.all(), 3)
+
+	// plain text client errors are returned with the body
+	server, _ = newTestServer(t, respondWith(http.StatusBadRequest, "bad request"))
+	llm = NewLanguageModel(test_user_id, logger, &NewLanguageModelArgs{
+		OpenAICompatibleProviders: []*OpenAICompatibleProvider{{Name: "vllm", BaseUrl: server.URL}},
+	})
+	_, err = llm.Completion(context.TODO(), &CompletionInput{
+		Model:        "vllm/llama",
+		Conversation: []*Message{NewUserMessage("Hello")},
+	})
+	require.ErrorContains(t, err, "bad request")
+}
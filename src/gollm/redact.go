@@ -0,0 +1,164 @@
+package gollm
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+const redaction_replacement_default = "[REDACTED]"
+
+// Secrets shorter than this are not masked by value, as they would match unrelated text
+const redaction_secret_min_length = 8
+
+// Patterns matching the api key formats of the supported providers
+var apiKeyPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`sk-[A-Za-z0-9_\-]{16,}`),          // OpenAI and Anthropic
+	regexp.MustCompile(`AIza[0-9A-Za-z_\-]{35}`),          // Google
+	regexp.MustCompile(`(?i)([?&]key=)[^&\s"]+`),          // keys passed as a query parameter
+	regexp.MustCompile(`(?i)(Bearer\s+)[A-Za-z0-9._\-]+`), // bearer tokens
+}
+
+// Common PII patterns that can be passed to `RedactionOpts.Patterns`
+var (
+	PIIEmailPattern      = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	PIIPhonePattern      = regexp.MustCompile(`(?:\+\d{1,2}[\s.\-]?)?(?:\(\d{3}\)|\b\d{3})[\s.\-]?\d{3}[\s.\-]?\d{4}\b`)
+	PIICreditCardPattern = regexp.MustCompile(`\b(?:\d[ \-]?){13,16}\b`)
+	PIISSNPattern        = regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`)
+)
+
+// All of the built in PII patterns
+var PIIPatterns = []*regexp.Regexp{
+	PIIEmailPattern,
+	PIISSNPattern,
+	PIICreditCardPattern,
+	PIIPhonePattern,
+}
+
+// Keys of request and response bodies that hold user or model content
+var redactionContentKeys = map[string]bool{
+	"content":   true,
+	"text":      true,
+	"arguments": true,
+	"args":      true,
+	"input":     true,
+	"system":    true,
+	"response":  true,
+	"parts":     true,
+	"messages":  true,
+	"contents":  true,
+}
+
+// Keys that describe the structure of a body, which are kept even inside of content
+var redactionStructuralKeys = map[string]bool{
+	"type":         true,
+	"role":         true,
+	"id":           true,
+	"name":         true,
+	"tool_use_id":  true,
+	"tool_call_id": true,
+}
+
+/*
+Configures how requests and responses are redacted before they are logged. Api keys are always
+masked, this struct controls what is masked on top of that. Can be passed in as nil.
+*/
+type RedactionOpts struct {
+	// Mask all message content, tool arguments and tool results in logged bodies
+	RedactContent bool
+
+	// Additional patterns to mask, such as `PIIPatterns`
+	Patterns []*regexp.Regexp
+
+	// Text used in place of the masked values. Defaults to "[REDACTED]"
+	Replacement string
+}
+
+// Masks secrets and configured content from text before it is logged
+type redactor struct {
+	opts *RedactionOpts
+}
+
+func newRedactor(opts *RedactionOpts) *redactor {
+	// copy the options, so the defaults are not written to the caller's struct
+	resp := RedactionOpts{}
+	if opts != nil {
+		resp = *opts
+		resp.Patterns = append([]*regexp.Regexp(nil), opts.Patterns...)
+	}
+	if resp.Replacement == "" {
+		resp.Replacement = redaction_replacement_default
+	}
+	return &redactor{opts: &resp}
+}
+
+// Masks api keys, the passed secrets and the configured patterns from the string
+func (r *redactor) String(input string, secrets ...string) string {
+	for _, secret := range secrets {
+		if len(secret) >= redaction_secret_min_length {
+			input = strings.ReplaceAll(input, secret, r.opts.Replacement)
+		}
+	}
+	for _, pattern := range apiKeyPatterns {
+		if pattern.NumSubexp() > 0 {
+			input = pattern.ReplaceAllString(input, "${1}"+r.opts.Replacement)
+		} else {
+			input = pattern.ReplaceAllLiteralString(input, r.opts.Replacement)
+		}
+	}
+	for _, pattern := range r.opts.Patterns {
+		input = pattern.ReplaceAllLiteralString(input, r.opts.Replacement)
+	}
+	return input
+}
+
+// Redacts message content, masking all of it when content is redacted
+func (r *redactor) Content(input string) string {
+	if r.opts.RedactContent && input != "" {
+		return r.opts.Replacement
+	}
+	return r.String(input)
+}
+
+// Redacts a json request or response body. If the body is not valid json, it is redacted as plain text.
+func (r *redactor) Body(body []byte, secrets ...string) string {
+	if !r.opts.RedactContent {
+		return r.String(string(body), secrets...)
+	}
+
+	var parsed any
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return r.String(string(body), secrets...)
+	}
+	enc, err := json.Marshal(r.redactContent(parsed, false))
+	if err != nil {
+		return r.opts.Replacement
+	}
+	return r.String(string(enc), secrets...)
+}
+
+// Walks the decoded json, masking all string values that sit beneath a content key
+func (r *redactor) redactContent(value any, inContent bool) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			if inContent && redactionStructuralKeys[key] {
+				continue
+			}
+			v[key] = r.redactContent(item, inContent || redactionContentKeys[key])
+		}
+		return v
+	case []any:
+		for i, item := range v {
+			v[i] = r.redactContent(item, inContent)
+		}
+		return v
+	case string:
+		if inContent {
+			return r.opts.Replacement
+		}
+		return v
+	default:
+		return v
+	}
+}
@@ -0,0 +1,79 @@
+package gollm
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+
+	"github.com/jake-landersweb/gollm/v2/src/tokens"
+)
+
+type RerankArgs struct {
+	Query     string
+	Documents []string
+
+	// The number of results to return. Defaults to all of the documents
+	TopN int
+}
+
+func (args *RerankArgs) IsValid() error {
+	if args == nil {
+		return fmt.Errorf("cannot be nil")
+	}
+	if args.Query == "" {
+		return fmt.Errorf("the query cannot be empty")
+	}
+	if len(args.Documents) == 0 {
+		return fmt.Errorf("the documents cannot be empty")
+	}
+	if args.TopN < 0 {
+		return fmt.Errorf("topN cannot be negative")
+	}
+	if args.TopN == 0 || args.TopN > len(args.Documents) {
+		args.TopN = len(args.Documents)
+	}
+	return nil
+}
+
+// The relevance of a document to the query
+type RerankResult struct {
+	// Position of the document in `RerankArgs.Documents`
+	Index    int
+	Document string
+
+	// Higher is more relevant. The scale depends on the reranker, but is usually between 0 and 1
+	Score float64
+}
+
+type RerankResponse struct {
+	// Sorted by score, most relevant first
+	Results []*RerankResult
+	Usage   *tokens.UsageRecord
+}
+
+type Reranker interface {
+	// Scores the relevance of every document to the query
+	Rerank(
+		ctx context.Context,
+		logger *slog.Logger,
+		args *RerankArgs,
+	) (*RerankResponse, error)
+
+	// optionally store token records state inside the object as well
+	GetUsageRecords() []*tokens.UsageRecord
+}
+
+// Sorts the results by score, breaking ties by their position, and keeps the top n
+func sortRerankResults(results []*RerankResult, topN int) []*RerankResult {
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Index < results[j].Index
+	})
+	if len(results) > topN {
+		results = results[:topN]
+	}
+	return results
+}
@@ -0,0 +1,293 @@
+package gollm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/jake-landersweb/gollm/v2/src/ltypes"
+	"github.com/jake-landersweb/gollm/v2/src/metrics"
+	"github.com/jake-landersweb/gollm/v2/src/tokens"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// The defaults of every provider with a `/rerank` endpoint
+type rerankProviderDefaults struct {
+	system  string
+	model   string
+	baseUrl string
+	envKey  string
+}
+
+var rerankProviders = map[ltypes.RerankProvider]*rerankProviderDefaults{
+	ltypes.RERANK_PROVIDER_COHERE: {system: genAISystemCohere, model: "rerank-v3.5", baseUrl: cohere_rerank_base_url, envKey: "CO_API_KEY"},
+	ltypes.RERANK_PROVIDER_JINA:   {system: genAISystemJina, model: "jina-reranker-v2-base-multilingual", baseUrl: jina_rerank_base_url, envKey: "JINA_API_KEY"},
+	ltypes.RERANK_PROVIDER_VOYAGE: {system: genAISystemVoyage, model: "rerank-2", baseUrl: voyage_rerank_base_url, envKey: "VOYAGE_API_KEY"},
+}
+
+// Struct to handle the reranking lifecycle with the cross-encoder models of Cohere, Jina or Voyage
+type HTTPReranker struct {
+	opts     *HTTPRerankerOpts
+	defaults *rerankProviderDefaults
+	tracer   trace.Tracer
+	metrics  metrics.Metrics
+	redactor *redactor
+
+	usageRecords []*tokens.UsageRecord
+}
+
+// Optional configurations to customize the usage of the model.
+// This struct can be passed in as nil, and reasonable and functional defaults will be used.
+type HTTPRerankerOpts struct {
+	// Defaults to `cohere`
+	Provider ltypes.RerankProvider
+
+	// Defaults to `rerank-v3.5` for Cohere, `jina-reranker-v2-base-multilingual` for Jina and `rerank-2` for Voyage
+	Model string
+
+	// Defaults to the `/rerank` endpoint of the provider. Any service with the same request and
+	// response shape can be used, such as a self-hosted text-embeddings-inference server behind a proxy
+	BaseUrl string
+
+	// Optionally pass the http client used to send all requests, such as one with a custom transport.
+	HttpClient *http.Client
+
+	// Optionally pass in an api key. If not specified, the environment variable of the provider will be
+	// read: `CO_API_KEY`, `JINA_API_KEY` or `VOYAGE_API_KEY`.
+	ApiKey string
+
+	// Optionally trace reranking with OpenTelemetry. If not specified, the global provider will be used.
+	TracerProvider trace.TracerProvider
+
+	// Optionally collect metrics on reranking. If not specified, no metrics are collected.
+	Metrics metrics.Metrics
+
+	// Optionally configure what is masked from logged requests and responses. Api keys are always masked.
+	Redaction *RedactionOpts
+}
+
+func NewHTTPReranker(opts *HTTPRerankerOpts) (*HTTPReranker, error) {
+	if opts == nil {
+		opts = &HTTPRerankerOpts{}
+	}
+	if opts.Provider == "" {
+		opts.Provider = ltypes.RERANK_PROVIDER_COHERE
+	}
+	defaults, ok := rerankProviders[opts.Provider]
+	if !ok {
+		return nil, fmt.Errorf("unsupported rerank provider: %s", opts.Provider)
+	}
+	if opts.Model == "" {
+		opts.Model = defaults.model
+	}
+	if opts.BaseUrl == "" {
+		opts.BaseUrl = defaults.baseUrl
+	}
+	if opts.HttpClient == nil {
+		opts.HttpClient = &http.Client{}
+	}
+
+	return &HTTPReranker{
+		opts:     opts,
+		defaults: defaults,
+		tracer:   newTracer(opts.TracerProvider),
+		metrics:  metrics.OrNoop(opts.Metrics),
+		redactor: newRedactor(opts.Redaction),
+	}, nil
+}
+
+/*
+Scores the documents with the model of the provider. The usage records of Cohere have no tokens,
+as Cohere bills reranking by search units.
+*/
+func (r *HTTPReranker) Rerank(
+	ctx context.Context,
+	logger *slog.Logger,
+	args *RerankArgs,
+) (*RerankResponse, error) {
+	if logger == nil {
+		logger = discardLogger()
+	}
+
+	ctx, span := r.tracer.Start(ctx, fmt.Sprintf("%s %s", genAIOperationRerank, r.opts.Model),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attrGenAIOperationName.String(genAIOperationRerank),
+			attrGenAISystem.String(r.defaults.system),
+			attrGenAIRequestModel.String(r.opts.Model),
+		),
+	)
+	defer span.End()
+
+	if err := args.IsValid(); err != nil {
+		err = fmt.Errorf("invalid arguments: %s", err)
+		recordSpanError(span, err)
+		return nil, err
+	}
+
+	start := time.Now()
+	response, err := r.rerank(ctx, logger, args)
+	if err != nil {
+		observeRequest(r.metrics, metrics.OperationRerank, r.defaults.system, r.opts.Model, start, nil, err)
+		recordSpanError(span, err)
+		return nil, err
+	}
+
+	// voyage names the results differently
+	items := response.Results
+	if len(items) == 0 {
+		items = response.Data
+	}
+	results := make([]*RerankResult, 0, len(items))
+	for _, item := range items {
+		if item.Index < 0 || item.Index >= len(args.Documents) {
+			err = fmt.Errorf("the provider returned an unknown document index: %d", item.Index)
+			observeRequest(r.metrics, metrics.OperationRerank, r.defaults.system, r.opts.Model, start, nil, err)
+			recordSpanError(span, err)
+			return nil, err
+		}
+		results = append(results, &RerankResult{
+			Index:    item.Index,
+			Document: args.Documents[item.Index],
+			Score:    item.RelevanceScore,
+		})
+	}
+
+	// track token usage
+	usage := &ltypes.RerankUsage{}
+	if response.Usage != nil {
+		usage = response.Usage
+	}
+	usageRecord := tokens.NewUsageRecordFromRerankUsage(r.opts.Model, usage)
+	r.usageRecords = append(r.usageRecords, usageRecord)
+	span.SetAttributes(usageAttributes(usageRecord)...)
+	observeRequest(r.metrics, metrics.OperationRerank, r.defaults.system, r.opts.Model, start, usageRecord, nil)
+
+	return &RerankResponse{
+		Results: sortRerankResults(results, args.TopN),
+		Usage:   usageRecord,
+	}, nil
+}
+
+func (r *HTTPReranker) GetUsageRecords() []*tokens.UsageRecord {
+	return r.usageRecords
+}
+
+func (r *HTTPReranker) rerank(
+	ctx context.Context,
+	logger *slog.Logger,
+	args *RerankArgs,
+) (*ltypes.RerankResponse, error) {
+	apiKey := r.opts.ApiKey
+	if apiKey == "" {
+		apiKey = os.Getenv(r.defaults.envKey)
+		if apiKey == "" || apiKey == "null" {
+			return nil, fmt.Errorf("the environment variable `%s` is required", r.defaults.envKey)
+		}
+	}
+
+	// create the body
+	comprequest := ltypes.RerankRequest{
+		Model:     r.opts.Model,
+		Query:     args.Query,
+		Documents: args.Documents,
+	}
+	switch r.opts.Provider {
+	case ltypes.RERANK_PROVIDER_VOYAGE:
+		comprequest.TopK = args.TopN
+	case ltypes.RERANK_PROVIDER_JINA:
+		returnDocuments := false
+		comprequest.ReturnDocuments = &returnDocuments
+		comprequest.TopN = args.TopN
+	default:
+		comprequest.TopN = args.TopN
+	}
+
+	enc, err := json.Marshal(&comprequest)
+	if err != nil {
+		return nil, fmt.Errorf("there was an issue encoding the body into json: %v", err)
+	}
+
+	logger.DebugContext(ctx, "Request body", "body", r.redactor.Body(enc, apiKey))
+
+	// send the request
+	client := r.opts.HttpClient
+
+	retries := 3
+	backoff := 1 * time.Second
+
+	for attempt := 0; attempt < retries; attempt++ {
+		logger.InfoContext(ctx, "Sending rerank request...", "documents", len(args.Documents))
+		statusCode, body, err := sendAttempt(ctx, r.tracer, client, r.defaults.system, r.opts.Model, attempt, func(ctx context.Context) (*http.Request, error) {
+			req, err := http.NewRequestWithContext(ctx, "POST", r.opts.BaseUrl, bytes.NewBuffer(enc))
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+apiKey)
+			return req, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		logger.InfoContext(ctx, "Completed request", "statusCode", statusCode)
+
+		// parse into the rerank response object
+		var response ltypes.RerankResponse
+		if statusCode == 200 {
+			if err = json.Unmarshal(body, &response); err != nil {
+				return nil, fmt.Errorf("there was an issue unmarshalling the request body: %v", err)
+			}
+			return &response, nil
+		}
+
+		// the providers only return an error message, so act based on the status code
+		json.Unmarshal(body, &response)
+		message := response.Message
+		if message == "" {
+			message = response.Detail
+		}
+		if message == "" {
+			message = string(body)
+		}
+		switch {
+		case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+			return nil, fmt.Errorf("the user is not authenticated: %s", message)
+		case statusCode == http.StatusBadRequest || statusCode == http.StatusUnprocessableEntity:
+			return nil, fmt.Errorf("there was a validation error: %s", message)
+		case statusCode == http.StatusTooManyRequests:
+			logger.WarnContext(ctx, "Rate limit hit, waiting 2 seconds then trying again ...")
+			if err := sleepContext(ctx, time.Second*2); err != nil {
+				return nil, err
+			}
+		case statusCode >= 500:
+			logger.WarnContext(ctx, "There was a server error, waiting 2 seconds then trying again ...")
+			if err := sleepContext(ctx, time.Second*2); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("there was an unknown error: [%d]: %s", statusCode, message)
+		}
+
+		recordRetryableError(ctx, r.metrics, metrics.OperationRerank, r.defaults.system, r.opts.Model, attempt, http.StatusText(statusCode))
+
+		if attempt < retries-1 {
+			sleep := backoff + time.Duration(rand.Intn(1000))*time.Millisecond // Add jitter
+			if err := sleepContext(ctx, sleep); err != nil {
+				return nil, err
+			}
+			backoff *= 2 // Double the backoff interval
+		} else {
+			return nil, fmt.Errorf("there was an issue with the request and could not recover: %s", r.redactor.Body(body, apiKey))
+		}
+	}
+
+	return nil, err
+}
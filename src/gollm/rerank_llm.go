@@ -0,0 +1,152 @@
+package gollm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/jake-landersweb/gollm/v2/src/tokens"
+)
+
+const llm_rerank_instructions = "You judge how relevant passages are to a search query. Score every passage from 0 to 10, where 0 is unrelated to the query and 10 fully answers it. Judge every passage on its own, and score every passage exactly once."
+
+const llm_rerank_schema = `{"scores": [{"index": int, "score": number}]}`
+
+// Reranks documents by asking a language model to score them, for when no cross-encoder service is available
+type LLMReranker struct {
+	llm  *LanguageModel
+	opts *LLMRerankerOpts
+
+	usageRecords []*tokens.UsageRecord
+}
+
+// Optional configurations of the reranker. `Model` is required.
+type LLMRerankerOpts struct {
+	// The model that scores the documents, such as `gpt-4o-mini`
+	Model       string
+	Temperature float64
+
+	// The number of documents scored in every completion. Defaults to 10
+	BatchSize int
+
+	// Optionally replace the instructions of the system message. The instructions must ask for scores from 0 to 10
+	Instructions string
+}
+
+func NewLLMReranker(llm *LanguageModel, opts *LLMRerankerOpts) (*LLMReranker, error) {
+	if llm == nil {
+		return nil, fmt.Errorf("the language model cannot be nil")
+	}
+	if opts == nil || opts.Model == "" {
+		return nil, fmt.Errorf("`Model` cannot be empty")
+	}
+	resp := *opts
+	if resp.BatchSize <= 0 {
+		resp.BatchSize = llm_rerank_batch_size
+	}
+	if resp.Instructions == "" {
+		resp.Instructions = llm_rerank_instructions
+	}
+	return &LLMReranker{llm: llm, opts: &resp}, nil
+}
+
+type llmRerankScores struct {
+	Scores []struct {
+		Index int     `json:"index"`
+		Score float64 `json:"score"`
+	} `json:"scores"`
+}
+
+/*
+Scores the documents in batches with structured output. The scores are scaled from 0 to 1, and
+documents that the model did not score get a score of 0.
+*/
+func (r *LLMReranker) Rerank(
+	ctx context.Context,
+	logger *slog.Logger,
+	args *RerankArgs,
+) (*RerankResponse, error) {
+	if logger == nil {
+		logger = discardLogger()
+	}
+	if err := args.IsValid(); err != nil {
+		return nil, fmt.Errorf("invalid arguments: %s", err)
+	}
+
+	results := make([]*RerankResult, len(args.Documents))
+	for idx, item := range args.Documents {
+		results[idx] = &RerankResult{Index: idx, Document: item}
+	}
+
+	records := make([]*tokens.UsageRecord, 0)
+	for batch := 0; batch < len(args.Documents); batch += r.opts.BatchSize {
+		end := min(batch+r.opts.BatchSize, len(args.Documents))
+		scores, record, err := r.score(ctx, args.Query, args.Documents[batch:end])
+		if err != nil {
+			// the batches that were scored are still billed
+			if len(records) != 0 {
+				r.usageRecords = append(r.usageRecords, tokens.MergeUsageRecords(records...))
+			}
+			return nil, err
+		}
+		records = append(records, record)
+
+		scored := make(map[int]bool)
+		for _, item := range scores.Scores {
+			if item.Index < 0 || item.Index >= end-batch {
+				logger.WarnContext(ctx, "The model scored an unknown passage", "index", item.Index)
+				continue
+			}
+			results[batch+item.Index].Score = min(max(item.Score, 0), 10) / 10
+			scored[item.Index] = true
+		}
+		if len(scored) != end-batch {
+			logger.WarnContext(ctx, "The model did not score every passage", "expected", end-batch, "scored", len(scored))
+		}
+	}
+
+	usageRecord := tokens.MergeUsageRecords(records...)
+	r.usageRecords = append(r.usageRecords, usageRecord)
+
+	return &RerankResponse{
+		Results: sortRerankResults(results, args.TopN),
+		Usage:   usageRecord,
+	}, nil
+}
+
+func (r *LLMReranker) GetUsageRecords() []*tokens.UsageRecord {
+	return r.usageRecords
+}
+
+// Asks the model to score a batch of documents, which are numbered from 0
+func (r *LLMReranker) score(ctx context.Context, query string, documents []string) (*llmRerankScores, *tokens.UsageRecord, error) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Query: %s\n\nPassages:", query)
+	for idx, item := range documents {
+		fmt.Fprintf(&sb, "\n\n[%d] %s", idx, item)
+	}
+
+	response, err := r.llm.Completion(ctx, &CompletionInput{
+		Model:        r.opts.Model,
+		Temperature:  r.opts.Temperature,
+		Json:         true,
+		JsonSchema:   llm_rerank_schema,
+		Conversation: []*Message{NewSystemMessage(r.opts.Instructions), NewUserMessage(sb.String())},
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("there was an issue scoring the documents: %v", err)
+	}
+
+	// some models wrap json in a code block even when asked not to
+	content := strings.TrimSpace(response.Message.Message)
+	content = strings.TrimPrefix(content, "```json")
+	content = strings.Trim(content, "`\n ")
+
+	var scores llmRerankScores
+	if err := json.Unmarshal([]byte(content), &scores); err != nil {
+		return nil, nil, fmt.Errorf("there was an issue parsing the scores of the model: %v", err)
+	}
+	return &scores, response.UsageRecord, nil
+}
@@ -0,0 +1,168 @@
+package gollm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/jake-landersweb/gollm/v2/src/ltypes"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPReranker(t *testing.T) {
+	tests := []struct {
+		provider ltypes.RerankProvider
+		model    string
+		response string
+		tokens   int
+	}{
+		{ltypes.RERANK_PROVIDER_COHERE, "rerank-v3.5", `{"id": "1", "results": [{"index": 2, "relevance_score": 0.9}, {"index": 0, "relevance_score": 0.2}], "meta": {"billed_units": {"search_units": 1}}}`, 0},
+		{ltypes.RERANK_PROVIDER_JINA, "jina-reranker-v2-base-multilingual", `{"model": "jina-reranker-v2-base-multilingual", "usage": {"total_tokens": 42}, "results": [{"index": 2, "relevance_score": 0.9}, {"index": 0, "relevance_score": 0.2}]}`, 42},
+		{ltypes.RERANK_PROVIDER_VOYAGE, "rerank-2", `{"object": "list", "data": [{"index": 0, "relevance_score": 0.2}, {"index": 2, "relevance_score": 0.9}], "usage": {"total_tokens": 42}}`, 42},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.provider), func(t *testing.T) {
+			server, requests := newTestServer(t, respondWith(200, test.response))
+			reranker, err := NewHTTPReranker(&HTTPRerankerOpts{Provider: test.provider, BaseUrl: server.URL, ApiKey: "secret"})
+			require.NoError(t, err)
+
+			response, err := reranker.Rerank(context.TODO(), nil, &RerankArgs{
+				Query:     "cats",
+				Documents: []string{"dogs bark", "birds sing", "cats purr"},
+				TopN:      2,
+			})
+			require.NoError(t, err)
+			require.Len(t, response.Results, 2)
+			require.Equal(t, 2, response.Results[0].Index)
+			require.Equal(t, "cats purr", response.Results[0].Document)
+			require.Equal(t, 0.9, response.Results[0].Score)
+			require.Equal(t, 0, response.Results[1].Index)
+			require.Equal(t, test.tokens, response.Usage.TotalTokens)
+			require.Equal(t, test.model, response.Usage.Model)
+			require.Len(t, reranker.GetUsageRecords(), 1)
+
+			require.Equal(t, "Bearer secret", requests.header(t).Get("Authorization"))
+			require.Equal(t, test.model, requests.body(t)["model"])
+			require.Equal(t, "cats", requests.body(t)["query"])
+			if test.provider == ltypes.RERANK_PROVIDER_VOYAGE {
+				require.Equal(t, 2.0, requests.body(t)["top_k"])
+				require.NotContains(t, requests.body(t), "top_n")
+			} else {
+				require.Equal(t, 2.0, requests.body(t)["top_n"])
+			}
+			if test.provider == ltypes.RERANK_PROVIDER_JINA {
+				require.Equal(t, false, requests.body(t)["return_documents"])
+			}
+		})
+	}
+}
+
+func TestHTTPRerankerErrors(t *testing.T) {
+	_, err := NewHTTPReranker(&HTTPRerankerOpts{Provider: "mixedbread"})
+	require.ErrorContains(t, err, "unsupported rerank provider")
+
+	server, _ := newTestServer(t, respondWith(400, `{"message": "invalid request: documents must not be empty"}`))
+	reranker, err := NewHTTPReranker(&HTTPRerankerOpts{BaseUrl: server.URL, ApiKey: "secret"})
+	require.NoError(t, err)
+	_, err = reranker.Rerank(context.TODO(), nil, &RerankArgs{Query: "cats", Documents: []string{"cats purr"}})
+	require.ErrorContains(t, err, "documents must not be empty")
+
+	_, err = reranker.Rerank(context.TODO(), nil, &RerankArgs{Query: "cats"})
+	require.ErrorContains(t, err, "the documents cannot be empty")
+
+	server, _ = newTestServer(t, respondWith(200, `{"results": [{"index": 5, "relevance_score": 0.9}]}`))
+	reranker, err = NewHTTPReranker(&HTTPRerankerOpts{BaseUrl: server.URL, ApiKey: "secret"})
+	require.NoError(t, err)
+	_, err = reranker.Rerank(context.TODO(), nil, &RerankArgs{Query: "cats", Documents: []string{"cats purr"}})
+	require.ErrorContains(t, err, "unknown document index")
+}
+
+func TestLLMReranker(t *testing.T) {
+	passage := regexp.MustCompile(`\[(\d+)\] ([^\n]*)`)
+	requests := &testRequests{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request ltypes.GPTCompletionRequest
+		if err := json.Unmarshal(requests.record(r).Body, &request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// score passages about cats highly, and skip the passages about fish
+		scores := make([]string, 0)
+		for _, match := range passage.FindAllStringSubmatch(request.Messages[len(request.Messages)-1].Content, -1) {
+			switch {
+			case strings.Contains(match[2], "cat"):
+				scores = append(scores, fmt.Sprintf(`{"index": %s, "score": 9}`, match[1]))
+			case !strings.Contains(match[2], "fish"):
+				scores = append(scores, fmt.Sprintf(`{"index": %s, "score": 2}`, match[1]))
+			}
+		}
+		content, _ := json.Marshal(fmt.Sprintf("```json\n{\"scores\": [%s]}\n```", strings.Join(scores, ", ")))
+		fmt.Fprintf(w, `{"id": "chatcmpl-1", "object": "chat.completion", "model": "gpt-4o-mini", "choices": [{"index": 0, "message": {"role": "assistant", "content": %s}, "finish_reason": "stop"}], "usage": {"prompt_tokens": 10, "completion_tokens": 2, "total_tokens": 12}}`, content)
+	}))
+	defer server.Close()
+
+	llm := NewLanguageModel(test_user_id, nil, &NewLanguageModelArgs{GptBaseUrl: server.URL, OpenAIApiKey: "test"})
+	reranker, err := NewLLMReranker(llm, &LLMRerankerOpts{Model: "gpt-4o-mini", BatchSize: 2})
+	require.NoError(t, err)
+
+	response, err := reranker.Rerank(context.TODO(), nil, &RerankArgs{
+		Query:     "cats",
+		Documents: []string{"dogs bark", "fish swim", "cats purr", "birds sing", "a cat naps"},
+	})
+	require.NoError(t, err)
+	require.Len(t, requests.all(), 3)
+	for _, item := range requests.all() {
+		require.Equal(t, map[string]any{"type": "json_object"}, item.json(t)["response_format"])
+	}
+
+	order := make([]int, len(response.Results))
+	for idx, item := range response.Results {
+		order[idx] = item.Index
+	}
+	require.Equal(t, []int{2, 4, 0, 3, 1}, order)
+	require.InDelta(t, 0.9, response.Results[0].Score, 1e-9)
+	require.Equal(t, 0.0, response.Results[4].Score)
+	require.Equal(t, 36, response.Usage.TotalTokens)
+
+	_, err = NewLLMReranker(llm, nil)
+	require.ErrorContains(t, err, "`Model` cannot be empty")
+}
+
+func TestLLMRerankerPartialFailure(t *testing.T) {
+	requests := &testRequests{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.record(r)
+
+		// the second batch fails
+		if len(requests.all()) > 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": {"message": "invalid request", "type": "invalid_request_error"}}`))
+			return
+		}
+		w.Write([]byte(`{"id": "chatcmpl-1", "object": "chat.completion", "model": "gpt-4o-mini", "choices": [{"index": 0, "message": {"role": "assistant", "content": "{\"scores\": [{\"index\": 0, \"score\": 5}, {\"index\": 1, \"score\": 5}]}"}, "finish_reason": "stop"}], "usage": {"prompt_tokens": 10, "completion_tokens": 2, "total_tokens": 12}}`))
+	}))
+	defer server.Close()
+
+	llm := NewLanguageModel(test_user_id, nil, &NewLanguageModelArgs{GptBaseUrl: server.URL, OpenAIApiKey: "test"})
+	reranker, err := NewLLMReranker(llm, &LLMRerankerOpts{Model: "gpt-4o-mini", BatchSize: 2})
+	require.NoError(t, err)
+
+	_, err = reranker.Rerank(context.TODO(), nil, &RerankArgs{
+		Query:     "cats",
+		Documents: []string{"dogs bark", "fish swim", "cats purr", "birds sing"},
+	})
+	require.ErrorContains(t, err, "invalid request")
+	require.Len(t, requests.all(), 2)
+
+	// the usage of the batch that was scored is still recorded
+	records := reranker.GetUsageRecords()
+	require.Len(t, records, 1)
+	require.Equal(t, 12, records[0].TotalTokens)
+}
@@ -0,0 +1,286 @@
+package gollm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jake-landersweb/gollm/v2/src/tokens"
+	"github.com/jake-landersweb/gollm/v2/src/vecmath"
+	"github.com/jake-landersweb/gollm/v2/src/vectorstore"
+)
+
+const (
+	default_semantic_threshold = 0.95
+	semantic_cache_candidates  = 4
+
+	semantic_metadata_scope    = "scope"
+	semantic_metadata_created  = "created_at"
+	semantic_metadata_response = "response"
+)
+
+/*
+Reuses the responses of earlier questions that mean the same as the last user message, such as the
+near duplicate questions of a support bot. The question is embedded with any `Embeddings`, and the
+closest earlier question in the store is used when its cosine similarity reaches the threshold.
+
+Entries are scoped by the model, the system prompt, the temperature and the json and tool options,
+so a response is only reused for the same kind of request. Only the last user message is compared,
+so only single turn questions use the cache: inputs with earlier user, assistant or tool messages,
+or with tools, always reach the provider. Only text responses are stored.
+
+Embedding the question is recorded in the usage records of the language model.
+*/
+type SemanticCache struct {
+	embeddings Embeddings
+	store      vectorstore.VectorStore
+	opts       *SemanticCacheOpts
+}
+
+type SemanticCacheOpts struct {
+	// The lowest cosine similarity between questions for a cached response to be used. Defaults to 0.95
+	Threshold float64
+
+	// How long responses are reused. Older entries are deleted when they are found. Defaults to 0,
+	// which reuses them until they are forgotten
+	TTL time.Duration
+
+	// Optionally separate the entries of applications that share a store. Changing it invalidates
+	// every entry
+	Namespace string
+}
+
+func NewSemanticCache(embeddings Embeddings, store vectorstore.VectorStore, opts *SemanticCacheOpts) (*SemanticCache, error) {
+	if embeddings == nil {
+		return nil, fmt.Errorf("the embeddings cannot be nil")
+	}
+	if store == nil {
+		return nil, fmt.Errorf("the store cannot be nil")
+	}
+	resp := SemanticCacheOpts{}
+	if opts != nil {
+		resp = *opts
+	}
+	if resp.Threshold == 0 {
+		resp.Threshold = default_semantic_threshold
+	}
+	if resp.Threshold < -1 || resp.Threshold > 1 {
+		return nil, fmt.Errorf("the threshold must be between -1 and 1")
+	}
+	return &SemanticCache{embeddings: embeddings, store: store, opts: &resp}, nil
+}
+
+/*
+Returns the question of the input, or an empty string when the input cannot use the cache. Only
+single turn questions without tools can, since the rest of the conversation and the tools change
+the answer without changing the question.
+*/
+func semanticQuestion(input *CompletionInput) string {
+	if len(input.Tools) != 0 || input.RequiredTool != nil {
+		return ""
+	}
+	last := input.Conversation[len(input.Conversation)-1]
+	if last.Role != RoleUser {
+		return ""
+	}
+	for _, item := range input.Conversation[:len(input.Conversation)-1] {
+		if item.Role != RoleSystem {
+			return ""
+		}
+	}
+	return strings.TrimSpace(last.Message)
+}
+
+// Hashes the options and system prompt of the input, which entries must share to be reused
+func (c *SemanticCache) scope(input *CompletionInput) string {
+	h := sha256.New()
+	for _, item := range []string{
+		c.opts.Namespace,
+		input.Model,
+		strconv.FormatFloat(input.Temperature, 'g', -1, 64),
+		strconv.FormatBool(input.Json),
+		input.JsonSchema,
+		strconv.FormatBool(input.ProhibitTool),
+	} {
+		fmt.Fprintf(h, "%d:%s", len(item), item)
+	}
+	for _, item := range input.Conversation {
+		if item.Role == RoleSystem {
+			fmt.Fprintf(h, "%d:%s", len(item.Message), item.Message)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Returns the vector of the question along with the usage of embedding it
+func (c *SemanticCache) embed(ctx context.Context, logger *slog.Logger, question string) ([]float32, *tokens.UsageRecord, error) {
+	response, err := c.embeddings.Embed(ctx, logger, &EmbedArgs{InputChunks: []string{question}})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to embed the question: %v", err)
+	}
+	if len(response.Embeddings) == 0 {
+		return nil, response.Usage, fmt.Errorf("failed to embed the question: no embeddings were returned")
+	}
+	return response.Embeddings[0].Embedding, response.Usage, nil
+}
+
+// A stored question that is similar to the question of a request
+type semanticMatch struct {
+	id         string
+	similarity float64
+	response   *cachedCompletion
+}
+
+// Returns the stored entries in the scope that reach the threshold, closest first, and deletes the expired ones
+func (c *SemanticCache) matches(ctx context.Context, logger *slog.Logger, scope string, vector []float32) ([]*semanticMatch, error) {
+	results, err := c.store.Search(ctx, &vectorstore.Query{
+		Embedding: vector,
+		K:         semantic_cache_candidates,
+		Filter:    map[string]any{semantic_metadata_scope: scope},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search the store: %v", err)
+	}
+
+	matches := make([]*semanticMatch, 0)
+	expired := make([]string, 0)
+	for _, item := range results {
+		if c.opts.TTL > 0 {
+			created, _ := item.Document.Metadata[semantic_metadata_created].(string)
+			at, err := time.Parse(time.RFC3339Nano, created)
+			if err != nil || time.Since(at) > c.opts.TTL {
+				expired = append(expired, item.Document.ID)
+				continue
+			}
+		}
+		similarity, err := vecmath.Cosine(vector, item.Document.Embedding)
+		if err != nil || similarity < c.opts.Threshold {
+			continue
+		}
+		var response cachedCompletion
+		encoded, _ := item.Document.Metadata[semantic_metadata_response].(string)
+		if err := json.Unmarshal([]byte(encoded), &response); err != nil || response.Message == nil {
+			logger.WarnContext(ctx, "Ignoring an invalid semantic cache entry", "id", item.Document.ID)
+			continue
+		}
+		matches = append(matches, &semanticMatch{id: item.Document.ID, similarity: similarity, response: &response})
+	}
+
+	if len(expired) != 0 {
+		if err := c.store.Delete(ctx, expired...); err != nil {
+			logger.WarnContext(ctx, "There was an issue deleting the expired semantic cache entries", "error", err)
+		}
+	}
+
+	// the store orders by its own metric, so order by similarity
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].similarity > matches[j].similarity
+	})
+	return matches, nil
+}
+
+/*
+Returns the cached response for the question of the input, or nil when there is none. The vector
+of the question is returned either way, so the response of the provider can be stored without
+embedding the question again, along with the usage of embedding it.
+*/
+func (c *SemanticCache) lookup(ctx context.Context, logger *slog.Logger, input *CompletionInput) (*CompletionResponse, []float32, *tokens.UsageRecord, error) {
+	question := semanticQuestion(input)
+	if question == "" {
+		return nil, nil, nil, nil
+	}
+	vector, usage, err := c.embed(ctx, logger, question)
+	if err != nil {
+		return nil, nil, usage, err
+	}
+	if input.RefreshCache {
+		return nil, vector, usage, nil
+	}
+	matches, err := c.matches(ctx, logger, c.scope(input), vector)
+	if err != nil || len(matches) == 0 {
+		return nil, vector, usage, err
+	}
+
+	match := matches[0]
+	return &CompletionResponse{
+		Model:       match.response.Model,
+		StopReason:  match.response.StopReason,
+		Message:     match.response.Message,
+		UsageRecord: tokens.NewUsageRecord(input.Model, 0, 0, 0),
+		Cached:      true,
+		Similarity:  match.similarity,
+	}, vector, usage, nil
+}
+
+// Stores the response to the question of the input. Refreshed inputs replace the entries they match
+func (c *SemanticCache) add(ctx context.Context, logger *slog.Logger, input *CompletionInput, vector []float32, response *CompletionResponse) error {
+	question := semanticQuestion(input)
+	if question == "" || vector == nil || response.Message.Role != RoleAI || response.Message.ToolUseID != "" {
+		return nil
+	}
+	encoded, err := json.Marshal(&cachedCompletion{
+		Model:      response.Model,
+		StopReason: response.StopReason,
+		Message:    response.Message,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode the response: %v", err)
+	}
+
+	scope := c.scope(input)
+	if input.RefreshCache {
+		if err := c.forget(ctx, logger, scope, vector); err != nil {
+			return err
+		}
+	}
+	id, _ := uuid.NewV7()
+	return c.store.Upsert(ctx, &vectorstore.Document{
+		ID:        id.String(),
+		Content:   question,
+		Embedding: vector,
+		Metadata: map[string]any{
+			semantic_metadata_scope:    scope,
+			semantic_metadata_created:  time.Now().UTC().Format(time.RFC3339Nano),
+			semantic_metadata_response: string(encoded),
+		},
+	})
+}
+
+func (c *SemanticCache) forget(ctx context.Context, logger *slog.Logger, scope string, vector []float32) error {
+	matches, err := c.matches(ctx, logger, scope, vector)
+	if err != nil || len(matches) == 0 {
+		return err
+	}
+	ids := make([]string, len(matches))
+	for idx, item := range matches {
+		ids[idx] = item.id
+	}
+	return c.store.Delete(ctx, ids...)
+}
+
+// Deletes the cached responses that would be reused for the input, such as after a wrong answer
+func (c *SemanticCache) Forget(ctx context.Context, logger *slog.Logger, input *CompletionInput) error {
+	if logger == nil {
+		logger = discardLogger()
+	}
+	if input == nil || len(input.Conversation) == 0 {
+		return fmt.Errorf("the conversation cannot be empty")
+	}
+	question := semanticQuestion(input)
+	if question == "" {
+		return fmt.Errorf("the input must be a single user question without tools")
+	}
+	vector, _, err := c.embed(ctx, logger, question)
+	if err != nil {
+		return err
+	}
+	return c.forget(ctx, logger, c.scope(input), vector)
+}
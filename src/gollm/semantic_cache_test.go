@@ -0,0 +1,206 @@
+package gollm
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jake-landersweb/gollm/v2/src/ltypes"
+	"github.com/jake-landersweb/gollm/v2/src/tokens"
+	"github.com/jake-landersweb/gollm/v2/src/vectorstore"
+	"github.com/stretchr/testify/require"
+)
+
+// Embeds every text to a fixed vector
+type vectorEmbeddings map[string][]float32
+
+func (e vectorEmbeddings) Embed(ctx context.Context, logger *slog.Logger, args *EmbedArgs) (*EmbedResponse, error) {
+	list := make([]*ltypes.EmbeddingsData, len(args.InputChunks))
+	for idx, item := range args.InputChunks {
+		vector, ok := e[item]
+		if !ok {
+			return nil, fmt.Errorf("no vector for %q", item)
+		}
+		list[idx] = &ltypes.EmbeddingsData{Raw: item, Index: idx, Embedding: vector}
+	}
+	return &EmbedResponse{Embeddings: list, Usage: tokens.NewUsageRecord("fake", 1, 0, 1)}, nil
+}
+
+func (e vectorEmbeddings) GetUsageRecords() []*tokens.UsageRecord {
+	return nil
+}
+
+func TestSemanticCache(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write([]byte(compatible_test_response))
+	}))
+	defer server.Close()
+
+	embeddings := vectorEmbeddings{
+		"How do I reset my password?":    {1, 0, 0},
+		"how can i reset my password":    {0.99, 0.1, 0},
+		"Can I get a refund on my plan?": {0.9, 0, 0.44},
+	}
+	store, err := vectorstore.NewMemoryStore("")
+	require.NoError(t, err)
+	semantic, err := NewSemanticCache(embeddings, store, nil)
+	require.NoError(t, err)
+	llm := NewLanguageModel(test_user_id, nil, &NewLanguageModelArgs{
+		GptBaseUrl:    server.URL,
+		OpenAIApiKey:  "test",
+		SemanticCache: semantic,
+	})
+
+	ctx := context.TODO()
+	ask := func(system string, question string) *CompletionInput {
+		return &CompletionInput{
+			Model:        "gpt-4o-mini",
+			Conversation: []*Message{NewSystemMessage(system), NewUserMessage(question)},
+		}
+	}
+
+	response, err := llm.Completion(ctx, ask("You are a support bot.", "How do I reset my password?"))
+	require.NoError(t, err)
+	require.False(t, response.Cached)
+	require.Equal(t, 1, store.Len())
+
+	// a similar question reuses the response
+	response, err = llm.Completion(ctx, ask("You are a support bot.", "how can i reset my password"))
+	require.NoError(t, err)
+	require.True(t, response.Cached)
+	require.Equal(t, "Hello!", response.Message.Message)
+	require.InDelta(t, 0.995, response.Similarity, 0.001)
+	require.Equal(t, 0, response.UsageRecord.TotalTokens)
+	require.EqualValues(t, 1, requests.Load())
+
+	// questions under the threshold, other system prompts and skipped inputs reach the provider
+	response, err = llm.Completion(ctx, ask("You are a support bot.", "Can I get a refund on my plan?"))
+	require.NoError(t, err)
+	require.False(t, response.Cached)
+	response, err = llm.Completion(ctx, ask("You are a sales bot.", "How do I reset my password?"))
+	require.NoError(t, err)
+	require.False(t, response.Cached)
+	skipped := ask("You are a support bot.", "How do I reset my password?")
+	skipped.SkipCache = true
+	response, err = llm.Completion(ctx, skipped)
+	require.NoError(t, err)
+	require.False(t, response.Cached)
+	require.EqualValues(t, 4, requests.Load())
+	require.Equal(t, 3, store.Len())
+
+	// refreshed inputs replace the entries they match
+	refreshed := ask("You are a support bot.", "how can i reset my password")
+	refreshed.RefreshCache = true
+	response, err = llm.Completion(ctx, refreshed)
+	require.NoError(t, err)
+	require.False(t, response.Cached)
+	require.Equal(t, 3, store.Len())
+
+	// forgotten entries are no longer reused
+	require.NoError(t, semantic.Forget(ctx, nil, ask("You are a support bot.", "How do I reset my password?")))
+	require.Equal(t, 2, store.Len())
+	response, err = llm.Completion(ctx, ask("You are a support bot.", "How do I reset my password?"))
+	require.NoError(t, err)
+	require.False(t, response.Cached)
+
+	// expired entries are deleted when they are found
+	semantic, err = NewSemanticCache(embeddings, store, &SemanticCacheOpts{TTL: time.Nanosecond})
+	require.NoError(t, err)
+	llm.args.SemanticCache = semantic
+	response, err = llm.Completion(ctx, ask("You are a sales bot.", "How do I reset my password?"))
+	require.NoError(t, err)
+	require.False(t, response.Cached)
+	require.Equal(t, 3, store.Len())
+
+	_, err = NewSemanticCache(embeddings, store, &SemanticCacheOpts{Threshold: 2})
+	require.ErrorContains(t, err, "between -1 and 1")
+}
+
+func TestSemanticCacheScope(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write([]byte(compatible_test_response))
+	}))
+	defer server.Close()
+
+	embeddings := vectorEmbeddings{"How do I reset my password?": {1, 0, 0}}
+	store, err := vectorstore.NewMemoryStore("")
+	require.NoError(t, err)
+	semantic, err := NewSemanticCache(embeddings, store, nil)
+	require.NoError(t, err)
+	llm := NewLanguageModel(test_user_id, nil, &NewLanguageModelArgs{
+		GptBaseUrl:    server.URL,
+		OpenAIApiKey:  "test",
+		SemanticCache: semantic,
+	})
+
+	ctx := context.TODO()
+	ask := func() *CompletionInput {
+		return &CompletionInput{
+			Model:        "gpt-4o-mini",
+			Conversation: []*Message{NewSystemMessage("You are a support bot."), NewUserMessage("How do I reset my password?")},
+		}
+	}
+	_, err = llm.Completion(ctx, ask())
+	require.NoError(t, err)
+	require.Equal(t, 1, store.Len())
+
+	// the usage of embedding the question is recorded along with the completion
+	records := llm.GetUsageRecords()
+	require.Len(t, records, 2)
+	require.Equal(t, "fake", records[0].Model)
+	require.Equal(t, 1, records[0].InputTokens)
+
+	// conversations with history and inputs with tools bypass the cache
+	history := ask()
+	history.Conversation = []*Message{
+		NewUserMessage("I use the mobile app."),
+		NewAssistantMessage("Got it."),
+		NewUserMessage("How do I reset my password?"),
+	}
+	tools := ask()
+	tools.Tools = []*Tool{{Title: "reset_password", Description: "Resets the password", Schema: &ltypes.ToolSchema{Type: "object"}}}
+	required := ask()
+	required.RequiredTool = tools.Tools[0]
+	for _, input := range []*CompletionInput{history, tools, required} {
+		response, err := llm.Completion(ctx, input)
+		require.NoError(t, err)
+		require.False(t, response.Cached)
+	}
+	require.EqualValues(t, 4, requests.Load())
+	require.Equal(t, 1, store.Len())
+	require.Len(t, llm.GetUsageRecords(), 5)
+	require.ErrorContains(t, semantic.Forget(ctx, nil, history), "single user question")
+
+	// the temperature and the json and tool options are part of the scope
+	temperature := ask()
+	temperature.Temperature = 0.7
+	json := ask()
+	json.Json = true
+	json.JsonSchema = `{"answer": string}`
+	prohibited := ask()
+	prohibited.ProhibitTool = true
+	for _, input := range []*CompletionInput{temperature, json, prohibited} {
+		response, err := llm.Completion(ctx, input)
+		require.NoError(t, err)
+		require.False(t, response.Cached)
+	}
+	require.EqualValues(t, 7, requests.Load())
+	require.Equal(t, 4, store.Len())
+
+	// the same options reuse the response, and still record the embedding usage
+	response, err := llm.Completion(ctx, ask())
+	require.NoError(t, err)
+	require.True(t, response.Cached)
+	records = llm.GetUsageRecords()
+	require.Equal(t, "fake", records[len(records)-2].Model)
+	require.Equal(t, 0, records[len(records)-1].TotalTokens)
+}
@@ -0,0 +1,144 @@
+package gollm
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const sigv4_algorithm = "AWS4-HMAC-SHA256"
+const sigv4_time_format = "20060102T150405Z"
+
+// Credentials used to sign requests to AWS
+type awsCredentials struct {
+	accessKeyId     string
+	secretAccessKey string
+	sessionToken    string
+}
+
+/*
+Signs the request with AWS Signature Version 4, following
+https://docs.aws.amazon.com/IAM/latest/UserGuide/create-signed-request.html.
+Only the `host`, `content-type` and `x-amz-*` headers are signed, so proxies can add
+their own headers without breaking the signature.
+*/
+func signV4(req *http.Request, body []byte, creds *awsCredentials, region string, service string, now time.Time) {
+	amzDate := now.UTC().Format(sigv4_time_format)
+	date := amzDate[:8]
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.sessionToken)
+	}
+
+	// canonical headers
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for key, values := range req.Header {
+		name := strings.ToLower(key)
+		if name == "content-type" || strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.Join(values, ",")
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.Join(strings.Fields(headers[name]), " ") + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		sigv4CanonicalURI(req.URL),
+		sigv4CanonicalQuery(req.URL),
+		canonicalHeaders.String(),
+		signedHeaders,
+		sha256Hex(body),
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/%s/aws4_request", date, region, service)
+	stringToSign := strings.Join([]string{
+		sigv4_algorithm,
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+creds.secretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s", sigv4_algorithm, creds.accessKeyId, scope, signedHeaders, signature))
+}
+
+// Every service except S3 encodes the already escaped path a second time
+func sigv4CanonicalURI(u *url.URL) string {
+	path := u.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = awsEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+func sigv4CanonicalQuery(u *url.URL) string {
+	query := u.Query()
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0)
+	for _, key := range keys {
+		values := query[key]
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, awsEscape(key)+"="+awsEscape(value))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// Escapes everything except the unreserved characters of RFC 3986, as required by AWS
+func awsEscape(input string) string {
+	var resp strings.Builder
+	for _, b := range []byte(input) {
+		if ('A' <= b && b <= 'Z') || ('a' <= b && b <= 'z') || ('0' <= b && b <= '9') || b == '-' || b == '_' || b == '.' || b == '~' {
+			resp.WriteByte(b)
+		} else {
+			fmt.Fprintf(&resp, "%%%02X", b)
+		}
+	}
+	return resp.String()
+}
+
+func sha256Hex(input []byte) string {
+	hash := sha256.Sum256(input)
+	return hex.EncodeToString(hash[:])
+}
+
+func hmacSHA256(key []byte, input string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(input))
+	return h.Sum(nil)
+}
@@ -0,0 +1,72 @@
+package gollm
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Vectors from the AWS Signature Version 4 test suite
+func TestSignV4(t *testing.T) {
+	creds := &awsCredentials{
+		accessKeyId:     "AKIDEXAMPLE",
+		secretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		method        string
+		url           string
+		contentType   string
+		body          string
+		authorization string
+	}{
+		{
+			name:          "get-vanilla",
+			method:        "GET",
+			url:           "https://example.amazonaws.com/",
+			authorization: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			name:          "get-vanilla-query-order-key-case",
+			method:        "GET",
+			url:           "https://example.amazonaws.com/?Param2=value2&Param1=value1",
+			authorization: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+		},
+		{
+			name:          "post-x-www-form-urlencoded",
+			method:        "POST",
+			url:           "https://example.amazonaws.com/",
+			contentType:   "application/x-www-form-urlencoded",
+			body:          "Param1=value1",
+			authorization: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=content-type;host;x-amz-date, Signature=ff11897932ad3f4e8b18135d722051e5ac45fc38421b1da7b9d196a0fe09473a",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := http.NewRequest(test.method, test.url, strings.NewReader(test.body))
+			require.NoError(t, err)
+			if test.contentType != "" {
+				req.Header.Set("Content-Type", test.contentType)
+			}
+			signV4(req, []byte(test.body), creds, "us-east-1", "service", now)
+			require.Equal(t, "20150830T123600Z", req.Header.Get("X-Amz-Date"))
+			require.Equal(t, test.authorization, req.Header.Get("Authorization"))
+		})
+	}
+}
+
+func TestSignV4SessionToken(t *testing.T) {
+	req, err := http.NewRequest("POST", "https://bedrock-runtime.us-east-1.amazonaws.com/model/anthropic.claude-3-haiku-20240307-v1%3A0/converse", nil)
+	require.NoError(t, err)
+	signV4(req, nil, &awsCredentials{accessKeyId: "AKID", secretAccessKey: "secret", sessionToken: "session"}, "us-east-1", "bedrock", time.Now())
+
+	require.Equal(t, "session", req.Header.Get("X-Amz-Security-Token"))
+	require.Contains(t, req.Header.Get("Authorization"), "SignedHeaders=host;x-amz-date;x-amz-security-token")
+	require.Equal(t, "/model/anthropic.claude-3-haiku-20240307-v1%253A0/converse", sigv4CanonicalURI(req.URL))
+}
@@ -10,6 +10,10 @@ type Tool struct {
 	Title       string             `json:"title"`
 	Description string             `json:"description"`
 	Schema      *ltypes.ToolSchema `json:"schema"`
+
+	// Caches the tools up to and including this one, for the providers that support cache breakpoints.
+	// Tools are sent before the conversation, so this caches the tools alone
+	CacheBreakpoint bool `json:"cache_breakpoint,omitempty"`
 }
 
 func (t *Tool) ToOpenAI() *ltypes.GPTTool {
@@ -33,9 +37,53 @@ func (t *Tool) ToGemini() *ltypes.GemFunctionDeclaration {
 
 func (t *Tool) ToAnthropic() *ltypes.AnthropicTool {
 	return &ltypes.AnthropicTool{
-		Name:        t.Title,
-		Description: t.Description,
-		InputSchema: t.Schema,
+		Name:         t.Title,
+		Description:  t.Description,
+		InputSchema:  t.Schema,
+		CacheControl: anthropicCacheControl(t.CacheBreakpoint),
+	}
+}
+
+func (t *Tool) ToOllama() *ltypes.OllamaTool {
+	return &ltypes.OllamaTool{
+		Type: "function",
+		Function: &ltypes.GPTToolFunction{
+			Name:        t.Title,
+			Description: t.Description,
+			Parameters:  t.Schema,
+		},
+	}
+}
+
+func (t *Tool) ToBedrock() *ltypes.BedrockTool {
+	return &ltypes.BedrockTool{
+		ToolSpec: &ltypes.BedrockToolSpec{
+			Name:        t.Title,
+			Description: t.Description,
+			InputSchema: &ltypes.BedrockToolInputSchema{Json: t.Schema},
+		},
+	}
+}
+
+func (t *Tool) ToMistral() *ltypes.MistralTool {
+	return &ltypes.MistralTool{
+		Type: "function",
+		Function: &ltypes.GPTToolFunction{
+			Name:        t.Title,
+			Description: t.Description,
+			Parameters:  t.Schema,
+		},
+	}
+}
+
+func (t *Tool) ToCohere() *ltypes.CohereTool {
+	return &ltypes.CohereTool{
+		Type: "function",
+		Function: &ltypes.GPTToolFunction{
+			Name:        t.Title,
+			Description: t.Description,
+			Parameters:  t.Schema,
+		},
 	}
 }
 
@@ -72,6 +120,42 @@ func ToolsToAnthropic(tools []*Tool) []*ltypes.AnthropicTool {
 	return resp
 }
 
+// Converts to Ollama tools
+func ToolsToOllama(tools []*Tool) []*ltypes.OllamaTool {
+	resp := make([]*ltypes.OllamaTool, len(tools))
+	for i, item := range tools {
+		resp[i] = item.ToOllama()
+	}
+	return resp
+}
+
+// Converts to Bedrock Converse tools
+func ToolsToBedrock(tools []*Tool) []*ltypes.BedrockTool {
+	resp := make([]*ltypes.BedrockTool, len(tools))
+	for i, item := range tools {
+		resp[i] = item.ToBedrock()
+	}
+	return resp
+}
+
+// Converts to Mistral tools
+func ToolsToMistral(tools []*Tool) []*ltypes.MistralTool {
+	resp := make([]*ltypes.MistralTool, len(tools))
+	for i, item := range tools {
+		resp[i] = item.ToMistral()
+	}
+	return resp
+}
+
+// Converts to Cohere tools
+func ToolsToCohere(tools []*Tool) []*ltypes.CohereTool {
+	resp := make([]*ltypes.CohereTool, len(tools))
+	for i, item := range tools {
+		resp[i] = item.ToCohere()
+	}
+	return resp
+}
+
 type ToolCall struct {
 	ID        string         `json:"id"`        // Identifier of the tool call. Not applicable for all providers
 	Name      string         `json:"name"`      // Name of the calling function. Will match the name of a supplied `Tool` object `Schema`
@@ -102,6 +186,62 @@ func (t *ToolCall) ToAnthropic() *ltypes.AnthropicContent {
 	}
 }
 
+func (t *ToolCall) ToOllama() []*ltypes.OllamaToolCall {
+	resp := make([]*ltypes.OllamaToolCall, 0)
+	resp = append(resp, &ltypes.OllamaToolCall{
+		Function: &ltypes.OllamaToolCallFunction{
+			Name:      t.Name,
+			Arguments: t.Arguments,
+		},
+	})
+	return resp
+}
+
+func (t *ToolCall) ToBedrock() *ltypes.BedrockContentBlock {
+	// converse rejects a missing input, even for tools without arguments
+	input := t.Arguments
+	if input == nil {
+		input = make(map[string]any)
+	}
+	return &ltypes.BedrockContentBlock{
+		ToolUse: &ltypes.BedrockToolUse{
+			ToolUseId: t.ID,
+			Name:      t.Name,
+			Input:     input,
+		},
+	}
+}
+
+func (t *ToolCall) ToMistral() []*ltypes.MistralToolCall {
+	// encode
+	enc, _ := json.Marshal(t.Arguments)
+	resp := make([]*ltypes.MistralToolCall, 0)
+	resp = append(resp, &ltypes.MistralToolCall{
+		ID:   mistralToolCallId(t.ID),
+		Type: "function",
+		Function: &ltypes.MistralToolCallFunction{
+			Name:      t.Name,
+			Arguments: string(enc),
+		},
+	})
+	return resp
+}
+
+func (t *ToolCall) ToCohere() []*ltypes.CohereToolCall {
+	// encode
+	enc, _ := json.Marshal(t.Arguments)
+	resp := make([]*ltypes.CohereToolCall, 0)
+	resp = append(resp, &ltypes.CohereToolCall{
+		ID:   t.ID,
+		Type: "function",
+		Function: &ltypes.CohereToolCallFunction{
+			Name:      t.Name,
+			Arguments: string(enc),
+		},
+	})
+	return resp
+}
+
 func ToolCallFromOpenAI(call []*ltypes.GPTCompletionToolCall) *ToolCall {
 	// decode
 	args := make(map[string]any)
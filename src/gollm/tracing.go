@@ -0,0 +1,209 @@
+package gollm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/jake-landersweb/gollm/v2/src/metrics"
+	"github.com/jake-landersweb/gollm/v2/src/tokens"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Name of the tracer used to create all gollm spans
+const tracerName = "github.com/jake-landersweb/gollm/v2"
+
+// Attribute keys following the OpenTelemetry GenAI semantic conventions, along with
+// a few gollm specific keys where the conventions do not define one.
+const (
+	attrGenAISystem                = attribute.Key("gen_ai.system")
+	attrGenAIOperationName         = attribute.Key("gen_ai.operation.name")
+	attrGenAIRequestModel          = attribute.Key("gen_ai.request.model")
+	attrGenAIRequestTemperature    = attribute.Key("gen_ai.request.temperature")
+	attrGenAIResponseFinishReasons = attribute.Key("gen_ai.response.finish_reasons")
+	attrGenAIUsageInputTokens      = attribute.Key("gen_ai.usage.input_tokens")
+	attrGenAIUsageOutputTokens     = attribute.Key("gen_ai.usage.output_tokens")
+	attrGenAIToolName              = attribute.Key("gen_ai.tool.name")
+	attrGenAIToolCallID            = attribute.Key("gen_ai.tool.call.id")
+	attrHTTPStatusCode             = attribute.Key("http.response.status_code")
+	attrErrorType                  = attribute.Key("error.type")
+	attrRetryCount                 = attribute.Key("gollm.retry_count")
+	attrAttempt                    = attribute.Key("gollm.attempt")
+	attrEmbeddingsChunks           = attribute.Key("gollm.embeddings.chunks")
+	attrCacheHit                   = attribute.Key("gollm.cache.hit")
+)
+
+// Values for the `gen_ai.system` attribute
+const (
+	genAISystemOpenAI      = "openai"
+	genAISystemGemini      = "gcp.gemini"
+	genAISystemVertexAI    = "gcp.vertex_ai"
+	genAISystemAnthropic   = "anthropic"
+	genAISystemOllama      = "ollama"
+	genAISystemAzureOpenAI = "az.ai.openai"
+	genAISystemBedrock     = "aws.bedrock"
+	genAISystemMistral     = "mistral_ai"
+	genAISystemCohere      = "cohere"
+	genAISystemVoyage      = "voyage_ai"
+	genAISystemJina        = "jina_ai"
+)
+
+// Values for the `gen_ai.operation.name` attribute
+const (
+	genAIOperationChat        = "chat"
+	genAIOperationEmbeddings  = "embeddings"
+	genAIOperationExecuteTool = "execute_tool"
+	genAIOperationRerank      = "rerank"
+)
+
+// Returns the tracer to use from the passed provider. When no provider is passed,
+// the global provider is used, which is a no-op unless the application configured one.
+func newTracer(provider trace.TracerProvider) trace.Tracer {
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	return provider.Tracer(tracerName)
+}
+
+// Converts a usage record into span attributes
+func usageAttributes(record *tokens.UsageRecord) []attribute.KeyValue {
+	if record == nil {
+		return nil
+	}
+	return []attribute.KeyValue{
+		attrGenAIUsageInputTokens.Int(record.InputTokens),
+		attrGenAIUsageOutputTokens.Int(record.OutputTokens),
+	}
+}
+
+// Marks the span as failed with the passed error. The span is not ended.
+func recordSpanError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// Records on the span in `ctx` and in the metrics that the provider returned an error that can be retried
+func recordRetryableError(
+	ctx context.Context,
+	m metrics.Metrics,
+	operation string,
+	provider string,
+	model string,
+	attempt int,
+	errorType string,
+) {
+	trace.SpanFromContext(ctx).AddEvent("retryable_error", trace.WithAttributes(
+		attrAttempt.Int(attempt),
+		attrErrorType.String(errorType),
+	))
+	m.ObserveRetry(operation, provider, model, errorType)
+}
+
+/*
+Sends a single attempt of a provider request inside of its own span, and returns the
+status code and the raw body of the response. A new request is created for every attempt
+through `newRequest`, as the body of a request cannot be re-sent once it was read.
+*/
+func sendAttempt(
+	ctx context.Context,
+	tracer trace.Tracer,
+	client *http.Client,
+	system string,
+	model string,
+	attempt int,
+	newRequest func(ctx context.Context) (*http.Request, error),
+) (int, []byte, error) {
+	statusCode, _, body, err := sendAttemptWithHeaders(ctx, tracer, client, system, model, attempt, newRequest)
+	return statusCode, body, err
+}
+
+// The same as `sendAttempt`, but also returns the response headers for providers that report details through them
+func sendAttemptWithHeaders(
+	ctx context.Context,
+	tracer trace.Tracer,
+	client *http.Client,
+	system string,
+	model string,
+	attempt int,
+	newRequest func(ctx context.Context) (*http.Request, error),
+) (int, http.Header, []byte, error) {
+	// the parent span tracks how many retries were needed for the operation, when there were any
+	if attempt > 0 {
+		trace.SpanFromContext(ctx).SetAttributes(attrRetryCount.Int(attempt))
+	}
+
+	ctx, span := tracer.Start(ctx, fmt.Sprintf("%s attempt", system),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attrGenAISystem.String(system),
+			attrGenAIRequestModel.String(model),
+			attrAttempt.Int(attempt),
+		),
+	)
+	defer span.End()
+
+	req, err := newRequest(ctx)
+	if err != nil {
+		err = fmt.Errorf("there was an issue creating the http request: %v", err)
+		recordSpanError(span, err)
+		return 0, nil, nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		err = fmt.Errorf("there was an issue sending the request: %v", err)
+		recordSpanError(span, err)
+		return 0, nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	span.SetAttributes(attrHTTPStatusCode.Int(resp.StatusCode))
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		err = fmt.Errorf("there was an issue reading the body: %v", err)
+		recordSpanError(span, err)
+		return resp.StatusCode, resp.Header, nil, err
+	}
+
+	if resp.StatusCode >= 400 {
+		span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+	}
+
+	return resp.StatusCode, resp.Header, body, nil
+}
+
+// Function that executes a tool call requested by a model, and returns the result as text
+type ToolFunc func(ctx context.Context, call *ToolCall) (string, error)
+
+/*
+Executes the tool call inside of a traced span, and wraps the result of `fn` into a tool
+result message that can be appended directly onto the conversation.
+*/
+func (l *LanguageModel) ExecuteTool(ctx context.Context, call *ToolCall, fn ToolFunc) (*Message, error) {
+	if call == nil {
+		return nil, fmt.Errorf("the tool call cannot be nil")
+	}
+
+	ctx, span := l.tracer.Start(ctx, fmt.Sprintf("%s %s", genAIOperationExecuteTool, call.Name),
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(
+			attrGenAIOperationName.String(genAIOperationExecuteTool),
+			attrGenAIToolName.String(call.Name),
+			attrGenAIToolCallID.String(call.ID),
+		),
+	)
+	defer span.End()
+
+	result, err := fn(ctx, call)
+	if err != nil {
+		recordSpanError(span, err)
+		return nil, err
+	}
+
+	return NewToolResultMessage(call.ID, call.Name, result), nil
+}
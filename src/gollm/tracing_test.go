@@ -0,0 +1,195 @@
+package gollm
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+const tracingGPTResponse = `{
+	"id": "chatcmpl-test",
+	"object": "chat.completion",
+	"model": "gpt-3.5-turbo",
+	"choices": [{"index": 0, "message": {"role": "assistant", "content": "Hello!"}, "finish_reason": "stop"}],
+	"usage": {"prompt_tokens": 12, "completion_tokens": 3, "total_tokens": 15}
+}`
+
+func newTestTracerProvider() (*sdktrace.TracerProvider, *tracetest.InMemoryExporter) {
+	exporter := tracetest.NewInMemoryExporter()
+	return sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)), exporter
+}
+
+func findSpan(t *testing.T, spans tracetest.SpanStubs, name string) tracetest.SpanStub {
+	for _, span := range spans {
+		if span.Name == name {
+			return span
+		}
+	}
+	require.FailNow(t, "span not found", name)
+	return tracetest.SpanStub{}
+}
+
+func spanAttribute(span tracetest.SpanStub, key attribute.Key) attribute.Value {
+	for _, item := range span.Attributes {
+		if item.Key == key {
+			return item.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestTracingCompletion(t *testing.T) {
+	skipRetryDelays(t)
+	logger := defaultLogger(slog.LevelDebug).With("test", "TestTracingCompletion")
+	provider, exporter := newTestTracerProvider()
+
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error": {"message": "slow down", "type": "rate_limit_error"}}`))
+			return
+		}
+		w.Write([]byte(tracingGPTResponse))
+	}))
+	defer server.Close()
+
+	model := NewLanguageModel(test_user_id, logger, &NewLanguageModelArgs{
+		GptBaseUrl:     server.URL,
+		OpenAIApiKey:   "test",
+		TracerProvider: provider,
+	})
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "parent")
+	response, err := model.Completion(ctx, &CompletionInput{
+		Model:        gpt3_model,
+		Temperature:  0.5,
+		Conversation: []*Message{NewUserMessage("Hello")},
+	})
+	parent.End()
+	require.NoError(t, err)
+	require.Equal(t, "Hello!", response.Message.Message)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 4)
+
+	completion := findSpan(t, spans, "chat "+gpt3_model)
+	require.Equal(t, parent.SpanContext().SpanID(), completion.Parent.SpanID())
+	require.Equal(t, genAISystemOpenAI, spanAttribute(completion, attrGenAISystem).AsString())
+	require.Equal(t, gpt3_model, spanAttribute(completion, attrGenAIRequestModel).AsString())
+	require.Equal(t, 0.5, spanAttribute(completion, attrGenAIRequestTemperature).AsFloat64())
+	require.Equal(t, []string{"stop"}, spanAttribute(completion, attrGenAIResponseFinishReasons).AsStringSlice())
+	require.Equal(t, int64(12), spanAttribute(completion, attrGenAIUsageInputTokens).AsInt64())
+	require.Equal(t, int64(3), spanAttribute(completion, attrGenAIUsageOutputTokens).AsInt64())
+	require.Equal(t, int64(1), spanAttribute(completion, attrRetryCount).AsInt64())
+	require.Len(t, completion.Events, 1)
+
+	for _, span := range spans {
+		if span.Name == "openai attempt" {
+			require.Equal(t, completion.SpanContext.SpanID(), span.Parent.SpanID())
+		}
+	}
+}
+
+func TestTracingCompletionError(t *testing.T) {
+	logger := defaultLogger(slog.LevelDebug).With("test", "TestTracingCompletionError")
+	provider, exporter := newTestTracerProvider()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error": {"message": "bad key", "type": "authentication_error"}}`))
+	}))
+	defer server.Close()
+
+	model := NewLanguageModel(test_user_id, logger, &NewLanguageModelArgs{
+		GptBaseUrl:     server.URL,
+		OpenAIApiKey:   "test",
+		TracerProvider: provider,
+	})
+
+	_, err := model.Completion(context.Background(), &CompletionInput{
+		Model:        gpt3_model,
+		Conversation: []*Message{NewUserMessage("Hello")},
+	})
+	require.Error(t, err)
+
+	completion := findSpan(t, exporter.GetSpans(), "chat "+gpt3_model)
+	require.Equal(t, "Error", completion.Status.Code.String())
+	attempt := findSpan(t, exporter.GetSpans(), "openai attempt")
+	require.Equal(t, int64(http.StatusUnauthorized), spanAttribute(attempt, attrHTTPStatusCode).AsInt64())
+
+	// the retry count is only set when a request was retried
+	require.Equal(t, attribute.INVALID, spanAttribute(completion, attrRetryCount).Type())
+}
+
+func TestRetryBackoffCancelled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error": {"message": "unavailable", "type": "server_error"}}`))
+	}))
+	defer server.Close()
+
+	model := NewLanguageModel(test_user_id, nil, &NewLanguageModelArgs{
+		GptBaseUrl:   server.URL,
+		OpenAIApiKey: "test",
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := model.Completion(ctx, &CompletionInput{
+		Model:        gpt3_model,
+		Conversation: []*Message{NewUserMessage("Hello")},
+	})
+	require.ErrorContains(t, err, context.DeadlineExceeded.Error())
+	require.Less(t, time.Since(start), time.Second)
+}
+
+func TestTracingExecuteTool(t *testing.T) {
+	logger := defaultLogger(slog.LevelDebug).With("test", "TestTracingExecuteTool")
+	provider, exporter := newTestTracerProvider()
+	model := NewLanguageModel(test_user_id, logger, &NewLanguageModelArgs{TracerProvider: provider})
+
+	call := &ToolCall{ID: "call_1", Name: "get_weather", Arguments: map[string]any{"city_name": "Portland, OR"}}
+	message, err := model.ExecuteTool(context.Background(), call, func(ctx context.Context, call *ToolCall) (string, error) {
+		return "35 degrees", nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, RoleToolResult, message.Role)
+	require.Equal(t, "call_1", message.ToolUseID)
+
+	span := findSpan(t, exporter.GetSpans(), "execute_tool get_weather")
+	require.Equal(t, "get_weather", spanAttribute(span, attrGenAIToolName).AsString())
+	require.Equal(t, "call_1", spanAttribute(span, attrGenAIToolCallID).AsString())
+}
+
+func TestTracingEmbeddings(t *testing.T) {
+	logger := defaultLogger(slog.LevelDebug).With("test", "TestTracingEmbeddings")
+	provider, exporter := newTestTracerProvider()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"object": "list", "data": [{"object": "embedding", "embedding": [0.1, 0.2], "index": 0}], "usage": {"prompt_tokens": 5, "total_tokens": 5}}`))
+	}))
+	defer server.Close()
+
+	embeddings := NewOpenAIEmbeddings(test_user_id, &OpenAIEmbeddingsOpts{
+		BaseUrl:        server.URL,
+		OpenAIApiKey:   "test",
+		TracerProvider: provider,
+	})
+	_, err := embeddings.Embed(context.Background(), logger, &EmbedArgs{InputChunks: []string{"hello world"}})
+	require.NoError(t, err)
+
+	span := findSpan(t, exporter.GetSpans(), "embeddings "+OPENAI_EMBEDDINGS_MODEL)
+	require.Equal(t, int64(1), spanAttribute(span, attrEmbeddingsChunks).AsInt64())
+	require.Equal(t, int64(5), spanAttribute(span, attrGenAIUsageInputTokens).AsInt64())
+}
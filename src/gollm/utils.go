@@ -1,63 +1,81 @@
 package gollm
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
+	"io"
 	"log/slog"
 	"math"
 	"os"
-	"unicode/utf8"
+	"time"
 )
 
 func defaultLogger(level slog.Leveler) *slog.Logger {
 	return slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: level}))
 }
 
-// Chunks string `s` into a list if strings into equal lengths with a max size of `n`
-// If the string is not divisible by n, then the items will be as close in length as possible
-func ChunkStringEqualUntilN(s string) ([]string, error) {
-	if len(s) == 0 {
-		return nil, fmt.Errorf("the input was empty")
+// Logger that drops all records, used when the caller does not pass a logger
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+/*
+Waits for the duration, returning the error of the context early when it is cancelled. Every retry
+delay goes through it, so tests can replace it to skip the delays.
+*/
+var sleepContext = func(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
 	}
+}
+
+// Chunks string `s` into a list of strings of equal lengths with a max size of 1024 runes, where
+// consecutive strings overlap by 200 runes. Use `ChunkStringEqual` to configure the sizes
+func ChunkStringEqualUntilN(s string) ([]string, error) {
+	return ChunkStringEqual(embeddings_chunk_size_default, embeddings_chunk_overlap_default)(s)
+}
 
-	totalRuneCount := utf8.RuneCountInString(s) // Count of runes instead of bytes
-	numParts := int(math.Ceil(float64(totalRuneCount) / float64(embeddings_chunk_size_default)))
-	evenLength := totalRuneCount / numParts
-	extraChars := totalRuneCount % numParts
-
-	var parts []string
-	start := 0
-	for i := 0; i < numParts; i++ {
-		partLength := evenLength
-		if i < extraChars {
-			partLength++ // Distribute extra characters among the first few parts
+/*
+Creates a chunking function that chunks a string into a list of strings with a max size of `n` runes,
+where consecutive strings overlap by `overlap` runes. If the string is not divisible into chunks of
+size `n`, then the chunks will be as close in length as possible.
+*/
+func ChunkStringEqual(n int, overlap int) ChunkingFunction {
+	return func(s string) ([]string, error) {
+		if len(s) == 0 {
+			return nil, fmt.Errorf("the input was empty")
+		}
+		if n <= 0 || overlap < 0 || overlap >= n {
+			return nil, fmt.Errorf("the overlap (%d) must be less than the size (%d)", overlap, n)
 		}
 
-		end := start
-		count := 0
-		for count < partLength && end < len(s) {
-			_, size := utf8.DecodeRuneInString(s[end:])
-			end += size
-			count++
+		// byte offset of every rune, so the parts never split a rune
+		offsets := make([]int, 0, len(s)+1)
+		for idx := range s {
+			offsets = append(offsets, idx)
 		}
+		totalRuneCount := len(offsets)
+		offsets = append(offsets, len(s))
 
-		parts = append(parts, s[start:end])
-		start = end - embeddings_chunk_overlap_default
-	}
+		// the fewest parts of at most n runes that cover the string, and the even length of each
+		numParts := 1
+		if totalRuneCount > n {
+			numParts = int(math.Ceil(float64(totalRuneCount-overlap) / float64(n-overlap)))
+		}
+		partLength := int(math.Ceil(float64(totalRuneCount+(numParts-1)*overlap) / float64(numParts)))
 
-	return parts, nil
-}
+		parts := make([]string, 0, numParts)
+		for i := 0; i < numParts; i++ {
+			start := i * (partLength - overlap)
+			end := min(start+partLength, totalRuneCount)
+			parts = append(parts, s[offsets[start]:offsets[end]])
+		}
 
-// Converts a slice of one numeric type to another numeric type using generics.
-func convertSlice[T any, U any](list []T, convert func(T) U) []U {
-	result := make([]U, len(list))
-	for i, v := range list {
-		result[i] = convert(v)
+		return parts, nil
 	}
-	return result
-}
-
-func debugPrint(input any) {
-	enc, _ := json.MarshalIndent(input, "", "    ")
-	fmt.Println(string(enc))
 }
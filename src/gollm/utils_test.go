@@ -0,0 +1,197 @@
+package gollm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jake-landersweb/gollm/v2/src/cassette"
+	"github.com/stretchr/testify/require"
+)
+
+const test_cassette_dir = "testdata/cassettes"
+
+// Api key used when replaying, as the recorded interactions do not need real credentials
+const test_replay_api_key = "replay-api-key"
+
+// Skips the retry delays for the running test, still returning the error of a cancelled context
+func skipRetryDelays(t *testing.T) {
+	original := sleepContext
+	sleepContext = func(ctx context.Context, d time.Duration) error {
+		return ctx.Err()
+	}
+	t.Cleanup(func() { sleepContext = original })
+}
+
+// Starts a test server that records every request and answers it with `respond`, closing it when the test ends
+func newTestServer(t *testing.T, respond func(w http.ResponseWriter)) (*httptest.Server, *testRequests) {
+	requests := &testRequests{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.record(r)
+		respond(w)
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+// Answers every request with the status and body
+func respondWith(status int, body string) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}
+}
+
+/*
+Records the requests received by a test server. Handlers run on the goroutines of the server, so
+they only record what they receive, and the test asserts on the requests after the call returns.
+*/
+type testRequests struct {
+	mu       sync.Mutex
+	requests []*testRequest
+}
+
+// A request received by a test server
+type testRequest struct {
+	Method string
+	URL    *url.URL
+	Header http.Header
+	Body   []byte
+}
+
+// Records the request. A body that could not be read in full is recorded as far as it was read
+func (l *testRequests) record(r *http.Request) *testRequest {
+	body, _ := io.ReadAll(r.Body)
+	req := &testRequest{Method: r.Method, URL: r.URL, Header: r.Header.Clone(), Body: body}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.requests = append(l.requests, req)
+	return req
+}
+
+// All of the recorded requests, in the order they were received
+func (l *testRequests) all() []*testRequest {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	resp := make([]*testRequest, len(l.requests))
+	copy(resp, l.requests)
+	return resp
+}
+
+// The last recorded request. Fails the test when no request was received
+func (l *testRequests) last(t *testing.T) *testRequest {
+	t.Helper()
+	requests := l.all()
+	require.NotEmpty(t, requests, "the server did not receive a request")
+	return requests[len(requests)-1]
+}
+
+// Headers of the last recorded request
+func (l *testRequests) header(t *testing.T) http.Header {
+	t.Helper()
+	return l.last(t).Header
+}
+
+// Decoded json body of the last recorded request
+func (l *testRequests) body(t *testing.T) map[string]any {
+	t.Helper()
+	return l.last(t).json(t)
+}
+
+// Decodes the json body of the request
+func (r *testRequest) json(t *testing.T) map[string]any {
+	t.Helper()
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(r.Body, &body))
+	return body
+}
+
+func debugPrint(input any) {
+	enc, _ := json.MarshalIndent(input, "", "    ")
+	fmt.Println(string(enc))
+}
+
+/*
+Creates a recorder for the running test, storing its interactions in its own cassette.
+Tests replay the cassettes by default. The committed cassettes are hand-written fixtures, so
+replaying them only checks gollm against itself. Set `GOLLM_CASSETTE_MODE=record` with real api
+keys to record them from the live apis, or `GOLLM_CASSETTE_MODE=passthrough` to run against the
+live apis without a cassette.
+*/
+func newTestRecorder(t *testing.T) *cassette.Recorder {
+	path := filepath.Join(test_cassette_dir, t.Name()+".json")
+	recorder, err := cassette.New(path, cassette.ModeFromEnv(), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, recorder.Stop())
+	})
+	return recorder
+}
+
+// Creates a language model that sends all requests through the cassette of the running test
+func newTestLanguageModel(t *testing.T, logger *slog.Logger) *LanguageModel {
+	recorder := newTestRecorder(t)
+	args := &NewLanguageModelArgs{HttpClient: recorder.Client()}
+	if recorder.Mode() == cassette.ModeReplay {
+		args.OpenAIApiKey = test_replay_api_key
+		args.GeminiApiKey = test_replay_api_key
+		args.AnthropicApiKey = test_replay_api_key
+	}
+	return NewLanguageModel(test_user_id, logger, args)
+}
+
+// Creates OpenAI embeddings that send all requests through the cassette of the running test
+func newTestOpenAIEmbeddings(t *testing.T) *OpenAIEmbeddings {
+	recorder := newTestRecorder(t)
+	opts := &OpenAIEmbeddingsOpts{HttpClient: recorder.Client()}
+	if recorder.Mode() == cassette.ModeReplay {
+		opts.OpenAIApiKey = test_replay_api_key
+	}
+	return NewOpenAIEmbeddings(test_user_id, opts)
+}
+
+func TestChunkStringEqual(t *testing.T) {
+	// the parts overlap and cover the whole string, without splitting runes
+	input := strings.Repeat("aé", 25)
+	parts, err := ChunkStringEqual(20, 5)(input)
+	require.NoError(t, err)
+	require.Len(t, parts, 3)
+	for _, item := range parts {
+		require.LessOrEqual(t, utf8.RuneCountInString(item), 20)
+		require.True(t, utf8.ValidString(item))
+	}
+	require.True(t, strings.HasPrefix(input, parts[0]))
+	require.True(t, strings.HasSuffix(input, parts[2]))
+	require.Equal(t, string([]rune(parts[0])[len([]rune(parts[0]))-5:]), string([]rune(parts[1])[:5]))
+
+	// strings shorter than the overlap are a single part
+	parts, err = ChunkStringEqualUntilN("short")
+	require.NoError(t, err)
+	require.Equal(t, []string{"short"}, parts)
+
+	_, err = ChunkStringEqual(10, 10)("hello")
+	require.Error(t, err)
+}
+
+// Decodes the bodies of every recorded request, in the order they were received
+func decodeRequests[T any](t *testing.T, requests *testRequests) []T {
+	t.Helper()
+	decoded := make([]T, 0)
+	for _, item := range requests.all() {
+		var request T
+		require.NoError(t, json.Unmarshal(item.Body, &request))
+		decoded = append(decoded, request)
+	}
+	return decoded
+}
@@ -0,0 +1,320 @@
+package gollm
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+Configures access to Gemini through Vertex AI. Models are routed to Vertex with the `vertex/`
+prefix, such as `vertex/gemini-1.5-flash`.
+
+Requests are authenticated with OAuth2 access tokens minted from a service account key through
+the JWT bearer flow, or with the tokens returned by `TokenProvider` when it is set.
+*/
+type VertexAIConfig struct {
+	// Id of the Google Cloud project. If not defined, the env variable `GOOGLE_CLOUD_PROJECT` will be used
+	Project string
+
+	// Region of the endpoint, such as `us-central1` or `global`.
+	// If not defined, the env variable `GOOGLE_CLOUD_LOCATION` will be used, and then `us-central1`
+	Region string
+
+	// Contents of a service account key file. If not defined, the file at `CredentialsFile` is read,
+	// falling back to the env variable `GOOGLE_APPLICATION_CREDENTIALS`
+	CredentialsJSON []byte
+	CredentialsFile string
+
+	// Optionally authenticate with access tokens from another source, such as the metadata server.
+	// Called before every request, so it should cache its tokens
+	TokenProvider func(ctx context.Context) (string, error)
+
+	// Optionally override the endpoint, such as a private service connect endpoint or a local stand-in server.
+	// Defaults to `https://{region}-aiplatform.googleapis.com`
+	BaseUrl string
+
+	// service account tokens are minted once and shared by every request until they expire.
+	// The source is only stored once it was created, so a failure is retried by the next request
+	mu     sync.Mutex
+	source *serviceAccountTokenSource
+}
+
+func (c *VertexAIConfig) project() (string, error) {
+	if c.Project != "" {
+		return c.Project, nil
+	}
+	project := os.Getenv("GOOGLE_CLOUD_PROJECT")
+	if project == "" {
+		return "", fmt.Errorf("the env variable `GOOGLE_CLOUD_PROJECT` is required to be set")
+	}
+	return project, nil
+}
+
+func (c *VertexAIConfig) region() string {
+	if c.Region != "" {
+		return c.Region
+	}
+	if region := os.Getenv("GOOGLE_CLOUD_LOCATION"); region != "" {
+		return region
+	}
+	return vertex_region
+}
+
+// Builds the url of a method, such as `generateContent` or `countTokens`, on a Google model
+func (c *VertexAIConfig) modelUrl(model string, method string) (string, error) {
+	project, err := c.project()
+	if err != nil {
+		return "", err
+	}
+	region := c.region()
+
+	baseUrl := c.BaseUrl
+	if baseUrl == "" {
+		if region == "global" {
+			baseUrl = "https://aiplatform.googleapis.com"
+		} else {
+			baseUrl = fmt.Sprintf("https://%s-aiplatform.googleapis.com", region)
+		}
+	}
+	return fmt.Sprintf("%s/v1/projects/%s/locations/%s/publishers/google/models/%s:%s", strings.TrimSuffix(baseUrl, "/"), project, region, model, method), nil
+}
+
+// Returns a valid access token, minting a new one from the service account when needed
+func (c *VertexAIConfig) accessToken(ctx context.Context, client *http.Client) (string, error) {
+	if c.TokenProvider != nil {
+		token, err := c.TokenProvider(ctx)
+		if err != nil {
+			return "", fmt.Errorf("there was an issue getting the access token: %v", err)
+		}
+		return token, nil
+	}
+
+	source, err := c.tokenSource()
+	if err != nil {
+		return "", err
+	}
+	return source.token(ctx, client)
+}
+
+// Loads the service account key and creates the token source on first use
+func (c *VertexAIConfig) tokenSource() (*serviceAccountTokenSource, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.source != nil {
+		return c.source, nil
+	}
+
+	credentials := c.CredentialsJSON
+	if credentials == nil {
+		path := c.CredentialsFile
+		if path == "" {
+			path = os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+			if path == "" {
+				return nil, fmt.Errorf("the env variable `GOOGLE_APPLICATION_CREDENTIALS` is required to be set")
+			}
+		}
+		var err error
+		credentials, err = os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("there was an issue reading the credentials file: %v", err)
+		}
+	}
+	source, err := newServiceAccountTokenSource(credentials)
+	if err != nil {
+		return nil, err
+	}
+	c.source = source
+	return source, nil
+}
+
+// The fields of a service account key file that are needed to mint tokens
+type serviceAccountKey struct {
+	Type         string `json:"type"`
+	ClientEmail  string `json:"client_email"`
+	PrivateKeyId string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	TokenUri     string `json:"token_uri"`
+}
+
+// Mints access tokens from a service account key, caching them until shortly before they expire
+type serviceAccountTokenSource struct {
+	key        *serviceAccountKey
+	privateKey *rsa.PrivateKey
+
+	mu      sync.Mutex
+	cached  string
+	expires time.Time
+}
+
+func newServiceAccountTokenSource(credentials []byte) (*serviceAccountTokenSource, error) {
+	var key serviceAccountKey
+	if err := json.Unmarshal(credentials, &key); err != nil {
+		return nil, fmt.Errorf("there was an issue parsing the service account key: %v", err)
+	}
+	if key.Type != "service_account" {
+		return nil, fmt.Errorf("the credentials must be a service account key, found: %s", key.Type)
+	}
+	if key.TokenUri == "" {
+		key.TokenUri = google_token_uri
+	}
+
+	block, _ := pem.Decode([]byte(key.PrivateKey))
+	if block == nil {
+		return nil, fmt.Errorf("the private key of the service account is not valid pem")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		// older keys are encoded as pkcs1
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("there was an issue parsing the private key of the service account: %v", err)
+		}
+	}
+	privateKey, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("the private key of the service account must be an rsa key")
+	}
+
+	return &serviceAccountTokenSource{key: &key, privateKey: privateKey}, nil
+}
+
+func (s *serviceAccountTokenSource) token(ctx context.Context, client *http.Client) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if s.cached != "" && now.Before(s.expires) {
+		return s.cached, nil
+	}
+
+	assertion, err := s.assertion(now)
+	if err != nil {
+		return "", err
+	}
+
+	// exchange the signed jwt for an access token
+	form := url.Values{}
+	form.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+	form.Set("assertion", assertion)
+	req, err := http.NewRequestWithContext(ctx, "POST", s.key.TokenUri, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("there was an issue creating the token request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("there was an issue sending the token request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("there was an issue reading the token response: %v", err)
+	}
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("there was an issue minting the access token: [%d]: %s", resp.StatusCode, newRedactor(nil).Body(body))
+	}
+
+	var response struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", fmt.Errorf("there was an issue parsing the token response: %v", err)
+	}
+
+	// refresh a minute early so a token never expires mid request
+	s.cached = response.AccessToken
+	s.expires = now.Add(time.Duration(response.ExpiresIn)*time.Second - time.Minute)
+	return s.cached, nil
+}
+
+// Creates the jwt that is exchanged for an access token, signed with RS256
+func (s *serviceAccountTokenSource) assertion(now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{
+		"alg": "RS256",
+		"typ": "JWT",
+		"kid": s.key.PrivateKeyId,
+	})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]any{
+		"iss":   s.key.ClientEmail,
+		"scope": google_cloud_platform_scope,
+		"aud":   s.key.TokenUri,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(nil, s.privateKey, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", fmt.Errorf("there was an issue signing the jwt: %v", err)
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// Where a Gemini request is sent, and how it is authenticated
+type geminiTarget struct {
+	system  string
+	url     string
+	headers map[string]string
+	secret  string
+}
+
+// Resolves the target of a method, such as `generateContent`, for either AI Studio or Vertex AI
+func (l *LanguageModel) geminiTarget(ctx context.Context, model string, method string) (*geminiTarget, error) {
+	if vertexModel, ok := strings.CutPrefix(model, vertex_model_prefix); ok {
+		if l.args.Vertex == nil {
+			return nil, fmt.Errorf("`Vertex` must be configured to use the model: %s", model)
+		}
+		endpoint, err := l.args.Vertex.modelUrl(vertexModel, method)
+		if err != nil {
+			return nil, err
+		}
+		token, err := l.args.Vertex.accessToken(ctx, l.args.HttpClient)
+		if err != nil {
+			return nil, err
+		}
+		return &geminiTarget{
+			system:  genAISystemVertexAI,
+			url:     endpoint,
+			headers: map[string]string{"Authorization": "Bearer " + token},
+			secret:  token,
+		}, nil
+	}
+
+	apiKey := l.args.GeminiApiKey
+	if apiKey == "" {
+		apiKey = os.Getenv("GEMINI_API_KEY")
+		if apiKey == "" || apiKey == "null" {
+			return nil, fmt.Errorf("the environment variable `GEMINI_API_KEY` is required")
+		}
+	}
+	return &geminiTarget{
+		system:  genAISystemGemini,
+		url:     fmt.Sprintf("%s/%s:%s", l.args.GeminiBaseUrl, model, method),
+		headers: map[string]string{"x-goog-api-key": apiKey},
+		secret:  apiKey,
+	}, nil
+}
@@ -0,0 +1,228 @@
+package gollm
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const vertex_test_response = `{"candidates": [{"content": {"role": "model", "parts": [{"text": "Hello!"}]}, "finishReason": "STOP"}], "usageMetadata": {"promptTokenCount": 5, "candidatesTokenCount": 2, "totalTokenCount": 7}}`
+
+// Creates a service account key that mints tokens from the passed token uri
+func newTestServiceAccount(t *testing.T, tokenUri string) ([]byte, *rsa.PublicKey) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	credentials, err := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"project_id":     "gollm-test",
+		"private_key_id": "key-1",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"client_email":   "gollm@gollm-test.iam.gserviceaccount.com",
+		"token_uri":      tokenUri,
+	})
+	require.NoError(t, err)
+	return credentials, &key.PublicKey
+}
+
+// Verifies the signature of a jwt and returns its claims
+func verifyTestJWT(t *testing.T, token string, publicKey *rsa.PublicKey) map[string]any {
+	parts := strings.Split(token, ".")
+	require.Len(t, parts, 3)
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	require.NoError(t, err)
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	require.NoError(t, rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, hash[:], signature))
+
+	raw, err := base64.RawURLEncoding.DecodeString(parts[1])
+	require.NoError(t, err)
+	claims := make(map[string]any)
+	require.NoError(t, json.Unmarshal(raw, &claims))
+	return claims
+}
+
+func TestVertexCompletion(t *testing.T) {
+	logger := defaultLogger(slog.LevelDebug).With("test", "TestVertexCompletion")
+
+	tokenRequests, modelRequests := &testRequests{}, &testRequests{}
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		tokenRequests.record(r)
+		w.Write([]byte(`{"access_token": "vertex-access-token", "expires_in": 3599, "token_type": "Bearer"}`))
+	})
+	mux.HandleFunc("POST /v1/projects/gollm-test/locations/europe-west4/publishers/google/models/{method}", func(w http.ResponseWriter, r *http.Request) {
+		modelRequests.record(r)
+		switch r.PathValue("method") {
+		case "gemini-1.5-flash:generateContent":
+			w.Write([]byte(vertex_test_response))
+		case "gemini-1.5-flash:countTokens":
+			w.Write([]byte(`{"totalTokens": 9, "totalBillableCharacters": 30}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	credentials, publicKey := newTestServiceAccount(t, server.URL+"/token")
+
+	llm := NewLanguageModel(test_user_id, logger, &NewLanguageModelArgs{
+		Vertex: &VertexAIConfig{
+			Project:         "gollm-test",
+			Region:          "europe-west4",
+			CredentialsJSON: credentials,
+			BaseUrl:         server.URL,
+		},
+	})
+
+	for range 2 {
+		response, err := llm.Completion(context.TODO(), &CompletionInput{
+			Model: "vertex/gemini-1.5-flash",
+			Conversation: []*Message{
+				NewSystemMessage("You are a helpful assistant."),
+				NewUserMessage("Hello"),
+			},
+		})
+		require.NoError(t, err)
+		require.Equal(t, "Hello!", response.Message.Message)
+		require.Equal(t, "vertex/gemini-1.5-flash", response.UsageRecord.Model)
+		require.Equal(t, 7, response.UsageRecord.TotalTokens)
+	}
+
+	// the access token is cached between requests
+	count, err := llm.TokenEstimate("vertex/gemini-1.5-flash", "Hello world")
+	require.NoError(t, err)
+	require.Equal(t, 9, count)
+	require.Len(t, tokenRequests.all(), 1)
+
+	// the token is minted with a signed service account assertion
+	form, err := url.ParseQuery(string(tokenRequests.last(t).Body))
+	require.NoError(t, err)
+	require.Equal(t, "urn:ietf:params:oauth:grant-type:jwt-bearer", form.Get("grant_type"))
+	claims := verifyTestJWT(t, form.Get("assertion"), publicKey)
+	require.Equal(t, "gollm@gollm-test.iam.gserviceaccount.com", claims["iss"])
+	require.Equal(t, server.URL+"/token", claims["aud"])
+	require.Equal(t, google_cloud_platform_scope, claims["scope"])
+
+	require.Len(t, modelRequests.all(), 3)
+	for _, item := range modelRequests.all() {
+		require.Equal(t, "Bearer vertex-access-token", item.Header.Get("Authorization"))
+		require.Empty(t, item.Header.Get("x-goog-api-key"))
+	}
+}
+
+func TestVertexTokenProvider(t *testing.T) {
+	logger := defaultLogger(slog.LevelDebug).With("test", "TestVertexTokenProvider")
+	requests := &testRequests{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.record(r)
+		w.Write([]byte(vertex_test_response))
+	}))
+	defer server.Close()
+
+	t.Setenv("GOOGLE_CLOUD_PROJECT", "env-project")
+	t.Setenv("GOOGLE_CLOUD_LOCATION", "")
+
+	llm := NewLanguageModel(test_user_id, logger, &NewLanguageModelArgs{
+		Vertex: &VertexAIConfig{
+			BaseUrl: server.URL,
+			TokenProvider: func(ctx context.Context) (string, error) {
+				return "metadata-token", nil
+			},
+		},
+	})
+	_, err := llm.Completion(context.TODO(), &CompletionInput{
+		Model:        "vertex/gemini-1.5-pro",
+		Conversation: []*Message{NewUserMessage("Hello")},
+	})
+	require.NoError(t, err)
+
+	sent := requests.last(t)
+	require.Equal(t, "/v1/projects/env-project/locations/us-central1/publishers/google/models/gemini-1.5-pro:generateContent", sent.URL.Path)
+	require.Equal(t, "Bearer metadata-token", sent.Header.Get("Authorization"))
+}
+
+func TestVertexErrors(t *testing.T) {
+	// vertex must be configured
+	llm := NewLanguageModel(test_user_id, nil, nil)
+	_, err := llm.Completion(context.TODO(), &CompletionInput{
+		Model:        "vertex/gemini-1.5-flash",
+		Conversation: []*Message{NewUserMessage("Hello")},
+	})
+	require.ErrorContains(t, err, "`Vertex` must be configured")
+
+	// only service account keys are supported
+	llm = NewLanguageModel(test_user_id, nil, &NewLanguageModelArgs{
+		Vertex: &VertexAIConfig{
+			Project:         "gollm-test",
+			CredentialsJSON: []byte(`{"type": "authorized_user"}`),
+		},
+	})
+	_, err = llm.Completion(context.TODO(), &CompletionInput{
+		Model:        "vertex/gemini-1.5-flash",
+		Conversation: []*Message{NewUserMessage("Hello")},
+	})
+	require.ErrorContains(t, err, "must be a service account key")
+}
+
+func TestVertexCredentialsRetried(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"access_token": "vertex-access-token", "expires_in": 3599, "token_type": "Bearer"}`))
+	})
+	mux.HandleFunc("POST /v1/projects/gollm-test/locations/us-central1/publishers/google/models/{method}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"totalTokens": 9}`))
+	})
+
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	llm := NewLanguageModel(test_user_id, nil, &NewLanguageModelArgs{
+		Vertex: &VertexAIConfig{
+			Project:         "gollm-test",
+			Region:          "us-central1",
+			CredentialsFile: path,
+			BaseUrl:         server.URL,
+		},
+	})
+
+	// a failure to load the credentials is not cached
+	_, err := llm.TokenEstimate("vertex/gemini-1.5-flash", "Hello world")
+	require.ErrorContains(t, err, "reading the credentials file")
+
+	credentials, _ := newTestServiceAccount(t, server.URL+"/token")
+	require.NoError(t, os.WriteFile(path, credentials, 0o600))
+	count, err := llm.TokenEstimate("vertex/gemini-1.5-flash", "Hello world")
+	require.NoError(t, err)
+	require.Equal(t, 9, count)
+
+	// the requests are bound to the context of the caller
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = llm.TokenEstimateContext(ctx, "vertex/gemini-1.5-flash", "Hello world")
+	require.ErrorContains(t, err, context.Canceled.Error())
+
+	// the package level estimate has no vertex configuration
+	_, err = TokenEstimate("vertex/gemini-1.5-flash", "Hello world")
+	require.ErrorContains(t, err, "`Vertex` must be configured")
+}
@@ -0,0 +1,235 @@
+package gollmtest
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Provider wire format that a request was sent in
+type Provider int
+
+const (
+	ProviderOpenAI Provider = iota
+	ProviderGemini
+	ProviderAnthropic
+	ProviderOpenAIEmbeddings
+	ProviderGeminiCountTokens
+)
+
+func (p Provider) ToString() string {
+	switch p {
+	case ProviderOpenAI:
+		return "openai"
+	case ProviderGemini:
+		return "gemini"
+	case ProviderAnthropic:
+		return "anthropic"
+	case ProviderOpenAIEmbeddings:
+		return "openai embeddings"
+	case ProviderGeminiCountTokens:
+		return "gemini count tokens"
+	default:
+		return "unknown"
+	}
+}
+
+// Errors that can be returned by the fake server. They are translated into the error shape of each provider.
+type ErrorKind int
+
+const (
+	ErrorRateLimit ErrorKind = iota
+	ErrorOverloaded
+	ErrorServer
+	ErrorAuthentication
+	ErrorInvalidRequest
+	ErrorNotFound
+)
+
+type responseKind int
+
+const (
+	responseText responseKind = iota
+	responseToolCall
+	responseError
+	responseRaw
+	responseEmbeddings
+	responseTokens
+)
+
+/*
+A scripted request that the server expects to receive, and the canned response to send back.
+Expectations are consumed in the order they were added. Create them through the `Expect`
+methods on `Server`. The builder methods are safe to call while the server is handling requests.
+*/
+type Expectation struct {
+	// lock of the server, which reads the expectation while handling requests
+	mu       *sync.Mutex
+	provider Provider
+
+	// matchers
+	model        string
+	contains     []string
+	lastContains string
+
+	// response
+	kind         responseKind
+	text         string
+	toolName     string
+	toolID       string
+	toolArgs     map[string]any
+	errorKind    ErrorKind
+	status       int
+	rawBody      string
+	vectors      [][]float32
+	tokens       int
+	inputTokens  int
+	outputTokens int
+	stopReason   string
+
+	times    int
+	received int
+}
+
+func newExpectation(mu *sync.Mutex, provider Provider) *Expectation {
+	return &Expectation{
+		mu:       mu,
+		provider: provider,
+		kind:     responseText,
+		text:     "Hello from gollmtest!",
+		times:    1,
+	}
+}
+
+// Only match requests for this model
+func (e *Expectation) WithModel(model string) *Expectation {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.model = model
+	return e
+}
+
+// Only match requests whose raw body contains the text
+func (e *Expectation) WithBodyContaining(text string) *Expectation {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.contains = append(e.contains, text)
+	return e
+}
+
+// Only match requests where the text of the last message contains the text
+func (e *Expectation) WithLastMessageContaining(text string) *Expectation {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.lastContains = text
+	return e
+}
+
+// Allow the expectation to be matched `n` times before moving on to the next one
+func (e *Expectation) Times(n int) *Expectation {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.times = n
+	return e
+}
+
+// Override the token usage that is reported in the response
+func (e *Expectation) WithUsage(inputTokens int, outputTokens int) *Expectation {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.inputTokens = inputTokens
+	e.outputTokens = outputTokens
+	return e
+}
+
+// Override the stop or finish reason reported in the response
+func (e *Expectation) WithStopReason(reason string) *Expectation {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.stopReason = reason
+	return e
+}
+
+// Respond with a plain text assistant message
+func (e *Expectation) RespondText(text string) *Expectation {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.kind = responseText
+	e.text = text
+	return e
+}
+
+// Respond with a tool call. When `id` is empty, a provider specific id is generated
+func (e *Expectation) RespondToolCall(id string, name string, arguments map[string]any) *Expectation {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.kind = responseToolCall
+	e.toolID = id
+	e.toolName = name
+	e.toolArgs = arguments
+	return e
+}
+
+// Respond with an error, in the error shape of the provider
+func (e *Expectation) RespondError(kind ErrorKind) *Expectation {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.kind = responseError
+	e.errorKind = kind
+	return e
+}
+
+// Respond with a raw status code and body
+func (e *Expectation) RespondRaw(status int, body string) *Expectation {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.kind = responseRaw
+	e.status = status
+	e.rawBody = body
+	return e
+}
+
+// Respond to an embeddings request with these vectors. When not set, deterministic vectors are generated per input.
+func (e *Expectation) RespondEmbeddings(vectors ...[]float32) *Expectation {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.kind = responseEmbeddings
+	e.vectors = vectors
+	return e
+}
+
+// Respond to a count tokens request with the number of tokens
+func (e *Expectation) RespondTokens(tokens int) *Expectation {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.kind = responseTokens
+	e.tokens = tokens
+	return e
+}
+
+// Checks whether the request satisfies the matchers, returning the reason when it does not
+func (e *Expectation) match(req *Request) error {
+	if req.Provider != e.provider {
+		return fmt.Errorf("expected a %s request, got a %s request", e.provider.ToString(), req.Provider.ToString())
+	}
+	if e.model != "" && req.Model != e.model {
+		return fmt.Errorf("expected model %q, got %q", e.model, req.Model)
+	}
+	for _, item := range e.contains {
+		if !strings.Contains(string(req.Body), item) {
+			return fmt.Errorf("expected the body to contain %q", item)
+		}
+	}
+	if e.lastContains != "" && !strings.Contains(req.LastMessage, e.lastContains) {
+		return fmt.Errorf("expected the last message to contain %q, got %q", e.lastContains, req.LastMessage)
+	}
+	return nil
+}
+
+func (e *Expectation) String() string {
+	resp := e.provider.ToString()
+	if e.model != "" {
+		resp += " " + e.model
+	}
+	return resp
+}
@@ -0,0 +1,334 @@
+package gollmtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"time"
+
+	"github.com/jake-landersweb/gollm/v2/src/ltypes"
+)
+
+// Size of the vectors generated when an embeddings expectation does not set any
+const default_embedding_dimensions = 8
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// Writes the canned response of the expectation in the wire format of the request
+func writeResponse(w http.ResponseWriter, req *Request, e *Expectation) {
+	switch e.kind {
+	case responseError:
+		writeError(w, req.Provider, e.errorKind)
+		return
+	case responseRaw:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(e.status)
+		w.Write([]byte(e.rawBody))
+		return
+	}
+
+	switch req.Provider {
+	case ProviderOpenAI:
+		writeJSON(w, http.StatusOK, openAIResponse(req, e))
+	case ProviderGemini:
+		writeJSON(w, http.StatusOK, geminiResponse(req, e))
+	case ProviderAnthropic:
+		writeJSON(w, http.StatusOK, anthropicResponse(req, e))
+	case ProviderOpenAIEmbeddings:
+		writeJSON(w, http.StatusOK, openAIEmbeddingsResponse(req, e))
+	case ProviderGeminiCountTokens:
+		tokens := e.tokens
+		if tokens == 0 {
+			tokens = approximateTokens(req.LastMessage)
+		}
+		writeJSON(w, http.StatusOK, map[string]int{"totalTokens": tokens})
+	}
+}
+
+// Roughly four characters per token, which is close enough for usage reporting
+func approximateTokens(input string) int {
+	return len(input)/4 + 1
+}
+
+// Usage reported for the expectation, falling back to an approximation from the request and response text
+func (e *Expectation) usage(req *Request) (int, int) {
+	input := e.inputTokens
+	if input == 0 {
+		input = approximateTokens(string(req.Body))
+	}
+	output := e.outputTokens
+	if output == 0 {
+		output = approximateTokens(e.text)
+	}
+	return input, output
+}
+
+func (e *Expectation) toolArguments() string {
+	args := e.toolArgs
+	if args == nil {
+		args = map[string]any{}
+	}
+	enc, _ := json.Marshal(args)
+	return string(enc)
+}
+
+func openAIResponse(req *Request, e *Expectation) *ltypes.GPTCompletionResponse {
+	input, output := e.usage(req)
+	message := ltypes.GPTCompletionMessage{
+		Role: "assistant",
+	}
+	finishReason := "stop"
+	if e.kind == responseToolCall {
+		id := e.toolID
+		if id == "" {
+			id = fmt.Sprintf("call_gollmtest%d", e.received)
+		}
+		message.ToolCalls = []*ltypes.GPTCompletionToolCall{
+			{
+				ID:   id,
+				Type: "function",
+				Function: &ltypes.GPTToolCallFunction{
+					Name:      e.toolName,
+					Arguments: e.toolArguments(),
+				},
+			},
+		}
+		finishReason = "tool_calls"
+	} else {
+		message.Content = e.text
+	}
+	if e.stopReason != "" {
+		finishReason = e.stopReason
+	}
+
+	return &ltypes.GPTCompletionResponse{
+		ID:      fmt.Sprintf("chatcmpl-gollmtest%d", e.received),
+		Object:  "chat.completion",
+		Created: int(time.Now().Unix()),
+		Model:   req.Model,
+		Choices: []ltypes.GPTChoice{
+			{
+				Index:        0,
+				Message:      message,
+				FinishReason: finishReason,
+			},
+		},
+		Usage: ltypes.GPTUsage{
+			PromptTokens:     input,
+			CompletionTokens: output,
+			TotalTokens:      input + output,
+		},
+	}
+}
+
+func geminiResponse(req *Request, e *Expectation) *ltypes.GemCompletionResponse {
+	input, output := e.usage(req)
+	part := ltypes.GemPart{}
+	if e.kind == responseToolCall {
+		args := e.toolArgs
+		if args == nil {
+			args = map[string]any{}
+		}
+		part.FunctionCall = &ltypes.GemFunctionCall{
+			Name: e.toolName,
+			Args: args,
+		}
+	} else {
+		part.Text = e.text
+	}
+	finishReason := "STOP"
+	if e.stopReason != "" {
+		finishReason = e.stopReason
+	}
+
+	return &ltypes.GemCompletionResponse{
+		Candidates: []ltypes.GemCandidate{
+			{
+				Content: ltypes.GemContent{
+					Parts: []ltypes.GemPart{part},
+					Role:  "model",
+				},
+				FinishReason: finishReason,
+			},
+		},
+		UsageMetadata: &ltypes.GemUsageMetadata{
+			PromptTokenCount:     input,
+			CandidatesTokenCount: output,
+			TotalTokenCount:      input + output,
+		},
+	}
+}
+
+func anthropicResponse(req *Request, e *Expectation) *ltypes.AnthropicResponse {
+	input, output := e.usage(req)
+	var content []*ltypes.AnthropicContent
+	stopReason := "end_turn"
+	if e.kind == responseToolCall {
+		id := e.toolID
+		if id == "" {
+			id = fmt.Sprintf("toolu_gollmtest%d", e.received)
+		}
+		args := e.toolArgs
+		if args == nil {
+			args = map[string]any{}
+		}
+		// anthropic always sends a text block before the tool use
+		content = []*ltypes.AnthropicContent{
+			{Type: "text", Text: e.text},
+			{Type: "tool_use", ID: id, Name: e.toolName, Input: args},
+		}
+		stopReason = "tool_use"
+	} else {
+		content = []*ltypes.AnthropicContent{
+			{Type: "text", Text: fmt.Sprintf("<response>%s</response>", e.text)},
+		}
+	}
+	if e.stopReason != "" {
+		stopReason = e.stopReason
+	}
+
+	return &ltypes.AnthropicResponse{
+		ID:         fmt.Sprintf("msg_gollmtest%d", e.received),
+		Type:       "message",
+		Role:       "assistant",
+		Content:    content,
+		Model:      req.Model,
+		StopReason: stopReason,
+		Usage: &ltypes.AnthropicUsage{
+			InputTokens:  input,
+			OutputTokens: output,
+		},
+	}
+}
+
+func openAIEmbeddingsResponse(req *Request, e *Expectation) *ltypes.OpenAIEmbeddingResponse {
+	input := req.OpenAIEmbeddings().Input
+	data := make([]ltypes.OpenAIEmbeddingData, len(input))
+	tokens := 0
+	for i, item := range input {
+		var vector []float32
+		if i < len(e.vectors) {
+			vector = e.vectors[i]
+		} else {
+			vector = deterministicVector(item, default_embedding_dimensions)
+		}
+		data[i] = ltypes.OpenAIEmbeddingData{
+			Object:    "embedding",
+			Embedding: vector,
+			Index:     i,
+		}
+		tokens += approximateTokens(item)
+	}
+	if e.inputTokens != 0 {
+		tokens = e.inputTokens
+	}
+
+	return &ltypes.OpenAIEmbeddingResponse{
+		Object: "list",
+		Data:   data,
+		Model:  req.Model,
+		Usage: ltypes.GPTUsage{
+			PromptTokens: tokens,
+			TotalTokens:  tokens,
+		},
+	}
+}
+
+// Generates a stable vector from the input, so equal inputs always embed the same way
+func deterministicVector(input string, dimensions int) []float32 {
+	vector := make([]float32, dimensions)
+	for i := range vector {
+		h := fnv.New32a()
+		fmt.Fprintf(h, "%d:%s", i, input)
+		vector[i] = float32(float64(h.Sum32())/float64(^uint32(0))*2 - 1)
+	}
+	return vector
+}
+
+// Writes an error in the error shape of the provider
+func writeError(w http.ResponseWriter, provider Provider, kind ErrorKind) {
+	switch provider {
+	case ProviderGemini, ProviderGeminiCountTokens:
+		status, code := geminiError(kind)
+		writeJSON(w, code, map[string]any{
+			"error": &ltypes.GemError{
+				Code:    code,
+				Message: fmt.Sprintf("gollmtest: %s", status),
+				Status:  status,
+			},
+		})
+	case ProviderAnthropic:
+		errorType, code := anthropicError(kind)
+		writeJSON(w, code, map[string]any{
+			"type": "error",
+			"error": &ltypes.AnthropicError{
+				Type:    errorType,
+				Message: fmt.Sprintf("gollmtest: %s", errorType),
+			},
+		})
+	default:
+		errorType, code := openAIError(kind)
+		writeJSON(w, code, map[string]any{
+			"error": &ltypes.GPTError{
+				Message: fmt.Sprintf("gollmtest: %s", errorType),
+				Type:    errorType,
+			},
+		})
+	}
+}
+
+func openAIError(kind ErrorKind) (ltypes.GPT_ERROR_TYPE, int) {
+	switch kind {
+	case ErrorRateLimit:
+		return ltypes.GPT_ERROR_RATE_LIMIT, http.StatusTooManyRequests
+	case ErrorOverloaded:
+		return ltypes.GPT_ERROR_SERVER, http.StatusServiceUnavailable
+	case ErrorAuthentication:
+		return ltypes.GPT_ERROR_AUTH, http.StatusUnauthorized
+	case ErrorInvalidRequest:
+		return ltypes.GPT_ERROR_INVALID, http.StatusBadRequest
+	case ErrorNotFound:
+		return ltypes.GPT_ERROR_NOT_FOUND, http.StatusNotFound
+	default:
+		return ltypes.GPT_ERROR_SERVER, http.StatusInternalServerError
+	}
+}
+
+func anthropicError(kind ErrorKind) (ltypes.AnthropicErrorType, int) {
+	switch kind {
+	case ErrorRateLimit:
+		return ltypes.ANTHROPIC_RATE_LIMIT_ERROR, http.StatusTooManyRequests
+	case ErrorOverloaded:
+		return ltypes.ANTHROPIC_OVERLOADED_ERROR, 529
+	case ErrorAuthentication:
+		return ltypes.ANTHROPIC_AUTHENTICATION_ERROR, http.StatusUnauthorized
+	case ErrorInvalidRequest:
+		return ltypes.ANTHROPIC_INVALID_REQUEST_ERROR, http.StatusBadRequest
+	case ErrorNotFound:
+		return ltypes.ANTHROPIC_NOT_FOUND_ERROR, http.StatusNotFound
+	default:
+		return ltypes.ANTHROPIC_API_ERROR, http.StatusInternalServerError
+	}
+}
+
+func geminiError(kind ErrorKind) (ltypes.GemErrorStatus, int) {
+	switch kind {
+	case ErrorRateLimit:
+		return ltypes.GEM_ERROR_RESOURCE_EXHAUSTED, http.StatusTooManyRequests
+	case ErrorOverloaded:
+		return ltypes.GEM_ERROR_UNAVAILABLE, http.StatusServiceUnavailable
+	case ErrorAuthentication:
+		return ltypes.GEM_ERROR_PERMISSION_DENIED, http.StatusForbidden
+	case ErrorInvalidRequest:
+		return ltypes.GEM_ERROR_INVALID_ARGUMENT, http.StatusBadRequest
+	case ErrorNotFound:
+		return ltypes.GEM_ERROR_NOT_FOUND, http.StatusNotFound
+	default:
+		return ltypes.GEM_ERROR_INTERNAL, http.StatusInternalServerError
+	}
+}
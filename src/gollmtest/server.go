@@ -0,0 +1,362 @@
+package gollmtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jake-landersweb/gollm/v2/src/gollm"
+	"github.com/jake-landersweb/gollm/v2/src/ltypes"
+)
+
+// Api key that the helpers configure, and that the server expects
+const TestApiKey = "gollmtest-api-key"
+
+// Paths that the server serves each wire format under
+const (
+	openai_chat_path       = "/openai/v1/chat/completions"
+	openai_embeddings_path = "/openai/v1/embeddings"
+	gemini_models_path     = "/gemini/v1beta/models/"
+	anthropic_path         = "/anthropic/v1/messages"
+)
+
+// A request received by the server
+type Request struct {
+	Provider    Provider
+	Model       string
+	Path        string
+	Header      http.Header
+	Body        []byte
+	LastMessage string // text of the last message in the conversation, if any
+}
+
+// Decodes the body as an OpenAI chat completion request. A body that is not valid json decodes as empty
+func (r *Request) OpenAI() *ltypes.GPTCompletionRequest {
+	var req ltypes.GPTCompletionRequest
+	json.Unmarshal(r.Body, &req)
+	return &req
+}
+
+// Decodes the body as a Gemini generate content request. A body that is not valid json decodes as empty
+func (r *Request) Gemini() *ltypes.GemRequestBody {
+	var req ltypes.GemRequestBody
+	json.Unmarshal(r.Body, &req)
+	return &req
+}
+
+// Decodes the body as an Anthropic messages request. A body that is not valid json decodes as empty
+func (r *Request) Anthropic() *ltypes.AnthropicRequest {
+	var req ltypes.AnthropicRequest
+	json.Unmarshal(r.Body, &req)
+	return &req
+}
+
+// Decodes the body as an OpenAI embeddings request. A body that is not valid json decodes as empty
+func (r *Request) OpenAIEmbeddings() *ltypes.OpenAIEmbeddingRequest {
+	var req ltypes.OpenAIEmbeddingRequest
+	json.Unmarshal(r.Body, &req)
+	return &req
+}
+
+/*
+An in-process server that speaks the wire formats of the OpenAI chat completions and
+embeddings apis, the Gemini `generateContent` and `countTokens` apis, and the Anthropic
+messages api. Responses are scripted through the `Expect` methods, and are served in the
+order they were added.
+
+Handlers run on the goroutines of the http server, so problems with the received requests are
+collected and reported on the test when it finishes, or through `AssertNoFailures`.
+
+	server := gollmtest.NewServer(t)
+	server.ExpectOpenAI().WithModel("gpt-4o").RespondText("Ahoy!")
+	llm := gollm.NewLanguageModel("user", logger, server.LanguageModelArgs())
+*/
+type Server struct {
+	server *httptest.Server
+
+	mu           sync.Mutex
+	expectations []*Expectation
+	requests     []*Request
+	failures     []string
+}
+
+// Starts a new server. The server is closed and all expectations are asserted when the test finishes.
+func NewServer(t testing.TB) *Server {
+	s := &Server{
+		expectations: make([]*Expectation, 0),
+		requests:     make([]*Request, 0),
+		failures:     make([]string, 0),
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(s.handle))
+	t.Cleanup(func() {
+		s.server.Close()
+		s.AssertNoFailures(t)
+		s.AssertExpectationsMet(t)
+	})
+	return s
+}
+
+// Base url of the server
+func (s *Server) URL() string {
+	return s.server.URL
+}
+
+// Http client that can reach the server
+func (s *Server) Client() *http.Client {
+	return s.server.Client()
+}
+
+// Configuration for a language model that sends every provider to the server
+func (s *Server) LanguageModelArgs() *gollm.NewLanguageModelArgs {
+	return &gollm.NewLanguageModelArgs{
+		GptBaseUrl:       s.URL() + openai_chat_path,
+		OpenAIApiKey:     TestApiKey,
+		GeminiBaseUrl:    strings.TrimSuffix(s.URL()+gemini_models_path, "/"),
+		GeminiApiKey:     TestApiKey,
+		AnthropicBaseUrl: s.URL() + anthropic_path,
+		AnthropicApiKey:  TestApiKey,
+		HttpClient:       s.Client(),
+	}
+}
+
+// Configuration for OpenAI embeddings that are sent to the server
+func (s *Server) OpenAIEmbeddingsOpts() *gollm.OpenAIEmbeddingsOpts {
+	return &gollm.OpenAIEmbeddingsOpts{
+		BaseUrl:      s.URL() + openai_embeddings_path,
+		OpenAIApiKey: TestApiKey,
+		HttpClient:   s.Client(),
+	}
+}
+
+func (s *Server) expect(provider Provider) *Expectation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := newExpectation(&s.mu, provider)
+	s.expectations = append(s.expectations, e)
+	return e
+}
+
+// Expect an OpenAI chat completions request
+func (s *Server) ExpectOpenAI() *Expectation {
+	return s.expect(ProviderOpenAI)
+}
+
+// Expect a Gemini generate content request
+func (s *Server) ExpectGemini() *Expectation {
+	return s.expect(ProviderGemini)
+}
+
+// Expect a Gemini count tokens request
+func (s *Server) ExpectGeminiCountTokens() *Expectation {
+	return s.expect(ProviderGeminiCountTokens).RespondTokens(0)
+}
+
+// Expect an Anthropic messages request
+func (s *Server) ExpectAnthropic() *Expectation {
+	return s.expect(ProviderAnthropic)
+}
+
+// Expect an OpenAI embeddings request
+func (s *Server) ExpectOpenAIEmbeddings() *Expectation {
+	return s.expect(ProviderOpenAIEmbeddings).RespondEmbeddings()
+}
+
+// All requests received so far
+func (s *Server) Requests() []*Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resp := make([]*Request, len(s.requests))
+	copy(resp, s.requests)
+	return resp
+}
+
+// The last request received, or nil if there were none
+func (s *Server) LastRequest() *Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.requests) == 0 {
+		return nil
+	}
+	return s.requests[len(s.requests)-1]
+}
+
+// Fails the test if any expectation was not fully consumed
+func (s *Server) AssertExpectationsMet(t testing.TB) {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.expectations {
+		if e.received < e.times {
+			t.Errorf("gollmtest: expected %d %s request(s), received %d", e.times, e.String(), e.received)
+		}
+	}
+}
+
+/*
+Fails the test with every problem the server found while handling requests, such as unscripted
+requests, requests that did not match the next expectation, or bodies that were not valid json.
+The problems are cleared once they are reported.
+*/
+func (s *Server) AssertNoFailures(t testing.TB) {
+	t.Helper()
+	s.mu.Lock()
+	failures := s.failures
+	s.failures = make([]string, 0)
+	s.mu.Unlock()
+	for _, item := range failures {
+		t.Errorf("gollmtest: %s", item)
+	}
+}
+
+// Records a problem with a request. Must be called with the lock held
+func (s *Server) failLocked(format string, args ...any) {
+	s.failures = append(s.failures, fmt.Sprintf(format, args...))
+}
+
+// Fails the test if the server did not receive exactly `n` requests
+func (s *Server) AssertRequestCount(t testing.TB, n int) {
+	t.Helper()
+	if count := len(s.Requests()); count != n {
+		t.Errorf("gollmtest: expected %d request(s), received %d", n, count)
+	}
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	req, err := parseRequest(r, body)
+	if err != nil {
+		s.failLocked("could not parse the request to %s %s: %v", r.Method, r.URL.Path, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req == nil {
+		s.failLocked("unexpected request to %s %s", r.Method, r.URL.Path)
+		http.Error(w, "unknown path", http.StatusNotFound)
+		return
+	}
+
+	if !authorized(req) {
+		writeError(w, req.Provider, ErrorAuthentication)
+		return
+	}
+
+	s.requests = append(s.requests, req)
+	var expectation *Expectation
+	for _, e := range s.expectations {
+		if e.received < e.times {
+			expectation = e
+			break
+		}
+	}
+	if expectation == nil {
+		s.failLocked("received an unscripted %s request", req.Provider.ToString())
+		writeError(w, req.Provider, ErrorServer)
+		return
+	}
+	if err := expectation.match(req); err != nil {
+		s.failLocked("request did not match the next expectation (%s): %v", expectation.String(), err)
+		writeError(w, req.Provider, ErrorInvalidRequest)
+		return
+	}
+	expectation.received++
+
+	writeResponse(w, req, expectation)
+}
+
+// Checks that the request carries the test api key in the header of its provider
+func authorized(req *Request) bool {
+	switch req.Provider {
+	case ProviderOpenAI, ProviderOpenAIEmbeddings:
+		return req.Header.Get("Authorization") == "Bearer "+TestApiKey
+	case ProviderGemini, ProviderGeminiCountTokens:
+		return req.Header.Get("x-goog-api-key") == TestApiKey
+	case ProviderAnthropic:
+		return req.Header.Get("x-api-key") == TestApiKey
+	default:
+		return false
+	}
+}
+
+/*
+Parses the provider, model and last message from the request. Returns nil for unknown paths,
+and an error when the body is not valid json for the wire format of the path.
+*/
+func parseRequest(r *http.Request, body []byte) (*Request, error) {
+	req := &Request{
+		Path:   r.URL.Path,
+		Header: r.Header.Clone(),
+		Body:   body,
+	}
+
+	switch {
+	case r.URL.Path == openai_chat_path:
+		req.Provider = ProviderOpenAI
+		var parsed ltypes.GPTCompletionRequest
+		if err := json.Unmarshal(body, &parsed); err != nil {
+			return nil, err
+		}
+		req.Model = parsed.Model
+		if len(parsed.Messages) > 0 {
+			req.LastMessage = parsed.Messages[len(parsed.Messages)-1].Content
+		}
+	case r.URL.Path == openai_embeddings_path:
+		req.Provider = ProviderOpenAIEmbeddings
+		var parsed ltypes.OpenAIEmbeddingRequest
+		if err := json.Unmarshal(body, &parsed); err != nil {
+			return nil, err
+		}
+		req.Model = parsed.Model
+		if len(parsed.Input) > 0 {
+			req.LastMessage = parsed.Input[len(parsed.Input)-1]
+		}
+	case r.URL.Path == anthropic_path:
+		req.Provider = ProviderAnthropic
+		var parsed ltypes.AnthropicRequest
+		if err := json.Unmarshal(body, &parsed); err != nil {
+			return nil, err
+		}
+		req.Model = parsed.Model
+		if len(parsed.Messages) > 0 {
+			for _, item := range parsed.Messages[len(parsed.Messages)-1].Content {
+				req.LastMessage += item.Text + item.Content
+			}
+		}
+	case strings.HasPrefix(r.URL.Path, gemini_models_path):
+		model, method, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, gemini_models_path), ":")
+		req.Model = model
+		switch method {
+		case "generateContent":
+			req.Provider = ProviderGemini
+		case "countTokens":
+			req.Provider = ProviderGeminiCountTokens
+		default:
+			return nil, nil
+		}
+		var parsed ltypes.GemRequestBody
+		if err := json.Unmarshal(body, &parsed); err != nil {
+			return nil, err
+		}
+		if len(parsed.Contents) > 0 {
+			for _, part := range parsed.Contents[len(parsed.Contents)-1].Parts {
+				req.LastMessage += part.Text
+			}
+		}
+	default:
+		return nil, nil
+	}
+
+	return req, nil
+}
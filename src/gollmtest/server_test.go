@@ -0,0 +1,203 @@
+package gollmtest
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jake-landersweb/gollm/v2/src/gollm"
+	"github.com/jake-landersweb/gollm/v2/src/ltypes"
+	"github.com/stretchr/testify/require"
+)
+
+var weatherTool = &gollm.Tool{
+	Title:       "get_weather",
+	Description: "Get the weather for a city",
+	Schema: &ltypes.ToolSchema{
+		Type: "object",
+		Properties: map[string]*ltypes.ToolSchema{
+			"city": {Type: "string"},
+		},
+	},
+}
+
+func TestServerTextCompletion(t *testing.T) {
+	tests := []struct {
+		model  string
+		expect func(s *Server) *Expectation
+	}{
+		{"gpt-4o-mini", (*Server).ExpectOpenAI},
+		{"gemini-1.5-flash", (*Server).ExpectGemini},
+		{"claude-3-haiku-20240307", (*Server).ExpectAnthropic},
+	}
+
+	for _, test := range tests {
+		t.Run(test.model, func(t *testing.T) {
+			server := NewServer(t)
+			test.expect(server).WithModel(test.model).WithLastMessageContaining("pirate").WithUsage(12, 3).RespondText("Ahoy matey!")
+
+			llm := gollm.NewLanguageModel("test-user", nil, server.LanguageModelArgs())
+			response, err := llm.Completion(context.TODO(), &gollm.CompletionInput{
+				Model:        test.model,
+				Conversation: append(gollm.NewConversation("You are a helpful assistant."), gollm.NewUserMessage("Talk like a pirate.")),
+			})
+			require.NoError(t, err)
+			require.Equal(t, "Ahoy matey!", response.Message.Message)
+			require.Equal(t, 12, response.UsageRecord.InputTokens)
+			require.Equal(t, 3, response.UsageRecord.OutputTokens)
+			server.AssertRequestCount(t, 1)
+		})
+	}
+}
+
+func TestServerToolCall(t *testing.T) {
+	tests := []struct {
+		model  string
+		expect func(s *Server) *Expectation
+	}{
+		{"gpt-4o-mini", (*Server).ExpectOpenAI},
+		{"gemini-1.5-flash", (*Server).ExpectGemini},
+		{"claude-3-haiku-20240307", (*Server).ExpectAnthropic},
+	}
+
+	for _, test := range tests {
+		t.Run(test.model, func(t *testing.T) {
+			server := NewServer(t)
+			test.expect(server).WithBodyContaining("get_weather").RespondToolCall("", "get_weather", map[string]any{"city": "Paris"})
+
+			llm := gollm.NewLanguageModel("test-user", nil, server.LanguageModelArgs())
+			response, err := llm.Completion(context.TODO(), &gollm.CompletionInput{
+				Model:        test.model,
+				Conversation: append(gollm.NewConversation("You are a helpful assistant."), gollm.NewUserMessage("What is the weather in Paris?")),
+				Tools:        []*gollm.Tool{weatherTool},
+			})
+			require.NoError(t, err)
+			require.Equal(t, gollm.RoleToolCall, response.Message.Role)
+			require.Equal(t, "get_weather", response.Message.ToolName)
+			require.Equal(t, "Paris", response.Message.ToolArguments["city"])
+		})
+	}
+}
+
+func TestServerErrors(t *testing.T) {
+	server := NewServer(t)
+	server.ExpectAnthropic().RespondError(ErrorOverloaded)
+	server.ExpectAnthropic().RespondText("Recovered")
+	server.ExpectOpenAI().RespondError(ErrorAuthentication)
+
+	llm := gollm.NewLanguageModel("test-user", nil, server.LanguageModelArgs())
+
+	// overloaded errors are retried
+	response, err := llm.Completion(context.TODO(), &gollm.CompletionInput{
+		Model:        "claude-3-haiku-20240307",
+		Conversation: []*gollm.Message{gollm.NewUserMessage("Hello")},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "Recovered", response.Message.Message)
+
+	// authentication errors are not
+	_, err = llm.Completion(context.TODO(), &gollm.CompletionInput{
+		Model:        "gpt-4o-mini",
+		Conversation: []*gollm.Message{gollm.NewUserMessage("Hello")},
+	})
+	require.Error(t, err)
+	server.AssertRequestCount(t, 3)
+}
+
+func TestServerEmbeddings(t *testing.T) {
+	server := NewServer(t)
+	server.ExpectOpenAIEmbeddings().WithModel("text-embedding-3-small")
+	server.ExpectOpenAIEmbeddings().RespondEmbeddings([]float32{1, 0, 0})
+
+	opts := server.OpenAIEmbeddingsOpts()
+	opts.Model = "text-embedding-3-small"
+	embeddings := gollm.NewOpenAIEmbeddings("test-user", opts)
+
+	first, err := embeddings.Embed(context.TODO(), nil, &gollm.EmbedArgs{Input: "The quick brown fox"})
+	require.NoError(t, err)
+	require.Len(t, first.Embeddings, 1)
+	require.Len(t, first.Embeddings[0].Embedding, default_embedding_dimensions)
+
+	second, err := embeddings.Embed(context.TODO(), nil, &gollm.EmbedArgs{Input: "The quick brown fox"})
+	require.NoError(t, err)
+	require.Equal(t, []float32{1, 0, 0}, second.Embeddings[0].Embedding)
+}
+
+func TestServerGeminiCountTokens(t *testing.T) {
+	server := NewServer(t)
+	server.ExpectGeminiCountTokens().WithModel("gemini-1.5-flash").RespondTokens(42)
+
+	llm := gollm.NewLanguageModel("test-user", nil, server.LanguageModelArgs())
+	tokens, err := llm.TokenEstimate("gemini-1.5-flash", "How many tokens is this?")
+	require.NoError(t, err)
+	require.Equal(t, 42, tokens)
+	require.Equal(t, "How many tokens is this?", server.LastRequest().LastMessage)
+}
+
+func TestServerUnscriptedRequest(t *testing.T) {
+	server := NewServer(t)
+	server.ExpectOpenAI().WithModel("gpt-4o")
+
+	llm := gollm.NewLanguageModel("test-user", nil, server.LanguageModelArgs())
+	_, err := llm.Completion(context.TODO(), &gollm.CompletionInput{
+		Model:        "gpt-4o-mini",
+		Conversation: []*gollm.Message{gollm.NewUserMessage("Hello")},
+	})
+	require.Error(t, err)
+
+	// report the mismatch to a separate recorder so it does not fail this test
+	fake := &testing.T{}
+	server.AssertNoFailures(fake)
+	require.True(t, fake.Failed())
+
+	// satisfy the expectation so the cleanup assertion passes
+	_, err = llm.Completion(context.TODO(), &gollm.CompletionInput{
+		Model:        "gpt-4o",
+		Conversation: []*gollm.Message{gollm.NewUserMessage("Hello")},
+	})
+	require.NoError(t, err)
+}
+
+func TestServerInvalidBody(t *testing.T) {
+	server := NewServer(t)
+
+	req, err := http.NewRequest("POST", server.URL()+openai_chat_path, strings.NewReader("not json"))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+TestApiKey)
+	resp, err := server.Client().Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	require.Empty(t, server.Requests())
+
+	fake := &testing.T{}
+	server.AssertNoFailures(fake)
+	require.True(t, fake.Failed())
+}
+
+func TestServerConcurrentExpectations(t *testing.T) {
+	server := NewServer(t)
+	expectation := server.ExpectOpenAI().Times(10)
+
+	// the expectation can be changed while its requests are being served
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			expectation.WithUsage(5, 1).RespondText("Hello!")
+		}()
+		go func() {
+			defer wg.Done()
+			llm := gollm.NewLanguageModel("test-user", nil, server.LanguageModelArgs())
+			llm.Completion(context.TODO(), &gollm.CompletionInput{
+				Model:        "gpt-4o",
+				Conversation: []*gollm.Message{gollm.NewUserMessage("Hello")},
+			})
+		}()
+	}
+	wg.Wait()
+	server.AssertRequestCount(t, 10)
+}
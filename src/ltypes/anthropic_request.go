@@ -13,20 +13,33 @@ type AnthropicMetadata struct {
 
 // RequestConfig represents the configuration for a request to the model.
 type AnthropicRequest struct {
-	Model       string               `json:"model"`    // The model version, e.g., "claude-2.1"
-	Messages    []*AnthropicMessage  `json:"messages"` // Array of input messages
-	Tools       []*AnthropicTool     `json:"tools"`
-	ToolChoice  *AnthropicToolChoice `json:"tool_choice,omitempty"` // Force the model to select a tool
-	System      string               `json:"system,omitempty"`      // System prompt, if any
-	MaxTokens   int                  `json:"max_tokens"`            // Maximum number of tokens to generate
-	Metadata    *AnthropicMetadata   `json:"metadata,omitempty"`    // Metadata about the request
-	Stream      bool                 `json:"stream,omitempty"`      // Whether to stream the response
-	Temperature float64              `json:"temperature,omitempty"` // Randomness in response
-	TopP        float64              `json:"top_p,omitempty"`       // Nucleus sampling probability
-	TopK        int                  `json:"top_k,omitempty"`       // Sample from the top K options
+	Model       string                  `json:"model"`    // The model version, e.g., "claude-2.1"
+	Messages    []*AnthropicMessage     `json:"messages"` // Array of input messages
+	Tools       []*AnthropicTool        `json:"tools"`
+	ToolChoice  *AnthropicToolChoice    `json:"tool_choice,omitempty"` // Force the model to select a tool
+	System      []*AnthropicSystemBlock `json:"system,omitempty"`      // System prompt, if any
+	MaxTokens   int                     `json:"max_tokens"`            // Maximum number of tokens to generate
+	Metadata    *AnthropicMetadata      `json:"metadata,omitempty"`    // Metadata about the request
+	Stream      bool                    `json:"stream,omitempty"`      // Whether to stream the response
+	Temperature float64                 `json:"temperature,omitempty"` // Randomness in response
+	TopP        float64                 `json:"top_p,omitempty"`       // Nucleus sampling probability
+	TopK        int                     `json:"top_k,omitempty"`       // Sample from the top K options
 }
 
 type AnthropicToolChoice struct {
 	Type string `json:"type"`
 	Name string `json:"name"`
 }
+
+// Marks the end of a prefix of the request that Anthropic caches, so later requests that start
+// with the same prefix are billed for reading the cache instead of the full input
+type AnthropicCacheControl struct {
+	Type string `json:"type"` // "ephemeral"
+}
+
+// A text block of the system prompt
+type AnthropicSystemBlock struct {
+	Type         string                 `json:"type"` // "text"
+	Text         string                 `json:"text"`
+	CacheControl *AnthropicCacheControl `json:"cache_control,omitempty"`
+}
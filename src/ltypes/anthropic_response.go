@@ -29,12 +29,18 @@ type AnthropicContent struct {
 	ToolUseID string `json:"tool_use_id,omitempty"`
 	Content   string `json:"content,omitempty"`
 	IsError   bool   `json:"is_error,omitempty"`
+
+	// Caches the request up to and including this block
+	CacheControl *AnthropicCacheControl `json:"cache_control,omitempty"`
 }
 
 // Usage provides information on token usage for the request.
 type AnthropicUsage struct {
-	InputTokens  int `json:"input_tokens"`
+	InputTokens  int `json:"input_tokens"` // Input tokens that were neither written to nor read from the cache
 	OutputTokens int `json:"output_tokens"`
+
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"` // Input tokens written to the cache
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`     // Input tokens read from the cache
 }
 
 type AnthropicErrorType string
@@ -4,4 +4,7 @@ type AnthropicTool struct {
 	Name        string      `json:"name"`
 	Description string      `json:"description"`
 	InputSchema *ToolSchema `json:"input_schema"`
+
+	// Caches the request up to and including this tool
+	CacheControl *AnthropicCacheControl `json:"cache_control,omitempty"`
 }
@@ -0,0 +1,28 @@
+package ltypes
+
+// Error code Azure OpenAI returns when the prompt is blocked by the content filter
+const AZURE_ERROR_CONTENT_FILTER = "content_filter"
+
+// Finish reason returned when the completion is blocked by the content filter
+const AZURE_FINISH_REASON_CONTENT_FILTER = "content_filter"
+
+// Additional error details returned by Azure OpenAI
+type AzureInnerError struct {
+	// Such as `ResponsibleAIPolicyViolation`
+	Code string `json:"code"`
+
+	// The content filter results of the prompt, keyed by category, such as `hate` or `jailbreak`
+	ContentFilterResult map[string]*AzureContentFilterResult `json:"content_filter_result,omitempty"`
+}
+
+// Result of a single content filter category
+type AzureContentFilterResult struct {
+	// Whether the content was filtered in this category
+	Filtered bool `json:"filtered"`
+
+	// Severity of the content, one of `safe`, `low`, `medium` or `high`. Not set for detection categories
+	Severity string `json:"severity,omitempty"`
+
+	// Set by the detection categories, such as `jailbreak` and `protected_material_text`
+	Detected bool `json:"detected,omitempty"`
+}
@@ -0,0 +1,27 @@
+package ltypes
+
+// Request body of the Amazon Titan text embeddings models. Titan embeds a single input per request
+type BedrockTitanEmbeddingRequest struct {
+	InputText  string `json:"inputText"`
+	Dimensions int    `json:"dimensions,omitempty"` // v2 only. One of 256, 512 or 1024
+	Normalize  *bool  `json:"normalize,omitempty"`  // v2 only. Defaults to true
+}
+
+type BedrockTitanEmbeddingResponse struct {
+	Embedding           []float32 `json:"embedding"`
+	InputTextTokenCount int       `json:"inputTextTokenCount"`
+}
+
+// Request body of the Cohere embed models on Bedrock
+type BedrockCohereEmbeddingRequest struct {
+	Texts     []string `json:"texts"`
+	InputType string   `json:"input_type"`         // `search_document`, `search_query`, `classification` or `clustering`
+	Truncate  string   `json:"truncate,omitempty"` // `NONE`, `START` or `END`
+}
+
+type BedrockCohereEmbeddingResponse struct {
+	ID           string      `json:"id"`
+	Embeddings   [][]float32 `json:"embeddings"`
+	Texts        []string    `json:"texts"`
+	ResponseType string      `json:"response_type"`
+}
@@ -0,0 +1,75 @@
+package ltypes
+
+// Request body of the Bedrock Converse api. The model id is sent in the url
+type BedrockConverseRequest struct {
+	Messages        []*BedrockMessage       `json:"messages"`                  // The messages of the conversation. Must start with a user message and alternate roles
+	System          []*BedrockContentBlock  `json:"system,omitempty"`          // System prompts, as text blocks
+	InferenceConfig *BedrockInferenceConfig `json:"inferenceConfig,omitempty"` // Inference parameters shared by all models
+	ToolConfig      *BedrockToolConfig      `json:"toolConfig,omitempty"`      // Tools the model may use. Required when the messages contain tool blocks
+}
+
+type BedrockMessage struct {
+	Role    string                 `json:"role"` // Either `user` or `assistant`
+	Content []*BedrockContentBlock `json:"content"`
+}
+
+// A content block holds exactly one of its fields
+type BedrockContentBlock struct {
+	Text       string             `json:"text,omitempty"`
+	ToolUse    *BedrockToolUse    `json:"toolUse,omitempty"`
+	ToolResult *BedrockToolResult `json:"toolResult,omitempty"`
+}
+
+type BedrockToolUse struct {
+	ToolUseId string         `json:"toolUseId"`
+	Name      string         `json:"name"`
+	Input     map[string]any `json:"input"`
+}
+
+type BedrockToolResult struct {
+	ToolUseId string                      `json:"toolUseId"`
+	Content   []*BedrockToolResultContent `json:"content"`
+	Status    string                      `json:"status,omitempty"` // Either `success` or `error`
+}
+
+type BedrockToolResultContent struct {
+	Text string         `json:"text,omitempty"`
+	Json map[string]any `json:"json,omitempty"`
+}
+
+type BedrockInferenceConfig struct {
+	MaxTokens     int      `json:"maxTokens,omitempty"`
+	Temperature   float64  `json:"temperature"`
+	TopP          float64  `json:"topP,omitempty"`
+	StopSequences []string `json:"stopSequences,omitempty"`
+}
+
+type BedrockToolConfig struct {
+	Tools      []*BedrockTool     `json:"tools"`
+	ToolChoice *BedrockToolChoice `json:"toolChoice,omitempty"`
+}
+
+type BedrockTool struct {
+	ToolSpec *BedrockToolSpec `json:"toolSpec"`
+}
+
+type BedrockToolSpec struct {
+	Name        string                  `json:"name"`
+	Description string                  `json:"description,omitempty"`
+	InputSchema *BedrockToolInputSchema `json:"inputSchema"`
+}
+
+type BedrockToolInputSchema struct {
+	Json *ToolSchema `json:"json"`
+}
+
+// Exactly one of the fields is set. Only supported by Anthropic and Mistral models
+type BedrockToolChoice struct {
+	Auto *struct{}              `json:"auto,omitempty"`
+	Any  *struct{}              `json:"any,omitempty"`
+	Tool *BedrockToolChoiceTool `json:"tool,omitempty"`
+}
+
+type BedrockToolChoiceTool struct {
+	Name string `json:"name"`
+}
@@ -0,0 +1,41 @@
+package ltypes
+
+type BedrockConverseResponse struct {
+	Output     *BedrockOutput  `json:"output"`
+	StopReason string          `json:"stopReason"` // `end_turn`, `tool_use`, `max_tokens`, `stop_sequence`, `guardrail_intervened` or `content_filtered`
+	Usage      *BedrockUsage   `json:"usage"`
+	Metrics    *BedrockMetrics `json:"metrics"`
+
+	// Set when the request failed. Bedrock sends the type of the error in the `x-amzn-ErrorType` header
+	Message string `json:"message"`
+}
+
+type BedrockOutput struct {
+	Message *BedrockMessage `json:"message"`
+}
+
+type BedrockUsage struct {
+	InputTokens  int `json:"inputTokens"`
+	OutputTokens int `json:"outputTokens"`
+	TotalTokens  int `json:"totalTokens"`
+}
+
+type BedrockMetrics struct {
+	LatencyMs int `json:"latencyMs"`
+}
+
+type BedrockErrorType string
+
+const (
+	BEDROCK_ACCESS_DENIED_EXCEPTION       BedrockErrorType = "AccessDeniedException"
+	BEDROCK_UNRECOGNIZED_CLIENT_EXCEPTION BedrockErrorType = "UnrecognizedClientException"
+	BEDROCK_EXPIRED_TOKEN_EXCEPTION       BedrockErrorType = "ExpiredTokenException"
+	BEDROCK_VALIDATION_EXCEPTION          BedrockErrorType = "ValidationException"
+	BEDROCK_RESOURCE_NOT_FOUND_EXCEPTION  BedrockErrorType = "ResourceNotFoundException"
+	BEDROCK_THROTTLING_EXCEPTION          BedrockErrorType = "ThrottlingException"
+	BEDROCK_MODEL_NOT_READY_EXCEPTION     BedrockErrorType = "ModelNotReadyException"
+	BEDROCK_MODEL_TIMEOUT_EXCEPTION       BedrockErrorType = "ModelTimeoutException"
+	BEDROCK_MODEL_ERROR_EXCEPTION         BedrockErrorType = "ModelErrorException"
+	BEDROCK_SERVICE_UNAVAILABLE_EXCEPTION BedrockErrorType = "ServiceUnavailableException"
+	BEDROCK_INTERNAL_SERVER_EXCEPTION     BedrockErrorType = "InternalServerException"
+)
@@ -0,0 +1,33 @@
+package ltypes
+
+// How Cohere should optimize the vectors. Required by the v3 embed models
+type CohereInputType string
+
+const (
+	COHERE_INPUT_SEARCH_DOCUMENT CohereInputType = "search_document"
+	COHERE_INPUT_SEARCH_QUERY    CohereInputType = "search_query"
+	COHERE_INPUT_CLASSIFICATION  CohereInputType = "classification"
+	COHERE_INPUT_CLUSTERING      CohereInputType = "clustering"
+)
+
+// Request body of the Cohere v2 embed api
+type CohereEmbedRequest struct {
+	Model          string          `json:"model"`
+	Texts          []string        `json:"texts"`
+	InputType      CohereInputType `json:"input_type"`
+	EmbeddingTypes []EmbeddingType `json:"embedding_types"`
+	Truncate       string          `json:"truncate,omitempty"` // `NONE`, `START` or `END`. Defaults to `END`
+}
+
+type CohereEmbedResponse struct {
+	ID string `json:"id"`
+
+	// The vectors of every requested embedding type
+	Embeddings map[EmbeddingType][][]float32 `json:"embeddings"`
+	Texts      []string                      `json:"texts"`
+	Meta       *CohereEmbedMeta              `json:"meta"`
+}
+
+type CohereEmbedMeta struct {
+	BilledUnits *CohereUsageUnits `json:"billed_units"`
+}
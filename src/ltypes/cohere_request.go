@@ -0,0 +1,42 @@
+package ltypes
+
+// Request body of the Cohere v2 chat api
+type CohereChatRequest struct {
+	Model          string                `json:"model"`                     // ID of the model to use, such as `command-r-plus`
+	Messages       []*CohereMessage      `json:"messages"`                  // The conversation so far, including the system message
+	Tools          []*CohereTool         `json:"tools,omitempty"`           // A list of tools the model may call
+	ToolChoice     string                `json:"tool_choice,omitempty"`     // Either `REQUIRED` to force a tool call, or `NONE` to prevent one
+	ResponseFormat *CohereResponseFormat `json:"response_format,omitempty"` // Setting to `{"type": "json_object"}` enables JSON mode
+	Temperature    float64               `json:"temperature"`
+	MaxTokens      int                   `json:"max_tokens,omitempty"`
+	Seed           int                   `json:"seed,omitempty"`
+}
+
+type CohereMessage struct {
+	Role       string            `json:"role"`                   // One of `system`, `user`, `assistant` or `tool`
+	Content    string            `json:"content,omitempty"`      // Text of the message. Assistant messages with tool calls have no content
+	ToolPlan   string            `json:"tool_plan,omitempty"`    // The reasoning of the model before calling the tools
+	ToolCalls  []*CohereToolCall `json:"tool_calls,omitempty"`   // The tool calls generated by the model
+	ToolCallId string            `json:"tool_call_id,omitempty"` // Tool call that this message is responding to
+}
+
+type CohereToolCall struct {
+	ID       string                  `json:"id"`
+	Type     string                  `json:"type"`
+	Function *CohereToolCallFunction `json:"function"`
+}
+
+type CohereToolCallFunction struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"` // The arguments encoded as json
+}
+
+type CohereTool struct {
+	Type     string           `json:"type"`
+	Function *GPTToolFunction `json:"function"`
+}
+
+type CohereResponseFormat struct {
+	Type       string      `json:"type"`                  // Either `text` or `json_object`
+	JsonSchema *ToolSchema `json:"json_schema,omitempty"` // Optionally constrain the json to a schema
+}
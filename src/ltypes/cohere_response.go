@@ -0,0 +1,39 @@
+package ltypes
+
+// Response body of the Cohere v2 chat api
+type CohereChatResponse struct {
+	ID string `json:"id"`
+
+	// One of `COMPLETE`, `STOP_SEQUENCE`, `MAX_TOKENS`, `TOOL_CALL` or `ERROR`
+	FinishReason string                 `json:"finish_reason"`
+	Message      *CohereResponseMessage `json:"message"`
+	Usage        *CohereUsage           `json:"usage"`
+}
+
+type CohereResponseMessage struct {
+	Role      string            `json:"role"`
+	Content   []*CohereContent  `json:"content"`
+	ToolPlan  string            `json:"tool_plan"`
+	ToolCalls []*CohereToolCall `json:"tool_calls"`
+}
+
+type CohereContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type CohereUsage struct {
+	BilledUnits *CohereUsageUnits `json:"billed_units"` // The units that were billed, which exclude the tokens of the prompt template
+	Tokens      *CohereUsageUnits `json:"tokens"`       // The tokens that were processed by the model
+}
+
+type CohereUsageUnits struct {
+	InputTokens  float64 `json:"input_tokens"`
+	OutputTokens float64 `json:"output_tokens"`
+}
+
+// Returned with a non-200 status code
+type CohereError struct {
+	ID      string `json:"id"`
+	Message string `json:"message"`
+}
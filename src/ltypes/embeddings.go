@@ -1,8 +1,42 @@
 package ltypes
 
-import "github.com/pgvector/pgvector-go"
-
+// The embedding of a chunk of the input, along with where the chunk came from
 type EmbeddingsData struct {
-	Raw       string
-	Embedding pgvector.Vector
+	Raw string
+
+	// The position of the chunk in the input
+	Index int
+
+	// Byte offsets of the chunk in the input, when it was chunked by an `EmbedArgs.Chunker`.
+	// Both are 0 when the offsets are not known
+	Start int
+	End   int
+
+	// The heading path or declaration the chunk starts in, when the chunker sets one
+	Section string
+
+	// The tokens in the chunk, as counted by `EmbedArgs.Tokenizer`
+	Tokens int
+
+	// Identifies the document the input came from, copied from `EmbedArgs.SourceID`
+	SourceID string
+
+	// The vector of the chunk. The `vecformat` package converts it to the types of pgvector and
+	// other stores
+	Embedding []float32
 }
+
+/*
+The data type of the returned vectors, for the providers that support compressed embeddings.
+The values of integer and binary embeddings are returned as float32, and binary embeddings hold
+8 dimensions in every value.
+*/
+type EmbeddingType string
+
+const (
+	EMBEDDING_TYPE_FLOAT   EmbeddingType = "float"
+	EMBEDDING_TYPE_INT8    EmbeddingType = "int8"
+	EMBEDDING_TYPE_UINT8   EmbeddingType = "uint8"
+	EMBEDDING_TYPE_BINARY  EmbeddingType = "binary"  // 8 dimensions packed into every int8 value
+	EMBEDDING_TYPE_UBINARY EmbeddingType = "ubinary" // 8 dimensions packed into every uint8 value
+)
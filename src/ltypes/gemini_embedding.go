@@ -0,0 +1,35 @@
+package ltypes
+
+// The type of task the embeddings will be used for, which optimizes the vectors for that task
+type GemTaskType string
+
+const (
+	GEM_TASK_RETRIEVAL_QUERY     GemTaskType = "RETRIEVAL_QUERY"
+	GEM_TASK_RETRIEVAL_DOCUMENT  GemTaskType = "RETRIEVAL_DOCUMENT"
+	GEM_TASK_SEMANTIC_SIMILARITY GemTaskType = "SEMANTIC_SIMILARITY"
+	GEM_TASK_CLASSIFICATION      GemTaskType = "CLASSIFICATION"
+	GEM_TASK_CLUSTERING          GemTaskType = "CLUSTERING"
+	GEM_TASK_QUESTION_ANSWERING  GemTaskType = "QUESTION_ANSWERING"
+	GEM_TASK_FACT_VERIFICATION   GemTaskType = "FACT_VERIFICATION"
+)
+
+type GemBatchEmbedRequest struct {
+	Requests []*GemEmbedContentRequest `json:"requests"` // At most 100 requests per batch
+}
+
+type GemEmbedContentRequest struct {
+	Model                string      `json:"model"`                          // Must match the model of the url, in the form `models/{model}`
+	Content              *GemContent `json:"content"`                        // The content to embed. Only the text parts are counted
+	TaskType             GemTaskType `json:"taskType,omitempty"`             // Optional. The task the embeddings will be used for
+	Title                string      `json:"title,omitempty"`                // Optional. The title of the document. Only applicable to RETRIEVAL_DOCUMENT
+	OutputDimensionality int         `json:"outputDimensionality,omitempty"` // Optional. Truncates the embedding to this size
+}
+
+type GemBatchEmbedResponse struct {
+	Embeddings []*GemContentEmbedding `json:"embeddings"`
+	Error      *GemError              `json:"error"`
+}
+
+type GemContentEmbedding struct {
+	Values []float32 `json:"values"`
+}
@@ -11,6 +11,9 @@ type GemUsageMetadata struct {
 	CandidatesTokenCount int `json:"candidatesTokenCount"`
 	PromptTokenCount     int `json:"promptTokenCount"`
 	TotalTokenCount      int `json:"totalTokenCount"`
+
+	// Tokens of the prompt that were read from cached content. Included in `PromptTokenCount`
+	CachedContentTokenCount int `json:"cachedContentTokenCount,omitempty"`
 }
 
 type GemCandidate struct {
@@ -1,5 +1,7 @@
 package ltypes
 
+import "encoding/json"
+
 type GPTCompletionRequest struct {
 	Messages         []*GPTCompletionMessage `json:"messages"`                   // A list of messages comprising the conversation so far.
 	Model            string                  `json:"model"`                      // ID of the model to use.
@@ -18,6 +20,18 @@ type GPTCompletionRequest struct {
 	User             string                  `json:"user,omitempty"`             // A unique identifier representing your end-user, which can help OpenAI to monitor and detect abuse.
 }
 
+// Omits `response_format` when it has no type, as some OpenAI compatible providers reject the field
+func (r GPTCompletionRequest) MarshalJSON() ([]byte, error) {
+	type request GPTCompletionRequest
+	if r.ResponseFormat.Type != "" {
+		return json.Marshal(request(r))
+	}
+	return json.Marshal(struct {
+		request
+		ResponseFormat *GPTRespFormat `json:"response_format,omitempty"`
+	}{request: request(r)})
+}
+
 type GPTToolChoice struct {
 	Type     string                 `json:"type"`
 	Function *GPTToolChoiceFunction `json:"function,omitempty"`
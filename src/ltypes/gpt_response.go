@@ -39,6 +39,9 @@ type GPTChoice struct {
 
 	// The reason the model stopped generating tokens. This will be stop if the model hit a natural stop point or a provided stop sequence, length if the maximum number of tokens specified in the request was reached, content_filter if content was omitted due to a flag from our content filters, tool_calls if the model called a tool, or function_call (deprecated) if the model called a function.
 	FinishReason string `json:"finish_reason"`
+
+	// Azure OpenAI only. The content filter results of the completion, keyed by category
+	ContentFilterResults map[string]*AzureContentFilterResult `json:"content_filter_results,omitempty"`
 }
 
 type GPTUsage struct {
@@ -50,6 +53,14 @@ type GPTUsage struct {
 
 	// Total number of tokens used in the request (prompt + completion).
 	TotalTokens int `json:"total_tokens"`
+
+	// Breakdown of the tokens in the prompt. Not returned by every compatible provider
+	PromptTokensDetails *GPTPromptTokensDetails `json:"prompt_tokens_details,omitempty"`
+}
+
+type GPTPromptTokensDetails struct {
+	// Tokens of the prompt that were read from the prompt cache. Included in `PromptTokens`
+	CachedTokens int `json:"cached_tokens"`
 }
 
 type GPT_ERROR_TYPE string
@@ -69,4 +80,7 @@ type GPTError struct {
 	Type    GPT_ERROR_TYPE `json:"type"`
 	Param   string         `json:"param"`
 	Code    string         `json:"code"`
+
+	// Azure OpenAI only. Holds the content filter results when the prompt was filtered
+	InnerError *AzureInnerError `json:"innererror,omitempty"`
 }
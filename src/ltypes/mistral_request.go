@@ -0,0 +1,51 @@
+package ltypes
+
+type MistralChatRequest struct {
+	Model          string                 `json:"model"`                     // ID of the model to use, such as `mistral-large-latest`
+	Messages       []*MistralMessage      `json:"messages"`                  // The conversation so far. The last message must be from the user or a tool
+	Temperature    float64                `json:"temperature"`               // What sampling temperature to use, between 0.0 and 1.0
+	MaxTokens      int                    `json:"max_tokens,omitempty"`      // The maximum number of tokens to generate
+	Tools          []*MistralTool         `json:"tools,omitempty"`           // A list of tools the model may call
+	ToolChoice     any                    `json:"tool_choice,omitempty"`     // One of `auto`, `none`, `any` or `required`, or a `MistralToolChoice` to force a specific tool
+	ResponseFormat *MistralResponseFormat `json:"response_format,omitempty"` // Setting to `{"type": "json_object"}` enables JSON mode
+	RandomSeed     int                    `json:"random_seed,omitempty"`     // The seed to use for random sampling
+	SafePrompt     bool                   `json:"safe_prompt,omitempty"`     // Whether to inject a safety prompt before all conversations
+}
+
+type MistralMessage struct {
+	Role       string             `json:"role"` // One of `system`, `user`, `assistant` or `tool`
+	Content    string             `json:"content"`
+	ToolCalls  []*MistralToolCall `json:"tool_calls,omitempty"`   // The tool calls generated by the model
+	ToolCallId string             `json:"tool_call_id,omitempty"` // Tool call that this message is responding to
+	Name       string             `json:"name,omitempty"`         // Name of the tool that this message is responding to
+}
+
+type MistralToolCall struct {
+	// The ID of the tool call. Mistral only accepts ids made of exactly 9 letters and digits
+	ID string `json:"id"`
+
+	// The type of the tool. Currently, only function is supported.
+	Type string `json:"type"`
+
+	Function *MistralToolCallFunction `json:"function"`
+}
+
+type MistralToolCallFunction struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"` // The arguments encoded as json
+}
+
+type MistralTool struct {
+	Type     string           `json:"type"`
+	Function *GPTToolFunction `json:"function"`
+}
+
+// Forces the model to call a specific tool
+type MistralToolChoice struct {
+	Type     string                 `json:"type"`
+	Function *GPTToolChoiceFunction `json:"function"`
+}
+
+type MistralResponseFormat struct {
+	Type string `json:"type"` // Either `text` or `json_object`
+}
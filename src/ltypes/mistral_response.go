@@ -0,0 +1,30 @@
+package ltypes
+
+import "encoding/json"
+
+type MistralChatResponse struct {
+	ID      string           `json:"id"`
+	Object  string           `json:"object"` // `chat.completion`, or `error` when the request failed
+	Model   string           `json:"model"`
+	Created int              `json:"created"`
+	Choices []*MistralChoice `json:"choices"`
+	Usage   *MistralUsage    `json:"usage"`
+
+	// Set when the request failed. The message is a string, or an object with the details of a validation error
+	Message json.RawMessage `json:"message"`
+	Type    string          `json:"type"`
+}
+
+type MistralChoice struct {
+	Index   int            `json:"index"`
+	Message MistralMessage `json:"message"`
+
+	// One of `stop`, `length`, `model_length`, `error` or `tool_calls`
+	FinishReason string `json:"finish_reason"`
+}
+
+type MistralUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
@@ -0,0 +1,16 @@
+package ltypes
+
+type OllamaEmbeddingRequest struct {
+	Model      string   `json:"model"`
+	Input      []string `json:"input"`
+	Truncate   *bool    `json:"truncate,omitempty"`   // Truncates the end of each input to fit within the context length. Defaults to true on the server
+	Dimensions int      `json:"dimensions,omitempty"` // Optional, only supported by some models
+	KeepAlive  string   `json:"keep_alive,omitempty"`
+}
+
+type OllamaEmbeddingResponse struct {
+	Model      string      `json:"model"`
+	Embeddings [][]float32 `json:"embeddings"`
+	OllamaUsage
+	Error string `json:"error"`
+}
@@ -0,0 +1,53 @@
+package ltypes
+
+type OllamaChatRequest struct {
+	Model     string           `json:"model"`                // Name of the model to use, such as `llama3.1`
+	Messages  []*OllamaMessage `json:"messages"`             // The messages of the chat, used to keep a chat memory
+	Tools     []*OllamaTool    `json:"tools,omitempty"`      // Tools the model may use, if supported by the model
+	Format    string           `json:"format,omitempty"`     // The format to return a response in. Currently the only accepted value is `json`
+	Options   *OllamaOptions   `json:"options,omitempty"`    // Additional model parameters, such as temperature
+	Stream    bool             `json:"stream"`               // If false, the response will be returned as a single response object. Defaults to true on the server, so it is always sent
+	KeepAlive string           `json:"keep_alive,omitempty"` // How long the model will stay loaded into memory following the request. Defaults to 5m
+}
+
+type OllamaMessage struct {
+	// The role of the message, either `system`, `user`, `assistant` or `tool`
+	Role string `json:"role"`
+
+	// The content of the message
+	Content string `json:"content"`
+
+	// A list of base64 encoded images, for multimodal models
+	Images []string `json:"images,omitempty"`
+
+	// The tools that the model wants to use
+	ToolCalls []*OllamaToolCall `json:"tool_calls,omitempty"`
+
+	// The name of the tool that a `tool` message is the result of
+	ToolName string `json:"tool_name,omitempty"`
+}
+
+type OllamaToolCall struct {
+	Function *OllamaToolCallFunction `json:"function"`
+}
+
+type OllamaToolCallFunction struct {
+	// The name of the function to call
+	Name string `json:"name"`
+
+	// The arguments to call the function with. Unlike OpenAI, these are sent as an object and not an encoded string
+	Arguments map[string]any `json:"arguments"`
+}
+
+// Ollama accepts tools in the same shape as OpenAI
+type OllamaTool struct {
+	Type     string           `json:"type"`
+	Function *GPTToolFunction `json:"function"`
+}
+
+type OllamaOptions struct {
+	Temperature float64 `json:"temperature"`           // The temperature of the model. Sent even when 0, as the server default is 0.8
+	NumPredict  int     `json:"num_predict,omitempty"` // Maximum number of tokens to predict
+	NumCtx      int     `json:"num_ctx,omitempty"`     // Size of the context window
+	Seed        int     `json:"seed,omitempty"`        // Random seed to use for generation
+}
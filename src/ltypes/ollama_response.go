@@ -0,0 +1,23 @@
+package ltypes
+
+type OllamaChatResponse struct {
+	Model      string        `json:"model"`
+	CreatedAt  string        `json:"created_at"`
+	Message    OllamaMessage `json:"message"`
+	Done       bool          `json:"done"`
+	DoneReason string        `json:"done_reason"` // `stop`, `length` or `load`
+	OllamaUsage
+
+	// Will be empty if no error exists. Ollama only sends a message, so the status code describes the type of error
+	Error string `json:"error"`
+}
+
+// Token counts and timings reported by Ollama. Durations are in nanoseconds
+type OllamaUsage struct {
+	TotalDuration      int64 `json:"total_duration"`
+	LoadDuration       int64 `json:"load_duration"`
+	PromptEvalCount    int   `json:"prompt_eval_count"` // Number of tokens in the prompt
+	PromptEvalDuration int64 `json:"prompt_eval_duration"`
+	EvalCount          int   `json:"eval_count"` // Number of tokens in the response
+	EvalDuration       int64 `json:"eval_duration"`
+}
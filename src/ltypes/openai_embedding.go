@@ -1,5 +1,13 @@
 package ltypes
 
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
 type OpenAIEmbeddingRequest struct {
 	Input          []string `json:"input" binding:"required"` // Can be string or []string
 	Model          string   `json:"model" binding:"required"`
@@ -17,7 +25,35 @@ type OpenAIEmbeddingResponse struct {
 }
 
 type OpenAIEmbeddingData struct {
-	Object    string    `json:"object"`
-	Embedding []float64 `json:"embedding"`
-	Index     int       `json:"index"`
+	Object    string                `json:"object"`
+	Embedding OpenAIEmbeddingVector `json:"embedding"`
+	Index     int                   `json:"index"`
+}
+
+// The values of an embedding, decoded from a list of numbers or from the base64 string of little
+// endian float32 values that is returned when the request has the `base64` encoding format
+type OpenAIEmbeddingVector []float32
+
+func (v *OpenAIEmbeddingVector) UnmarshalJSON(data []byte) error {
+	if len(data) == 0 || data[0] != '"' {
+		return json.Unmarshal(data, (*[]float32)(v))
+	}
+
+	var encoded string
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return fmt.Errorf("invalid base64 embedding: %v", err)
+	}
+	if len(raw)%4 != 0 {
+		return fmt.Errorf("invalid base64 embedding: %d bytes is not a multiple of 4", len(raw))
+	}
+	vector := make([]float32, len(raw)/4)
+	for idx := range vector {
+		vector[idx] = math.Float32frombits(binary.LittleEndian.Uint32(raw[idx*4:]))
+	}
+	*v = vector
+	return nil
 }
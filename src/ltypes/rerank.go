@@ -0,0 +1,56 @@
+package ltypes
+
+// Services that host cross-encoder models behind a `/rerank` endpoint
+type RerankProvider string
+
+const (
+	RERANK_PROVIDER_COHERE RerankProvider = "cohere"
+	RERANK_PROVIDER_JINA   RerankProvider = "jina"
+	RERANK_PROVIDER_VOYAGE RerankProvider = "voyage"
+)
+
+// Request body of the `/rerank` endpoints of Cohere, Jina and Voyage, which only differ in how
+// the number of results is named
+type RerankRequest struct {
+	Model     string   `json:"model"`
+	Query     string   `json:"query"`
+	Documents []string `json:"documents"`
+
+	// The number of results to return for Cohere and Jina
+	TopN int `json:"top_n,omitempty"`
+
+	// The number of results to return for Voyage
+	TopK int `json:"top_k,omitempty"`
+
+	// Jina returns the documents unless this is false
+	ReturnDocuments *bool `json:"return_documents,omitempty"`
+}
+
+type RerankResult struct {
+	Index          int     `json:"index"`
+	RelevanceScore float64 `json:"relevance_score"`
+}
+
+type RerankUsage struct {
+	TotalTokens int `json:"total_tokens"`
+}
+
+type RerankResponse struct {
+	ID    string `json:"id,omitempty"`
+	Model string `json:"model,omitempty"`
+
+	// The results of Cohere and Jina
+	Results []RerankResult `json:"results,omitempty"`
+
+	// The results of Voyage
+	Data []RerankResult `json:"data,omitempty"`
+
+	// The tokens used by Jina and Voyage. Cohere bills reranking by search units instead of tokens
+	Usage *RerankUsage `json:"usage,omitempty"`
+
+	// The error of Cohere, returned with a non-200 status code
+	Message string `json:"message,omitempty"`
+
+	// The error of Jina and Voyage, returned with a non-200 status code
+	Detail string `json:"detail,omitempty"`
+}
@@ -0,0 +1,38 @@
+package ltypes
+
+// How Voyage should optimize the vectors. When not set, the text is embedded as is
+type VoyageInputType string
+
+const (
+	VOYAGE_INPUT_QUERY    VoyageInputType = "query"
+	VOYAGE_INPUT_DOCUMENT VoyageInputType = "document"
+)
+
+type VoyageEmbeddingRequest struct {
+	Input           []string        `json:"input"`
+	Model           string          `json:"model"`
+	InputType       VoyageInputType `json:"input_type,omitempty"`
+	Truncation      *bool           `json:"truncation,omitempty"`       // Defaults to true. When false, inputs over the context length are an error
+	OutputDimension int             `json:"output_dimension,omitempty"` // Only supported by some models, such as `voyage-3-large`
+	OutputDtype     EmbeddingType   `json:"output_dtype,omitempty"`     // Defaults to `float`
+}
+
+type VoyageEmbeddingResponse struct {
+	Object string                `json:"object"`
+	Data   []VoyageEmbeddingData `json:"data"`
+	Model  string                `json:"model"`
+	Usage  *VoyageUsage          `json:"usage"`
+
+	// Returned with a non-200 status code
+	Detail string `json:"detail"`
+}
+
+type VoyageEmbeddingData struct {
+	Object    string    `json:"object"`
+	Embedding []float32 `json:"embedding"`
+	Index     int       `json:"index"`
+}
+
+type VoyageUsage struct {
+	TotalTokens int `json:"total_tokens"`
+}
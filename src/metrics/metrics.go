@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/jake-landersweb/gollm/v2/src/tokens"
+)
+
+// Operations that are measured
+const (
+	OperationCompletion = "completion"
+	OperationEmbeddings = "embeddings"
+	OperationRerank     = "rerank"
+)
+
+// Outcomes of a measured request
+const (
+	StatusSuccess = "success"
+	StatusError   = "error"
+
+	// The response was read from a cache without sending a request. Only completions are cached
+	StatusCacheHit = "cache_hit"
+)
+
+/*
+Receives measurements from completions and embeddings. Implementations must be safe for
+concurrent use. Use `NewPrometheusMetrics` to export the measurements to Prometheus, or
+`NoopMetrics` to discard them.
+*/
+type Metrics interface {
+	// Called once a request finished, with the total latency including retries, or once a completion
+	// was read from a cache with `StatusCacheHit`
+	ObserveRequest(operation string, provider string, model string, status string, latency time.Duration)
+
+	// Called every time a provider returned an error that will be retried
+	ObserveRetry(operation string, provider string, model string, errorType string)
+
+	// Called with the token usage of a request that was billed, including the batches that succeeded
+	// before a partial failure, and the estimated cost in USD
+	ObserveUsage(operation string, provider string, record *tokens.UsageRecord, cost float64)
+
+	// Called with the number of chunks sent in a single embeddings call
+	ObserveEmbeddingChunks(provider string, model string, chunks int)
+}
+
+// Metrics implementation that discards all measurements
+type NoopMetrics struct{}
+
+func (NoopMetrics) ObserveRequest(string, string, string, string, time.Duration) {}
+func (NoopMetrics) ObserveRetry(string, string, string, string)                  {}
+func (NoopMetrics) ObserveUsage(string, string, *tokens.UsageRecord, float64)    {}
+func (NoopMetrics) ObserveEmbeddingChunks(string, string, int)                   {}
+
+// Returns `m`, or a `NoopMetrics` if `m` is nil
+func OrNoop(m Metrics) Metrics {
+	if m == nil {
+		return NoopMetrics{}
+	}
+	return m
+}
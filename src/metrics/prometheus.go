@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/jake-landersweb/gollm/v2/src/tokens"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics implementation backed by the Prometheus client
+type PrometheusMetrics struct {
+	requests        *prometheus.CounterVec
+	latency         *prometheus.HistogramVec
+	retries         *prometheus.CounterVec
+	tokens          *prometheus.CounterVec
+	cost            *prometheus.CounterVec
+	embeddingChunks *prometheus.HistogramVec
+}
+
+/*
+Creates the collectors and registers them with `registerer`. When `registerer` is nil,
+`prometheus.DefaultRegisterer` is used. All metric names are prefixed with `namespace`,
+which defaults to "gollm".
+*/
+func NewPrometheusMetrics(registerer prometheus.Registerer, namespace string) (*PrometheusMetrics, error) {
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+	if namespace == "" {
+		namespace = "gollm"
+	}
+
+	m := &PrometheusMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "requests_total",
+			Help:      "Number of completion and embeddings requests.",
+		}, []string{"operation", "provider", "model", "status"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "request_duration_seconds",
+			Help:      "Latency of completion and embeddings requests, including retries.",
+			Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 40, 80},
+		}, []string{"operation", "provider", "model"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "retries_total",
+			Help:      "Number of retried provider errors.",
+		}, []string{"operation", "provider", "model", "error_type"}),
+		tokens: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "tokens_total",
+			Help:      "Number of tokens used, by direction.",
+		}, []string{"operation", "provider", "model", "direction"}),
+		cost: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "estimated_cost_usd_total",
+			Help:      "Estimated cost of all requests in USD.",
+		}, []string{"operation", "provider", "model"}),
+		embeddingChunks: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "embedding_chunks",
+			Help:      "Number of chunks sent per embeddings call.",
+			Buckets:   prometheus.ExponentialBuckets(1, 2, 12),
+		}, []string{"provider", "model"}),
+	}
+
+	for _, collector := range []prometheus.Collector{m.requests, m.latency, m.retries, m.tokens, m.cost, m.embeddingChunks} {
+		if err := registerer.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+func (m *PrometheusMetrics) ObserveRequest(operation string, provider string, model string, status string, latency time.Duration) {
+	m.requests.WithLabelValues(operation, provider, model, status).Inc()
+	m.latency.WithLabelValues(operation, provider, model).Observe(latency.Seconds())
+}
+
+func (m *PrometheusMetrics) ObserveRetry(operation string, provider string, model string, errorType string) {
+	m.retries.WithLabelValues(operation, provider, model, errorType).Inc()
+}
+
+func (m *PrometheusMetrics) ObserveUsage(operation string, provider string, record *tokens.UsageRecord, cost float64) {
+	if record == nil {
+		return
+	}
+	m.tokens.WithLabelValues(operation, provider, record.Model, "input").Add(float64(record.InputTokens))
+	m.tokens.WithLabelValues(operation, provider, record.Model, "output").Add(float64(record.OutputTokens))
+	m.cost.WithLabelValues(operation, provider, record.Model).Add(cost)
+}
+
+func (m *PrometheusMetrics) ObserveEmbeddingChunks(provider string, model string, chunks int) {
+	m.embeddingChunks.WithLabelValues(provider, model).Observe(float64(chunks))
+}
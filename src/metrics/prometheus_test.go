@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jake-landersweb/gollm/v2/src/tokens"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrometheusMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	m, err := NewPrometheusMetrics(registry, "")
+	require.NoError(t, err)
+
+	m.ObserveRequest(OperationCompletion, "openai", "gpt-3.5-turbo", StatusSuccess, 2*time.Second)
+	m.ObserveRequest(OperationCompletion, "openai", "gpt-3.5-turbo", StatusError, time.Second)
+	m.ObserveRetry(OperationCompletion, "gcp.gemini", "gemini-1.5-flash", "RESOURCE_EXHAUSTED")
+	m.ObserveUsage(OperationCompletion, "openai", tokens.NewUsageRecord("gpt-3.5-turbo", 100, 20, 120), 0.5)
+	m.ObserveEmbeddingChunks("openai", "text-embedding-3-small", 4)
+
+	require.Equal(t, 1.0, testutil.ToFloat64(m.requests.WithLabelValues(OperationCompletion, "openai", "gpt-3.5-turbo", StatusSuccess)))
+	require.Equal(t, 1.0, testutil.ToFloat64(m.requests.WithLabelValues(OperationCompletion, "openai", "gpt-3.5-turbo", StatusError)))
+	require.Equal(t, 1.0, testutil.ToFloat64(m.retries.WithLabelValues(OperationCompletion, "gcp.gemini", "gemini-1.5-flash", "RESOURCE_EXHAUSTED")))
+	require.Equal(t, 100.0, testutil.ToFloat64(m.tokens.WithLabelValues(OperationCompletion, "openai", "gpt-3.5-turbo", "input")))
+	require.Equal(t, 20.0, testutil.ToFloat64(m.tokens.WithLabelValues(OperationCompletion, "openai", "gpt-3.5-turbo", "output")))
+	require.Equal(t, 0.5, testutil.ToFloat64(m.cost.WithLabelValues(OperationCompletion, "openai", "gpt-3.5-turbo")))
+
+	expected := `
+# HELP gollm_embedding_chunks Number of chunks sent per embeddings call.
+# TYPE gollm_embedding_chunks histogram
+gollm_embedding_chunks_bucket{model="text-embedding-3-small",provider="openai",le="1"} 0
+gollm_embedding_chunks_bucket{model="text-embedding-3-small",provider="openai",le="2"} 0
+gollm_embedding_chunks_bucket{model="text-embedding-3-small",provider="openai",le="4"} 1
+gollm_embedding_chunks_bucket{model="text-embedding-3-small",provider="openai",le="8"} 1
+gollm_embedding_chunks_bucket{model="text-embedding-3-small",provider="openai",le="16"} 1
+gollm_embedding_chunks_bucket{model="text-embedding-3-small",provider="openai",le="32"} 1
+gollm_embedding_chunks_bucket{model="text-embedding-3-small",provider="openai",le="64"} 1
+gollm_embedding_chunks_bucket{model="text-embedding-3-small",provider="openai",le="128"} 1
+gollm_embedding_chunks_bucket{model="text-embedding-3-small",provider="openai",le="256"} 1
+gollm_embedding_chunks_bucket{model="text-embedding-3-small",provider="openai",le="512"} 1
+gollm_embedding_chunks_bucket{model="text-embedding-3-small",provider="openai",le="1024"} 1
+gollm_embedding_chunks_bucket{model="text-embedding-3-small",provider="openai",le="2048"} 1
+gollm_embedding_chunks_bucket{model="text-embedding-3-small",provider="openai",le="+Inf"} 1
+gollm_embedding_chunks_sum{model="text-embedding-3-small",provider="openai"} 4
+gollm_embedding_chunks_count{model="text-embedding-3-small",provider="openai"} 1
+`
+	require.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected), "gollm_embedding_chunks"))
+}
+
+func TestPrometheusMetricsDuplicateRegistration(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	_, err := NewPrometheusMetrics(registry, "gollm")
+	require.NoError(t, err)
+	_, err = NewPrometheusMetrics(registry, "gollm")
+	require.Error(t, err)
+}
@@ -0,0 +1,210 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+
+	"github.com/jake-landersweb/gollm/v2/src/tokens"
+	"github.com/jake-landersweb/gollm/v2/src/vectorstore"
+)
+
+// Searches a BM25 index for the chunks that share the most terms with the query
+type LexicalRetriever struct {
+	index *vectorstore.BM25Index
+}
+
+func NewLexicalRetriever(index *vectorstore.BM25Index) *LexicalRetriever {
+	return &LexicalRetriever{index: index}
+}
+
+// Searches the index locally, so the usage is always nil
+func (r *LexicalRetriever) Retrieve(ctx context.Context, logger *slog.Logger, query *RetrievalQuery) ([]*vectorstore.Result, *tokens.UsageRecord, error) {
+	if query == nil || query.Text == "" {
+		return nil, nil, fmt.Errorf("the query cannot be empty")
+	}
+	results, err := r.index.Search(ctx, query.Text, query.K, query.Filter)
+	return results, nil, err
+}
+
+// How the results of the retrievers of a `HybridRetriever` are combined
+type FusionMethod string
+
+const (
+	// Scores every chunk by the sum of `weight / (RRFK + rank)` over the retrievers that returned it.
+	// Only uses the ranks, so the scales of the scores do not matter
+	FusionRRF FusionMethod = "rrf"
+
+	// Scales the distances of every retriever to scores from 0 to 1 and sums them by weight
+	FusionWeighted FusionMethod = "weighted"
+)
+
+const (
+	default_rrf_k             = 60
+	default_hybrid_candidates = 2
+)
+
+/*
+Combines the results of a vector and a lexical retriever, so queries match both by meaning and by
+exact terms. The `Distance` of the results is the negative fused score, so smaller is closer.
+*/
+type HybridRetriever struct {
+	vector  Retriever
+	lexical Retriever
+	opts    *HybridRetrieverOpts
+}
+
+type HybridRetrieverOpts struct {
+	// Defaults to `FusionRRF`
+	Fusion FusionMethod
+
+	// Dampens the advantage of the top ranks in `FusionRRF`. Defaults to 60
+	RRFK float64
+
+	// The weights of the retrievers. Both default to 1 when neither is set, and a retriever with a
+	// weight of 0 is not called
+	VectorWeight  float64
+	LexicalWeight float64
+
+	// The number of results requested from each retriever, as a multiple of k. Defaults to 2
+	CandidatesMultiplier int
+}
+
+/*
+Creates a retriever that fuses the results of the retrievers, which are usually a
+`VectorRetriever` and a `LexicalRetriever` over the same chunks.
+*/
+func NewHybridRetriever(vector Retriever, lexical Retriever, opts *HybridRetrieverOpts) (*HybridRetriever, error) {
+	if vector == nil || lexical == nil {
+		return nil, fmt.Errorf("both retrievers are required")
+	}
+	resp := HybridRetrieverOpts{}
+	if opts != nil {
+		resp = *opts
+	}
+	if resp.Fusion == "" {
+		resp.Fusion = FusionRRF
+	}
+	if resp.Fusion != FusionRRF && resp.Fusion != FusionWeighted {
+		return nil, fmt.Errorf("unsupported fusion method: %s", resp.Fusion)
+	}
+	if resp.RRFK == 0 {
+		resp.RRFK = default_rrf_k
+	}
+	if resp.VectorWeight < 0 || resp.LexicalWeight < 0 {
+		return nil, fmt.Errorf("the weights cannot be negative")
+	}
+	if resp.VectorWeight == 0 && resp.LexicalWeight == 0 {
+		resp.VectorWeight = 1
+		resp.LexicalWeight = 1
+	}
+	if resp.CandidatesMultiplier <= 0 {
+		resp.CandidatesMultiplier = default_hybrid_candidates
+	}
+	return &HybridRetriever{vector: vector, lexical: lexical, opts: &resp}, nil
+}
+
+// Returns the usage of both retrievers merged
+func (r *HybridRetriever) Retrieve(ctx context.Context, logger *slog.Logger, query *RetrievalQuery) ([]*vectorstore.Result, *tokens.UsageRecord, error) {
+	if query == nil || query.Text == "" {
+		return nil, nil, fmt.Errorf("the query cannot be empty")
+	}
+	k := query.K
+	if k == 0 {
+		k = default_k
+	}
+	candidates := &RetrievalQuery{Text: query.Text, K: k * r.opts.CandidatesMultiplier, Filter: query.Filter}
+
+	// a retriever with a weight of 0 cannot change the scores, so it is not called
+	var vector, lexical []*vectorstore.Result
+	var vectorUsage, lexicalUsage *tokens.UsageRecord
+	var err error
+	if r.opts.VectorWeight != 0 {
+		vector, vectorUsage, err = r.vector.Retrieve(ctx, logger, candidates)
+		if err != nil {
+			return nil, vectorUsage, fmt.Errorf("there was an issue with the vector search: %v", err)
+		}
+	}
+	if r.opts.LexicalWeight != 0 {
+		lexical, lexicalUsage, err = r.lexical.Retrieve(ctx, logger, candidates)
+	}
+	usage := mergeUsage(vectorUsage, lexicalUsage)
+	if err != nil {
+		return nil, usage, fmt.Errorf("there was an issue with the lexical search: %v", err)
+	}
+
+	var scores map[string]float64
+	documents := make(map[string]*vectorstore.Document)
+	switch r.opts.Fusion {
+	case FusionWeighted:
+		scores = fuseWeighted(documents, []float64{r.opts.VectorWeight, r.opts.LexicalWeight}, vector, lexical)
+	default:
+		scores = fuseRRF(documents, r.opts.RRFK, []float64{r.opts.VectorWeight, r.opts.LexicalWeight}, vector, lexical)
+	}
+
+	results := make([]*vectorstore.Result, 0, len(scores))
+	for id, score := range scores {
+		results = append(results, &vectorstore.Result{Document: documents[id], Distance: -score})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Distance != results[j].Distance {
+			return results[i].Distance < results[j].Distance
+		}
+		return results[i].Document.ID < results[j].Document.ID
+	})
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results, usage, nil
+}
+
+// Merges the usage records that are not nil, returning nil when all of them are
+func mergeUsage(records ...*tokens.UsageRecord) *tokens.UsageRecord {
+	items := make([]*tokens.UsageRecord, 0, len(records))
+	for _, item := range records {
+		if item != nil {
+			items = append(items, item)
+		}
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	return tokens.MergeUsageRecords(items...)
+}
+
+// Scores the documents by reciprocal rank fusion, collecting the documents by id
+func fuseRRF(documents map[string]*vectorstore.Document, k float64, weights []float64, lists ...[]*vectorstore.Result) map[string]float64 {
+	scores := make(map[string]float64)
+	for idx, list := range lists {
+		for rank, item := range list {
+			documents[item.Document.ID] = item.Document
+			scores[item.Document.ID] += weights[idx] / (k + float64(rank+1))
+		}
+	}
+	return scores
+}
+
+// Scores the documents by the weighted sum of their min-max scaled distances, collecting the documents by id
+func fuseWeighted(documents map[string]*vectorstore.Document, weights []float64, lists ...[]*vectorstore.Result) map[string]float64 {
+	scores := make(map[string]float64)
+	for idx, list := range lists {
+		if len(list) == 0 {
+			continue
+		}
+		lowest, highest := list[0].Distance, list[0].Distance
+		for _, item := range list {
+			lowest = min(lowest, item.Distance)
+			highest = max(highest, item.Distance)
+		}
+		for _, item := range list {
+			documents[item.Document.ID] = item.Document
+			scaled := 1.0
+			if highest != lowest {
+				scaled = (highest - item.Distance) / (highest - lowest)
+			}
+			scores[item.Document.ID] += weights[idx] * scaled
+		}
+	}
+	return scores
+}
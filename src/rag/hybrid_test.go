@@ -0,0 +1,106 @@
+package rag
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/jake-landersweb/gollm/v2/src/gollmtest"
+	"github.com/jake-landersweb/gollm/v2/src/tokens"
+	"github.com/jake-landersweb/gollm/v2/src/vectorstore"
+	"github.com/stretchr/testify/require"
+)
+
+// Returns scripted results, ignoring the query
+type staticRetriever struct {
+	results []*vectorstore.Result
+	usage   *tokens.UsageRecord
+	query   *RetrievalQuery
+}
+
+func (r *staticRetriever) Retrieve(ctx context.Context, logger *slog.Logger, query *RetrievalQuery) ([]*vectorstore.Result, *tokens.UsageRecord, error) {
+	r.query = query
+	return r.results, r.usage, nil
+}
+
+func staticResults(distances map[string]float64, ids ...string) []*vectorstore.Result {
+	results := make([]*vectorstore.Result, len(ids))
+	for idx, id := range ids {
+		results[idx] = &vectorstore.Result{Document: &vectorstore.Document{ID: id}, Distance: distances[id]}
+	}
+	return results
+}
+
+func resultIDs(results []*vectorstore.Result) []string {
+	ids := make([]string, len(results))
+	for idx, item := range results {
+		ids[idx] = item.Document.ID
+	}
+	return ids
+}
+
+func TestHybridRetrieverRRF(t *testing.T) {
+	vector := &staticRetriever{results: staticResults(nil, "a", "b", "c"), usage: &tokens.UsageRecord{InputTokens: 3}}
+	lexical := &staticRetriever{results: staticResults(nil, "c", "d", "a")}
+	retriever, err := NewHybridRetriever(vector, lexical, nil)
+	require.NoError(t, err)
+
+	results, usage, err := retriever.Retrieve(context.TODO(), slog.Default(), &RetrievalQuery{Text: "query", K: 3, Filter: map[string]any{"source": "docs"}})
+	require.NoError(t, err)
+	// a and c are found by both with the same ranks, so they tie and are ordered by id
+	require.Equal(t, []string{"a", "c", "b"}, resultIDs(results))
+	require.InDelta(t, -(1.0/61 + 1.0/63), results[0].Distance, 1e-9)
+
+	// both retrievers are asked for more candidates with the same filter, and their usage is merged
+	require.Equal(t, 6, vector.query.K)
+	require.Equal(t, "docs", lexical.query.Filter["source"])
+	require.Equal(t, 3, usage.InputTokens)
+
+	// a retriever with a weight of zero is not called
+	vector.query = nil
+	retriever, err = NewHybridRetriever(vector, lexical, &HybridRetrieverOpts{LexicalWeight: 1})
+	require.NoError(t, err)
+	results, usage, err = retriever.Retrieve(context.TODO(), slog.Default(), &RetrievalQuery{Text: "query", K: 2})
+	require.NoError(t, err)
+	require.Equal(t, []string{"c", "d"}, resultIDs(results))
+	require.Nil(t, vector.query)
+	require.Nil(t, usage)
+}
+
+func TestHybridRetrieverWeighted(t *testing.T) {
+	distances := map[string]float64{"a": 0.1, "b": 0.2, "c": 0.5, "x": -9, "y": -3, "z": -1}
+	vector := &staticRetriever{results: staticResults(distances, "a", "b", "c")}
+	lexical := &staticRetriever{results: staticResults(distances, "x", "y", "z")}
+	lexical.results[2].Document.ID = "b"
+	retriever, err := NewHybridRetriever(vector, lexical, &HybridRetrieverOpts{Fusion: FusionWeighted})
+	require.NoError(t, err)
+
+	results, _, err := retriever.Retrieve(context.TODO(), slog.Default(), &RetrievalQuery{Text: "query", K: 4})
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "x", "b", "y"}, resultIDs(results))
+	require.InDelta(t, -1, results[0].Distance, 1e-9)
+	require.InDelta(t, -0.75, results[2].Distance, 1e-9)
+
+	_, err = NewHybridRetriever(vector, lexical, &HybridRetrieverOpts{Fusion: "max"})
+	require.ErrorContains(t, err, "unsupported fusion method")
+}
+
+func TestPipelineHybrid(t *testing.T) {
+	server := gollmtest.NewServer(t)
+	index := vectorstore.NewBM25Index(nil)
+	pipeline, _ := newTestPipeline(t, server, &PipelineOpts{K: 1, LexicalIndex: index})
+	ingestAnimals(t, server, pipeline)
+	require.Equal(t, 3, index.Len())
+
+	// the embedding is closest to the dogs, but the exact term matches the birds in both the
+	// lexical search and the second place of the vector search
+	server.ExpectOpenAIEmbeddings().RespondEmbeddings([]float32{0, 1, 0.9})
+	results, usage, err := pipeline.Retrieve(context.TODO(), slog.Default(), "Which animal can sing?", nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"wild#0"}, resultIDs(results))
+	require.NotNil(t, usage)
+	require.NotZero(t, usage.InputTokens)
+
+	require.NoError(t, pipeline.Delete(context.TODO(), "wild#0"))
+	require.Equal(t, 2, index.Len())
+}
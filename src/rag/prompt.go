@@ -0,0 +1,78 @@
+package rag
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jake-landersweb/gollm/v2/src/gollm"
+	"github.com/jake-landersweb/gollm/v2/src/vectorstore"
+)
+
+/*
+Builds the conversation sent to the language model from the question, the previous messages of
+the conversation and the retrieved chunks. The last message must be from the user.
+*/
+type PromptFormatter func(question string, history []*gollm.Message, results []*vectorstore.Result) []*gollm.Message
+
+const default_system_prompt = "Answer the question using only the sources below. Cite the sources that support your answer by their number in square brackets, such as [1]. If the sources do not contain the answer, say that you do not know."
+
+/*
+Numbers the chunks and lists them in the system message, followed by the history and the
+question. The answer cites the chunks by their number, which `Pipeline.Query` maps back to the
+chunk ids.
+*/
+func DefaultPromptFormatter(question string, history []*gollm.Message, results []*vectorstore.Result) []*gollm.Message {
+	return NewPromptFormatter(default_system_prompt)(question, history, results)
+}
+
+// Creates a formatter like `DefaultPromptFormatter` with different instructions in the system message
+func NewPromptFormatter(instructions string) PromptFormatter {
+	return func(question string, history []*gollm.Message, results []*vectorstore.Result) []*gollm.Message {
+		var sb strings.Builder
+		sb.WriteString(instructions)
+		sb.WriteString("\n\nSources:")
+		for idx, item := range results {
+			fmt.Fprintf(&sb, "\n\n[%d] %s", idx+1, sourceName(item.Document))
+			fmt.Fprintf(&sb, "\n%s", item.Document.Content)
+		}
+
+		conversation := make([]*gollm.Message, 0, len(history)+2)
+		conversation = append(conversation, gollm.NewSystemMessage(sb.String()))
+		conversation = append(conversation, history...)
+		conversation = append(conversation, gollm.NewUserMessage(question))
+		return conversation
+	}
+}
+
+// Names the source of a chunk by its document id, and section when it has one
+func sourceName(document *vectorstore.Document) string {
+	name := document.ID
+	if id, ok := document.Metadata[MetadataDocumentID].(string); ok && id != "" {
+		name = id
+	}
+	if section, ok := document.Metadata[MetadataSection].(string); ok && section != "" {
+		name = fmt.Sprintf("%s (%s)", name, section)
+	}
+	return name
+}
+
+var citationPattern = regexp.MustCompile(`\[(\d+(?:\s*,\s*\d+)*)\]`)
+
+// Returns the ids of the chunks cited in the answer by their number, in the order they are first cited
+func citedChunks(answer string, results []*vectorstore.Result) []string {
+	cited := make([]string, 0)
+	seen := make(map[int]bool)
+	for _, match := range citationPattern.FindAllStringSubmatch(answer, -1) {
+		for _, item := range strings.Split(match[1], ",") {
+			number, err := strconv.Atoi(strings.TrimSpace(item))
+			if err != nil || number < 1 || number > len(results) || seen[number] {
+				continue
+			}
+			seen[number] = true
+			cited = append(cited, results[number-1].Document.ID)
+		}
+	}
+	return cited
+}
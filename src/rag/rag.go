@@ -0,0 +1,335 @@
+/*
+Package rag answers questions from a corpus of documents with retrieval-augmented generation.
+
+A `Pipeline` ingests documents by chunking, embedding and storing them in a vector store. To
+answer a question it retrieves the closest chunks, optionally reranks them, formats them into
+the conversation as numbered sources, and calls `LanguageModel.Completion`. The retriever,
+reranker and prompt formatter can all be replaced.
+*/
+package rag
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/jake-landersweb/gollm/v2/src/chunking"
+	"github.com/jake-landersweb/gollm/v2/src/gollm"
+	"github.com/jake-landersweb/gollm/v2/src/tokens"
+	"github.com/jake-landersweb/gollm/v2/src/vectorstore"
+)
+
+// Metadata keys that the pipeline sets on every stored chunk
+const (
+	MetadataDocumentID = "document_id"
+	MetadataChunk      = "chunk"
+	MetadataStart      = "start"
+	MetadataEnd        = "end"
+	MetadataSection    = "section"
+)
+
+const (
+	default_k               = 4
+	default_candidates_mult = 4
+)
+
+type Pipeline struct {
+	opts *PipelineOpts
+}
+
+type PipelineOpts struct {
+	// Embeds the chunks of ingested documents. Required
+	Embeddings gollm.Embeddings
+
+	// Stores the embedded chunks. Required
+	Store vectorstore.VectorStore
+
+	// Optionally indexes the chunks for lexical searches as well, which makes the default retriever
+	// a `HybridRetriever` that fuses both searches by reciprocal rank
+	LexicalIndex *vectorstore.BM25Index
+
+	// Answers the questions. Required to query
+	LanguageModel *gollm.LanguageModel
+
+	// The model the questions are answered with. Required to query
+	Model       string
+	Temperature float64
+
+	// Splits the documents into chunks. Defaults to a `chunking.ParagraphChunker` with the default options
+	Chunker chunking.Chunker
+
+	// Finds the chunks for a question. Defaults to a `VectorRetriever` over `Embeddings` and `Store`,
+	// fused with a `LexicalRetriever` when there is a `LexicalIndex`
+	Retriever Retriever
+
+	// Optionally reorders the retrieved chunks before they are formatted
+	Reranker Reranker
+
+	// Defaults to `DefaultPromptFormatter`
+	Formatter PromptFormatter
+
+	// The number of chunks passed to the language model. Defaults to 4
+	K int
+
+	// The number of chunks retrieved for the reranker to choose from. Defaults to 4 times `K`, and
+	// is ignored without a reranker
+	Candidates int
+}
+
+func NewPipeline(opts *PipelineOpts) (*Pipeline, error) {
+	if opts == nil {
+		return nil, fmt.Errorf("the options cannot be nil")
+	}
+	resp := *opts
+	if resp.Embeddings == nil {
+		return nil, fmt.Errorf("`Embeddings` cannot be nil")
+	}
+	if resp.Store == nil {
+		return nil, fmt.Errorf("`Store` cannot be nil")
+	}
+	if resp.K < 0 || resp.Candidates < 0 {
+		return nil, fmt.Errorf("`K` and `Candidates` cannot be negative")
+	}
+	if resp.Chunker == nil {
+		resp.Chunker = chunking.NewParagraphChunker(nil)
+	}
+	if resp.Retriever == nil {
+		resp.Retriever = NewVectorRetriever(resp.Embeddings, resp.Store)
+		if resp.LexicalIndex != nil {
+			retriever, err := NewHybridRetriever(resp.Retriever, NewLexicalRetriever(resp.LexicalIndex), nil)
+			if err != nil {
+				return nil, err
+			}
+			resp.Retriever = retriever
+		}
+	}
+	if resp.Formatter == nil {
+		resp.Formatter = DefaultPromptFormatter
+	}
+	if resp.K == 0 {
+		resp.K = default_k
+	}
+	if resp.Candidates == 0 {
+		resp.Candidates = resp.K * default_candidates_mult
+	}
+	resp.Candidates = max(resp.Candidates, resp.K)
+
+	return &Pipeline{opts: &resp}, nil
+}
+
+// A document to ingest
+type Source struct {
+	// Identifies the document. The chunks are stored with the ids `<ID>#<index>`
+	ID      string
+	Content string
+
+	// Copied onto every chunk of the document, so queries can filter on it
+	Metadata map[string]any
+}
+
+type IngestResponse struct {
+	// The ids of the stored chunks, in the order of the documents
+	ChunkIDs []string
+	Usage    *tokens.UsageRecord
+}
+
+/*
+Chunks, embeds and stores the documents. Ingesting a document again replaces all of its chunks:
+once the new chunks are embedded, the stored chunks of the document are deleted by their
+`MetadataDocumentID` before the new ones are stored, so chunks past the new end are not kept.
+*/
+func (p *Pipeline) Ingest(ctx context.Context, logger *slog.Logger, sources ...*Source) (*IngestResponse, error) {
+	chunks := make([]*chunking.Chunk, 0)
+	owners := make([]*Source, 0)
+	indexes := make([]int, 0)
+	for idx, source := range sources {
+		if source == nil || source.ID == "" {
+			return nil, fmt.Errorf("the source %d must have an id", idx)
+		}
+		if source.Content == "" {
+			return nil, fmt.Errorf("the source %s cannot be empty", source.ID)
+		}
+		items, err := p.opts.Chunker.Chunk(source.Content)
+		if err != nil {
+			return nil, fmt.Errorf("there was an issue chunking the source %s: %v", source.ID, err)
+		}
+		for i, item := range items {
+			chunks = append(chunks, item)
+			owners = append(owners, source)
+			indexes = append(indexes, i)
+		}
+	}
+	if len(chunks) == 0 {
+		return &IngestResponse{ChunkIDs: []string{}}, nil
+	}
+
+	texts := make([]string, len(chunks))
+	for idx, item := range chunks {
+		texts[idx] = item.Text
+	}
+	embedded, err := p.opts.Embeddings.Embed(ctx, logger, &gollm.EmbedArgs{InputChunks: texts})
+	if err != nil {
+		return nil, fmt.Errorf("there was an issue embedding the sources: %v", err)
+	}
+	if len(embedded.Embeddings) != len(chunks) {
+		return nil, fmt.Errorf("expected %d embeddings, found %d", len(chunks), len(embedded.Embeddings))
+	}
+
+	documents := make([]*vectorstore.Document, len(chunks))
+	ids := make([]string, len(chunks))
+	for idx, item := range chunks {
+		source := owners[idx]
+		metadata := make(map[string]any, len(source.Metadata)+5)
+		for key, value := range source.Metadata {
+			metadata[key] = value
+		}
+		metadata[MetadataDocumentID] = source.ID
+		metadata[MetadataChunk] = indexes[idx]
+		metadata[MetadataStart] = item.Start
+		metadata[MetadataEnd] = item.End
+		if item.Section != "" {
+			metadata[MetadataSection] = item.Section
+		}
+
+		ids[idx] = ChunkID(source.ID, indexes[idx])
+		documents[idx] = vectorstore.NewDocument(ids[idx], embedded.Embeddings[idx], metadata)
+	}
+
+	// delete the previous chunks of the documents, which may have had more of them
+	for _, source := range sources {
+		filter := map[string]any{MetadataDocumentID: source.ID}
+		if err := p.opts.Store.DeleteMatching(ctx, filter); err != nil {
+			return nil, fmt.Errorf("there was an issue deleting the previous chunks of %s: %v", source.ID, err)
+		}
+		if p.opts.LexicalIndex != nil {
+			if err := p.opts.LexicalIndex.DeleteMatching(ctx, filter); err != nil {
+				return nil, fmt.Errorf("there was an issue deleting the previous chunks of %s: %v", source.ID, err)
+			}
+		}
+	}
+
+	if err := p.opts.Store.Upsert(ctx, documents...); err != nil {
+		return nil, fmt.Errorf("there was an issue storing the chunks: %v", err)
+	}
+	if p.opts.LexicalIndex != nil {
+		if err := p.opts.LexicalIndex.Upsert(ctx, documents...); err != nil {
+			return nil, fmt.Errorf("there was an issue indexing the chunks: %v", err)
+		}
+	}
+
+	return &IngestResponse{ChunkIDs: ids, Usage: embedded.Usage}, nil
+}
+
+// Deletes chunks by their ids
+func (p *Pipeline) Delete(ctx context.Context, chunkIDs ...string) error {
+	if err := p.opts.Store.Delete(ctx, chunkIDs...); err != nil {
+		return err
+	}
+	if p.opts.LexicalIndex != nil {
+		return p.opts.LexicalIndex.Delete(ctx, chunkIDs...)
+	}
+	return nil
+}
+
+// Returns the id that the chunk of a document is stored with
+func ChunkID(documentID string, index int) string {
+	return fmt.Sprintf("%s#%d", documentID, index)
+}
+
+type QueryInput struct {
+	Question string
+
+	// Optional previous messages of the conversation, placed between the sources and the question
+	History []*gollm.Message
+
+	// Only retrieve chunks with all of these metadata values
+	Filter map[string]any
+}
+
+type QueryResponse struct {
+	// The text of the answer
+	Answer string
+
+	// The full response of the language model
+	Completion *gollm.CompletionResponse
+
+	// The chunks passed to the language model, in the order they were numbered
+	Sources []*vectorstore.Result
+
+	// The ids of `Sources`
+	ChunkIDs []string
+
+	// The ids of the sources that the answer cites, in the order they are first cited
+	Citations []string
+
+	// The usage of retrieving the sources, such as embedding the question and reranking the chunks, or
+	// nil when the retriever and the reranker made no requests. The usage of the answer is on `Completion`
+	RetrievalUsage *tokens.UsageRecord
+}
+
+// Retrieves the chunks for the question and answers it with the language model
+func (p *Pipeline) Query(ctx context.Context, logger *slog.Logger, input *QueryInput) (*QueryResponse, error) {
+	if input == nil || input.Question == "" {
+		return nil, fmt.Errorf("the question cannot be empty")
+	}
+	if p.opts.LanguageModel == nil || p.opts.Model == "" {
+		return nil, fmt.Errorf("`LanguageModel` and `Model` are required to query")
+	}
+
+	results, usage, err := p.Retrieve(ctx, logger, input.Question, input.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	completion, err := p.opts.LanguageModel.Completion(ctx, &gollm.CompletionInput{
+		Model:        p.opts.Model,
+		Temperature:  p.opts.Temperature,
+		Conversation: p.opts.Formatter(input.Question, input.History, results),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("there was an issue answering the question: %v", err)
+	}
+
+	ids := make([]string, len(results))
+	for idx, item := range results {
+		ids[idx] = item.Document.ID
+	}
+
+	return &QueryResponse{
+		Answer:         completion.Message.Message,
+		Completion:     completion,
+		Sources:        results,
+		ChunkIDs:       ids,
+		Citations:      citedChunks(completion.Message.Message, results),
+		RetrievalUsage: usage,
+	}, nil
+}
+
+/*
+Returns the chunks that would be passed to the language model for the question, most relevant
+first, along with the usage of the retriever and the reranker
+*/
+func (p *Pipeline) Retrieve(ctx context.Context, logger *slog.Logger, question string, filter map[string]any) ([]*vectorstore.Result, *tokens.UsageRecord, error) {
+	k := p.opts.K
+	if p.opts.Reranker != nil {
+		k = p.opts.Candidates
+	}
+
+	results, usage, err := p.opts.Retriever.Retrieve(ctx, logger, &RetrievalQuery{Text: question, K: k, Filter: filter})
+	if err != nil {
+		return nil, usage, fmt.Errorf("there was an issue retrieving the chunks: %v", err)
+	}
+
+	if p.opts.Reranker != nil && len(results) != 0 {
+		var rerankUsage *tokens.UsageRecord
+		results, rerankUsage, err = p.opts.Reranker.Rerank(ctx, logger, question, results, p.opts.K)
+		usage = mergeUsage(usage, rerankUsage)
+		if err != nil {
+			return nil, usage, fmt.Errorf("there was an issue reranking the chunks: %v", err)
+		}
+	}
+	if len(results) > p.opts.K {
+		results = results[:p.opts.K]
+	}
+	return results, usage, nil
+}
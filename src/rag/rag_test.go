@@ -0,0 +1,164 @@
+package rag
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/jake-landersweb/gollm/v2/src/chunking"
+	"github.com/jake-landersweb/gollm/v2/src/gollm"
+	"github.com/jake-landersweb/gollm/v2/src/gollmtest"
+	"github.com/jake-landersweb/gollm/v2/src/tokens"
+	"github.com/jake-landersweb/gollm/v2/src/vectorstore"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestPipeline(t *testing.T, server *gollmtest.Server, opts *PipelineOpts) (*Pipeline, *vectorstore.MemoryStore) {
+	store, err := vectorstore.NewMemoryStore(vectorstore.MetricCosine)
+	require.NoError(t, err)
+
+	opts.Embeddings = gollm.NewOpenAIEmbeddings("test-user", server.OpenAIEmbeddingsOpts())
+	opts.Store = store
+	opts.LanguageModel = gollm.NewLanguageModel("test-user", nil, server.LanguageModelArgs())
+	opts.Model = "gpt-4o-mini"
+	opts.Chunker = chunking.NewParagraphChunker(&chunking.Options{Size: 3, Tokenizer: chunking.ApproximateTokens})
+	pipeline, err := NewPipeline(opts)
+	require.NoError(t, err)
+	return pipeline, store
+}
+
+func ingestAnimals(t *testing.T, server *gollmtest.Server, pipeline *Pipeline) {
+	server.ExpectOpenAIEmbeddings().RespondEmbeddings([]float32{1, 0, 0}, []float32{0, 1, 0}, []float32{0, 0, 1})
+	response, err := pipeline.Ingest(context.TODO(), slog.Default(),
+		&Source{ID: "pets", Content: "Cats purr.\n\nDogs bark.", Metadata: map[string]any{"kind": "pets"}},
+		&Source{ID: "wild", Content: "Birds sing.", Metadata: map[string]any{"kind": "wild"}},
+	)
+	require.NoError(t, err)
+	require.Equal(t, []string{"pets#0", "pets#1", "wild#0"}, response.ChunkIDs)
+	require.NotNil(t, response.Usage)
+}
+
+func TestPipeline(t *testing.T) {
+	server := gollmtest.NewServer(t)
+	pipeline, store := newTestPipeline(t, server, &PipelineOpts{K: 2})
+	ingestAnimals(t, server, pipeline)
+	require.Equal(t, 3, store.Len())
+
+	server.ExpectOpenAIEmbeddings().WithUsage(4, 0).RespondEmbeddings([]float32{0.1, 1, 0})
+	server.ExpectOpenAI().
+		WithBodyContaining("[1] pets").
+		WithBodyContaining("Dogs bark.").
+		WithLastMessageContaining("Which animal barks?").
+		RespondText("Dogs bark [1].")
+
+	response, err := pipeline.Query(context.TODO(), slog.Default(), &QueryInput{Question: "Which animal barks?"})
+	require.NoError(t, err)
+	require.Equal(t, "Dogs bark [1].", response.Answer)
+	require.Equal(t, []string{"pets#1", "pets#0"}, response.ChunkIDs)
+	require.Equal(t, []string{"pets#1"}, response.Citations)
+
+	// the usage of embedding the question is reported apart from the answer
+	require.NotNil(t, response.RetrievalUsage)
+	require.Equal(t, 4, response.RetrievalUsage.InputTokens)
+
+	// the offsets of the chunks in the document are kept in the metadata
+	metadata := response.Sources[0].Document.Metadata
+	require.Equal(t, "pets", metadata[MetadataDocumentID])
+	require.Equal(t, "pets", metadata["kind"])
+	require.Equal(t, 1, metadata[MetadataChunk])
+	require.Equal(t, 12, metadata[MetadataStart])
+	require.Equal(t, 22, metadata[MetadataEnd])
+
+	// the request puts the sources in the system message and the question last
+	conversation := server.LastRequest().OpenAI().Messages
+	require.Len(t, conversation, 2)
+	require.Equal(t, "system", conversation[0].Role)
+}
+
+func TestPipelineReingest(t *testing.T) {
+	server := gollmtest.NewServer(t)
+	index := vectorstore.NewBM25Index(nil)
+	pipeline, store := newTestPipeline(t, server, &PipelineOpts{LexicalIndex: index})
+	ingestAnimals(t, server, pipeline)
+
+	// the chunks past the new end of a shrunk document are deleted
+	server.ExpectOpenAIEmbeddings().RespondEmbeddings([]float32{1, 0, 0})
+	response, err := pipeline.Ingest(context.TODO(), slog.Default(), &Source{ID: "pets", Content: "Cats purr."})
+	require.NoError(t, err)
+	require.Equal(t, []string{"pets#0"}, response.ChunkIDs)
+	require.Equal(t, 2, store.Len())
+	require.Equal(t, 2, index.Len())
+
+	results, err := index.Search(context.TODO(), "dogs bark", 0, nil)
+	require.NoError(t, err)
+	require.Empty(t, results)
+
+	// a failed embedding keeps the previous chunks
+	server.ExpectOpenAIEmbeddings().RespondRaw(400, `{"error": {"message": "invalid input", "type": "invalid_request_error"}}`)
+	_, err = pipeline.Ingest(context.TODO(), slog.Default(), &Source{ID: "wild", Content: "Birds fly."})
+	require.Error(t, err)
+	require.Equal(t, 2, store.Len())
+}
+
+// Reverses the order of the results
+type reverseReranker struct {
+	candidates int
+	usage      *tokens.UsageRecord
+}
+
+func (r *reverseReranker) Rerank(ctx context.Context, logger *slog.Logger, query string, results []*vectorstore.Result, k int) ([]*vectorstore.Result, *tokens.UsageRecord, error) {
+	r.candidates = len(results)
+	resp := make([]*vectorstore.Result, 0, len(results))
+	for idx := len(results) - 1; idx >= 0; idx-- {
+		resp = append(resp, results[idx])
+	}
+	return resp[:min(k, len(resp))], r.usage, nil
+}
+
+func TestPipelineRerankAndFilter(t *testing.T) {
+	server := gollmtest.NewServer(t)
+	reranker := &reverseReranker{usage: &tokens.UsageRecord{Model: "rerank-v3.5", InputTokens: 100}}
+	pipeline, _ := newTestPipeline(t, server, &PipelineOpts{K: 1, Candidates: 3, Reranker: reranker})
+	ingestAnimals(t, server, pipeline)
+
+	// the reranker chooses from all the candidates
+	server.ExpectOpenAIEmbeddings().RespondEmbeddings([]float32{0.1, 1, 0})
+	results, usage, err := pipeline.Retrieve(context.TODO(), slog.Default(), "Which animal barks?", nil)
+	require.NoError(t, err)
+	require.Equal(t, 3, reranker.candidates)
+	require.Len(t, results, 1)
+	require.Equal(t, "wild#0", results[0].Document.ID)
+
+	// the usage of the reranker is merged with the usage of embedding the question
+	require.NotNil(t, usage)
+	require.Equal(t, 105, usage.InputTokens)
+
+	// filters are passed to the store
+	server.ExpectOpenAIEmbeddings().RespondEmbeddings([]float32{0.1, 1, 0})
+	results, _, err = pipeline.Retrieve(context.TODO(), slog.Default(), "Which animal barks?", map[string]any{"kind": "pets"})
+	require.NoError(t, err)
+	require.Equal(t, 2, reranker.candidates)
+	require.Equal(t, "pets#0", results[0].Document.ID)
+}
+
+func TestPipelineErrors(t *testing.T) {
+	server := gollmtest.NewServer(t)
+	_, err := NewPipeline(&PipelineOpts{})
+	require.ErrorContains(t, err, "`Embeddings` cannot be nil")
+
+	pipeline, _ := newTestPipeline(t, server, &PipelineOpts{})
+	_, err = pipeline.Ingest(context.TODO(), slog.Default(), &Source{Content: "no id"})
+	require.ErrorContains(t, err, "must have an id")
+	_, err = pipeline.Query(context.TODO(), slog.Default(), &QueryInput{})
+	require.ErrorContains(t, err, "cannot be empty")
+}
+
+func TestCitedChunks(t *testing.T) {
+	results := []*vectorstore.Result{
+		{Document: &vectorstore.Document{ID: "a#0"}},
+		{Document: &vectorstore.Document{ID: "a#1"}},
+		{Document: &vectorstore.Document{ID: "b#0"}},
+	}
+	require.Equal(t, []string{"b#0", "a#0", "a#1"}, citedChunks("See [3] and [1, 2], again [3]. Not [4] or [0].", results))
+	require.Empty(t, citedChunks("No citations.", results))
+}
@@ -0,0 +1,45 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/jake-landersweb/gollm/v2/src/gollm"
+	"github.com/jake-landersweb/gollm/v2/src/tokens"
+	"github.com/jake-landersweb/gollm/v2/src/vectorstore"
+)
+
+/*
+Reranks the retrieved chunks by their content with a `gollm.Reranker`, such as a
+`gollm.HTTPReranker` or a `gollm.LLMReranker`. The `Distance` of the results is the negative
+relevance score, so smaller is closer.
+*/
+type ScoreReranker struct {
+	reranker gollm.Reranker
+}
+
+func NewScoreReranker(reranker gollm.Reranker) *ScoreReranker {
+	return &ScoreReranker{reranker: reranker}
+}
+
+func (r *ScoreReranker) Rerank(ctx context.Context, logger *slog.Logger, query string, results []*vectorstore.Result, k int) ([]*vectorstore.Result, *tokens.UsageRecord, error) {
+	documents := make([]string, len(results))
+	for idx, item := range results {
+		documents[idx] = item.Document.Content
+	}
+
+	response, err := r.reranker.Rerank(ctx, logger, &gollm.RerankArgs{Query: query, Documents: documents, TopN: k})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp := make([]*vectorstore.Result, 0, len(response.Results))
+	for _, item := range response.Results {
+		if item.Index < 0 || item.Index >= len(results) {
+			return nil, response.Usage, fmt.Errorf("the reranker returned an unknown index: %d", item.Index)
+		}
+		resp = append(resp, &vectorstore.Result{Document: results[item.Index].Document, Distance: -item.Score})
+	}
+	return resp, response.Usage, nil
+}
@@ -0,0 +1,49 @@
+package rag
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/jake-landersweb/gollm/v2/src/gollm"
+	"github.com/jake-landersweb/gollm/v2/src/tokens"
+	"github.com/jake-landersweb/gollm/v2/src/vectorstore"
+	"github.com/stretchr/testify/require"
+)
+
+// Scores documents by whether they contain the query
+type containsReranker struct{}
+
+func (r *containsReranker) Rerank(ctx context.Context, logger *slog.Logger, args *gollm.RerankArgs) (*gollm.RerankResponse, error) {
+	results := make([]*gollm.RerankResult, 0)
+	for idx, item := range args.Documents {
+		if strings.Contains(item, args.Query) {
+			results = append(results, &gollm.RerankResult{Index: idx, Document: item, Score: 0.8})
+		}
+	}
+	return &gollm.RerankResponse{
+		Results: results[:min(args.TopN, len(results))],
+		Usage:   &tokens.UsageRecord{InputTokens: len(args.Documents)},
+	}, nil
+}
+
+func (r *containsReranker) GetUsageRecords() []*tokens.UsageRecord {
+	return nil
+}
+
+func TestScoreReranker(t *testing.T) {
+	results := []*vectorstore.Result{
+		{Document: &vectorstore.Document{ID: "a", Content: "dogs bark"}, Distance: 0.1},
+		{Document: &vectorstore.Document{ID: "b", Content: "cats purr"}, Distance: 0.2},
+		{Document: &vectorstore.Document{ID: "c", Content: "cats nap"}, Distance: 0.3},
+	}
+
+	reranked, usage, err := NewScoreReranker(&containsReranker{}).Rerank(context.TODO(), slog.Default(), "cats", results, 1)
+	require.NoError(t, err)
+	require.Equal(t, 3, usage.InputTokens)
+	require.Len(t, reranked, 1)
+	require.Equal(t, "b", reranked[0].Document.ID)
+	require.Equal(t, -0.8, reranked[0].Distance)
+	require.Equal(t, 0.2, results[1].Distance) // the retrieved results are not changed
+}
@@ -0,0 +1,76 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/jake-landersweb/gollm/v2/src/gollm"
+	"github.com/jake-landersweb/gollm/v2/src/tokens"
+	"github.com/jake-landersweb/gollm/v2/src/vectorstore"
+)
+
+// A query for the chunks that are relevant to a question
+type RetrievalQuery struct {
+	Text string
+
+	// The most chunks to return
+	K int
+
+	// Only return chunks with all of these metadata values
+	Filter map[string]any
+}
+
+/*
+Finds the chunks that are relevant to a query, most relevant first. Also returns the usage of the
+requests made to find them, such as embedding the query, or nil when it made none.
+*/
+type Retriever interface {
+	Retrieve(ctx context.Context, logger *slog.Logger, query *RetrievalQuery) ([]*vectorstore.Result, *tokens.UsageRecord, error)
+}
+
+// Reorders retrieved chunks by their relevance to the query, returning at most k of them, along with
+// the usage of scoring them or nil when it made no requests. Rerankers usually score the query
+// against every chunk with a slower but more accurate model
+type Reranker interface {
+	Rerank(ctx context.Context, logger *slog.Logger, query string, results []*vectorstore.Result, k int) ([]*vectorstore.Result, *tokens.UsageRecord, error)
+}
+
+// Embeds the query and searches the vector store for the closest chunks
+type VectorRetriever struct {
+	embeddings gollm.Embeddings
+	store      vectorstore.VectorStore
+}
+
+/*
+Creates a retriever that embeds queries with the embeddings. Providers that embed queries and
+documents differently, such as Cohere or Voyage with an input type, should be passed the
+embeddings configured for queries.
+*/
+func NewVectorRetriever(embeddings gollm.Embeddings, store vectorstore.VectorStore) *VectorRetriever {
+	return &VectorRetriever{embeddings: embeddings, store: store}
+}
+
+func (r *VectorRetriever) Retrieve(ctx context.Context, logger *slog.Logger, query *RetrievalQuery) ([]*vectorstore.Result, *tokens.UsageRecord, error) {
+	if query == nil || query.Text == "" {
+		return nil, nil, fmt.Errorf("the query cannot be empty")
+	}
+
+	response, err := r.embeddings.Embed(ctx, logger, &gollm.EmbedArgs{InputChunks: []string{query.Text}})
+	if err != nil {
+		return nil, nil, fmt.Errorf("there was an issue embedding the query: %v", err)
+	}
+	if len(response.Embeddings) != 1 {
+		return nil, response.Usage, fmt.Errorf("expected 1 embedding for the query, found %d", len(response.Embeddings))
+	}
+
+	results, err := r.store.Search(ctx, &vectorstore.Query{
+		Embedding: response.Embeddings[0].Embedding,
+		K:         query.K,
+		Filter:    query.Filter,
+	})
+	if err != nil {
+		return nil, response.Usage, err
+	}
+	return results, response.Usage, nil
+}
@@ -0,0 +1,122 @@
+package tokens
+
+import "strings"
+
+// Price of a model in USD per one million tokens
+type Pricing struct {
+	InputPerMillion  float64
+	OutputPerMillion float64
+
+	// Prices of the input tokens written to and read from the prompt cache. When 0, they are billed
+	// as other input tokens
+	CacheWritePerMillion float64
+	CacheReadPerMillion  float64
+}
+
+/*
+Published list prices of the supported models, keyed by the model name or a prefix of it.
+The longest matching key is used, so `gpt-4o-mini` will not be priced as `gpt-4o`. Provider
+prefixes such as `azure/` or `vertex/` and Bedrock vendor prefixes such as `us.anthropic.` are
+skipped when matching, so `bedrock/us.anthropic.claude-3-haiku-20240307-v1:0` is priced as
+`claude-3-haiku`. Azure models are matched by their deployment name, and Ollama models run
+locally so they have no price.
+
+These prices are only an estimate and can be out of date. The map can be modified at startup
+to add or correct entries, but should not be modified while completions are running.
+*/
+var ModelPricing = map[string]Pricing{
+	// OpenAI
+	"gpt-3.5-turbo":          {InputPerMillion: 0.5, OutputPerMillion: 1.5},
+	"gpt-4":                  {InputPerMillion: 30, OutputPerMillion: 60},
+	"gpt-4-turbo":            {InputPerMillion: 10, OutputPerMillion: 30},
+	"gpt-4o":                 {InputPerMillion: 2.5, OutputPerMillion: 10, CacheReadPerMillion: 1.25},
+	"gpt-4o-mini":            {InputPerMillion: 0.15, OutputPerMillion: 0.6, CacheReadPerMillion: 0.075},
+	"text-embedding-3-small": {InputPerMillion: 0.02},
+	"text-embedding-3-large": {InputPerMillion: 0.13},
+
+	// Gemini
+	"gemini-1.5-flash": {InputPerMillion: 0.075, OutputPerMillion: 0.3, CacheReadPerMillion: 0.01875},
+	"gemini-1.5-pro":   {InputPerMillion: 1.25, OutputPerMillion: 5, CacheReadPerMillion: 0.3125},
+
+	// Voyage
+	"voyage-3":      {InputPerMillion: 0.06},
+	"voyage-3-lite": {InputPerMillion: 0.02},
+
+	// Cohere
+	"embed-english-v3.0":      {InputPerMillion: 0.1},
+	"embed-multilingual-v3.0": {InputPerMillion: 0.1},
+
+	// Mistral
+	"mistral-large":     {InputPerMillion: 2, OutputPerMillion: 6},
+	"mistral-small":     {InputPerMillion: 0.2, OutputPerMillion: 0.6},
+	"open-mistral-nemo": {InputPerMillion: 0.15, OutputPerMillion: 0.15},
+
+	// Cohere chat
+	"command-r":      {InputPerMillion: 0.15, OutputPerMillion: 0.6},
+	"command-r-plus": {InputPerMillion: 2.5, OutputPerMillion: 10},
+
+	// Anthropic
+	"claude-3-haiku":    {InputPerMillion: 0.25, OutputPerMillion: 1.25, CacheWritePerMillion: 0.3, CacheReadPerMillion: 0.03},
+	"claude-3-sonnet":   {InputPerMillion: 3, OutputPerMillion: 15},
+	"claude-3-5-sonnet": {InputPerMillion: 3, OutputPerMillion: 15, CacheWritePerMillion: 3.75, CacheReadPerMillion: 0.3},
+	"claude-3-opus":     {InputPerMillion: 15, OutputPerMillion: 75, CacheWritePerMillion: 18.75, CacheReadPerMillion: 1.5},
+}
+
+// Models served by Ollama, which run locally and are never priced, even when they share a name with a paid model
+const ollama_prefix = "ollama/"
+
+// Returns the pricing for the model, and whether the model has a known price
+func PricingForModel(model string) (Pricing, bool) {
+	if strings.HasPrefix(model, ollama_prefix) {
+		return Pricing{}, false
+	}
+
+	// the model name can start after any provider or vendor prefix
+	names := []string{model}
+	for idx := 1; idx < len(model); idx++ {
+		if model[idx-1] == '/' || model[idx-1] == '.' {
+			names = append(names, model[idx:])
+		}
+	}
+
+	match := ""
+	for key := range ModelPricing {
+		if len(key) <= len(match) {
+			continue
+		}
+		for _, name := range names {
+			if strings.HasPrefix(name, key) {
+				match = key
+				break
+			}
+		}
+	}
+	if match == "" {
+		return Pricing{}, false
+	}
+	return ModelPricing[match], true
+}
+
+// Estimates the cost of the usage record in USD. Returns false if the model has no known price.
+func EstimateCost(record *UsageRecord) (float64, bool) {
+	if record == nil {
+		return 0, false
+	}
+	pricing, ok := PricingForModel(record.Model)
+	if !ok {
+		return 0, false
+	}
+	writePrice, readPrice := pricing.CacheWritePerMillion, pricing.CacheReadPerMillion
+	if writePrice == 0 {
+		writePrice = pricing.InputPerMillion
+	}
+	if readPrice == 0 {
+		readPrice = pricing.InputPerMillion
+	}
+	uncached := record.InputTokens - record.CacheCreationInputTokens - record.CacheReadInputTokens
+	cost := float64(uncached)*pricing.InputPerMillion/1_000_000 +
+		float64(record.CacheCreationInputTokens)*writePrice/1_000_000 +
+		float64(record.CacheReadInputTokens)*readPrice/1_000_000 +
+		float64(record.OutputTokens)*pricing.OutputPerMillion/1_000_000
+	return cost, true
+}
@@ -0,0 +1,42 @@
+package tokens
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPricingForModel(t *testing.T) {
+	tests := []struct {
+		model string
+		key   string
+	}{
+		{model: "gpt-4o-mini-2024-07-18", key: "gpt-4o-mini"},
+		{model: "gpt-4o-2024-08-06", key: "gpt-4o"},
+		{model: "azure/gpt-4o", key: "gpt-4o"},
+		{model: "vertex/gemini-1.5-pro", key: "gemini-1.5-pro"},
+		{model: "bedrock/anthropic.claude-3-haiku-20240307-v1:0", key: "claude-3-haiku"},
+		{model: "bedrock/us.anthropic.claude-3-5-sonnet-20240620-v1:0", key: "claude-3-5-sonnet"},
+		{model: "mistral/mistral-large-latest", key: "mistral-large"},
+		{model: "cohere/command-r-plus", key: "command-r-plus"},
+		{model: "cohere/command-r", key: "command-r"},
+		{model: "embed-english-v3.0", key: "embed-english-v3.0"},
+	}
+	for _, test := range tests {
+		pricing, ok := PricingForModel(test.model)
+		require.True(t, ok, test.model)
+		require.Equal(t, ModelPricing[test.key], pricing, test.model)
+	}
+
+	// models served by ollama run locally, even when a paid model has the same name
+	for _, model := range []string{"ollama/llama3.1", "ollama/mistral-small", "ollama/command-r", "ollama/gpt-4o"} {
+		_, ok := PricingForModel(model)
+		require.False(t, ok, model)
+	}
+}
+
+func TestEstimateCostPrefixedModel(t *testing.T) {
+	cost, ok := EstimateCost(NewUsageRecord("azure/gpt-4o-mini", 1_000_000, 1_000_000, 2_000_000))
+	require.True(t, ok)
+	require.InDelta(t, 0.75, cost, 1e-9)
+}
@@ -11,6 +11,14 @@ type UsageRecord struct {
 	InputTokens  int
 	OutputTokens int
 	TotalTokens  int
+
+	// Input tokens written to and read from the prompt cache of the provider. Both are included in
+	// `InputTokens`, and are billed at different prices than the rest of the input
+	CacheCreationInputTokens int
+	CacheReadInputTokens     int
+
+	// The provider did not report the usage, so the tokens were approximated and should not be used for billing
+	Estimated bool
 }
 
 func NewUsageRecord(model string, input int, output int, total int) *UsageRecord {
@@ -26,13 +34,17 @@ func NewUsageRecord(model string, input int, output int, total int) *UsageRecord
 
 func NewUsageRecordFromGPTUsage(model string, usage *ltypes.GPTUsage) *UsageRecord {
 	id, _ := uuid.NewV7()
-	return &UsageRecord{
+	record := &UsageRecord{
 		ID:           id,
 		Model:        model,
 		InputTokens:  usage.PromptTokens,
 		OutputTokens: usage.CompletionTokens,
 		TotalTokens:  usage.TotalTokens,
 	}
+	if usage.PromptTokensDetails != nil {
+		record.CacheReadInputTokens = usage.PromptTokensDetails.CachedTokens
+	}
+	return record
 }
 
 func NewUsageRecordFromGeminiUsage(model string, usage *ltypes.GemUsageMetadata) *UsageRecord {
@@ -43,16 +55,119 @@ func NewUsageRecordFromGeminiUsage(model string, usage *ltypes.GemUsageMetadata)
 		InputTokens:  usage.PromptTokenCount,
 		OutputTokens: usage.CandidatesTokenCount,
 		TotalTokens:  usage.TotalTokenCount,
+
+		CacheReadInputTokens: usage.CachedContentTokenCount,
 	}
 }
 
+// Anthropic does not count the cached tokens in its input tokens, so they are added to them
 func NewUsageRecordFromAnthropicUsage(model string, usage *ltypes.AnthropicUsage) *UsageRecord {
+	id, _ := uuid.NewV7()
+	input := usage.InputTokens + usage.CacheCreationInputTokens + usage.CacheReadInputTokens
+	return &UsageRecord{
+		ID:           id,
+		Model:        model,
+		InputTokens:  input,
+		OutputTokens: usage.OutputTokens,
+		TotalTokens:  input + usage.OutputTokens,
+
+		CacheCreationInputTokens: usage.CacheCreationInputTokens,
+		CacheReadInputTokens:     usage.CacheReadInputTokens,
+	}
+}
+
+func NewUsageRecordFromOllamaUsage(model string, usage *ltypes.OllamaUsage) *UsageRecord {
+	id, _ := uuid.NewV7()
+	return &UsageRecord{
+		ID:           id,
+		Model:        model,
+		InputTokens:  usage.PromptEvalCount,
+		OutputTokens: usage.EvalCount,
+		TotalTokens:  usage.PromptEvalCount + usage.EvalCount,
+	}
+}
+
+func NewUsageRecordFromBedrockUsage(model string, usage *ltypes.BedrockUsage) *UsageRecord {
 	id, _ := uuid.NewV7()
 	return &UsageRecord{
 		ID:           id,
 		Model:        model,
 		InputTokens:  usage.InputTokens,
 		OutputTokens: usage.OutputTokens,
-		TotalTokens:  usage.InputTokens + usage.OutputTokens,
+		TotalTokens:  usage.TotalTokens,
+	}
+}
+
+func NewUsageRecordFromMistralUsage(model string, usage *ltypes.MistralUsage) *UsageRecord {
+	id, _ := uuid.NewV7()
+	return &UsageRecord{
+		ID:           id,
+		Model:        model,
+		InputTokens:  usage.PromptTokens,
+		OutputTokens: usage.CompletionTokens,
+		TotalTokens:  usage.TotalTokens,
+	}
+}
+
+// Uses the billed units when they are reported, as they exclude the tokens of the prompt template
+func NewUsageRecordFromCohereUsage(model string, usage *ltypes.CohereUsage) *UsageRecord {
+	units := usage.BilledUnits
+	if units == nil {
+		units = usage.Tokens
+	}
+	if units == nil {
+		units = &ltypes.CohereUsageUnits{}
+	}
+	id, _ := uuid.NewV7()
+	return &UsageRecord{
+		ID:           id,
+		Model:        model,
+		InputTokens:  int(units.InputTokens),
+		OutputTokens: int(units.OutputTokens),
+		TotalTokens:  int(units.InputTokens + units.OutputTokens),
+	}
+}
+
+func NewUsageRecordFromVoyageUsage(model string, usage *ltypes.VoyageUsage) *UsageRecord {
+	id, _ := uuid.NewV7()
+	return &UsageRecord{
+		ID:           id,
+		Model:        model,
+		InputTokens:  usage.TotalTokens,
+		OutputTokens: 0,
+		TotalTokens:  usage.TotalTokens,
+	}
+}
+
+func NewUsageRecordFromRerankUsage(model string, usage *ltypes.RerankUsage) *UsageRecord {
+	id, _ := uuid.NewV7()
+	return &UsageRecord{
+		ID:           id,
+		Model:        model,
+		InputTokens:  usage.TotalTokens,
+		OutputTokens: 0,
+		TotalTokens:  usage.TotalTokens,
+	}
+}
+
+// Combines the records into a single record with a new ID, such as the records of every batch of a request.
+// The model of the first record is used, nil records are skipped, and the result is estimated if any record is.
+func MergeUsageRecords(records ...*UsageRecord) *UsageRecord {
+	id, _ := uuid.NewV7()
+	merged := &UsageRecord{ID: id}
+	for _, record := range records {
+		if record == nil {
+			continue
+		}
+		if merged.Model == "" {
+			merged.Model = record.Model
+		}
+		merged.InputTokens += record.InputTokens
+		merged.OutputTokens += record.OutputTokens
+		merged.TotalTokens += record.TotalTokens
+		merged.CacheCreationInputTokens += record.CacheCreationInputTokens
+		merged.CacheReadInputTokens += record.CacheReadInputTokens
+		merged.Estimated = merged.Estimated || record.Estimated
 	}
+	return merged
 }
@@ -0,0 +1,150 @@
+package vecformat
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jake-landersweb/gollm/v2/src/ltypes"
+	"github.com/pgvector/pgvector-go"
+)
+
+// Converts the embedding to a pgvector `vector`
+func PGVector(data *ltypes.EmbeddingsData) pgvector.Vector {
+	return pgvector.NewVector(data.Embedding)
+}
+
+/*
+A pgvector `halfvec`, which stores every dimension in 2 bytes instead of 4 and supports indexes of
+up to 4000 dimensions. The values are sent as text and rounded to half precision by Postgres.
+*/
+type HalfVector []float32
+
+// Converts the embedding to a pgvector `halfvec`
+func PGHalfVector(data *ltypes.EmbeddingsData) HalfVector {
+	return HalfVector(data.Embedding)
+}
+
+var _ driver.Valuer = HalfVector(nil)
+var _ sql.Scanner = (*HalfVector)(nil)
+
+func (v HalfVector) Value() (driver.Value, error) {
+	return pgvector.NewVector(v).String(), nil
+}
+
+func (v *HalfVector) Scan(src any) error {
+	var vector pgvector.Vector
+	if err := vector.Scan(src); err != nil {
+		return fmt.Errorf("invalid halfvec: %v", err)
+	}
+	*v = vector.Slice()
+	return nil
+}
+
+/*
+A pgvector `sparsevec`, which only stores the dimensions that are not 0, for the sparse vectors of
+models such as SPLADE. Indices start at 0, and are sent to Postgres starting at 1.
+*/
+type SparseVector struct {
+	Dimensions int
+	Indices    []int
+	Values     []float32
+}
+
+// Keeps the dimensions of the vector that are not 0
+func NewSparseVector(v []float32) *SparseVector {
+	resp := &SparseVector{Dimensions: len(v), Indices: make([]int, 0), Values: make([]float32, 0)}
+	for idx, item := range v {
+		if item != 0 {
+			resp.Indices = append(resp.Indices, idx)
+			resp.Values = append(resp.Values, item)
+		}
+	}
+	return resp
+}
+
+// Converts the embedding to a pgvector `sparsevec`
+func PGSparseVector(data *ltypes.EmbeddingsData) *SparseVector {
+	return NewSparseVector(data.Embedding)
+}
+
+// Returns the vector with all of its dimensions
+func (v *SparseVector) Dense() []float32 {
+	resp := make([]float32, v.Dimensions)
+	for idx, item := range v.Indices {
+		resp[item] = v.Values[idx]
+	}
+	return resp
+}
+
+// Formats the vector as `{1:0.5,3:2}/5`
+func (v *SparseVector) String() string {
+	var buf strings.Builder
+	buf.WriteString("{")
+	for idx, item := range v.Indices {
+		if idx > 0 {
+			buf.WriteString(",")
+		}
+		buf.WriteString(strconv.Itoa(item + 1))
+		buf.WriteString(":")
+		buf.WriteString(strconv.FormatFloat(float64(v.Values[idx]), 'f', -1, 32))
+	}
+	buf.WriteString("}/")
+	buf.WriteString(strconv.Itoa(v.Dimensions))
+	return buf.String()
+}
+
+// Parses a vector formatted by `String`
+func (v *SparseVector) Parse(s string) error {
+	body, dimensions, ok := strings.Cut(s, "/")
+	if !ok || !strings.HasPrefix(body, "{") || !strings.HasSuffix(body, "}") {
+		return fmt.Errorf("invalid sparsevec: %s", s)
+	}
+	size, err := strconv.Atoi(dimensions)
+	if err != nil {
+		return fmt.Errorf("invalid sparsevec dimensions: %v", err)
+	}
+
+	resp := SparseVector{Dimensions: size, Indices: make([]int, 0), Values: make([]float32, 0)}
+	body = body[1 : len(body)-1]
+	if body != "" {
+		for _, item := range strings.Split(body, ",") {
+			index, value, ok := strings.Cut(item, ":")
+			if !ok {
+				return fmt.Errorf("invalid sparsevec element: %s", item)
+			}
+			i, err := strconv.Atoi(index)
+			if err != nil || i < 1 || i > size {
+				return fmt.Errorf("invalid sparsevec index: %s", index)
+			}
+			f, err := strconv.ParseFloat(value, 32)
+			if err != nil {
+				return fmt.Errorf("invalid sparsevec value: %v", err)
+			}
+			resp.Indices = append(resp.Indices, i-1)
+			resp.Values = append(resp.Values, float32(f))
+		}
+	}
+	*v = resp
+	return nil
+}
+
+var _ driver.Valuer = (*SparseVector)(nil)
+var _ sql.Scanner = (*SparseVector)(nil)
+
+func (v *SparseVector) Value() (driver.Value, error) {
+	return v.String(), nil
+}
+
+func (v *SparseVector) Scan(src any) error {
+	switch src := src.(type) {
+	case []byte:
+		return v.Parse(string(src))
+	case string:
+		return v.Parse(src)
+	default:
+		return fmt.Errorf("unsupported data type: %T", src)
+	}
+}
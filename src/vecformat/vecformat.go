@@ -0,0 +1,45 @@
+/*
+Package vecformat converts the `[]float32` vectors of `ltypes.EmbeddingsData` to the types that
+vector stores expect.
+
+The pgvector adapters implement `driver.Valuer` and `sql.Scanner`, so they can be passed to any
+`database/sql` driver as the `vector`, `halfvec` and `sparsevec` types. `Float32Blob` encodes
+vectors as little endian float32 values, the format of sqlite-vec and of flat files.
+*/
+package vecformat
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Encodes the vector as little endian float32 values, 4 bytes per dimension
+func Float32Blob(v []float32) []byte {
+	buf := make([]byte, 4*len(v))
+	for idx, item := range v {
+		binary.LittleEndian.PutUint32(buf[4*idx:], math.Float32bits(item))
+	}
+	return buf
+}
+
+// Decodes a vector encoded by `Float32Blob`
+func ParseFloat32Blob(buf []byte) ([]float32, error) {
+	if len(buf)%4 != 0 {
+		return nil, fmt.Errorf("invalid float32 blob: %d bytes is not a multiple of 4", len(buf))
+	}
+	v := make([]float32, len(buf)/4)
+	for idx := range v {
+		v[idx] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*idx:]))
+	}
+	return v, nil
+}
+
+// Copies the vector to float64 values, for libraries that work in float64
+func Float64s(v []float32) []float64 {
+	resp := make([]float64, len(v))
+	for idx, item := range v {
+		resp[idx] = float64(item)
+	}
+	return resp
+}
@@ -0,0 +1,55 @@
+package vecformat
+
+import (
+	"testing"
+
+	"github.com/jake-landersweb/gollm/v2/src/ltypes"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFloat32Blob(t *testing.T) {
+	blob := Float32Blob([]float32{0.5, -2})
+	require.Equal(t, []byte{0, 0, 0, 0x3f, 0, 0, 0, 0xc0}, blob)
+
+	v, err := ParseFloat32Blob(blob)
+	require.NoError(t, err)
+	require.Equal(t, []float32{0.5, -2}, v)
+	_, err = ParseFloat32Blob([]byte{1, 2, 3})
+	require.ErrorContains(t, err, "multiple of 4")
+
+	require.Equal(t, []float64{0.5, -2}, Float64s(v))
+}
+
+func TestPGVector(t *testing.T) {
+	data := &ltypes.EmbeddingsData{Embedding: []float32{0.5, 0, -2}}
+
+	value, err := PGVector(data).Value()
+	require.NoError(t, err)
+	require.Equal(t, "[0.5,0,-2]", value)
+
+	value, err = PGHalfVector(data).Value()
+	require.NoError(t, err)
+	require.Equal(t, "[0.5,0,-2]", value)
+	var half HalfVector
+	require.NoError(t, half.Scan([]byte("[1.5,2]")))
+	require.Equal(t, HalfVector{1.5, 2}, half)
+}
+
+func TestSparseVector(t *testing.T) {
+	sparse := PGSparseVector(&ltypes.EmbeddingsData{Embedding: []float32{0.5, 0, -2, 0}})
+	require.Equal(t, []int{0, 2}, sparse.Indices)
+
+	value, err := sparse.Value()
+	require.NoError(t, err)
+	require.Equal(t, "{1:0.5,3:-2}/4", value)
+
+	var parsed SparseVector
+	require.NoError(t, parsed.Scan("{1:0.5,3:-2}/4"))
+	require.Equal(t, []float32{0.5, 0, -2, 0}, parsed.Dense())
+	require.NoError(t, parsed.Scan([]byte("{}/3")))
+	require.Equal(t, []float32{0, 0, 0}, parsed.Dense())
+
+	require.ErrorContains(t, parsed.Scan("{5:1}/4"), "invalid sparsevec index")
+	require.ErrorContains(t, parsed.Scan("[1,2]"), "invalid sparsevec")
+	require.ErrorContains(t, parsed.Scan(1), "unsupported data type")
+}
@@ -0,0 +1,153 @@
+package vecmath
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// A vector quantized to int8 values. The original values are approximately `Values[i] * Scale`
+type Int8Vector struct {
+	Values []int8
+	Scale  float32
+}
+
+// Quantizes the vector to int8 values with a symmetric scale, so the largest magnitude maps to 127
+func QuantizeInt8(v []float32) *Int8Vector {
+	var largest float64
+	for _, item := range v {
+		largest = max(largest, math.Abs(float64(item)))
+	}
+	resp := &Int8Vector{Values: make([]int8, len(v))}
+	if largest == 0 {
+		return resp
+	}
+	scale := largest / 127
+	for i, item := range v {
+		resp.Values[i] = int8(max(-127, min(127, math.Round(float64(item)/scale))))
+	}
+	resp.Scale = float32(scale)
+	return resp
+}
+
+// Returns the approximate original values of the vector
+func (v *Int8Vector) Dequantize() []float32 {
+	resp := make([]float32, len(v.Values))
+	for i, item := range v.Values {
+		resp[i] = float32(item) * v.Scale
+	}
+	return resp
+}
+
+// Returns the approximate dot product of the full vector and the quantized vector
+func (v *Int8Vector) Dot(query []float32) (float64, error) {
+	if len(query) != len(v.Values) {
+		return 0, fmt.Errorf("the vectors have different dimensions: %d and %d", len(query), len(v.Values))
+	}
+	var sum float64
+	for i, item := range v.Values {
+		sum += float64(query[i]) * float64(item)
+	}
+	return sum * float64(v.Scale), nil
+}
+
+/*
+Quantizes the vector to one bit per dimension, set when the value is positive. The bits are packed
+from the most significant bit of the first byte, so a vector of 1024 dimensions takes 128 bytes.
+*/
+func QuantizeBinary(v []float32) []byte {
+	resp := make([]byte, (len(v)+7)/8)
+	for i, item := range v {
+		if item > 0 {
+			resp[i/8] |= 0x80 >> (i % 8)
+		}
+	}
+	return resp
+}
+
+// Returns the number of bits that differ between the binary vectors, where smaller is closer
+func Hamming(a []byte, b []byte) (int, error) {
+	if len(a) != len(b) {
+		return 0, fmt.Errorf("the vectors have different lengths: %d and %d", len(a), len(b))
+	}
+	count := 0
+	for i := range a {
+		count += bits.OnesCount8(a[i] ^ b[i])
+	}
+	return count, nil
+}
+
+// Returns the dot product of the full query and the binary vector read as -1 and 1 values
+func binaryDot(query []float32, v []byte) float64 {
+	var sum float64
+	for i, item := range query {
+		if v[i/8]&(0x80>>(i%8)) != 0 {
+			sum += float64(item)
+		} else {
+			sum -= float64(item)
+		}
+	}
+	return sum
+}
+
+// A match of a search over quantized vectors, where a larger score is closer
+type Scored struct {
+	Index int
+	Score float64
+}
+
+func sortScored(items []*Scored) {
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Score > items[j].Score
+	})
+}
+
+/*
+Searches binary vectors for the closest k to the query. The `k * oversample` vectors with the
+smallest hamming distance to the quantized query are rescored with the dot product of the full
+query, which recovers most of the accuracy that the quantization loses. An oversample under 1
+defaults to 4.
+*/
+func SearchBinary(query []float32, corpus [][]byte, k int, oversample int) ([]*Scored, error) {
+	if k <= 0 {
+		return nil, fmt.Errorf("k must be greater than 0")
+	}
+	if oversample < 1 {
+		oversample = 4
+	}
+	quantized := QuantizeBinary(query)
+	candidates := make([]*Scored, len(corpus))
+	for idx, item := range corpus {
+		distance, err := Hamming(quantized, item)
+		if err != nil {
+			return nil, fmt.Errorf("vector %d: %v", idx, err)
+		}
+		candidates[idx] = &Scored{Index: idx, Score: -float64(distance)}
+	}
+	sortScored(candidates)
+	candidates = candidates[:min(len(candidates), k*oversample)]
+
+	for _, item := range candidates {
+		item.Score = binaryDot(query, corpus[item.Index])
+	}
+	sortScored(candidates)
+	return candidates[:min(len(candidates), k)], nil
+}
+
+// Searches int8 vectors for the k with the largest dot product with the full query
+func SearchInt8(query []float32, corpus []*Int8Vector, k int) ([]*Scored, error) {
+	if k <= 0 {
+		return nil, fmt.Errorf("k must be greater than 0")
+	}
+	resp := make([]*Scored, len(corpus))
+	for idx, item := range corpus {
+		score, err := item.Dot(query)
+		if err != nil {
+			return nil, fmt.Errorf("vector %d: %v", idx, err)
+		}
+		resp[idx] = &Scored{Index: idx, Score: score}
+	}
+	sortScored(resp)
+	return resp[:min(len(resp), k)], nil
+}
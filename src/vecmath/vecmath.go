@@ -0,0 +1,125 @@
+/*
+Package vecmath compares and compacts embeddings without a database.
+
+It measures the similarity of vectors, normalizes them, truncates the embeddings of Matryoshka
+models such as `text-embedding-3` to fewer dimensions, and quantizes them to int8 or binary
+values, with searches that rescore the quantized candidates with the full query.
+*/
+package vecmath
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/jake-landersweb/gollm/v2/src/ltypes"
+)
+
+func checkDimensions(a []float32, b []float32) error {
+	if len(a) != len(b) {
+		return fmt.Errorf("the vectors have different dimensions: %d and %d", len(a), len(b))
+	}
+	if len(a) == 0 {
+		return fmt.Errorf("the vectors cannot be empty")
+	}
+	return nil
+}
+
+// Returns the dot product of the vectors, which equals their cosine similarity when both are normalized
+func Dot(a []float32, b []float32) (float64, error) {
+	if err := checkDimensions(a, b); err != nil {
+		return 0, err
+	}
+	return dot(a, b), nil
+}
+
+func dot(a []float32, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
+
+// Returns the cosine of the angle between the vectors, from -1 to 1. Vectors without a length have a similarity of 0
+func Cosine(a []float32, b []float32) (float64, error) {
+	if err := checkDimensions(a, b); err != nil {
+		return 0, err
+	}
+	normA, normB := Norm(a), Norm(b)
+	if normA == 0 || normB == 0 {
+		return 0, nil
+	}
+	return dot(a, b) / (normA * normB), nil
+}
+
+// Returns the euclidean distance between the vectors
+func L2(a []float32, b []float32) (float64, error) {
+	if err := checkDimensions(a, b); err != nil {
+		return 0, err
+	}
+	var sum float64
+	for i := range a {
+		d := float64(a[i]) - float64(b[i])
+		sum += d * d
+	}
+	return math.Sqrt(sum), nil
+}
+
+// Returns the euclidean length of the vector
+func Norm(v []float32) float64 {
+	return math.Sqrt(dot(v, v))
+}
+
+// Returns a copy of the vector scaled to a length of 1. Vectors without a length are returned as a copy
+func Normalize(v []float32) []float32 {
+	resp := make([]float32, len(v))
+	norm := Norm(v)
+	if norm == 0 {
+		copy(resp, v)
+		return resp
+	}
+	for i, item := range v {
+		resp[i] = float32(float64(item) / norm)
+	}
+	return resp
+}
+
+/*
+Keeps the first dimensions of the vector and normalizes the result. This matches the vectors that
+Matryoshka models such as `text-embedding-3` return when they are asked for fewer `Dimensions`,
+so vectors can be stored once and compared at several sizes.
+*/
+func Truncate(v []float32, dimensions int) ([]float32, error) {
+	if dimensions <= 0 || dimensions > len(v) {
+		return nil, fmt.Errorf("cannot truncate a vector of %d dimensions to %d", len(v), dimensions)
+	}
+	return Normalize(v[:dimensions]), nil
+}
+
+// Returns the cosine similarity of the embeddings
+func Similarity(a *ltypes.EmbeddingsData, b *ltypes.EmbeddingsData) (float64, error) {
+	return Cosine(a.Embedding, b.Embedding)
+}
+
+// Normalizes the vectors of the embeddings in place
+func NormalizeEmbeddings(items []*ltypes.EmbeddingsData) {
+	for _, item := range items {
+		item.Embedding = Normalize(item.Embedding)
+	}
+}
+
+// Truncates the vectors of the embeddings in place, failing without changes if any is too small
+func TruncateEmbeddings(items []*ltypes.EmbeddingsData, dimensions int) error {
+	vectors := make([][]float32, len(items))
+	for idx, item := range items {
+		vector, err := Truncate(item.Embedding, dimensions)
+		if err != nil {
+			return fmt.Errorf("embedding %d: %v", idx, err)
+		}
+		vectors[idx] = vector
+	}
+	for idx, item := range items {
+		item.Embedding = vectors[idx]
+	}
+	return nil
+}
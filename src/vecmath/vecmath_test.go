@@ -0,0 +1,133 @@
+package vecmath
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/jake-landersweb/gollm/v2/src/ltypes"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimilarity(t *testing.T) {
+	a, b := []float32{1, 0, 0}, []float32{1, 1, 0}
+
+	dot, err := Dot(a, b)
+	require.NoError(t, err)
+	require.Equal(t, 1.0, dot)
+
+	cosine, err := Cosine(a, b)
+	require.NoError(t, err)
+	require.InDelta(t, 1/math.Sqrt2, cosine, 1e-9)
+
+	distance, err := L2(a, b)
+	require.NoError(t, err)
+	require.Equal(t, 1.0, distance)
+
+	cosine, err = Cosine(a, []float32{0, 0, 0})
+	require.NoError(t, err)
+	require.Equal(t, 0.0, cosine)
+
+	_, err = Dot(a, []float32{1})
+	require.ErrorContains(t, err, "different dimensions")
+	_, err = L2(nil, nil)
+	require.ErrorContains(t, err, "empty")
+
+	cosine, err = Similarity(
+		&ltypes.EmbeddingsData{Embedding: a},
+		&ltypes.EmbeddingsData{Embedding: []float32{2, 0, 0}},
+	)
+	require.NoError(t, err)
+	require.InDelta(t, 1, cosine, 1e-9)
+}
+
+func TestNormalizeAndTruncate(t *testing.T) {
+	v := []float32{3, 4, 12}
+	normalized := Normalize(v)
+	require.InDelta(t, 1, Norm(normalized), 1e-6)
+	require.Equal(t, []float32{3, 4, 12}, v)
+
+	truncated, err := Truncate(v, 2)
+	require.NoError(t, err)
+	require.InDeltaSlice(t, []float32{0.6, 0.8}, truncated, 1e-6)
+	_, err = Truncate(v, 4)
+	require.ErrorContains(t, err, "cannot truncate")
+
+	// the embeddings are only changed when all of them can be truncated
+	items := []*ltypes.EmbeddingsData{
+		{Embedding: []float32{3, 4, 12}},
+		{Embedding: []float32{1}},
+	}
+	require.Error(t, TruncateEmbeddings(items, 2))
+	require.Len(t, items[0].Embedding, 3)
+	require.NoError(t, TruncateEmbeddings(items[:1], 2))
+	require.InDeltaSlice(t, []float32{0.6, 0.8}, items[0].Embedding, 1e-6)
+
+	NormalizeEmbeddings(items[1:])
+	require.Equal(t, []float32{1}, items[1].Embedding)
+}
+
+func TestQuantizeInt8(t *testing.T) {
+	v := []float32{0.5, -1, 0.25, 0}
+	quantized := QuantizeInt8(v)
+	require.Equal(t, []int8{64, -127, 32, 0}, quantized.Values)
+	require.InDeltaSlice(t, v, quantized.Dequantize(), 0.01)
+
+	dot, err := quantized.Dot([]float32{1, 1, 1, 1})
+	require.NoError(t, err)
+	require.InDelta(t, -0.25, dot, 0.01)
+
+	require.Equal(t, float32(0), QuantizeInt8([]float32{0, 0}).Scale)
+}
+
+func TestQuantizeBinary(t *testing.T) {
+	require.Equal(t, []byte{0b10100000, 0b10000000}, QuantizeBinary([]float32{1, -1, 0.5, 0, -2, -1, -1, -1, 3}))
+
+	distance, err := Hamming([]byte{0b1111}, []byte{0b1010})
+	require.NoError(t, err)
+	require.Equal(t, 2, distance)
+	_, err = Hamming([]byte{1}, []byte{1, 2})
+	require.ErrorContains(t, err, "different lengths")
+}
+
+func TestSearch(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	corpus := make([][]float32, 200)
+	for i := range corpus {
+		corpus[i] = make([]float32, 64)
+		for j := range corpus[i] {
+			corpus[i][j] = float32(random.NormFloat64())
+		}
+		corpus[i] = Normalize(corpus[i])
+	}
+
+	// a query close to one of the vectors finds it first
+	query := make([]float32, 64)
+	for j := range query {
+		query[j] = corpus[42][j] + float32(random.NormFloat64()*0.05)
+	}
+
+	binary := make([][]byte, len(corpus))
+	int8s := make([]*Int8Vector, len(corpus))
+	for i, item := range corpus {
+		binary[i] = QuantizeBinary(item)
+		int8s[i] = QuantizeInt8(item)
+	}
+
+	results, err := SearchBinary(query, binary, 3, 0)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	require.Equal(t, 42, results[0].Index)
+	require.GreaterOrEqual(t, results[0].Score, results[1].Score)
+
+	results, err = SearchInt8(query, int8s, 3)
+	require.NoError(t, err)
+	require.Equal(t, 42, results[0].Index)
+	expected, _ := Dot(query, corpus[42])
+	require.InDelta(t, expected, results[0].Score, 0.05)
+
+	_, err = SearchBinary(query, binary, 0, 4)
+	require.ErrorContains(t, err, "k must be")
+	_, err = SearchInt8(query[:3], int8s, 1)
+	require.ErrorContains(t, err, "vector 0")
+}
@@ -0,0 +1,245 @@
+package vectorstore
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+/*
+A lexical index that scores documents against a query with Okapi BM25. Complements vector
+searches by matching exact terms, such as identifiers and error codes, that embeddings blur.
+The embeddings of the documents are ignored, so the same documents can be upserted into both.
+Safe for concurrent use.
+*/
+type BM25Index struct {
+	opts *BM25Opts
+	k1   float64
+	b    float64
+
+	mu          sync.RWMutex
+	documents   map[string]*bm25Document
+	postings    map[string]map[string]int // term -> document id -> frequency
+	totalLength int
+}
+
+type bm25Document struct {
+	document *Document
+	metadata map[string]any
+	length   int
+	terms    map[string]int
+}
+
+type BM25Opts struct {
+	// Saturates the frequency of terms. Defaults to 1.2 when nil, and 0 ignores how often terms occur
+	K1 *float64
+
+	// How much the length of documents normalizes the scores, from 0 to 1. Defaults to 0.75 when nil,
+	// and 0 turns off the length normalization
+	B *float64
+
+	// Splits text into terms. Defaults to `Tokenize`
+	Tokenizer func(text string) []string
+}
+
+const (
+	bm25_default_k1 = 1.2
+	bm25_default_b  = 0.75
+)
+
+func NewBM25Index(opts *BM25Opts) *BM25Index {
+	resp := BM25Opts{}
+	if opts != nil {
+		resp = *opts
+	}
+	k1, b := bm25_default_k1, bm25_default_b
+	if resp.K1 != nil {
+		k1 = *resp.K1
+	}
+	if resp.B != nil {
+		b = *resp.B
+	}
+	if resp.Tokenizer == nil {
+		resp.Tokenizer = Tokenize
+	}
+	return &BM25Index{
+		opts:      &resp,
+		k1:        k1,
+		b:         b,
+		documents: make(map[string]*bm25Document),
+		postings:  make(map[string]map[string]int),
+	}
+}
+
+/*
+Lowercases the text and splits it into runs of letters, digits and underscores, so identifiers
+like `ERR_TIMEOUT` are kept whole and `HTTP-503` matches both `http` and `503`.
+*/
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+}
+
+// Indexes the content of the documents, replacing the indexed documents with the same ids
+func (i *BM25Index) Upsert(ctx context.Context, documents ...*Document) error {
+	items := make([]*bm25Document, 0, len(documents))
+	for idx, item := range documents {
+		if item == nil {
+			return fmt.Errorf("document %d cannot be nil", idx)
+		}
+		if item.ID == "" {
+			return fmt.Errorf("document %d must have an id", idx)
+		}
+		metadata, err := normalizeMetadata(item.Metadata)
+		if err != nil {
+			return err
+		}
+		document := copyDocument(item)
+		terms := i.opts.Tokenizer(item.Content)
+		frequencies := make(map[string]int)
+		for _, term := range terms {
+			frequencies[term]++
+		}
+		items = append(items, &bm25Document{document: document, metadata: metadata, length: len(terms), terms: frequencies})
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for _, item := range items {
+		i.remove(item.document.ID)
+		i.documents[item.document.ID] = item
+		i.totalLength += item.length
+		for term, frequency := range item.terms {
+			if i.postings[term] == nil {
+				i.postings[term] = make(map[string]int)
+			}
+			i.postings[term][item.document.ID] = frequency
+		}
+	}
+	return nil
+}
+
+func (i *BM25Index) Delete(ctx context.Context, ids ...string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for _, id := range ids {
+		i.remove(id)
+	}
+	return nil
+}
+
+// Deletes the documents whose metadata contains the filter, matched like `Query.Filter`
+func (i *BM25Index) DeleteMatching(ctx context.Context, filter map[string]any) error {
+	if err := validateDeleteFilter(filter); err != nil {
+		return err
+	}
+	normalized, err := normalizeMetadata(filter)
+	if err != nil {
+		return err
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for id, item := range i.documents {
+		if matchesFilter(item.metadata, normalized) {
+			i.remove(id)
+		}
+	}
+	return nil
+}
+
+// Removes a document from the index. The lock must be held
+func (i *BM25Index) remove(id string) {
+	item, ok := i.documents[id]
+	if !ok {
+		return
+	}
+	for term := range item.terms {
+		delete(i.postings[term], id)
+		if len(i.postings[term]) == 0 {
+			delete(i.postings, term)
+		}
+	}
+	i.totalLength -= item.length
+	delete(i.documents, id)
+}
+
+/*
+Returns the k documents with the highest scores for the query that match the filter, best first.
+Documents that share no terms with the query are not returned, so queries without terms return
+none. The `Distance` of the results is the negative score, so smaller is closer like the vector
+stores.
+*/
+func (i *BM25Index) Search(ctx context.Context, query string, k int, filter map[string]any) ([]*Result, error) {
+	if k < 0 {
+		return nil, fmt.Errorf("k cannot be negative")
+	}
+	if k == 0 {
+		k = default_k
+	}
+	normalized, err := normalizeMetadata(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	terms := make(map[string]bool)
+	for _, term := range i.opts.Tokenizer(query) {
+		terms[term] = true
+	}
+	if len(terms) == 0 {
+		return []*Result{}, nil
+	}
+
+	i.mu.RLock()
+	count := float64(len(i.documents))
+	averageLength := 0.0
+	if count != 0 {
+		averageLength = float64(i.totalLength) / count
+	}
+	scores := make(map[string]float64)
+	for term := range terms {
+		postings := i.postings[term]
+		if len(postings) == 0 {
+			continue
+		}
+		frequency := float64(len(postings))
+		idf := math.Log(1 + (count-frequency+0.5)/(frequency+0.5))
+		for id, tf := range postings {
+			length := float64(i.documents[id].length)
+			norm := i.k1 * (1 - i.b + i.b*length/max(averageLength, 1))
+			scores[id] += idf * float64(tf) * (i.k1 + 1) / (float64(tf) + norm)
+		}
+	}
+	results := make([]*Result, 0, len(scores))
+	for id, score := range scores {
+		item := i.documents[id]
+		if !matchesFilter(item.metadata, normalized) {
+			continue
+		}
+		results = append(results, &Result{Document: copyDocument(item.document), Distance: -score})
+	}
+	i.mu.RUnlock()
+
+	sort.Slice(results, func(a, b int) bool {
+		if results[a].Distance != results[b].Distance {
+			return results[a].Distance < results[b].Distance
+		}
+		return results[a].Document.ID < results[b].Document.ID
+	})
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results, nil
+}
+
+// Returns the number of indexed documents
+func (i *BM25Index) Len() int {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return len(i.documents)
+}
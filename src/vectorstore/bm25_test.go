@@ -0,0 +1,90 @@
+package vectorstore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenize(t *testing.T) {
+	require.Equal(t, []string{"retry", "on", "err_timeout", "http", "503"}, Tokenize("Retry on ERR_TIMEOUT (HTTP-503)."))
+	require.Empty(t, Tokenize("?!"))
+}
+
+func TestBM25Index(t *testing.T) {
+	ctx := context.TODO()
+	index := NewBM25Index(nil)
+	require.NoError(t, index.Upsert(ctx,
+		&Document{ID: "timeout", Content: "The request failed with ERR_TIMEOUT after 30 seconds.", Metadata: map[string]any{"source": "errors"}},
+		&Document{ID: "auth", Content: "The request failed with ERR_AUTH because the key was invalid.", Metadata: map[string]any{"source": "errors"}},
+		&Document{ID: "guide", Content: "Retry a failed request. Retry it again if it keeps failing.", Metadata: map[string]any{"source": "guide"}},
+	))
+	require.Equal(t, 3, index.Len())
+
+	// rare terms outweigh common ones
+	results, err := index.Search(ctx, "request ERR_TIMEOUT", 0, nil)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	require.Equal(t, "timeout", results[0].Document.ID)
+	require.Less(t, results[0].Distance, results[1].Distance)
+
+	// repeated terms score higher, and documents without any terms are not returned
+	results, err = index.Search(ctx, "retry", 0, nil)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.Equal(t, "guide", results[0].Document.ID)
+
+	results, err = index.Search(ctx, "failed", 1, map[string]any{"source": "errors"})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.Equal(t, "timeout", results[0].Document.ID) // the shorter document scores higher
+
+	// upserts replace the terms of the document, and deletes remove them
+	require.NoError(t, index.Upsert(ctx, &Document{ID: "timeout", Content: "Connections are pooled."}))
+	require.NoError(t, index.Delete(ctx, "auth", "missing"))
+	results, err = index.Search(ctx, "ERR_TIMEOUT ERR_AUTH", 0, nil)
+	require.NoError(t, err)
+	require.Empty(t, results)
+	results, err = index.Search(ctx, "pooled", 0, nil)
+	require.NoError(t, err)
+	require.Equal(t, "timeout", results[0].Document.ID)
+
+	results, err = index.Search(ctx, "...", 0, nil)
+	require.NoError(t, err)
+	require.Empty(t, results)
+	require.ErrorContains(t, index.Upsert(ctx, &Document{Content: "no id"}), "must have an id")
+
+	// deleting by metadata removes the terms of the matching documents
+	require.NoError(t, index.DeleteMatching(ctx, map[string]any{"source": "guide"}))
+	require.Equal(t, 1, index.Len())
+	results, err = index.Search(ctx, "retry", 0, nil)
+	require.NoError(t, err)
+	require.Empty(t, results)
+	require.ErrorContains(t, index.DeleteMatching(ctx, map[string]any{}), "cannot be empty")
+}
+
+func TestBM25IndexOpts(t *testing.T) {
+	ctx := context.TODO()
+	documents := []*Document{
+		{ID: "short", Content: "timeout"},
+		{ID: "long", Content: "the request hit a timeout after retrying"},
+	}
+
+	// by default the shorter document scores higher
+	index := NewBM25Index(nil)
+	require.NoError(t, index.Upsert(ctx, documents...))
+	results, err := index.Search(ctx, "timeout", 0, nil)
+	require.NoError(t, err)
+	require.Equal(t, "short", results[0].Document.ID)
+	require.Less(t, results[0].Distance, results[1].Distance)
+
+	// a b of 0 turns off the length normalization, so both score the same
+	b := 0.0
+	index = NewBM25Index(&BM25Opts{B: &b})
+	require.NoError(t, index.Upsert(ctx, documents...))
+	results, err = index.Search(ctx, "timeout", 0, nil)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	require.InDelta(t, results[0].Distance, results[1].Distance, 1e-9)
+}
@@ -0,0 +1,252 @@
+package vectorstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// Stores the documents in memory and compares the query to every document. Safe for concurrent use
+type MemoryStore struct {
+	metric Metric
+
+	mu        sync.RWMutex
+	documents map[string]*memoryDocument
+}
+
+type memoryDocument struct {
+	document *Document
+	metadata map[string]any // the metadata after a json round trip, so filters compare by value
+}
+
+// Creates an empty store. Defaults to `MetricCosine` when the metric is empty
+func NewMemoryStore(metric Metric) (*MemoryStore, error) {
+	if metric == "" {
+		metric = MetricCosine
+	}
+	if err := metric.isValid(); err != nil {
+		return nil, err
+	}
+	return &MemoryStore{metric: metric, documents: make(map[string]*memoryDocument)}, nil
+}
+
+func (s *MemoryStore) Upsert(ctx context.Context, documents ...*Document) error {
+	if err := validateDocuments(documents); err != nil {
+		return err
+	}
+
+	// copy the documents so changes by the caller do not leak into the store
+	items := make([]*memoryDocument, 0, len(documents))
+	for _, item := range documents {
+		metadata, err := normalizeMetadata(item.Metadata)
+		if err != nil {
+			return err
+		}
+		items = append(items, &memoryDocument{document: copyDocument(item), metadata: metadata})
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, item := range items {
+		s.documents[item.document.ID] = item
+	}
+	return nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, ids ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		delete(s.documents, id)
+	}
+	return nil
+}
+
+func (s *MemoryStore) DeleteMatching(ctx context.Context, filter map[string]any) error {
+	if err := validateDeleteFilter(filter); err != nil {
+		return err
+	}
+	normalized, err := normalizeMetadata(filter)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, item := range s.documents {
+		if matchesFilter(item.metadata, normalized) {
+			delete(s.documents, id)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) Search(ctx context.Context, query *Query) ([]*Result, error) {
+	if err := query.isValid(); err != nil {
+		return nil, err
+	}
+	filter, err := normalizeMetadata(query.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	results := make([]*Result, 0)
+	for _, item := range s.documents {
+		if !matchesFilter(item.metadata, filter) {
+			continue
+		}
+		if len(item.document.Embedding) != len(query.Embedding) {
+			s.mu.RUnlock()
+			return nil, fmt.Errorf("the query has %d dimensions, but the document %s has %d", len(query.Embedding), item.document.ID, len(item.document.Embedding))
+		}
+		results = append(results, &Result{
+			Document: copyDocument(item.document),
+			Distance: distance(s.metric, query.Embedding, item.document.Embedding),
+		})
+	}
+	s.mu.RUnlock()
+
+	// sort by distance, with the id as the tie breaker so the order is stable
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Distance != results[j].Distance {
+			return results[i].Distance < results[j].Distance
+		}
+		return results[i].Document.ID < results[j].Document.ID
+	})
+	if len(results) > query.K {
+		results = results[:query.K]
+	}
+	return results, nil
+}
+
+// Returns the number of stored documents
+func (s *MemoryStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.documents)
+}
+
+func normalizeMetadata(metadata map[string]any) (map[string]any, error) {
+	enc, err := encodeMetadata(metadata)
+	if err != nil {
+		return nil, err
+	}
+	resp := make(map[string]any)
+	if err := json.Unmarshal(enc, &resp); err != nil {
+		return nil, fmt.Errorf("there was an issue decoding the metadata: %v", err)
+	}
+	return resp, nil
+}
+
+// Copies the document with its embedding and metadata, so neither the caller nor the store can change the other's
+func copyDocument(document *Document) *Document {
+	resp := *document
+	resp.Embedding = append([]float32(nil), document.Embedding...)
+	if document.Metadata != nil {
+		resp.Metadata = cloneValue(reflect.ValueOf(document.Metadata)).Interface().(map[string]any)
+	}
+	return &resp
+}
+
+// Deep copies the maps and slices in a value, keeping their types
+func cloneValue(value reflect.Value) reflect.Value {
+	switch value.Kind() {
+	case reflect.Interface:
+		if value.IsNil() {
+			return value
+		}
+		resp := reflect.New(value.Type()).Elem()
+		resp.Set(cloneValue(value.Elem()))
+		return resp
+	case reflect.Map:
+		if value.IsNil() {
+			return value
+		}
+		resp := reflect.MakeMapWithSize(value.Type(), value.Len())
+		iter := value.MapRange()
+		for iter.Next() {
+			resp.SetMapIndex(iter.Key(), cloneValue(iter.Value()))
+		}
+		return resp
+	case reflect.Slice:
+		if value.IsNil() {
+			return value
+		}
+		resp := reflect.MakeSlice(value.Type(), value.Len(), value.Len())
+		for i := 0; i < value.Len(); i++ {
+			resp.Index(i).Set(cloneValue(value.Index(i)))
+		}
+		return resp
+	default:
+		return value
+	}
+}
+
+// Matches the metadata with the same containment as the jsonb `@>` operator used by the Postgres store
+func matchesFilter(metadata map[string]any, filter map[string]any) bool {
+	return containsJSON(metadata, filter)
+}
+
+/*
+Checks whether the decoded json value contains the other, the same as jsonb containment: objects
+contain the objects whose keys they contain, arrays contain the arrays whose elements are each
+contained by one of their elements, and scalars only contain equal values.
+*/
+func containsJSON(container any, value any) bool {
+	switch value := value.(type) {
+	case map[string]any:
+		object, ok := container.(map[string]any)
+		if !ok {
+			return false
+		}
+		for key, item := range value {
+			found, ok := object[key]
+			if !ok || !containsJSON(found, item) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		array, ok := container.([]any)
+		if !ok {
+			return false
+		}
+		for _, item := range value {
+			if !slices.ContainsFunc(array, func(found any) bool { return containsJSON(found, item) }) {
+				return false
+			}
+		}
+		return true
+	default:
+		return container == value
+	}
+}
+
+func distance(metric Metric, a []float32, b []float32) float64 {
+	var dot, normA, normB, squared float64
+	for i := range a {
+		x, y := float64(a[i]), float64(b[i])
+		dot += x * y
+		normA += x * x
+		normB += y * y
+		squared += (x - y) * (x - y)
+	}
+
+	switch metric {
+	case MetricL2:
+		return math.Sqrt(squared)
+	case MetricInnerProduct:
+		return -dot
+	default:
+		if normA == 0 || normB == 0 {
+			return 1
+		}
+		return 1 - dot/(math.Sqrt(normA)*math.Sqrt(normB))
+	}
+}
@@ -0,0 +1,167 @@
+package vectorstore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jake-landersweb/gollm/v2/src/ltypes"
+	"github.com/stretchr/testify/require"
+)
+
+func testDocuments() []*Document {
+	return []*Document{
+		{ID: "north", Content: "north", Embedding: []float32{0, 1}, Metadata: map[string]any{"source": "compass", "page": 1}},
+		{ID: "east", Content: "east", Embedding: []float32{1, 0}, Metadata: map[string]any{"source": "compass", "page": 2}},
+		{ID: "north-east", Content: "north east", Embedding: []float32{2, 2}, Metadata: map[string]any{"source": "map"}},
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.TODO()
+	store, err := NewMemoryStore("")
+	require.NoError(t, err)
+	require.NoError(t, store.Upsert(ctx, testDocuments()...))
+	require.Equal(t, 3, store.Len())
+
+	// cosine ignores the length of the vectors
+	results, err := store.Search(ctx, &Query{Embedding: []float32{1, 0.9}, K: 2})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	require.Equal(t, "north-east", results[0].Document.ID)
+	require.Equal(t, "east", results[1].Document.ID)
+	require.InDelta(t, 0.0014, results[0].Distance, 0.0001)
+
+	// filters compare the metadata by value, so ints match the decoded numbers
+	results, err = store.Search(ctx, &Query{Embedding: []float32{1, 0.9}, Filter: map[string]any{"source": "compass", "page": 1}})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.Equal(t, "north", results[0].Document.ID)
+
+	// upserts replace documents, and deletes ignore unknown ids
+	require.NoError(t, store.Upsert(ctx, &Document{ID: "north", Content: "south", Embedding: []float32{0, -1}}))
+	require.NoError(t, store.Delete(ctx, "east", "missing"))
+	results, err = store.Search(ctx, &Query{Embedding: []float32{0, 1}})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	require.Equal(t, "north-east", results[0].Document.ID)
+	require.Equal(t, "south", results[1].Document.Content)
+	require.InDelta(t, 2, results[1].Distance, 1e-9)
+}
+
+func TestMemoryStoreCopies(t *testing.T) {
+	ctx := context.TODO()
+	store, err := NewMemoryStore("")
+	require.NoError(t, err)
+
+	document := &Document{ID: "a", Embedding: []float32{1, 0}, Metadata: map[string]any{"tags": []any{"x"}}}
+	require.NoError(t, store.Upsert(ctx, document))
+
+	// changes by the caller after the upsert do not leak into the store
+	document.Embedding[0] = -1
+	document.Metadata["tags"] = []any{"y"}
+	results, err := store.Search(ctx, &Query{Embedding: []float32{1, 0}})
+	require.NoError(t, err)
+	require.Equal(t, []float32{1, 0}, results[0].Document.Embedding)
+	require.Equal(t, []any{"x"}, results[0].Document.Metadata["tags"])
+
+	// nor do changes to the results
+	results[0].Document.Embedding[0] = -1
+	results[0].Document.Metadata["tags"].([]any)[0] = "z"
+	results, err = store.Search(ctx, &Query{Embedding: []float32{1, 0}})
+	require.NoError(t, err)
+	require.Equal(t, []float32{1, 0}, results[0].Document.Embedding)
+	require.Equal(t, []any{"x"}, results[0].Document.Metadata["tags"])
+}
+
+func TestMemoryStoreFilterContainment(t *testing.T) {
+	ctx := context.TODO()
+	store, err := NewMemoryStore("")
+	require.NoError(t, err)
+	require.NoError(t, store.Upsert(ctx,
+		&Document{ID: "a", Embedding: []float32{1, 0}, Metadata: map[string]any{"tags": []string{"go", "llm"}, "author": map[string]any{"name": "jake", "team": "core"}}},
+		&Document{ID: "b", Embedding: []float32{0, 1}, Metadata: map[string]any{"tags": []string{"go"}, "author": map[string]any{"name": "sam"}}},
+	))
+
+	// filters match like the jsonb `@>` operator of the Postgres store
+	for _, test := range []struct {
+		filter map[string]any
+		ids    []string
+	}{
+		{map[string]any{"tags": []string{"go"}}, []string{"a", "b"}},
+		{map[string]any{"tags": []string{"llm"}}, []string{"a"}},
+		{map[string]any{"tags": []string{"llm", "go"}}, []string{"a"}},
+		{map[string]any{"tags": "go"}, []string{}},
+		{map[string]any{"author": map[string]any{"name": "jake"}}, []string{"a"}},
+		{map[string]any{"author": map[string]any{"team": "core", "name": "sam"}}, []string{}},
+	} {
+		results, err := store.Search(ctx, &Query{Embedding: []float32{1, 1}, Filter: test.filter})
+		require.NoError(t, err)
+		ids := make([]string, 0)
+		for _, item := range results {
+			ids = append(ids, item.Document.ID)
+		}
+		require.ElementsMatch(t, test.ids, ids, "filter %v", test.filter)
+	}
+}
+
+func TestMemoryStoreDeleteMatching(t *testing.T) {
+	ctx := context.TODO()
+	store, err := NewMemoryStore("")
+	require.NoError(t, err)
+	require.NoError(t, store.Upsert(ctx, testDocuments()...))
+
+	require.ErrorContains(t, store.DeleteMatching(ctx, nil), "cannot be empty")
+	require.NoError(t, store.DeleteMatching(ctx, map[string]any{"source": "compass"}))
+	require.Equal(t, 1, store.Len())
+	results, err := store.Search(ctx, &Query{Embedding: []float32{0, 1}})
+	require.NoError(t, err)
+	require.Equal(t, "north-east", results[0].Document.ID)
+}
+
+func TestMemoryStoreMetrics(t *testing.T) {
+	ctx := context.TODO()
+
+	store, err := NewMemoryStore(MetricL2)
+	require.NoError(t, err)
+	require.NoError(t, store.Upsert(ctx, testDocuments()...))
+	results, err := store.Search(ctx, &Query{Embedding: []float32{1, 1}, K: 1})
+	require.NoError(t, err)
+	require.Equal(t, "east", results[0].Document.ID) // ties with north, broken by id
+	require.InDelta(t, 1, results[0].Distance, 1e-9)
+
+	store, err = NewMemoryStore(MetricInnerProduct)
+	require.NoError(t, err)
+	require.NoError(t, store.Upsert(ctx, testDocuments()...))
+	results, err = store.Search(ctx, &Query{Embedding: []float32{1, 1}, K: 1})
+	require.NoError(t, err)
+	require.Equal(t, "north-east", results[0].Document.ID)
+	require.InDelta(t, -4, results[0].Distance, 1e-9)
+
+	_, err = NewMemoryStore("manhattan")
+	require.ErrorContains(t, err, "unsupported metric")
+}
+
+func TestMemoryStoreErrors(t *testing.T) {
+	ctx := context.TODO()
+	store, err := NewMemoryStore(MetricCosine)
+	require.NoError(t, err)
+
+	require.ErrorContains(t, store.Upsert(ctx, &Document{Embedding: []float32{1}}), "must have an id")
+	require.ErrorContains(t, store.Upsert(ctx, &Document{ID: "a"}), "must have an embedding")
+
+	require.NoError(t, store.Upsert(ctx, &Document{ID: "a", Embedding: []float32{1, 2}}))
+	_, err = store.Search(ctx, &Query{Embedding: []float32{1, 2, 3}})
+	require.ErrorContains(t, err, "dimensions")
+	_, err = store.Search(ctx, &Query{})
+	require.ErrorContains(t, err, "cannot be empty")
+}
+
+func TestNewDocument(t *testing.T) {
+	document := NewDocument("doc-1#0", &ltypes.EmbeddingsData{
+		Raw:       "Hello world",
+		Embedding: []float32{0.5, 0.25},
+	}, map[string]any{"source": "doc-1"})
+	require.Equal(t, "Hello world", document.Content)
+	require.Equal(t, []float32{0.5, 0.25}, document.Embedding)
+	require.Equal(t, "doc-1", document.Metadata["source"])
+}
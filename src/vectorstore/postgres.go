@@ -0,0 +1,272 @@
+package vectorstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pgvector/pgvector-go"
+)
+
+// The index used to speed up searches in Postgres
+type IndexType string
+
+const (
+	// Hierarchical navigable small worlds. Better recall and query speed than IVFFlat, but slower to build
+	IndexHNSW IndexType = "hnsw"
+
+	// Inverted file with flat compression. Faster to build, but should be created after the table has data
+	IndexIVFFlat IndexType = "ivfflat"
+
+	// Searches scan the whole table, which is exact
+	IndexNone IndexType = "none"
+)
+
+const postgres_default_table = "gollm_documents"
+
+// Table names can be qualified by a schema, such as `rag.documents`
+var postgresTableName = regexp.MustCompile(`^([a-zA-Z_][a-zA-Z0-9_]*\.)?[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Stores the documents in a Postgres table with the pgvector extension
+type PostgresStore struct {
+	db   *sql.DB
+	opts *PostgresOpts
+}
+
+// Optional configurations of the store. Only `Dimensions` is required, and only to create the table.
+type PostgresOpts struct {
+	// Defaults to `gollm_documents`
+	Table string
+
+	// The size of the embeddings, which is fixed by the column type
+	Dimensions int
+
+	// Defaults to `MetricCosine`. The index is built for the metric, so it cannot be changed after migrating
+	Metric Metric
+
+	// Defaults to `IndexHNSW`
+	Index IndexType
+
+	// Parameters of an HNSW index. Default to 16 and 64, the defaults of pgvector
+	HNSWM              int
+	HNSWEfConstruction int
+
+	// The lists of an IVFFlat index. Defaults to 100, and pgvector recommends rows / 1000 for up to 1M rows
+	IVFFlatLists int
+}
+
+func NewPostgresStore(db *sql.DB, opts *PostgresOpts) (*PostgresStore, error) {
+	if db == nil {
+		return nil, fmt.Errorf("the database cannot be nil")
+	}
+	resp := PostgresOpts{}
+	if opts != nil {
+		resp = *opts
+	}
+	if resp.Table == "" {
+		resp.Table = postgres_default_table
+	}
+	if !postgresTableName.MatchString(resp.Table) {
+		return nil, fmt.Errorf("invalid table name: %s", resp.Table)
+	}
+	if resp.Metric == "" {
+		resp.Metric = MetricCosine
+	}
+	if err := resp.Metric.isValid(); err != nil {
+		return nil, err
+	}
+	if resp.Index == "" {
+		resp.Index = IndexHNSW
+	}
+	if resp.HNSWM == 0 {
+		resp.HNSWM = 16
+	}
+	if resp.HNSWEfConstruction == 0 {
+		resp.HNSWEfConstruction = 64
+	}
+	if resp.IVFFlatLists == 0 {
+		resp.IVFFlatLists = 100
+	}
+
+	return &PostgresStore{db: db, opts: &resp}, nil
+}
+
+// The distance operator and index operator class of the metric
+func (s *PostgresStore) operator() (string, string) {
+	switch s.opts.Metric {
+	case MetricL2:
+		return "<->", "vector_l2_ops"
+	case MetricInnerProduct:
+		return "<#>", "vector_ip_ops"
+	default:
+		return "<=>", "vector_cosine_ops"
+	}
+}
+
+// Returns the statements that create the extension, table and indexes
+func (s *PostgresStore) migrations() ([]string, error) {
+	if s.opts.Dimensions <= 0 {
+		return nil, fmt.Errorf("the dimensions of the embeddings are required to create the table")
+	}
+
+	table := s.opts.Table
+	indexPrefix := strings.ReplaceAll(table, ".", "_")
+	_, ops := s.operator()
+
+	statements := []string{
+		"CREATE EXTENSION IF NOT EXISTS vector",
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	id TEXT PRIMARY KEY,
+	content TEXT NOT NULL,
+	metadata JSONB NOT NULL DEFAULT '{}',
+	embedding vector(%d) NOT NULL
+)`, table, s.opts.Dimensions),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_metadata_idx ON %s USING gin (metadata)", indexPrefix, table),
+	}
+
+	switch s.opts.Index {
+	case IndexHNSW:
+		statements = append(statements, fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_embedding_idx ON %s USING hnsw (embedding %s) WITH (m = %d, ef_construction = %d)", indexPrefix, table, ops, s.opts.HNSWM, s.opts.HNSWEfConstruction))
+	case IndexIVFFlat:
+		statements = append(statements, fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_embedding_idx ON %s USING ivfflat (embedding %s) WITH (lists = %d)", indexPrefix, table, ops, s.opts.IVFFlatLists))
+	case IndexNone:
+	default:
+		return nil, fmt.Errorf("unsupported index type: %s", s.opts.Index)
+	}
+
+	return statements, nil
+}
+
+// Creates the pgvector extension, the table and its indexes if they do not exist
+func (s *PostgresStore) Migrate(ctx context.Context) error {
+	statements, err := s.migrations()
+	if err != nil {
+		return err
+	}
+	for _, statement := range statements {
+		if _, err := s.db.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("there was an issue migrating the vector store: %v", err)
+		}
+	}
+	return nil
+}
+
+func (s *PostgresStore) Upsert(ctx context.Context, documents ...*Document) error {
+	if err := validateDocuments(documents); err != nil {
+		return err
+	}
+	if len(documents) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("there was an issue starting the transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	statement := fmt.Sprintf(`INSERT INTO %s (id, content, metadata, embedding) VALUES ($1, $2, $3::jsonb, $4::vector)
+ON CONFLICT (id) DO UPDATE SET content = EXCLUDED.content, metadata = EXCLUDED.metadata, embedding = EXCLUDED.embedding`, s.opts.Table)
+	for _, item := range documents {
+		metadata, err := encodeMetadata(item.Metadata)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, statement, item.ID, item.Content, string(metadata), pgvector.NewVector(item.Embedding)); err != nil {
+			return fmt.Errorf("there was an issue upserting the document %s: %v", item.ID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("there was an issue committing the transaction: %v", err)
+	}
+	return nil
+}
+
+func (s *PostgresStore) Delete(ctx context.Context, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	placeholders := make([]string, len(ids))
+	args := make([]any, len(ids))
+	for idx, id := range ids {
+		placeholders[idx] = fmt.Sprintf("$%d", idx+1)
+		args[idx] = id
+	}
+
+	statement := fmt.Sprintf("DELETE FROM %s WHERE id IN (%s)", s.opts.Table, strings.Join(placeholders, ", "))
+	if _, err := s.db.ExecContext(ctx, statement, args...); err != nil {
+		return fmt.Errorf("there was an issue deleting the documents: %v", err)
+	}
+	return nil
+}
+
+func (s *PostgresStore) DeleteMatching(ctx context.Context, filter map[string]any) error {
+	if err := validateDeleteFilter(filter); err != nil {
+		return err
+	}
+	enc, err := encodeMetadata(filter)
+	if err != nil {
+		return err
+	}
+
+	statement := fmt.Sprintf("DELETE FROM %s WHERE metadata @> $1::jsonb", s.opts.Table)
+	if _, err := s.db.ExecContext(ctx, statement, string(enc)); err != nil {
+		return fmt.Errorf("there was an issue deleting the documents: %v", err)
+	}
+	return nil
+}
+
+func (s *PostgresStore) Search(ctx context.Context, query *Query) ([]*Result, error) {
+	if err := query.isValid(); err != nil {
+		return nil, err
+	}
+
+	// order by the operator directly so the index is used
+	operator, _ := s.operator()
+	args := []any{pgvector.NewVector(query.Embedding)}
+	where := ""
+	if len(query.Filter) != 0 {
+		filter, err := encodeMetadata(query.Filter)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, string(filter))
+		where = fmt.Sprintf("WHERE metadata @> $%d::jsonb ", len(args))
+	}
+	args = append(args, query.K)
+	statement := fmt.Sprintf("SELECT id, content, metadata, embedding, embedding %s $1::vector AS distance FROM %s %sORDER BY embedding %s $1::vector LIMIT $%d",
+		operator, s.opts.Table, where, operator, len(args))
+
+	rows, err := s.db.QueryContext(ctx, statement, args...)
+	if err != nil {
+		return nil, fmt.Errorf("there was an issue searching the documents: %v", err)
+	}
+	defer rows.Close()
+
+	results := make([]*Result, 0)
+	for rows.Next() {
+		var document Document
+		var metadata []byte
+		var embedding pgvector.Vector
+		var result Result
+		if err := rows.Scan(&document.ID, &document.Content, &metadata, &embedding, &result.Distance); err != nil {
+			return nil, fmt.Errorf("there was an issue reading the documents: %v", err)
+		}
+		if err := json.Unmarshal(metadata, &document.Metadata); err != nil {
+			return nil, fmt.Errorf("there was an issue decoding the metadata of the document %s: %v", document.ID, err)
+		}
+		document.Embedding = embedding.Slice()
+		result.Document = &document
+		results = append(results, &result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("there was an issue reading the documents: %v", err)
+	}
+
+	return results, nil
+}
@@ -0,0 +1,179 @@
+package vectorstore
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// A database/sql driver that records the statements it is sent and returns scripted rows,
+// so the sql of the store can be tested without a running Postgres
+type fakeConnector struct {
+	statements []string
+	args       [][]driver.NamedValue
+	commits    int
+	rows       [][]driver.Value
+}
+
+func (c *fakeConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return &fakeConn{connector: c}, nil
+}
+
+func (c *fakeConnector) Driver() driver.Driver {
+	return nil
+}
+
+type fakeConn struct {
+	connector *fakeConnector
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, fmt.Errorf("prepare is not supported")
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return &fakeTx{connector: c.connector}, nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.connector.statements = append(c.connector.statements, query)
+	c.connector.args = append(c.connector.args, args)
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.connector.statements = append(c.connector.statements, query)
+	c.connector.args = append(c.connector.args, args)
+	return &fakeRows{rows: c.connector.rows}, nil
+}
+
+type fakeTx struct {
+	connector *fakeConnector
+}
+
+func (t *fakeTx) Commit() error {
+	t.connector.commits++
+	return nil
+}
+
+func (t *fakeTx) Rollback() error {
+	return nil
+}
+
+type fakeRows struct {
+	rows [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	return []string{"id", "content", "metadata", "embedding", "distance"}
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func newFakePostgresStore(t *testing.T, opts *PostgresOpts) (*PostgresStore, *fakeConnector) {
+	connector := &fakeConnector{}
+	db := sql.OpenDB(connector)
+	t.Cleanup(func() { db.Close() })
+	store, err := NewPostgresStore(db, opts)
+	require.NoError(t, err)
+	return store, connector
+}
+
+func TestPostgresMigrate(t *testing.T) {
+	store, connector := newFakePostgresStore(t, &PostgresOpts{Table: "rag.documents", Dimensions: 512})
+	require.NoError(t, store.Migrate(context.TODO()))
+	require.Len(t, connector.statements, 4)
+	require.Equal(t, "CREATE EXTENSION IF NOT EXISTS vector", connector.statements[0])
+	require.Contains(t, connector.statements[1], "CREATE TABLE IF NOT EXISTS rag.documents")
+	require.Contains(t, connector.statements[1], "embedding vector(512) NOT NULL")
+	require.Equal(t, "CREATE INDEX IF NOT EXISTS rag_documents_metadata_idx ON rag.documents USING gin (metadata)", connector.statements[2])
+	require.Equal(t, "CREATE INDEX IF NOT EXISTS rag_documents_embedding_idx ON rag.documents USING hnsw (embedding vector_cosine_ops) WITH (m = 16, ef_construction = 64)", connector.statements[3])
+
+	store, connector = newFakePostgresStore(t, &PostgresOpts{Dimensions: 3, Metric: MetricL2, Index: IndexIVFFlat, IVFFlatLists: 20})
+	require.NoError(t, store.Migrate(context.TODO()))
+	require.Equal(t, "CREATE INDEX IF NOT EXISTS gollm_documents_embedding_idx ON gollm_documents USING ivfflat (embedding vector_l2_ops) WITH (lists = 20)", connector.statements[3])
+
+	store, _ = newFakePostgresStore(t, nil)
+	require.ErrorContains(t, store.Migrate(context.TODO()), "dimensions")
+
+	_, err := NewPostgresStore(sql.OpenDB(&fakeConnector{}), &PostgresOpts{Table: "documents; DROP TABLE users"})
+	require.ErrorContains(t, err, "invalid table name")
+}
+
+func TestPostgresUpsertAndDelete(t *testing.T) {
+	store, connector := newFakePostgresStore(t, nil)
+	err := store.Upsert(context.TODO(), testDocuments()[:2]...)
+	require.NoError(t, err)
+	require.Equal(t, 1, connector.commits)
+	require.Len(t, connector.statements, 2)
+	require.Contains(t, connector.statements[0], "ON CONFLICT (id) DO UPDATE")
+	require.Equal(t, "north", connector.args[0][0].Value)
+	require.Equal(t, `{"page":1,"source":"compass"}`, connector.args[0][2].Value)
+	require.Equal(t, "[0,1]", connector.args[0][3].Value)
+
+	require.NoError(t, store.Delete(context.TODO(), "north", "east"))
+	require.Equal(t, "DELETE FROM gollm_documents WHERE id IN ($1, $2)", connector.statements[2])
+	require.Len(t, connector.args[2], 2)
+
+	require.NoError(t, store.DeleteMatching(context.TODO(), map[string]any{"source": "compass"}))
+	require.Equal(t, "DELETE FROM gollm_documents WHERE metadata @> $1::jsonb", connector.statements[3])
+	require.Equal(t, `{"source":"compass"}`, connector.args[3][0].Value)
+	require.ErrorContains(t, store.DeleteMatching(context.TODO(), nil), "cannot be empty")
+	require.Len(t, connector.statements, 4)
+}
+
+func TestPostgresSearch(t *testing.T) {
+	store, connector := newFakePostgresStore(t, &PostgresOpts{Metric: MetricInnerProduct})
+	connector.rows = [][]driver.Value{
+		{"north", "north", []byte(`{"source": "compass"}`), []byte("[0,1]"), float64(-1)},
+		{"east", "east", []byte(`{}`), "[1,0]", float64(-0.5)},
+	}
+
+	results, err := store.Search(context.TODO(), &Query{
+		Embedding: []float32{0.5, 1},
+		K:         2,
+		Filter:    map[string]any{"source": "compass"},
+	})
+	require.NoError(t, err)
+
+	statement := connector.statements[0]
+	require.True(t, strings.HasPrefix(statement, "SELECT id, content, metadata, embedding, embedding <#> $1::vector AS distance FROM gollm_documents"))
+	require.Contains(t, statement, "WHERE metadata @> $2::jsonb ORDER BY embedding <#> $1::vector LIMIT $3")
+	require.Equal(t, "[0.5,1]", connector.args[0][0].Value)
+	require.Equal(t, `{"source":"compass"}`, connector.args[0][1].Value)
+	require.Equal(t, int64(2), connector.args[0][2].Value)
+
+	require.Len(t, results, 2)
+	require.Equal(t, "north", results[0].Document.ID)
+	require.Equal(t, "compass", results[0].Document.Metadata["source"])
+	require.Equal(t, []float32{0, 1}, results[0].Document.Embedding)
+	require.Equal(t, -1.0, results[0].Distance)
+	require.Equal(t, []float32{1, 0}, results[1].Document.Embedding)
+
+	// without a filter, the limit is the second argument
+	_, err = store.Search(context.TODO(), &Query{Embedding: []float32{0.5, 1}})
+	require.NoError(t, err)
+	require.Contains(t, connector.statements[1], "FROM gollm_documents ORDER BY embedding <#> $1::vector LIMIT $2")
+	require.Equal(t, int64(default_k), connector.args[1][1].Value)
+}
@@ -0,0 +1,147 @@
+/*
+Package vectorstore stores embedded documents and searches them by similarity.
+
+`MemoryStore` keeps the documents in memory and searches them exhaustively, for tests and small
+corpora. `PostgresStore` stores them in Postgres with the pgvector extension, through any
+`database/sql` driver. `BM25Index` scores the same documents by their terms instead, for
+lexical searches.
+*/
+package vectorstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jake-landersweb/gollm/v2/src/ltypes"
+)
+
+// How the distance between two vectors is measured
+type Metric string
+
+const (
+	// 1 - the cosine similarity of the vectors. Ranges from 0 for vectors in the same direction to 2
+	MetricCosine Metric = "cosine"
+
+	// The euclidean distance between the vectors
+	MetricL2 Metric = "l2"
+
+	// The negative inner product of the vectors, so smaller is closer like the other metrics.
+	// Equivalent to cosine for normalized vectors, such as the ones from OpenAI, and faster
+	MetricInnerProduct Metric = "inner_product"
+)
+
+func (m Metric) isValid() error {
+	switch m {
+	case MetricCosine, MetricL2, MetricInnerProduct:
+		return nil
+	default:
+		return fmt.Errorf("unsupported metric: %s", m)
+	}
+}
+
+// A piece of content along with its embedding
+type Document struct {
+	ID        string
+	Content   string
+	Embedding []float32
+
+	// Optional attributes of the document that searches can filter on, such as its source.
+	// Must be encodable as json
+	Metadata map[string]any
+}
+
+// Creates a document from the embedding of a chunk returned by `Embeddings.Embed`
+func NewDocument(id string, data *ltypes.EmbeddingsData, metadata map[string]any) *Document {
+	return &Document{
+		ID:        id,
+		Content:   data.Raw,
+		Embedding: data.Embedding,
+		Metadata:  metadata,
+	}
+}
+
+type Query struct {
+	Embedding []float32
+
+	// The most documents to return. Defaults to 10
+	K int
+
+	// Only return documents whose metadata contains these values, as matched by the jsonb `@>` operator:
+	// nested objects match on their keys, and arrays match when they contain every element of the filter
+	Filter map[string]any
+}
+
+const default_k = 10
+
+func (q *Query) isValid() error {
+	if q == nil {
+		return fmt.Errorf("the query cannot be nil")
+	}
+	if len(q.Embedding) == 0 {
+		return fmt.Errorf("the query embedding cannot be empty")
+	}
+	if q.K < 0 {
+		return fmt.Errorf("k cannot be negative")
+	}
+	if q.K == 0 {
+		q.K = default_k
+	}
+	return nil
+}
+
+type Result struct {
+	Document *Document
+
+	// The distance from the query to the document under the metric of the store. Smaller is closer
+	Distance float64
+}
+
+type VectorStore interface {
+	// Inserts the documents, replacing the stored documents with the same ids
+	Upsert(ctx context.Context, documents ...*Document) error
+
+	// Deletes the documents with the ids. Ids that are not stored are ignored
+	Delete(ctx context.Context, ids ...string) error
+
+	// Deletes the documents whose metadata contains the filter, matched like `Query.Filter`.
+	// The filter cannot be empty, so a mistake cannot delete every document
+	DeleteMatching(ctx context.Context, filter map[string]any) error
+
+	// Returns the closest documents to the query embedding that match the filter, closest first
+	Search(ctx context.Context, query *Query) ([]*Result, error)
+}
+
+func validateDocuments(documents []*Document) error {
+	for idx, item := range documents {
+		if item == nil {
+			return fmt.Errorf("document %d cannot be nil", idx)
+		}
+		if item.ID == "" {
+			return fmt.Errorf("document %d must have an id", idx)
+		}
+		if len(item.Embedding) == 0 {
+			return fmt.Errorf("the document %s must have an embedding", item.ID)
+		}
+	}
+	return nil
+}
+
+func validateDeleteFilter(filter map[string]any) error {
+	if len(filter) == 0 {
+		return fmt.Errorf("the filter to delete by cannot be empty")
+	}
+	return nil
+}
+
+// Encodes the metadata into json, so it can be stored or compared by value
+func encodeMetadata(metadata map[string]any) ([]byte, error) {
+	if metadata == nil {
+		return []byte("{}"), nil
+	}
+	enc, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("there was an issue encoding the metadata into json: %v", err)
+	}
+	return enc, nil
+}